@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/engine"
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// Actions of a change since a recorded run
+const (
+	sinceAdded   = "added"
+	sinceChanged = "changed"
+	sinceRemoved = "removed"
+)
+
+// repoSnapshot is what the labels and milestones of a repository looked like when a run
+// finished, kept in its run record for plan --against
+type repoSnapshot struct {
+	Labels     []LabelData     `json:"labels"`
+	Milestones []MilestoneData `json:"milestones"`
+}
+
+// runComparison is what changed in a repository since a recorded run: by the templates,
+// i.e. changes that now match the definitions, and outside of them
+type runComparison struct {
+	Run          string          `json:"run"`
+	Finished     time.Time       `json:"finished"`
+	TemplateHash string          `json:"template_hash"` // Of the recorded run
+	External     []plannedChange `json:"external,omitempty"`
+	Template     []plannedChange `json:"template,omitempty"`
+}
+
+// takeRepoSnapshot records the current labels and milestones of a repository
+func takeRepoSnapshot(ctx context.Context, t repoTarget) (*repoSnapshot, error) {
+	labels, err := repoProvider(t).ListLabels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	milestones, err := repoProvider(t).ListMilestones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &repoSnapshot{Labels: make([]LabelData, 0, len(labels)), Milestones: make([]MilestoneData, 0, len(milestones))}
+	for _, l := range labels {
+		snapshot.Labels = append(snapshot.Labels, LabelData{Name: l.Name, Description: l.Description, Color: l.Color})
+	}
+	for _, m := range milestones {
+		snapshot.Milestones = append(snapshot.Milestones, MilestoneData{Title: m.Title, Description: m.Description, DueOn: m.DueOn})
+	}
+	sort.Slice(snapshot.Labels, func(i, j int) bool { return snapshot.Labels[i].Name < snapshot.Labels[j].Name })
+	sort.Slice(snapshot.Milestones, func(i, j int) bool { return snapshot.Milestones[i].Title < snapshot.Milestones[j].Title })
+	return snapshot, nil
+}
+
+// findRun returns the run record with the given ID
+func (s *repoState) findRun(id string) (runRecord, bool) {
+	for i := len(s.Runs) - 1; i >= 0; i-- {
+		if s.Runs[i].ID == id {
+			return s.Runs[i], true
+		}
+	}
+	return runRecord{}, false
+}
+
+// compareWithRun works out what changed in a repository since the recorded run with the
+// given ID. Removals always count as external changes: apply only deletes with --prune,
+// which cannot be told apart from a deletion by hand.
+func compareWithRun(ctx context.Context, backend stateBackend, t repoTarget, defs *definitions, runID string) (*runComparison, error) {
+	state, err := loadState(ctx, backend, t)
+	if err != nil {
+		return nil, err
+	}
+	run, ok := state.findRun(runID)
+	if !ok {
+		return nil, fmt.Errorf("run %s is not recorded in the run state of %s", runID, t)
+	}
+	if run.Snapshot == nil {
+		return nil, fmt.Errorf("run %s of %s recorded no snapshot of the repository", runID, t)
+	}
+	current, err := takeRepoSnapshot(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	comparison := &runComparison{Run: runID, Finished: run.Finished, TemplateHash: run.TemplateHash}
+	add := func(change plannedChange, byTemplate bool) {
+		if byTemplate {
+			comparison.Template = append(comparison.Template, change)
+		} else {
+			comparison.External = append(comparison.External, change)
+		}
+	}
+
+	defined := make(map[string]LabelData, len(defs.Labels))
+	for _, label := range defs.Labels {
+		defined[strings.ToLower(label.Name)] = label
+	}
+	before, after := labelsByName(run.Snapshot.Labels), labelsByName(current.Labels)
+	for _, key := range unionKeys(before, after) {
+		was, hadIt := before[key]
+		now, hasIt := after[key]
+		change := plannedChange{Kind: "label", Name: now.Name}
+		switch {
+		case !hasIt:
+			change.Name, change.Action = was.Name, sinceRemoved
+		case !hadIt:
+			change.Action = sinceAdded
+		default:
+			change.Action, change.Diffs = sinceChanged, labelChanges(was, now)
+			if len(change.Diffs) == 0 {
+				continue
+			}
+		}
+		definition, isDefined := defined[key]
+		add(change, hasIt && isDefined && len(labelDifferences(definition, github.Label{Color: now.Color, Description: now.Description})) == 0)
+	}
+
+	definedMilestones := make(map[string]MilestoneData, len(defs.Milestones))
+	for _, milestone := range defs.Milestones {
+		definedMilestones[milestoneKey(milestone.Title)] = milestone
+	}
+	beforeMilestones, afterMilestones := milestonesByKey(run.Snapshot.Milestones), milestonesByKey(current.Milestones)
+	for _, key := range unionKeys(beforeMilestones, afterMilestones) {
+		was, hadIt := beforeMilestones[key]
+		now, hasIt := afterMilestones[key]
+		change := plannedChange{Kind: "milestone", Name: now.Title}
+		switch {
+		case !hasIt:
+			change.Name, change.Action = was.Title, sinceRemoved
+		case !hadIt:
+			change.Action = sinceAdded
+		default:
+			change.Action, change.Diffs = sinceChanged, milestoneChanges(was, now)
+			if len(change.Diffs) == 0 {
+				continue
+			}
+		}
+		definition, isDefined := definedMilestones[key]
+		add(change, hasIt && isDefined && len(milestoneDifferences(definition, github.Milestone{Title: now.Title, Description: now.Description, DueOn: now.DueOn})) == 0)
+	}
+	return comparison, nil
+}
+
+// labelChanges lists how a label changed between two snapshots
+func labelChanges(was, now LabelData) []string {
+	var diffs []string
+	if !strings.EqualFold(was.Color, now.Color) {
+		diffs = append(diffs, fmt.Sprintf("color %s -> %s", was.Color, now.Color))
+	}
+	if was.Description != now.Description {
+		diffs = append(diffs, fmt.Sprintf("description %q -> %q", was.Description, now.Description))
+	}
+	return diffs
+}
+
+// milestoneChanges lists how a milestone changed between two snapshots
+func milestoneChanges(was, now MilestoneData) []string {
+	var diffs []string
+	if was.Title != now.Title {
+		diffs = append(diffs, fmt.Sprintf("title %q -> %q", was.Title, now.Title))
+	}
+	if engine.DueDate(was.DueOn) != engine.DueDate(now.DueOn) {
+		diffs = append(diffs, fmt.Sprintf("due date %s -> %s", orNone(engine.DueDate(was.DueOn)), orNone(engine.DueDate(now.DueOn))))
+	}
+	if was.Description != now.Description {
+		diffs = append(diffs, fmt.Sprintf("description %q -> %q", was.Description, now.Description))
+	}
+	return diffs
+}
+
+// labelsByName keys labels by their name; GitHub label names are case-insensitive
+func labelsByName(labels []LabelData) map[string]LabelData {
+	m := make(map[string]LabelData, len(labels))
+	for _, l := range labels {
+		m[strings.ToLower(l.Name)] = l
+	}
+	return m
+}
+
+// milestonesByKey keys milestones by milestoneKey
+func milestonesByKey(milestones []MilestoneData) map[string]MilestoneData {
+	m := make(map[string]MilestoneData, len(milestones))
+	for _, milestone := range milestones {
+		m[milestoneKey(milestone.Title)] = milestone
+	}
+	return m
+}
+
+// unionKeys returns the keys of both maps in order
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := sortedKeys(a)
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// compareTargets compares every prepared target with the recorded run; a target that
+// cannot be compared gets a warning instead
+func compareTargets(ctx context.Context, prepared *preparedRun, backend stateBackend, runID string) (map[string]*runComparison, map[string]string) {
+	comparisons, problems := make(map[string]*runComparison), make(map[string]string)
+	for _, rp := range prepared.plans {
+		comparison, err := compareWithRun(ctx, backend, rp.Target, prepared.defs, runID)
+		if err != nil {
+			log.Printf("Warning: Could not compare %s with run %s: %v", rp.Target, runID, err)
+			problems[rp.Target.String()] = err.Error()
+			continue
+		}
+		comparisons[rp.Target.String()] = comparison
+	}
+	return comparisons, problems
+}
+
+// withComparison adds the comparison of a planned repository, or why there is none
+func withComparison(repo repoChangePlan, comparisons map[string]*runComparison, problems map[string]string) repoChangePlan {
+	repo.Since = comparisons[repo.Repo]
+	if problem, ok := problems[repo.Repo]; ok {
+		repo.Warnings = append(repo.Warnings, "not compared: "+problem)
+	}
+	return repo
+}
+
+// writeComparisonText prints what changed in a repository since the recorded run
+func writeComparisonText(w io.Writer, since *runComparison) {
+	symbols := map[string]string{sinceAdded: "+", sinceChanged: "~", sinceRemoved: "-"}
+	fmt.Fprintf(w, "  since run %s (%s, template hash %s):\n", since.Run, since.Finished.Format(time.RFC3339), since.TemplateHash)
+	for _, section := range []struct {
+		title   string
+		changes []plannedChange
+	}{{"changed outside the templates", since.External}, {"changed by the templates", since.Template}} {
+		if len(section.changes) == 0 {
+			fmt.Fprintf(w, "    %s: nothing\n", section.title)
+			continue
+		}
+		fmt.Fprintf(w, "    %s:\n", section.title)
+		for _, c := range section.changes {
+			line := fmt.Sprintf("      %s %s \"%s\"", symbols[c.Action], c.Kind, c.Name)
+			if len(c.Diffs) > 0 {
+				line += ": " + strings.Join(c.Diffs, "; ")
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
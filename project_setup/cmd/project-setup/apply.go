@@ -0,0 +1,891 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/engine"
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// repoRun is the state of applying the definitions to a single repository
+type repoRun struct {
+	target    repoTarget
+	conflicts *conflictResolver // Shared by all repositories of the run
+	options   applyOptions
+	degraded  map[capability]bool // Optional features this repository does not support
+	// Issues created in this repository, by index in the issue definitions
+	createdIssues map[int]github.Issue
+	kept          []string         // "kind name" of every conflict where the remote version was kept
+	skipped       []string         // "kind name" of every conflict left unresolved
+	updated       []string         // "kind name: differences" of every entity updated to match its definition
+	problems      []string         // Everything that failed, for the final report
+	failures      []failureRecord  // The same with the details of the failed requests
+	failedIssues  []int            // Indexes of the issues that could not be created, for --failed-out
+	state         *repoState       // Run state, nil without --state
+	teamTurns     map[string]int   // Next member of each round-robin team assignee
+	templateData  *templateData    // Data of templated issue bodies, nil unless issue_body.templates is set
+	assignees     map[int][]string // Assignees of issues with fallbacks, resolved by the preflight check
+	newMilestones map[int]bool     // Milestones created by this run and not yet verified, with --wait-for-milestones
+	mu            sync.Mutex       // Guards kept, skipped, updated and problems, as phases run in parallel
+}
+
+// failed logs a failure and records it for the final report of this repository
+func (r *repoRun) failed(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	log.Printf("%s", message)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.problems = append(r.problems, message)
+	r.failures = append(r.failures, newFailureRecord(r.target.String(), message, args))
+}
+
+// issueFailed reports an issue that could not be created and keeps it for the retry file
+func (r *repoRun) issueFailed(index int, issue IssueData, err error) {
+	r.failed("Failed to create issue '%s': %v", issue.Title, err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failedIssues = append(r.failedIssues, index)
+}
+
+// resolveConflict applies the configured policy of the entity type, or else asks the run's
+// conflict resolver, and records unresolved conflicts for this repository
+func (r *repoRun) resolveConflict(kind, name string, diffs []string) conflictAction {
+	var action conflictAction
+	switch policy := config.Policies.forKind(kind); policy {
+	case policyUpdate:
+		log.Printf("Updating %s \"%s\" (policy %q): %s", kind, name, policy, strings.Join(diffs, "; "))
+		action = conflictTakeLocal
+	case policyCreateIfMissing:
+		log.Printf("Leaving existing %s \"%s\" as it is (policy %q): %s", kind, name, policy, strings.Join(diffs, "; "))
+		action = conflictKeepRemote
+	default:
+		action = r.conflicts.resolve(r.target, kind, name, diffs)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch action {
+	case conflictKeepRemote:
+		r.kept = append(r.kept, fmt.Sprintf("%s \"%s\"", kind, name))
+	case conflictSkip:
+		r.skipped = append(r.skipped, fmt.Sprintf("%s \"%s\"", kind, name))
+	}
+	return action
+}
+
+// recordUpdate records an entity updated to match its definition, for the final report
+func (r *repoRun) recordUpdate(kind, name string, diffs []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updated = append(r.updated, fmt.Sprintf("%s \"%s\": %s", kind, name, strings.Join(diffs, "; ")))
+}
+
+// applyToRepo creates the labels, milestones, issues and files of defs in one repository
+func applyToRepo(ctx context.Context, plan repoPlan, defs *definitions, conflicts *conflictResolver, options applyOptions) (summary runSummary) {
+	var err error
+	t := plan.Target
+	ctx = engine.WithProgressRepo(ctx, t.String())
+	run := &repoRun{target: t, conflicts: conflicts, options: options, degraded: plan.Degraded,
+		createdIssues: make(map[int]github.Issue), teamTurns: make(map[string]int), assignees: plan.Assignees}
+	if config.IssueBody.Templates {
+		data := newTemplateData(t, plan.Metadata, defs.RepoVariables)
+		run.templateData = &data
+	}
+
+	log.Printf("Target Repository: %s", t)
+
+	if options.State != nil {
+		if run.state, err = lockState(ctx, options.State, t); err != nil {
+			run.failed("Error loading run state: %v", err)
+			summary.Errors++
+			summary.Problems, summary.FailureRecords = run.problems, run.failures
+			return summary
+		}
+		// Recorded even when the run stops early, which also releases the lock;
+		// this has to work after the run deadline too, as it is the checkpoint to resume from
+		defer func() {
+			ctx := context.WithoutCancel(ctx)
+			snapshot, err := takeRepoSnapshot(ctx, t)
+			if err != nil {
+				log.Printf("Warning: Could not snapshot %s for plan --against: %v", t, err)
+			}
+			if err := finishState(ctx, options.State, t, run.state, summary, defs.Hash, options.RunID, snapshot); err != nil {
+				log.Printf("Warning: Could not save run state of %s: %v", t, err)
+			}
+		}()
+	}
+
+	// Each phase writes its own counts, so phases running in parallel never share one
+	var labelCounts, mirrorCounts, milestoneCounts, issueCounts, epicCounts, trackingCounts, planCounts, removedCounts entityCounts
+	var labelPruneCounts, milestonePruneCounts entityCounts
+	var milestoneTitleToIDMap map[string]int
+	mirror := options.MirrorMilestoneLabels && !run.degraded[capMirrorLabels]
+	phases := []phase{
+		{name: "label processing", run: func(ctx context.Context) (err error) {
+			labelCounts, err = processLabels(ctx, run, defs.Labels)
+			return err
+		}},
+		// Issues depend on the milestone map, so they are skipped when this fails
+		{name: "milestone processing", run: func(ctx context.Context) (err error) {
+			milestoneTitleToIDMap, milestoneCounts, err = processMilestones(ctx, run, defs.Milestones)
+			return err
+		}},
+	}
+	issuesAfter := []string{"label processing"}
+	if mirror {
+		phases = append(phases, phase{name: "milestone label mirroring", needs: []string{"milestone processing"},
+			after: []string{"label processing"}, run: func(ctx context.Context) (err error) {
+				mirrorCounts, err = syncMilestoneLabels(ctx, run, milestoneTitleToIDMap)
+				return err
+			}})
+		issuesAfter = append(issuesAfter, "milestone label mirroring")
+	}
+	phases = append(phases, phase{name: "issue processing", needs: []string{"milestone processing"},
+		after: issuesAfter, run: func(ctx context.Context) (err error) {
+			issueCounts, err = processIssues(ctx, run, defs.Issues, milestoneTitleToIDMap)
+			if run.state != nil && len(run.createdIssues) > 0 {
+				// Checkpoint, so a run that fails later does not create the issues again
+				if err := saveState(ctx, options.State, t, run.state); err != nil {
+					log.Printf("Warning: Could not save run state of %s: %v", t, err)
+				}
+			}
+			return err
+		}})
+	if config.Policies.Issues != policySkip {
+		phases = append(phases, phase{name: "epic processing", needs: []string{"milestone processing"},
+			after: []string{"issue processing"}, run: func(ctx context.Context) (err error) {
+				epicCounts, err = syncEpics(ctx, run, defs.Issues)
+				return err
+			}})
+	}
+	if options.CloseRemoved && run.state != nil {
+		// After issue processing, which also writes the run state, and epic processing, which reads it
+		after := []string{"issue processing"}
+		for _, p := range phases {
+			if p.name == "epic processing" {
+				after = append(after, p.name)
+			}
+		}
+		phases = append(phases, phase{name: "removed issue processing", after: after,
+			run: func(ctx context.Context) (err error) {
+				removedCounts, err = closeRemovedIssues(ctx, run, defs.Issues)
+				return err
+			}})
+	}
+	if options.TrackingIssues {
+		phases = append(phases, phase{name: "tracking issue processing", needs: []string{"milestone processing"},
+			after: []string{"issue processing"}, run: func(ctx context.Context) (err error) {
+				trackingCounts, err = syncTrackingIssues(ctx, run, defs.Milestones, milestoneTitleToIDMap)
+				return err
+			}})
+	}
+	if options.Prune {
+		// Last of the label and milestone work, and only when the definitions were applied
+		after := []string{"issue processing"}
+		for _, p := range phases {
+			if p.name == "milestone label mirroring" || p.name == "tracking issue processing" {
+				after = append(after, p.name)
+			}
+		}
+		if config.Policies.Labels != policySkip {
+			phases = append(phases, phase{name: "label pruning", needs: []string{"label processing"}, after: after,
+				run: func(ctx context.Context) (err error) {
+					labelPruneCounts, err = pruneLabels(ctx, run, defs)
+					return err
+				}})
+		}
+		if config.Policies.Milestones != policySkip {
+			phases = append(phases, phase{name: "milestone pruning", needs: []string{"milestone processing"}, after: after,
+				run: func(ctx context.Context) (err error) {
+					milestonePruneCounts, err = pruneMilestones(ctx, run, defs)
+					return err
+				}})
+		}
+	}
+	// Files and custom properties do not depend on anything else
+	if files := mergeFiles(defs.Files, plan.CommunityFiles); len(files) > 0 {
+		phases = append(phases, phase{name: "file processing", run: func(ctx context.Context) (err error) {
+			summary.Files, err = processFiles(ctx, t, files)
+			return err
+		}})
+	}
+	if config.ProjectPlan.Path != "" {
+		// Lists what the other phases created, and commits after the files so both do not race for the branch
+		after := []string{"issue processing"}
+		for _, p := range phases {
+			if p.name == "epic processing" || p.name == "tracking issue processing" || p.name == "file processing" {
+				after = append(after, p.name)
+			}
+		}
+		phases = append(phases, phase{name: "project plan", needs: []string{"milestone processing"}, after: after,
+			run: func(ctx context.Context) (err error) {
+				planCounts, err = syncProjectPlan(ctx, t, defs)
+				return err
+			}})
+	}
+	if len(defs.Properties) > 0 {
+		phases = append(phases, phase{name: "custom property processing", run: func(ctx context.Context) (err error) {
+			summary.Properties, err = processProperties(ctx, t, defs.Properties)
+			return err
+		}})
+	}
+
+	failures, err := runPhases(ctx, phases, options.PhaseWorkers, func(p phase, err error) {
+		run.failed("Error during %s: %v", p.name, err)
+	})
+	if err != nil {
+		run.failed("Error scheduling the phases: %v", err)
+		failures++
+	}
+	summary.Errors += failures
+	if config.Manifest.Path != "" && summary.Errors == 0 && len(run.problems) == 0 {
+		// Only a complete run is recorded, so the manifest never claims a setup that failed half-way
+		manifestCounts, err := syncManifest(ctx, t, defs)
+		if err != nil {
+			run.failed("Error updating the manifest: %v", err)
+			summary.Errors++
+		}
+		planCounts.add(manifestCounts)
+	}
+	summary.Labels = labelCounts
+	summary.Labels.add(mirrorCounts)
+	summary.Labels.add(labelPruneCounts)
+	summary.Milestones = milestoneCounts
+	summary.Milestones.add(milestonePruneCounts)
+	summary.Issues = issueCounts
+	summary.Issues.add(epicCounts)
+	summary.Issues.add(trackingCounts)
+	summary.Issues.add(removedCounts)
+	summary.Files.add(planCounts)
+
+	summary.Drift = len(run.kept) + len(run.skipped)
+	summary.Skipped = run.skipped
+	summary.Updated = run.updated
+	summary.CreatedIssues = run.createdIssues
+	summary.Problems, summary.FailureRecords = run.problems, run.failures
+	summary.FailedIssues = run.failedIssues
+	return summary
+}
+
+// mergeFiles combines the configured files with the rendered community files;
+// a file listed in config.json wins over a generated one
+func mergeFiles(files, community map[string]string) map[string]string {
+	merged := make(map[string]string, len(files)+len(community))
+	for path, content := range community {
+		merged[path] = content
+	}
+	for path, content := range files {
+		merged[path] = content
+	}
+	return merged
+}
+
+// logSummary prints the final summary of one repository (or the total of several)
+func logSummary(heading string, summary runSummary, defs *definitions) {
+	log.Printf("--- %s ---", heading)
+	log.Printf("Labels processed: %d created, %d updated, %d failed.", summary.Labels.Created, summary.Labels.Updated, summary.Labels.Failed)
+	log.Printf("Milestones processed: %d created, %d updated, %d failed.", summary.Milestones.Created, summary.Milestones.Updated, summary.Milestones.Failed)
+	if summary.Labels.Deleted > 0 || summary.Milestones.Deleted > 0 {
+		log.Printf("Pruned: %d labels and %d milestones deleted.", summary.Labels.Deleted, summary.Milestones.Deleted)
+	}
+	if len(summary.Skipped) > 0 {
+		log.Printf("Unresolved conflicts (skipped): %s", strings.Join(summary.Skipped, ", "))
+	}
+	for _, updated := range summary.Updated {
+		log.Printf("Updated %s", updated)
+	}
+	log.Printf("Issues processed: %d created, %d updated, %d failed.", summary.Issues.Created, summary.Issues.Updated, summary.Issues.Failed)
+	if len(defs.Files) > 0 || defs.Community != nil {
+		log.Printf("Files processed: %d committed, %d failed.", summary.Files.Created, summary.Files.Failed)
+	}
+	if len(defs.Properties) > 0 {
+		log.Printf("Custom properties processed: %d updated, %d failed.", summary.Properties.Updated, summary.Properties.Failed)
+	}
+	if failures := summary.Failures(); failures > 0 {
+		log.Printf("Failures: %d, listed at the end of the run.", failures)
+	}
+}
+
+// resolveApplyTargets determines the repositories to apply to: every --repo,
+// every non-archived repository of --org, or when neither is given GITHUB_REPOSITORY
+// or else the origin remote of the current git working copy.
+// A --repo given as URL (or the remote) also selects the API of its host (github.com or GHES),
+// unless a tenant is given, whose API is fixed.
+func resolveApplyTargets(ctx context.Context, repos []string, org string, tenant *Tenant) ([]repoTarget, error) {
+	var targets []repoTarget
+	var apiHost string
+	for _, r := range repos {
+		t, host, err := parseRepoReference(r)
+		if err != nil {
+			return nil, err
+		}
+		if host != "" {
+			if apiHost != "" && !strings.EqualFold(host, apiHost) {
+				return nil, fmt.Errorf("repositories on different hosts (%s and %s) cannot be targeted in one run", apiHost, host)
+			}
+			apiHost = host
+		}
+		targets = append(targets, t)
+	}
+	if apiHost != "" {
+		if err := tenant.checkAPIHost(apiHost); err != nil {
+			return nil, err
+		}
+		useAPIHost(apiHost)
+	}
+
+	if org != "" {
+		orgRepos, err := listOrgRepos(ctx, org)
+		if err != nil {
+			return nil, fmt.Errorf("error listing repositories of %s: %w", org, err)
+		}
+		sort.Slice(orgRepos, func(i, j int) bool { return orgRepos[i].Name < orgRepos[j].Name })
+		for _, r := range orgRepos {
+			if r.Archived {
+				log.Printf("Skipping archived repository %s.", r.FullName)
+				continue
+			}
+			targets = append(targets, repoTarget{Owner: org, Repo: r.Name})
+		}
+	}
+
+	if len(targets) > 0 {
+		return targets, nil
+	}
+
+	githubRepo := os.Getenv("GITHUB_REPOSITORY") // Expects "owner/repo" format
+	if githubRepo != "" {
+		t, err := parseRepoTarget(githubRepo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GITHUB_REPOSITORY: %w", err)
+		}
+		return []repoTarget{t}, nil
+	}
+
+	t, host, err := detectGitRemote(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("no --repo or --org given, GITHUB_REPOSITORY not set and no repository detected (%v)", err)
+	}
+	log.Printf("Detected repository %s from the git remote '%s'.", t, gitRemoteName)
+	if err := tenant.checkAPIHost(host); err != nil {
+		return nil, err
+	}
+	useAPIHost(host)
+	return []repoTarget{t}, nil
+}
+
+// useAPIHost points the API base at github.com or a GitHub Enterprise Server host
+func useAPIHost(host string) {
+	githubAPIBaseURL = github.BaseURLForHost(host)
+	log.Printf("Using the API at %s.", githubAPIBaseURL)
+}
+
+// targetFlags select the definitions and target repositories; shared by apply and plan
+type targetFlags struct {
+	repos      stringList
+	reposFile  string // One target per line, with optional variable overrides
+	org        string
+	provider   string
+	paths      definitionPaths
+	properties propertyList
+	fixes      labelFixes
+	options    applyOptions
+	state      string
+	limits     runLimits
+	readOnly   bool
+	from       string // oci:// reference of a definition bundle, read as the first layer
+	onBehalfOf string // User named in the attribution footer
+	// Update existing labels that differ from their definitions, the "update" label policy
+	updateLabels bool
+	milestones   stringList // Only apply what these milestones need, registered by apply and plan
+	tenant       *Tenant    // Tenant of a --json-rpc request, bounding the targets, API and files; nil for none
+}
+
+// register adds the shared flags to fs
+func (f *targetFlags) register(fs *flag.FlagSet) {
+	fs.Var(&f.repos, "repo", "Target repository as owner/repo, URL or SSH remote (repeatable, defaults to GITHUB_REPOSITORY)")
+	fs.StringVar(&f.org, "org", "", "Apply to every non-archived repository of this organization")
+	fs.StringVar(&f.reposFile, "repos-file", "", "File listing target repositories, one owner/repo per line with optional name=value variable overrides")
+	fs.StringVar(&f.provider, "provider", "github", "Hosting provider of the target repositories")
+	fs.StringVar(&f.state, "state", "", "Keep run state in a directory, s3://bucket/prefix or github-branch[:name]")
+	fs.BoolVar(&f.options.MirrorMilestoneLabels, "mirror-milestone-labels", false, "Keep a 'milestone:<title>' label on the issues of every milestone")
+	fs.BoolVar(&f.options.CreateMissingLabels, "create-missing-labels", false, "Create labels used by issues but neither defined nor present in the repository, with a default color")
+	fs.BoolVar(&f.options.TrackingIssues, "tracking-issues", false, "Keep a tracking issue with a task list of its issues for every milestone")
+	fs.BoolVar(&f.options.MuteMentions, "mute-mentions", false, "Create issues with muted @-mentions, so bulk creation notifies nobody; 'restore-mentions' turns them back on")
+	fs.BoolVar(&f.options.CloseRemoved, "close-removed", false, "Close issues earlier runs created (per --state) whose definitions were removed, as not planned with a comment")
+	fs.BoolVar(&f.options.Prune, "prune", false, "Delete labels and milestones of the repository that are not defined (nor used by a defined issue), after creating the missing ones")
+	fs.BoolVar(&f.options.GraphQLWrites, "graphql-writes", false, fmt.Sprintf("Create issues with batches of up to %d GraphQL mutations per request", graphQLWriteBatchSize))
+	f.paths.register(fs)
+	fs.StringVar(&f.from, "from", "", "Pull the definitions from an OCI artifact, oci://registry/repository:tag or @sha256:<digest>, as the first layer")
+	f.properties = make(propertyList)
+	fs.Var(f.properties, "property", "Organization custom property to set as name=value (repeatable, overrides config.json)")
+	fs.BoolVar(&f.fixes.StripHash, "strip-hash", false, "Remove a leading '#' from label colors instead of rejecting them")
+	fs.BoolVar(&f.fixes.TruncateDescriptions, "truncate-descriptions", false, "Truncate label descriptions longer than 100 characters instead of rejecting them")
+	f.limits.register(fs)
+	fs.BoolVar(&f.readOnly, "read-only", false, "Block every request that could change something (only GET and GraphQL queries are sent)")
+	fs.StringVar(&f.onBehalfOf, "on-behalf-of", "", "Name this user as the one who requested the run in the issue footer (enables attribution.on_behalf_of)")
+	fs.BoolVar(&f.updateLabels, "update-labels", false, "Update existing labels whose color or description differ from labels.json (the \"update\" label policy, overrides config.json)")
+}
+
+// preparedRun is everything known before the first change is made
+type preparedRun struct {
+	defs     *definitions
+	plans    []repoPlan
+	orgFiles map[repoTarget]map[string]string // Community files for the owners' .github repositories
+}
+
+// prepare loads the config and definitions, resolves the targets and runs every
+// check that can fail before a single change is made
+func (f *targetFlags) prepare(ctx context.Context) (*preparedRun, error) {
+	defs, targets, err := f.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTokenScopes(ctx, defs, f.org != ""); err != nil {
+		return nil, err
+	}
+	plans, err := negotiateCapabilities(ctx, f.provider, targets, requiredCapabilities(defs, f.options), f.options.EnableIssues)
+	if err != nil {
+		return nil, err
+	}
+	if err := preflightCheck(ctx, plans, defs, f.options); err != nil {
+		return nil, err
+	}
+	if err := checkAttribution(ctx, config.Attribution, defs.Hash); err != nil {
+		return nil, err
+	}
+	if f.options.ProjectFields, err = resolveProjectFields(ctx, defs.Issues); err != nil {
+		return nil, err
+	}
+	if err := fetchPlanMetadata(ctx, defs, plans); err != nil {
+		return nil, err
+	}
+	if err := checkBodyTemplates(defs.Issues, plans, defs.RepoVariables); err != nil {
+		return nil, err
+	}
+	if err := planCommunityFiles(defs, plans); err != nil {
+		return nil, err
+	}
+	orgFiles, err := renderOrgCommunityFiles(defs, plans)
+	if err != nil {
+		return nil, err
+	}
+	return &preparedRun{defs: defs, plans: plans, orgFiles: orgFiles}, nil
+}
+
+// load reads the config and definitions and resolves the target repositories
+func (f *targetFlags) load(ctx context.Context) (*definitions, []repoTarget, error) {
+	if f.readOnly {
+		enableReadOnly()
+	}
+	// Resolved here too, so the options that write the files back see the file read
+	f.paths.resolveFormats()
+	if err := f.checkTenant(); err != nil {
+		return nil, nil, err
+	}
+	if f.from != "" {
+		dir, err := pullDefinitionBundle(ctx, f.from)
+		if err != nil {
+			return nil, nil, err
+		}
+		f.from, f.paths.Layers = "", append([]string{dir}, f.paths.Layers...)
+	}
+	var err error
+	if len(f.paths.Layers) > 0 {
+		config, err = loadLayeredConfig(f.paths.Layers)
+	} else {
+		config, err = loadConfig(configJSONPath)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if f.updateLabels {
+		config.Policies.Labels = policyUpdate
+	}
+	if f.onBehalfOf != "" {
+		config.Attribution.OnBehalfOf, config.Attribution.requester = true, f.onBehalfOf
+	}
+	enableWriteThrottle(config.Throttle)
+	enableHTTPCache(config.HTTPCache)
+	defs, err := loadDefinitions(f.paths, f.fixes, f.properties)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := defs.mapComponents(f.provider); err != nil {
+		return nil, nil, err
+	}
+	if f.options.Prune && len(f.milestones) > 0 {
+		return nil, nil, fmt.Errorf("--prune cannot be combined with --milestone, the labels and milestones of other milestones would be deleted")
+	}
+	if err := defs.selectMilestones(f.milestones); err != nil {
+		return nil, nil, err
+	}
+	if f.options.State, err = openStateBackend(f.state); err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Template hash: %s", defs.Hash)
+
+	repos := f.repos
+	if f.reposFile != "" {
+		listed, overrides, err := loadReposFile(f.reposFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		repos = append(append(stringList{}, repos...), listed...)
+		if len(overrides) > 0 && defs.Community == nil && !config.IssueBody.Templates {
+			log.Printf("Warning: %s sets variables, but neither community files nor issue body templates use them.", f.reposFile)
+		}
+		defs.RepoVariables = overrides
+	}
+	targets, err := resolveApplyTargets(ctx, repos, f.org, f.tenant)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := f.tenant.checkTargetOwners(targets); err != nil {
+		return nil, nil, err
+	}
+	if err := applyVariableCatalog(defs, targets); err != nil {
+		return nil, nil, err
+	}
+	return defs, targets, nil
+}
+
+// checkTenant keeps the files and run state of a tenant's request inside what the tenant owns
+func (f *targetFlags) checkTenant() error {
+	if f.tenant == nil {
+		return nil
+	}
+	paths := map[string]string{"--labels": f.paths.Labels, "--milestones": f.paths.Milestones, "--issues": f.paths.Issues,
+		"--components": f.paths.Components, "--vars-schema": f.paths.VarsSchema, "--repos-file": f.reposFile}
+	if err := f.tenant.checkPaths(paths); err != nil {
+		return err
+	}
+	for _, layer := range f.paths.Layers {
+		if err := f.tenant.checkPath("layer", layer); err != nil {
+			return err
+		}
+	}
+	return f.tenant.checkState(f.state)
+}
+
+// applyCommand is a parsed apply invocation
+type applyCommand struct {
+	onConflict      string
+	conflictDefault string
+	gates           qualityGates
+	workers         int
+	writeBack       bool
+	failedOut       string        // File the failed issues are written to, for a retry
+	ephemeral       bool          // Record what is created, for cleanup
+	ttl             time.Duration // How long an ephemeral run is kept before cleanup --expired
+	planFile        string        // Approved plan the run has to match
+	rolloutOrg      string        // Organization the API budget projects a rollout to
+	dryRun          bool          // Only print the plan, see dryRunPlan
+	strict          bool          // Fail on any warning, see countWarnings
+	allowMassChange bool          // Skip checkMassChanges
+	shared          targetFlags
+	// Called as soon as a repository is done, e.g. to stream results; nil to ignore
+	onRepoDone func(t repoTarget, summary runSummary)
+}
+
+// applyResult is the outcome of an apply run
+type applyResult struct {
+	Repos      []repoApplyResult `json:"repos"`
+	Total      runSummary        `json:"total"`
+	Violations []string          `json:"violations,omitempty"` // Exceeded quality gates
+	Failures   []failureRecord   `json:"failures,omitempty"`   // Everything that failed, in report order
+}
+
+// repoApplyResult is the summary of one repository
+type repoApplyResult struct {
+	Repo    string     `json:"repo"`
+	Summary runSummary `json:"summary"`
+}
+
+// parseApplyArgs parses and checks the command line of apply
+func parseApplyArgs(args []string, handling flag.ErrorHandling) (*applyCommand, error) {
+	c := &applyCommand{}
+	fs := flag.NewFlagSet("apply", handling)
+	fs.StringVar(&c.onConflict, "on-conflict", string(conflictPrompt), "How to handle labels/milestones that differ from the definitions: prompt, keep-remote, take-local or skip")
+	fs.StringVar(&c.conflictDefault, "conflict-default", string(conflictKeepRemote), "Action used instead of prompting when stdin is not a terminal: keep-remote, take-local or skip")
+	fs.IntVar(&c.gates.MaxFailures, "max-failures", -1, "Fail the run when more entities than this failed (-1 disables the check)")
+	fs.IntVar(&c.gates.MaxDrift, "max-drift", -1, "Fail the run when more existing labels/milestones than this still differ from the definitions (-1 disables the check)")
+	fs.IntVar(&c.workers, "workers", 1, "Number of repositories processed in parallel")
+	fs.IntVar(&c.shared.options.PhaseWorkers, "phase-workers", 4, "Number of independent phases (labels, milestones, files, ...) of one repository run in parallel")
+	fs.BoolVar(&c.writeBack, "write-back", false, "Record the number and URL of every created issue in the issues file")
+	fs.StringVar(&c.failedOut, "failed-out", "", "Write the issues that could not be created to this file in the issues.json format, to retry them with --issues")
+	fs.BoolVar(&c.ephemeral, "ephemeral", false, "Record everything created under a run id, so 'cleanup' can delete it again (demo and training repositories)")
+	fs.DurationVar(&c.ttl, "ttl", 0, "With --ephemeral, how long until 'cleanup --expired' deletes the run (default 24h)")
+	fs.BoolVar(&c.shared.options.WaitForMilestones, "wait-for-milestones", false, "Verify that new milestones are visible before issues use them, and retry issues rejected for a new milestone (for GHES versions with replication lag)")
+	fs.BoolVar(&c.shared.options.EnableIssues, "enable-issues", false, "Turn on the Issues feature of target repositories that have it turned off, e.g. forks")
+	fs.StringVar(&c.rolloutOrg, "rollout-org", "", "Project the API cost of rolling this run out to every non-archived repository of the organization")
+	fs.StringVar(&c.planFile, "plan", "", "Approved plan saved with 'plan --out'; refuse to run unless the repositories still match it")
+	fs.BoolVar(&c.strict, "strict", false, "Fail the run on any warning (e.g. an issue's milestone missing, an assignee that cannot be assigned, a label skipped); warnings before the first change stop the run before it changes anything")
+	fs.BoolVar(&c.allowMassChange, "allow-mass-change", false, "Delete and close more than config.json's mass_change limits allow (by default 10 labels, 5 milestones and 10 issues per repository)")
+	fs.BoolVar(&c.dryRun, "dry-run", false, "Only print what would be created or changed, like 'plan'; nothing but reads is sent")
+	c.shared.register(fs)
+	fs.Var(&c.shared.milestones, "milestone", "Only create the milestone, its issues and the labels they use (repeatable), to stage a backlog one sprint at a time")
+	if handling == flag.ContinueOnError {
+		fs.SetOutput(io.Discard)
+	}
+	layers, err := parseArgs(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	c.shared.paths.Layers = layers
+	if c.workers < 1 {
+		return nil, fmt.Errorf("--workers must be at least 1")
+	}
+	if c.shared.options.PhaseWorkers < 1 {
+		return nil, fmt.Errorf("--phase-workers must be at least 1")
+	}
+	if err := c.shared.limits.check(); err != nil {
+		return nil, err
+	}
+	switch {
+	case c.ttl < 0 || (c.ttl > 0 && !c.ephemeral):
+		return nil, fmt.Errorf("--ttl needs --ephemeral and a positive duration such as 2h")
+	case c.ephemeral && c.ttl == 0:
+		c.ttl = defaultEphemeralTTL
+	}
+	if c.shared.options.CloseRemoved && c.shared.state == "" {
+		return nil, fmt.Errorf("--close-removed needs --state, the run state records which issues were seeded")
+	}
+	if c.shared.options.CloseRemoved && len(c.shared.milestones) > 0 {
+		return nil, fmt.Errorf("--close-removed cannot be combined with --milestone, the issues of other milestones would count as removed")
+	}
+	if c.ephemeral && c.shared.options.GraphQLWrites {
+		return nil, fmt.Errorf("--ephemeral cannot be combined with --graphql-writes, it records the issues created through the REST API")
+	}
+	paths := c.shared.paths
+	if c.writeBack && (len(c.shared.repos) > 1 || c.shared.org != "" || c.shared.reposFile != "" || paths.Issues == stdinPath || isGlobPattern(paths.Issues) || len(layers) > 0) {
+		return nil, fmt.Errorf("--write-back needs a single target repository and an issues file")
+	}
+	if c.failedOut != "" && (paths.Issues == stdinPath || len(layers) > 0) {
+		return nil, fmt.Errorf("--failed-out needs an issues file, it copies the failed issues from it")
+	}
+	if c.dryRun && (c.writeBack || c.failedOut != "" || c.ephemeral || c.planFile != "") {
+		return nil, fmt.Errorf("--dry-run cannot be combined with --write-back, --failed-out, --ephemeral or --plan")
+	}
+	return c, nil
+}
+
+// dryRunPlan plans the run instead of applying it: the definitions are validated and every
+// label, milestone and issue is resolved against the repositories as apply would, but with
+// --read-only, so not a single write request can be sent
+func (c *applyCommand) dryRunPlan(ctx context.Context) (changePlan, error) {
+	c.shared.readOnly = true
+	plan := &planCommand{shared: c.shared}
+	return plan.execute(ctx)
+}
+
+// runApply creates the labels, milestones and issues in the target repositories.
+// The definitions are loaded and validated once; only remote state is fetched per repository.
+func runApply(ctx context.Context, args []string) {
+	c, err := parseApplyArgs(args, flag.ExitOnError)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	ctx, cancel := c.shared.limits.start(ctx)
+	defer cancel()
+	if c.dryRun {
+		plan, err := c.dryRunPlan(ctx)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		writePlanText(redacted(os.Stdout), plan)
+		log.Print("Dry run, nothing was changed.")
+		exitOnDeadline(ctx)
+		return
+	}
+	result, err := c.execute(ctx, os.Stdin)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := writeActionsOutputs(result.Total); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	writeFailureReport(redacted(os.Stdout), result.Failures)
+	exitOnDeadline(ctx)
+	if len(result.Violations) > 0 {
+		log.Fatalf("Error: quality gate failed: %s", strings.Join(result.Violations, "; "))
+	}
+	// Batch runs report every repository and fail when any of them did
+	if c.shared.reposFile != "" {
+		if failed := writeBatchReport(redacted(os.Stdout), result.Repos); failed > 0 {
+			log.Fatalf("Error: %d of %d repositories failed", failed, len(result.Repos))
+		}
+	}
+}
+
+// execute applies the definitions to every target; conflicts are prompted for on in
+// when it is a terminal. An error means nothing was applied.
+func (c *applyCommand) execute(ctx context.Context, in *os.File) (applyResult, error) {
+	var result applyResult
+	apiUsage.reset()
+	started := time.Now()
+	conflicts, err := newConflictResolver(c.onConflict, c.conflictDefault, in, redacted(os.Stderr))
+	if err != nil {
+		return result, err
+	}
+	var warnings *warningCount
+	if c.strict {
+		warnings = countWarnings()
+		defer warnings.stop()
+	}
+
+	prepared, err := c.shared.prepare(ctx)
+	if err != nil {
+		return result, err
+	}
+	if n := warnings.count(); n > 0 {
+		return result, fmt.Errorf("--strict: %d warnings while preparing the run, nothing was changed", n)
+	}
+	defs, plans, orgFiles, options, paths := prepared.defs, prepared.plans, prepared.orgFiles, c.shared.options, c.shared.paths
+	// Checked before anything is created, not when the files are written at the end
+	for option, enabled := range map[string]bool{"--write-back": c.writeBack, "--failed-out": c.failedOut != ""} {
+		if enabled {
+			if err := checkNotDecrypted(paths.Issues, option); err != nil {
+				return result, err
+			}
+		}
+	}
+	if c.writeBack {
+		if err := checkRewritable(paths.Issues, "--write-back"); err != nil {
+			return result, err
+		}
+	}
+	if c.failedOut != "" {
+		if err := checkRewritable(c.failedOut, "--failed-out"); err != nil {
+			return result, err
+		}
+	}
+	if c.planFile != "" {
+		if err := checkSavedPlan(ctx, c.planFile, prepared, options); err != nil {
+			return result, err
+		}
+	}
+	if !c.allowMassChange {
+		if err := checkMassChanges(ctx, plans, defs, options); err != nil {
+			return result, err
+		}
+	}
+
+	var ephemeral *ephemeralRun
+	stopRecording := func() {}
+	if c.ephemeral {
+		ephemeral, stopRecording = enableEphemeral(c.ttl)
+		defer stopRecording()
+	}
+	if options.State != nil {
+		options.RunID = newRunID(time.Now().UTC())
+		if ephemeral != nil {
+			options.RunID = ephemeral.ID
+		}
+		log.Printf("Run ID: %s (compare with it later with plan --against %s)", options.RunID, options.RunID)
+	}
+	results := newResultCollector(plans)
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.workers)
+	for _, plan := range plans {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(plan repoPlan) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			summary := applyToRepo(ctx, plan, defs, conflicts, options)
+			results.record(plan.Target, summary)
+			if c.onRepoDone != nil {
+				c.onRepoDone(plan.Target, summary)
+			}
+		}(plan)
+	}
+	wg.Wait()
+
+	// Reported only once all repositories are done, so parallel runs do not interleave
+	if len(plans) > 1 {
+		log.Printf("=== Results by Repository ===")
+	}
+	results.each(func(t repoTarget, summary runSummary) {
+		if len(plans) > 1 {
+			logSummary("Summary for "+t.String(), summary, defs)
+		}
+		for _, v := range c.gates.check(summary) {
+			result.Violations = append(result.Violations, fmt.Sprintf("%s: %s", t, v))
+		}
+		result.Repos = append(result.Repos, repoApplyResult{Repo: t.String(), Summary: summary})
+		result.Failures = append(result.Failures, summary.FailureRecords...)
+	})
+	total := results.total()
+	if len(orgFiles) > 0 {
+		var orgSummary runSummary
+		var err error
+		if orgSummary.Files, err = commitOrgCommunityFiles(ctx, orgFiles); err != nil {
+			log.Printf("Warning: %v", err)
+			orgSummary.Errors++
+			result.Failures = append(result.Failures, newFailureRecord("community files", err.Error(), []interface{}{err}))
+		}
+		total.add(orgSummary)
+		for _, v := range c.gates.check(orgSummary) {
+			result.Violations = append(result.Violations, "community files: "+v)
+		}
+	}
+	if config.Project.enabled() && !deadlineReached(ctx) {
+		// The project belongs to the organization, so it is updated once per run
+		if err := syncProjectIterations(ctx, config.Project, defs.Milestones); err != nil {
+			log.Printf("Warning: %v", err)
+			total.Errors++
+			result.Failures = append(result.Failures, newFailureRecord("project", err.Error(), []interface{}{err}))
+		}
+	}
+	if ephemeral != nil {
+		stopRecording() // The records themselves are not part of the run
+		// Written even past the run deadline, or the run could not be cleaned up
+		total.Errors += ephemeral.writeEphemeralRecords(context.WithoutCancel(ctx))
+		log.Printf("Clean up this run with: cleanup --run %s (or cleanup --expired after %s)", ephemeral.ID, ephemeral.Expires.Format(time.RFC3339))
+	}
+	logSummary("Final Summary", total, defs)
+	usage := apiUsage.snapshot()
+	rolloutRepos := 0
+	if c.rolloutOrg != "" {
+		if repos, err := listOrgRepos(ctx, c.rolloutOrg); err != nil {
+			log.Printf("Warning: cannot project the rollout to %s: %v", c.rolloutOrg, err)
+		} else {
+			for _, r := range repos {
+				if !r.Archived {
+					rolloutRepos++
+				}
+			}
+		}
+	}
+	logAPIBudget(usage, len(plans), rolloutRepos, time.Since(started))
+
+	if c.writeBack {
+		t := plans[0].Target
+		if err := writeBackIssues(paths.Issues, t, defs.sourceIndexes(results.get(t).CreatedIssues)); err != nil {
+			log.Printf("Warning: %v", err)
+			total.Errors++
+			result.Failures = append(result.Failures, newFailureRecord(t.String(), err.Error(), nil))
+		}
+	}
+	if c.failedOut != "" {
+		if err := writeRetryFiles(c.failedOut, defs, result.Repos); err != nil {
+			log.Printf("Warning: %v", err)
+			total.Errors++
+			result.Failures = append(result.Failures, newFailureRecord("retry file", err.Error(), []interface{}{err}))
+		}
+	}
+	if n := warnings.count(); n > 0 {
+		result.Violations = append(result.Violations, fmt.Sprintf("%d warnings with --strict", n))
+	}
+	result.Total = total
+	return result, nil
+}
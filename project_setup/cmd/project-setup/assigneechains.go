@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// fallbackAssigneePrefix marks an assignee that is only used when the entries before it
+// cannot be assigned, e.g. because someone left the organization
+const fallbackAssigneePrefix = "?"
+
+// assigneeChains groups assignees into fallback chains: every plain entry starts a chain
+// and the "?" entries after it are its fallbacks, so ["alice", "?bob", "carol"] gives
+// [[alice bob] [carol]]. A leading fallback starts a chain of its own.
+func assigneeChains(assignees []string) [][]string {
+	var chains [][]string
+	for _, assignee := range assignees {
+		fallback := strings.HasPrefix(assignee, fallbackAssigneePrefix)
+		assignee = strings.TrimPrefix(assignee, fallbackAssigneePrefix)
+		if fallback && len(chains) > 0 {
+			chains[len(chains)-1] = append(chains[len(chains)-1], assignee)
+		} else {
+			chains = append(chains, []string{assignee})
+		}
+	}
+	return chains
+}
+
+// hasFallbackAssignees reports whether an issue lists fallback assignees
+func hasFallbackAssignees(issue IssueData) bool {
+	for _, assignee := range issue.Assignees {
+		if strings.HasPrefix(assignee, fallbackAssigneePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// assigneeCheckKey is the lower-case key of the preflight existence check of an assignee
+func assigneeCheckKey(assignee string) string {
+	if org, slug, isTeam := parseTeamAssignee(assignee); isTeam {
+		return strings.ToLower("team " + org + "/" + slug)
+	}
+	return strings.ToLower("user " + assignee)
+}
+
+// isAssignable reports whether a user can be assigned to issues of t, i.e. is a collaborator
+func isAssignable(ctx context.Context, t repoTarget, login string) (bool, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/assignees/%s", githubAPIBaseURL, t.Owner, t.Repo, login)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return false, fmt.Errorf("error sending assignee check for '%s' in %s: %w", login, t, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("error checking assignee '%s' in %s: %w", login, t, github.NewAPIError(resp, bodyBytes))
+}
+
+// resolveAssigneeChains picks the first usable entry of every fallback chain for every
+// repository and stores the result in the plans. Users have to exist and be assignable in
+// the repository, teams have to exist. found holds the preflight results by assigneeCheckKey.
+func resolveAssigneeChains(ctx context.Context, plans []repoPlan, issues []IssueData, found map[string]bool) error {
+	var problems []error
+	for i := range plans {
+		t := plans[i].Target
+		assignable := make(map[string]bool) // By lower-case login, checked once per repository
+		usable := func(entry string) (bool, string, error) {
+			if !found[assigneeCheckKey(entry)] {
+				return false, "does not exist", nil
+			}
+			if _, _, isTeam := parseTeamAssignee(entry); isTeam {
+				return true, "", nil
+			}
+			key := strings.ToLower(entry)
+			if _, checked := assignable[key]; !checked {
+				ok, err := isAssignable(ctx, t, entry)
+				if err != nil {
+					return false, "", err
+				}
+				assignable[key] = ok
+			}
+			return assignable[key], "cannot be assigned (not a collaborator)", nil
+		}
+
+		for index, issue := range issues {
+			if !hasFallbackAssignees(issue) {
+				continue
+			}
+			var resolved []string
+			for _, chain := range assigneeChains(issue.Assignees) {
+				if len(chain) == 1 {
+					resolved = append(resolved, chain[0]) // No fallback, kept as it is
+					continue
+				}
+				chosen := ""
+				for j, entry := range chain {
+					ok, reason, err := usable(entry)
+					if err != nil {
+						return err
+					}
+					if ok {
+						chosen = entry
+						break
+					}
+					if j < len(chain)-1 {
+						log.Printf("Issue '%s' in %s: assignee '%s' %s, trying the next fallback.", issue.Title, t, entry, reason)
+					} else {
+						log.Printf("Issue '%s' in %s: assignee '%s' %s.", issue.Title, t, entry, reason)
+					}
+				}
+				if chosen == "" {
+					problems = append(problems, fmt.Errorf("issue '%s': none of %s can be assigned in %s", issue.Title, strings.Join(chain, ", "), t))
+					continue
+				}
+				resolved = append(resolved, chosen)
+			}
+			if plans[i].Assignees == nil {
+				plans[i].Assignees = make(map[int][]string)
+			}
+			plans[i].Assignees[index] = resolved
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("assignee fallbacks exhausted: %w", errors.Join(problems...))
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// AttributionConfig traces seeded issues back to the account and definitions that created them
+type AttributionConfig struct {
+	// Append a footer naming the account and the source commit of the definitions to every issue
+	Footer bool `json:"footer,omitempty"`
+	// Refuse to run unless the token belongs to this account, e.g. "acme-setup-bot"
+	ExpectedActor string `json:"expected_actor,omitempty"`
+	// Repository of the definitions, e.g. "https://github.com/acme/templates"; defaults to the
+	// workflow repository in GitHub Actions, otherwise the origin remote
+	SourceURL string `json:"source_url,omitempty"`
+	// Name the user who requested the run in the footer: --on-behalf-of, or the user who
+	// triggered the workflow in GitHub Actions
+	OnBehalfOf bool `json:"on_behalf_of,omitempty"`
+
+	requester string // Set by --on-behalf-of
+}
+
+// tokenActor returns the login of the account the token acts as. Installation tokens of
+// GitHub Apps cannot read /user; only the app the run authenticated as itself is known.
+func tokenActor(ctx context.Context) (string, error) {
+	if appBotLogin != "" {
+		return appBotLogin, nil
+	}
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "GET", githubAPIBaseURL+"/user", nil)
+	if err != nil {
+		return "", fmt.Errorf("error sending request for the authenticated user: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error getting the authenticated user: %w", github.NewAPIError(resp, bodyBytes))
+	}
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := json.Unmarshal(bodyBytes, &user); err != nil {
+		return "", fmt.Errorf("error unmarshalling the authenticated user: %w", err)
+	}
+	return user.Login, nil
+}
+
+// definitionSource returns the repository URL and commit the definitions come from; either
+// is empty when unknown
+func definitionSource(ctx context.Context, configured string) (url, commit string) {
+	commit = os.Getenv("GITHUB_SHA")
+	if commit == "" {
+		if out, err := exec.CommandContext(ctx, "git", "rev-parse", "HEAD").Output(); err == nil {
+			commit = strings.TrimSpace(string(out))
+		}
+	}
+	switch {
+	case configured != "":
+		url = strings.TrimSuffix(configured, "/")
+	case os.Getenv("GITHUB_ACTIONS") == "true" && os.Getenv("GITHUB_SERVER_URL") != "":
+		url = os.Getenv("GITHUB_SERVER_URL") + "/" + os.Getenv("GITHUB_REPOSITORY")
+	default:
+		if t, host, err := detectGitRemote(ctx); err == nil {
+			url = fmt.Sprintf("https://%s/%s", host, t)
+		}
+	}
+	return url, commit
+}
+
+// checkAttribution runs the run-as check and prepares the attribution footer of the issues
+func checkAttribution(ctx context.Context, c AttributionConfig, templateHash string) error {
+	config.IssueBody.attribution = ""
+	if !c.Footer && !c.OnBehalfOf && c.ExpectedActor == "" {
+		return nil
+	}
+	actor, err := tokenActor(ctx)
+	switch {
+	case c.ExpectedActor != "" && err != nil:
+		return fmt.Errorf("attribution.expected_actor is set, but the account of the token is unknown: %w", err)
+	case c.ExpectedActor != "" && !strings.EqualFold(actor, c.ExpectedActor):
+		return fmt.Errorf("the token acts as %s, but attribution.expected_actor is %s", actor, c.ExpectedActor)
+	case err != nil:
+		log.Printf("Warning: the footer cannot name the account of the token: %v", err)
+	default:
+		log.Printf("Running as %s.", actor)
+	}
+	requester := ""
+	if c.OnBehalfOf {
+		if requester = runRequester(c); requester == "" {
+			log.Printf("Warning: attribution.on_behalf_of is set, but the requesting user is unknown; pass --on-behalf-of.")
+		} else {
+			log.Printf("Running on behalf of %s.", requester)
+		}
+	}
+	switch {
+	case c.Footer:
+		url, commit := definitionSource(ctx, c.SourceURL)
+		config.IssueBody.attribution = renderAttribution(actor, url, commit, templateHash, requester)
+	case requester != "":
+		config.IssueBody.attribution = renderOnBehalfOf(actor, requester)
+	}
+	return nil
+}
+
+// runRequester returns the login of the user the run is made for: --on-behalf-of, or the
+// user who triggered the workflow in GitHub Actions
+func runRequester(c AttributionConfig) string {
+	if c.requester != "" {
+		return strings.TrimPrefix(c.requester, "@")
+	}
+	if os.Getenv("GITHUB_ACTIONS") != "true" {
+		return ""
+	}
+	if actor := os.Getenv("GITHUB_TRIGGERING_ACTOR"); actor != "" {
+		return actor
+	}
+	return os.Getenv("GITHUB_ACTOR")
+}
+
+// onBehalfOf is the part of the footer naming the requesting user, if any
+func onBehalfOf(requester string) string {
+	if requester == "" {
+		return ""
+	}
+	return fmt.Sprintf(" on behalf of `%s`", requester)
+}
+
+// renderOnBehalfOf writes the footer of attribution.on_behalf_of without attribution.footer
+func renderOnBehalfOf(actor, requester string) string {
+	by := "automation"
+	if actor != "" {
+		by = fmt.Sprintf("`%s`", actor)
+	}
+	return fmt.Sprintf("---\n<sub>Created by %s%s.</sub>", by, onBehalfOf(requester))
+}
+
+// renderAttribution writes the footer; the accounts are not @-mentions so they are not notified
+func renderAttribution(actor, url, commit, templateHash, requester string) string {
+	by := "automation"
+	if actor != "" {
+		by = fmt.Sprintf("`%s`", actor)
+	}
+	source := "definitions"
+	switch {
+	case url != "" && commit != "":
+		name := strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+		if _, path, ok := strings.Cut(name, "/"); ok {
+			name = path
+		}
+		source = fmt.Sprintf("[%s@%s](%s/tree/%s)", name, commit[:min(7, len(commit))], url, commit)
+	case url != "":
+		source = fmt.Sprintf("[definitions](%s)", url)
+	case commit != "":
+		source = fmt.Sprintf("definitions at commit %s", commit[:min(7, len(commit))])
+	}
+	return fmt.Sprintf("---\n<sub>Created by %s%s with the project setup from %s, template hash %s.</sub>", by, onBehalfOf(requester), source, templateHash)
+}
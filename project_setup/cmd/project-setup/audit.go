@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// RepoAuditResult describes how far a single repository deviates from the definitions
+type RepoAuditResult struct {
+	Repo              string   `json:"repo"`
+	MissingLabels     []string `json:"missing_labels,omitempty"`
+	ExtraLabels       []string `json:"extra_labels,omitempty"`
+	MissingMilestones []string `json:"missing_milestones,omitempty"`
+	ExtraMilestones   []string `json:"extra_milestones,omitempty"`
+	Error             string   `json:"error,omitempty"` // Set when the repo could not be scanned
+}
+
+// Consistent reports whether the repo matches the definitions exactly
+func (r RepoAuditResult) Consistent() bool {
+	return r.Error == "" && len(r.MissingLabels) == 0 && len(r.ExtraLabels) == 0 &&
+		len(r.MissingMilestones) == 0 && len(r.ExtraMilestones) == 0
+}
+
+// OrgAuditReport is the full result of `audit org`
+type OrgAuditReport struct {
+	Org     string            `json:"org"`
+	Results []RepoAuditResult `json:"results"`
+}
+
+// listOrgRepos fetches all repositories of an organization
+func listOrgRepos(ctx context.Context, org string) ([]github.Repository, error) {
+	url := fmt.Sprintf("%s/orgs/%s/repos?type=all&per_page=100", githubAPIBaseURL, org)
+	return getAllPages[github.Repository](ctx, "repositories", url)
+}
+
+// diffNames returns the names in want missing from have, and the names in have absent from want
+func diffNames(want, have []string) (missing, extra []string) {
+	wantSet := make(map[string]bool, len(want))
+	for _, name := range want {
+		wantSet[name] = true
+	}
+	haveSet := make(map[string]bool, len(have))
+	for _, name := range have {
+		haveSet[name] = true
+		if !wantSet[name] {
+			extra = append(extra, name)
+		}
+	}
+	for _, name := range want {
+		if !haveSet[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return missing, extra
+}
+
+// auditRepo compares the labels and milestones of one repo against the definitions
+func auditRepo(ctx context.Context, t repoTarget, labelNames, milestoneTitles []string) RepoAuditResult {
+	result := RepoAuditResult{Repo: t.String()}
+
+	labels, err := repoProvider(t).ListLabels(ctx)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	existingLabels := make([]string, 0, len(labels))
+	for _, l := range labels {
+		existingLabels = append(existingLabels, l.Name)
+	}
+
+	milestones, err := repoProvider(t).ListMilestones(ctx)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	// A title that only differs by milestoneKey counts as the defined one
+	defined := make(map[string]string, len(milestoneTitles))
+	for _, title := range milestoneTitles {
+		defined[milestoneKey(title)] = title
+	}
+	existingMilestones := make([]string, 0, len(milestones))
+	for _, m := range milestones {
+		if title, ok := defined[milestoneKey(m.Title)]; ok {
+			existingMilestones = append(existingMilestones, title)
+		} else {
+			existingMilestones = append(existingMilestones, m.Title)
+		}
+	}
+
+	result.MissingLabels, result.ExtraLabels = diffNames(labelNames, existingLabels)
+	result.MissingMilestones, result.ExtraMilestones = diffNames(milestoneTitles, existingMilestones)
+	return result
+}
+
+// writeAuditReport prints a human readable version of the report
+func writeAuditReport(w io.Writer, report OrgAuditReport) {
+	consistent := 0
+	fmt.Fprintf(w, "=== Audit report for organization %s (%d repositories) ===\n", report.Org, len(report.Results))
+	for _, r := range report.Results {
+		if r.Consistent() {
+			consistent++
+			fmt.Fprintf(w, "\n%s: consistent\n", r.Repo)
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", r.Repo)
+		if r.Error != "" {
+			fmt.Fprintf(w, "  error: %s\n", r.Error)
+			continue
+		}
+		writeAuditLine(w, "missing labels", r.MissingLabels)
+		writeAuditLine(w, "extra labels", r.ExtraLabels)
+		writeAuditLine(w, "missing milestones", r.MissingMilestones)
+		writeAuditLine(w, "extra milestones", r.ExtraMilestones)
+	}
+	fmt.Fprintf(w, "\nSummary: %d of %d repositories consistent with the definitions.\n", consistent, len(report.Results))
+}
+
+func writeAuditLine(w io.Writer, heading string, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	fmt.Fprintf(w, "  %s (%d): %s\n", heading, len(names), strings.Join(quoted, ", "))
+}
+
+// runAudit scans every repository of an organization and reports label/milestone drift.
+// Nothing is created, updated or deleted.
+func runAudit(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Print the report as JSON instead of text")
+	includeArchived := fs.Bool("include-archived", false, "Also scan archived repositories")
+	var paths definitionPaths
+	paths.register(fs)
+	var limits runLimits
+	limits.register(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := limits.check(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	ctx, cancel := limits.start(ctx)
+	defer cancel()
+	if len(positional) != 2 || positional[0] != "org" {
+		log.Fatal("Error: usage: audit org <name> [--json] [--include-archived]")
+	}
+	org := positional[1]
+	if err := paths.check(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	labels, err := loadLabels(paths.Labels)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	labelNames := make([]string, 0, len(labels))
+	for _, l := range labels {
+		labelNames = append(labelNames, l.Name)
+	}
+
+	milestones, err := loadMilestones(paths.Milestones)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	milestoneTitles := make([]string, 0, len(milestones))
+	for _, m := range milestones {
+		milestoneTitles = append(milestoneTitles, m.Title)
+	}
+
+	log.Printf("--- Auditing organization %s ---", org)
+	repos, err := listOrgRepos(ctx, org)
+	if err != nil {
+		log.Fatalf("Error listing repositories of %s: %v", org, err)
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Name < repos[j].Name })
+
+	report := OrgAuditReport{Org: org}
+	for _, r := range repos {
+		if r.Archived && !*includeArchived {
+			log.Printf("Skipping archived repository %s.", r.FullName)
+			continue
+		}
+		log.Printf("Auditing %s...", r.FullName)
+		report.Results = append(report.Results, auditRepo(ctx, repoTarget{Owner: org, Repo: r.Name}, labelNames, milestoneTitles))
+		time.Sleep(requestDelay)
+	}
+	// The report is still printed, but only covers the repositories scanned in time
+	defer exitOnDeadline(ctx)
+
+	if *jsonOutput {
+		enc := json.NewEncoder(redacted(os.Stdout))
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("Error encoding audit report: %v", err)
+		}
+		return
+	}
+	writeAuditReport(redacted(os.Stdout), report)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Labels created on demand for issues that refer to a label nobody defined
+const (
+	autoLabelColor       = "ededed" // GitHub's own default label color
+	autoLabelDescription = "Created for an issue by project setup"
+)
+
+// existingLabelNames fetches the names of the repository's labels, lower-cased as GitHub compares them
+func existingLabelNames(ctx context.Context, t repoTarget) (map[string]bool, error) {
+	existing, err := getExistingLabels(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(existing))
+	for name := range existing {
+		names[strings.ToLower(name)] = true
+	}
+	return names, nil
+}
+
+// createMissingLabels creates the labels of an issue that do not exist in the repository yet.
+// known is updated with every label created, so each is created only once per run.
+func createMissingLabels(ctx context.Context, run *repoRun, issue IssueData, known map[string]bool) error {
+	for _, name := range issue.Labels {
+		if known[strings.ToLower(name)] {
+			continue
+		}
+		label := LabelData{Name: name, Color: autoLabelColor, Description: autoLabelDescription}
+		if err := createLabel(ctx, run.target, label); err != nil {
+			return fmt.Errorf("error creating label '%s' for issue '%s': %w", name, issue.Title, err)
+		}
+		log.Printf("Created missing label \"%s\" for issue \"%s\".", name, issue.Title)
+		known[strings.ToLower(name)] = true
+		time.Sleep(requestDelay)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// GitHub's documented limits the projection of a rollout is measured against
+const (
+	primaryRequestsPerHour = 5000 // Primary rate limit of a user or app token
+	secondaryWritesPerHour = 500  // Secondary limit on content-creating requests
+)
+
+// apiCalls counts the requests sent to the API; writes are every request --read-only would block
+type apiCalls struct {
+	Reads  int `json:"reads"`
+	Writes int `json:"writes"`
+}
+
+// Total is the number of requests, reads and writes
+func (c apiCalls) Total() int { return c.Reads + c.Writes }
+
+// apiPhaseKey is the context key of the phase a request is sent for
+type apiPhaseKey struct{}
+
+// otherAPIPhase counts requests outside the phases, e.g. preflight checks and run state
+const otherAPIPhase = "preparation and other"
+
+// withAPIPhase attributes the requests sent with ctx to a phase
+func withAPIPhase(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, apiPhaseKey{}, name)
+}
+
+// apiMeter counts every request sent by the API client per phase
+type apiMeter struct {
+	next http.RoundTripper
+
+	mu      sync.Mutex
+	byPhase map[string]*apiCalls
+}
+
+// apiUsage is installed by main and reset at the start of every apply
+var apiUsage *apiMeter
+
+// enableAPIMeter puts the meter in front of the API client
+func enableAPIMeter() {
+	apiUsage = &apiMeter{next: orDefaultTransport(httpClient.Transport), byPhase: make(map[string]*apiCalls)}
+	httpClient.Transport = apiUsage
+}
+
+func (m *apiMeter) RoundTrip(req *http.Request) (*http.Response, error) {
+	phase, _ := req.Context().Value(apiPhaseKey{}).(string)
+	if phase == "" {
+		phase = otherAPIPhase
+	}
+	m.mu.Lock()
+	calls := m.byPhase[phase]
+	if calls == nil {
+		calls = &apiCalls{}
+		m.byPhase[phase] = calls
+	}
+	if checkReadOnly(req) != nil {
+		calls.Writes++
+	} else {
+		calls.Reads++
+	}
+	m.mu.Unlock()
+	return m.next.RoundTrip(req)
+}
+
+// reset forgets the requests of earlier runs of a long-lived process
+func (m *apiMeter) reset() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.byPhase = make(map[string]*apiCalls)
+}
+
+// snapshot returns the counts so far by phase
+func (m *apiMeter) snapshot() map[string]apiCalls {
+	counts := make(map[string]apiCalls)
+	if m == nil {
+		return counts
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for phase, calls := range m.byPhase {
+		counts[phase] = *calls
+	}
+	return counts
+}
+
+// logAPIBudget reports the requests of each phase and projects what a rollout to
+// rolloutRepos repositories would cost at the same rate per repository (0 to skip)
+func logAPIBudget(byPhase map[string]apiCalls, repos, rolloutRepos int, elapsed time.Duration) {
+	if len(byPhase) == 0 || repos == 0 {
+		return
+	}
+	phases := make([]string, 0, len(byPhase))
+	var total apiCalls
+	for phase, calls := range byPhase {
+		phases = append(phases, phase)
+		total.Reads += calls.Reads
+		total.Writes += calls.Writes
+	}
+	// Most expensive first
+	sort.Slice(phases, func(i, j int) bool {
+		if a, b := byPhase[phases[i]].Total(), byPhase[phases[j]].Total(); a != b {
+			return a > b
+		}
+		return phases[i] < phases[j]
+	})
+
+	log.Printf("--- API Budget ---")
+	for _, phase := range phases {
+		calls := byPhase[phase]
+		log.Printf("%s: %d requests (%d writes), %.1f per repository.", phase, calls.Total(), calls.Writes, float64(calls.Total())/float64(repos))
+	}
+	log.Printf("Total: %d requests (%d writes) for %d repositories in %s.", total.Total(), total.Writes, repos, elapsed.Round(time.Second))
+	if rolloutRepos <= 0 {
+		return
+	}
+	perRepo := float64(total.Total()) / float64(repos)
+	writesPerRepo := float64(total.Writes) / float64(repos)
+	projected := perRepo * float64(rolloutRepos)
+	projectedWrites := writesPerRepo * float64(rolloutRepos)
+	// Whichever limit is reached first sets the pace
+	hours := max(projected/primaryRequestsPerHour, projectedWrites/secondaryWritesPerHour)
+	log.Printf("Projected rollout to %d repositories: about %.0f requests (%.0f writes), at least %.1f hours within GitHub's limits of %d requests and %d content-creating writes per hour.",
+		rolloutRepos, projected, projectedWrites, hours, primaryRequestsPerHour, secondaryWritesPerHour)
+	if projectedWrites > secondaryWritesPerHour {
+		log.Printf("Spread the rollout over several runs, or pace it with throttle.max_writes_per_hour in config.json.")
+	}
+}
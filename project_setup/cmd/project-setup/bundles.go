@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// labelBundlePrefix marks an issue label that stands for a bundle of labels
+const labelBundlePrefix = "bundle:"
+
+// LabelBundles names reusable label sets, e.g. {"needs-triage": ["triage", "needs-info"]};
+// an issue lists "bundle:needs-triage" to get all of them
+type LabelBundles map[string][]string
+
+// validate rejects empty bundles and bundles of bundles
+func (b LabelBundles) validate() error {
+	for name, labels := range b {
+		if strings.TrimSpace(name) == "" || len(labels) == 0 {
+			return fmt.Errorf("label bundle %q needs a name and at least one label", name)
+		}
+		for _, label := range labels {
+			if strings.HasPrefix(label, labelBundlePrefix) {
+				return fmt.Errorf("label bundle %q refers to %q, bundles cannot contain bundles", name, label)
+			}
+		}
+	}
+	return nil
+}
+
+// expand replaces the bundle references in the labels of every issue by the bundled labels,
+// keeping the order and dropping duplicates
+func (b LabelBundles) expand(issues []IssueData) error {
+	var problems []string
+	for i := range issues {
+		issue := &issues[i]
+		if !hasBundleReference(issue.Labels) {
+			continue
+		}
+		seen := make(map[string]bool)
+		var labels []string
+		add := func(label string) {
+			if !seen[strings.ToLower(label)] {
+				seen[strings.ToLower(label)] = true
+				labels = append(labels, label)
+			}
+		}
+		for _, label := range issue.Labels {
+			name, isBundle := strings.CutPrefix(label, labelBundlePrefix)
+			if !isBundle {
+				add(label)
+				continue
+			}
+			bundled, ok := b[name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("issue '%s' uses unknown label bundle '%s'", issue.Title, name))
+				continue
+			}
+			for _, label := range bundled {
+				add(label)
+			}
+		}
+		issue.Labels = labels
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid label bundles:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func hasBundleReference(labels []string) bool {
+	for _, label := range labels {
+		if strings.HasPrefix(label, labelBundlePrefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+const calendarDateLayout = "2006-01-02"
+
+// CalendarConfig points at the working-day calendar milestone due dates are kept on
+type CalendarConfig struct {
+	Path string `json:"path,omitempty"` // Calendar file, e.g. "calendar.json"; empty allows every day
+
+	days *workCalendar // Read from Path by load
+}
+
+// calendarFile matches the structure of the calendar file
+type calendarFile struct {
+	WorkOnWeekends bool     `json:"work_on_weekends,omitempty"` // Weekends are skipped unless set
+	Holidays       []string `json:"holidays,omitempty"`         // e.g. "2026-12-25"
+	Blackouts      []struct {
+		From   string `json:"from"` // First day, inclusive
+		To     string `json:"to"`   // Last day, inclusive
+		Reason string `json:"reason,omitempty"`
+	} `json:"blackouts,omitempty"`
+}
+
+// workCalendar answers whether a day is a working day
+type workCalendar struct {
+	workOnWeekends bool
+	closed         map[string]string // Date -> reason it is not a working day
+}
+
+// load reads the calendar file, if one is configured
+func (c *CalendarConfig) load() error {
+	if c.Path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.Path)
+	if err == nil {
+		data, err = stripJSONC(data)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading calendar %s: %w", c.Path, err)
+	}
+	var file calendarFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("error unmarshalling calendar %s: %w", c.Path, err)
+	}
+	days := &workCalendar{workOnWeekends: file.WorkOnWeekends, closed: make(map[string]string)}
+	for _, date := range file.Holidays {
+		if _, err := time.Parse(calendarDateLayout, date); err != nil {
+			return fmt.Errorf("calendar %s has an invalid holiday %q, expected e.g. 2026-12-25", c.Path, date)
+		}
+		days.closed[date] = "holiday"
+	}
+	for _, blackout := range file.Blackouts {
+		from, err := time.Parse(calendarDateLayout, blackout.From)
+		if err != nil {
+			return fmt.Errorf("calendar %s has a blackout with an invalid from %q", c.Path, blackout.From)
+		}
+		to, err := time.Parse(calendarDateLayout, blackout.To)
+		if err != nil || to.Before(from) {
+			return fmt.Errorf("calendar %s has a blackout with an invalid to %q", c.Path, blackout.To)
+		}
+		reason := blackout.Reason
+		if reason == "" {
+			reason = "blackout"
+		}
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			days.closed[day.Format(calendarDateLayout)] = reason
+		}
+	}
+	c.days = days
+	return nil
+}
+
+// closedReason tells why day is not a working day, or returns "" for a working day
+func (w *workCalendar) closedReason(day time.Time) string {
+	if reason, ok := w.closed[day.Format(calendarDateLayout)]; ok {
+		return reason
+	}
+	if !w.workOnWeekends && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+		return "weekend"
+	}
+	return ""
+}
+
+// nextWorkingDay moves due forward to the first working day, keeping the time of day.
+// It returns why the original day was skipped, or "" when due is already a working day.
+func (c CalendarConfig) nextWorkingDay(due time.Time) (time.Time, string, error) {
+	if c.days == nil {
+		return due, "", nil
+	}
+	due = due.UTC() // The date of a due timestamp is taken in UTC, like GitHub shows it
+	reason := c.days.closedReason(due)
+	if reason == "" {
+		return due, "", nil
+	}
+	for day, i := due, 0; i < 366; i++ {
+		day = day.AddDate(0, 0, 1)
+		if c.days.closedReason(day) == "" {
+			return day, reason, nil
+		}
+	}
+	return due, "", fmt.Errorf("no working day within a year after %s", due.Format(calendarDateLayout))
+}
+
+// adjustMilestones moves the due dates of the milestones off weekends, holidays and blackouts
+func (c CalendarConfig) adjustMilestones(milestones []MilestoneData) error {
+	for i := range milestones {
+		m := &milestones[i]
+		if m.DueOn == nil {
+			continue
+		}
+		due, err := time.Parse(time.RFC3339, *m.DueOn)
+		if err != nil {
+			continue // Reported by validate
+		}
+		moved, reason, err := c.nextWorkingDay(due)
+		if err != nil {
+			return fmt.Errorf("milestone '%s': %w", m.Title, err)
+		}
+		if reason == "" {
+			continue
+		}
+		dueOn := moved.Format(time.RFC3339)
+		log.Printf("Moved the due date of milestone '%s' from %s (%s) to %s.", m.Title, due.UTC().Format(calendarDateLayout), reason, moved.Format(calendarDateLayout))
+		m.DueOn = &dueOn
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextWorkingDay(t *testing.T) {
+	holidays := &workCalendar{closed: map[string]string{
+		"2026-12-25": "holiday",
+		"2026-12-28": "winter freeze",
+	}}
+	day := func(s string) time.Time {
+		d, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		name       string
+		calendar   CalendarConfig
+		due        string
+		want       string
+		wantReason string
+	}{
+		{"no calendar", CalendarConfig{}, "2026-06-06T12:00:00Z", "2026-06-06T12:00:00Z", ""},
+		{"working day", CalendarConfig{days: holidays}, "2026-06-05T12:00:00Z", "2026-06-05T12:00:00Z", ""},
+		{"weekend keeps the time of day", CalendarConfig{days: holidays}, "2026-06-06T17:30:00Z", "2026-06-08T17:30:00Z", "weekend"},
+		{"holiday then weekend then blackout", CalendarConfig{days: holidays}, "2026-12-25T09:00:00Z", "2026-12-29T09:00:00Z", "holiday"},
+		{"date taken in UTC", CalendarConfig{days: holidays}, "2026-06-05T23:00:00-02:00", "2026-06-08T01:00:00Z", "weekend"},
+		{"weekends allowed", CalendarConfig{days: &workCalendar{workOnWeekends: true}}, "2026-06-06T12:00:00Z", "2026-06-06T12:00:00Z", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, reason, err := tc.calendar.nextWorkingDay(day(tc.due))
+			if err != nil {
+				t.Fatalf("nextWorkingDay(%s) failed: %v", tc.due, err)
+			}
+			if !got.Equal(day(tc.want)) || reason != tc.wantReason {
+				t.Errorf("nextWorkingDay(%s) = %s, %q, want %s, %q", tc.due, got.Format(time.RFC3339), reason, tc.want, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestNextWorkingDayNoneWithinAYear(t *testing.T) {
+	closed := make(map[string]string)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for d := 0; d < 400; d++ {
+		closed[start.AddDate(0, 0, d).Format(calendarDateLayout)] = "closed"
+	}
+	calendar := CalendarConfig{days: &workCalendar{workOnWeekends: true, closed: closed}}
+	if _, _, err := calendar.nextWorkingDay(start); err == nil {
+		t.Error("nextWorkingDay() found a working day in a closed year")
+	}
+}
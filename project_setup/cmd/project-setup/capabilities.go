@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// capability is a feature of the tool that a provider or repository may not support
+type capability string
+
+const (
+	capLabels       capability = "labels"
+	capMilestones   capability = "milestones"
+	capIssues       capability = "issues"
+	capReactions    capability = "reactions"
+	capMirrorLabels capability = "milestone label mirroring"
+	capFiles        capability = "file commits"
+	capPullRequests capability = "pull requests"
+	capProperties   capability = "custom properties"
+)
+
+// optionalCapabilities only add decoration; without them the run continues with a warning
+var optionalCapabilities = map[capability]bool{
+	capReactions:    true,
+	capMirrorLabels: true,
+}
+
+// providerCapabilities is the capability matrix of every supported provider.
+// A new provider only needs an entry here to be checked before anything is applied.
+var providerCapabilities = map[string]map[capability]bool{
+	"github": {
+		capLabels: true, capMilestones: true, capIssues: true, capReactions: true,
+		capMirrorLabels: true, capFiles: true, capPullRequests: true, capProperties: true,
+	},
+}
+
+// repoPlan is a target repository together with the optional features skipped for it
+type repoPlan struct {
+	Target         repoTarget
+	Degraded       map[capability]bool
+	CommunityFiles map[string]string // Rendered community health files committed with the configured files
+	Metadata       *targetMetadata   // Read for templates that use it, nil otherwise
+	Assignees      map[int][]string  // Resolved assignee fallbacks, by index in the issue definitions
+}
+
+// requiredCapabilities lists the features the definitions and options make use of
+func requiredCapabilities(defs *definitions, options applyOptions) []capability {
+	var required []capability
+	if len(defs.Labels) > 0 {
+		required = append(required, capLabels)
+	}
+	if len(defs.Milestones) > 0 {
+		required = append(required, capMilestones)
+	}
+	if len(defs.Issues) > 0 {
+		required = append(required, capIssues)
+	}
+	for _, issue := range defs.Issues {
+		if len(issue.Reactions) > 0 {
+			required = append(required, capReactions)
+			break
+		}
+	}
+	if options.MirrorMilestoneLabels {
+		required = append(required, capMirrorLabels)
+	}
+	if len(defs.Files) > 0 || defs.Community != nil {
+		required = append(required, capFiles)
+		if config.Commit.Branch != "" {
+			required = append(required, capPullRequests)
+		}
+	}
+	if len(defs.Properties) > 0 {
+		required = append(required, capProperties)
+	}
+	return required
+}
+
+// repoCapabilities narrows the provider's capabilities down to what one repository allows.
+// The hint tells what to do when the repository is archived or has issues turned off;
+// with enable, turned-off issues are turned on when the run needs them.
+func repoCapabilities(ctx context.Context, t repoTarget, provided map[capability]bool, needsIssues, enable bool) (map[capability]bool, string, error) {
+	repository, err := getRepository(ctx, t)
+	if err != nil {
+		return nil, "", err
+	}
+	warnMovedRepository(t, repository)
+	if needsIssues && enable && !repository.HasIssues && !repository.Archived {
+		if err := enableIssues(ctx, t); err != nil {
+			return nil, "", err
+		}
+		repository.HasIssues = true
+	}
+	available := make(map[capability]bool, len(provided))
+	for c, ok := range provided {
+		available[c] = ok && !repository.Archived
+	}
+	if repository.Owner.Type != "Organization" {
+		available[capProperties] = false // Custom properties are defined by organizations
+	}
+	if !repository.HasIssues {
+		// Milestones and reactions only exist on issues
+		for _, c := range []capability{capIssues, capMilestones, capReactions, capMirrorLabels} {
+			available[c] = false
+		}
+	}
+	return available, readinessHint(t, repository, needsIssues), nil
+}
+
+// negotiateCapabilities checks every target against the features the run needs
+// before anything is changed. Missing optional features are skipped with a warning;
+// missing required ones are all reported together as an error.
+// With enableIssues, repositories with issues turned off get them turned on (--enable-issues).
+func negotiateCapabilities(ctx context.Context, providerName string, targets []repoTarget, required []capability, enableIssues bool) ([]repoPlan, error) {
+	provided, known := providerCapabilities[providerName]
+	if !known {
+		names := make([]string, 0, len(providerCapabilities))
+		for name := range providerCapabilities {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown provider %q, supported: %s", providerName, strings.Join(names, ", "))
+	}
+
+	needsIssues := false
+	for _, c := range required {
+		needsIssues = needsIssues || c == capIssues || c == capMilestones
+	}
+	var problems errorList
+	plans := make([]repoPlan, 0, len(targets))
+	for _, t := range targets {
+		available, hint, err := repoCapabilities(ctx, t, provided, needsIssues, enableIssues)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		plan := repoPlan{Target: t, Degraded: make(map[capability]bool)}
+		var missing []string
+		for _, c := range required {
+			switch {
+			case available[c]:
+			case optionalCapabilities[c]:
+				log.Printf("Warning: %s does not support %s, it will be skipped there.", t, c)
+				plan.Degraded[c] = true
+			default:
+				missing = append(missing, string(c))
+			}
+		}
+		if len(missing) > 0 {
+			err := fmt.Errorf("%s (%s) does not support %s", t, providerName, strings.Join(missing, ", "))
+			if hint != "" {
+				err = fmt.Errorf("%w: %s", err, hint)
+			}
+			problems = append(problems, err)
+		}
+		plans = append(plans, plan)
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("unsupported features, nothing was applied: %w", problems)
+	}
+	return plans, nil
+}
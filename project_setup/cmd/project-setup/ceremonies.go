@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Ceremony generates one issue in every milestone, e.g. the sprint planning, retro or
+// release checklist of each sprint. Title, description and acceptance criteria are
+// templates of the milestone: {{.Milestone.Title}}, {{.Milestone.Description}},
+// {{.Milestone.Start}} and {{.Milestone.Due}}.
+type Ceremony struct {
+	Title           string `json:"title"` // e.g. "Retro: {{.Milestone.Title}}"
+	Description     string `json:"description,omitempty"`
+	DescriptionFile string `json:"description_file,omitempty"` // Reads the description from a file instead
+	// Rendered as a task list under acceptanceCriteriaHeading, e.g. the steps of a release
+	AcceptanceCriteria []string `json:"acceptance_criteria,omitempty"`
+	Labels             []string `json:"labels,omitempty"`
+	Assignees          []string `json:"assignees,omitempty"`
+	Milestones         []string `json:"milestones,omitempty"` // Only these milestones; every one by default
+}
+
+// Ceremonies are the issues generated for every milestone
+type Ceremonies []Ceremony
+
+// ceremonyMilestone is what ceremony templates know about their milestone. Dates are
+// YYYY-MM-DD and empty without a due date; a milestone starts the day after the previous
+// one is due, the first one project.first_iteration_days (14 by default) before its due date.
+type ceremonyMilestone struct {
+	Title       string
+	Description string
+	Start       string
+	Due         string
+}
+
+// load reads the descriptions kept in files and rejects ceremonies that cannot be generated
+func (c Ceremonies) load() error {
+	for i := range c {
+		ceremony := &c[i]
+		if strings.TrimSpace(ceremony.Title) == "" {
+			return fmt.Errorf("ceremony #%d has no title", i+1)
+		}
+		if ceremony.DescriptionFile == "" {
+			continue
+		}
+		if ceremony.Description != "" {
+			return fmt.Errorf("ceremony '%s' sets both description and description_file", ceremony.Title)
+		}
+		content, err := os.ReadFile(ceremony.DescriptionFile)
+		if err != nil {
+			return fmt.Errorf("error reading description of ceremony '%s': %w", ceremony.Title, err)
+		}
+		ceremony.Description = string(content)
+	}
+	return nil
+}
+
+// expand generates the issues of the ceremonies, milestone by milestone, attached to
+// their milestone
+func (c Ceremonies) expand(milestones []MilestoneData) ([]IssueData, error) {
+	if len(c) == 0 {
+		return nil, nil
+	}
+	firstDays := config.Project.FirstIterationDays
+	if firstDays == 0 {
+		firstDays = defaultFirstIterationDays
+	}
+	starts := make(map[string]string)
+	for _, iteration := range milestoneIterations(milestones, firstDays) {
+		starts[milestoneKey(iteration.Title)] = iteration.StartDate
+	}
+	defined := make(map[string]bool, len(milestones))
+	for _, m := range milestones {
+		defined[milestoneKey(m.Title)] = true
+	}
+
+	var issues []IssueData
+	for _, ceremony := range c {
+		only := make(map[string]bool, len(ceremony.Milestones))
+		for _, title := range ceremony.Milestones {
+			if !defined[milestoneKey(title)] {
+				return nil, fmt.Errorf("ceremony '%s' refers to milestone '%s', which is not defined", ceremony.Title, title)
+			}
+			only[milestoneKey(title)] = true
+		}
+		for _, m := range milestones {
+			if len(only) > 0 && !only[milestoneKey(m.Title)] {
+				continue
+			}
+			data := struct{ Milestone ceremonyMilestone }{ceremonyMilestone{
+				Title: m.Title, Description: m.Description, Start: starts[milestoneKey(m.Title)],
+			}}
+			if m.DueOn != nil {
+				if due, err := time.Parse(time.RFC3339, *m.DueOn); err == nil {
+					data.Milestone.Due = due.Format(calendarDateLayout)
+				}
+			}
+			issue := IssueData{Labels: ceremony.Labels, Assignees: ceremony.Assignees, MilestoneTitle: &m.Title}
+			var err error
+			if issue.Title, err = renderCeremonyTemplate(ceremony.Title, data); err != nil {
+				return nil, fmt.Errorf("error rendering the title of ceremony '%s': %w", ceremony.Title, err)
+			}
+			if issue.Description, err = renderCeremonyTemplate(ceremony.Description, data); err != nil {
+				return nil, fmt.Errorf("error rendering the description of ceremony '%s': %w", ceremony.Title, err)
+			}
+			for _, criterion := range ceremony.AcceptanceCriteria {
+				rendered, err := renderCeremonyTemplate(criterion, data)
+				if err != nil {
+					return nil, fmt.Errorf("error rendering the acceptance criteria of ceremony '%s': %w", ceremony.Title, err)
+				}
+				issue.AcceptanceCriteria = append(issue.AcceptanceCriteria, rendered)
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+// renderCeremonyTemplate executes one text of a ceremony for a milestone
+func renderCeremonyTemplate(text string, data interface{}) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("ceremony").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
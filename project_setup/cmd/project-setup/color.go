@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ANSI styles of the log lines
+const (
+	styleReset  = "\x1b[0m"
+	styleRed    = "\x1b[31m"
+	styleGreen  = "\x1b[32m"
+	styleYellow = "\x1b[33m"
+	styleGray   = "\x1b[90m"
+)
+
+// logTimestamp matches the date and time log puts before every message
+var logTimestamp = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d+)? `)
+
+// colorEnabled reports whether output to f is styled: only on a terminal, and neither with
+// NO_COLOR (https://no-color.org) nor TERM=dumb
+func colorEnabled(f *os.File) bool {
+	return os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && isTerminal(f)
+}
+
+// styled returns w styling the log lines written to it, or w itself when output to f is
+// not styled, e.g. when it is piped into a file
+func styled(w io.Writer, f *os.File) io.Writer {
+	if !colorEnabled(f) {
+		return w
+	}
+	return styleWriter{w: w}
+}
+
+// lineStyle picks the style of a log message: failures red, warnings yellow, changes
+// made green and skipped work gray; everything else is left plain
+func lineStyle(line string) string {
+	message := logTimestamp.ReplaceAllString(line, "")
+	switch {
+	case strings.HasPrefix(message, "Error") || strings.HasPrefix(message, "Failed") || strings.HasPrefix(message, "Fatal"):
+		return styleRed
+	case strings.HasPrefix(message, "Warning"):
+		return styleYellow
+	case strings.HasPrefix(message, "Successfully") || strings.HasPrefix(message, "Created ") ||
+		strings.HasPrefix(message, "Added ") || strings.HasPrefix(message, "Renamed ") || strings.HasPrefix(message, "Committed "):
+		return styleGreen
+	case strings.HasPrefix(message, "Skipping") || strings.Contains(message, "skipping") ||
+		strings.Contains(message, "already exists") || strings.Contains(message, "nothing to"):
+		return styleGray
+	}
+	return ""
+}
+
+// styleWriter styles every line written to it
+type styleWriter struct {
+	w io.Writer
+}
+
+func (s styleWriter) Write(p []byte) (int, error) {
+	var out bytes.Buffer
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		text := bytes.TrimSuffix(line, []byte("\n"))
+		style := lineStyle(string(text))
+		if style == "" || len(text) == 0 {
+			out.Write(line)
+			continue
+		}
+		out.WriteString(style)
+		out.Write(text)
+		out.WriteString(styleReset)
+		out.Write(line[len(text):])
+	}
+	if _, err := s.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
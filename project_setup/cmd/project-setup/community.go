@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// CommunityConfig selects the community health files generated from templates, see
+// https://docs.github.com/communities/setting-up-your-project-for-healthy-contributions
+type CommunityConfig struct {
+	Files     []string          `json:"files"`     // e.g. "SECURITY.md"; a built-in template is used unless templates names one
+	Templates map[string]string `json:"templates"` // File name -> local template file
+	Variables map[string]string `json:"variables"` // Available in templates as {{.Vars.name}}
+	Target    string            `json:"target"`    // "repo" (default) or "org" for the organization's .github repository
+}
+
+// The community health files GitHub recognizes, with the built-in templates.
+// Templates see .Owner, .Repo, .Repository ("owner/repo") and .Vars.
+var builtinCommunityTemplates = map[string]string{
+	"SECURITY.md": `# Security Policy
+
+## Reporting a Vulnerability
+
+Please do not report security vulnerabilities through public issues.
+Instead, email {{.Vars.security_contact}} with a description of the issue,
+the steps to reproduce it and the affected versions of {{.Repository}}.
+
+You should receive a response within a few working days. We will keep you
+informed of the progress towards a fix and may ask for more information.
+`,
+	"CONTRIBUTING.md": `# Contributing to {{.Repo}}
+
+Thank you for taking the time to contribute!
+
+1. Check the existing issues, or open a new one describing the change you propose.
+2. Fork the repository and create a branch for your change.
+3. Keep pull requests focused and describe what they change and why.
+4. Make sure the checks pass before asking for a review.
+
+By contributing you agree that your contributions are licensed under the
+license of this repository.
+`,
+	"CODE_OF_CONDUCT.md": `# Code of Conduct
+
+We as members, contributors and maintainers pledge to make participation in
+{{.Repository}} a harassment-free experience for everyone.
+
+Examples of unacceptable behavior include harassment, insulting or derogatory
+comments, and publishing others' private information without their permission.
+
+Instances of abusive, harassing or otherwise unacceptable behavior may be
+reported to {{.Vars.conduct_contact}}. All complaints will be reviewed and
+investigated promptly and fairly.
+
+This Code of Conduct is adapted from the Contributor Covenant, version 2.1.
+`,
+	"SUPPORT.md": `# Getting Help
+
+- Search the [existing issues](https://github.com/{{.Repository}}/issues) first.
+- Open a new issue if your question has not been answered yet.
+{{- with index .Vars "support_url"}}
+- For anything else see {{.}}.
+{{- end}}
+`,
+}
+
+// communityTemplates are the parsed community health file templates of a run
+type communityTemplates struct {
+	templates    map[string]*template.Template // File name -> template
+	org          bool                          // Commit to the organization's .github repository instead of each target
+	usesMetadata bool                          // Some template refers to .Repo or .Org fields read from the API
+}
+
+// loadCommunityTemplates parses the templates selected in config.json; nil means none are configured
+func loadCommunityTemplates(cfg CommunityConfig) (*communityTemplates, error) {
+	if len(cfg.Files) == 0 {
+		return nil, nil
+	}
+	c := &communityTemplates{templates: make(map[string]*template.Template)}
+	switch cfg.Target {
+	case "", "repo":
+	case "org":
+		c.org = true
+	default:
+		return nil, fmt.Errorf("invalid community.target %q, expected repo or org", cfg.Target)
+	}
+
+	for _, name := range cfg.Files {
+		text, builtin := builtinCommunityTemplates[name]
+		if path, ok := cfg.Templates[name]; ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("error reading community template %s: %w", path, err)
+			}
+			text = string(data)
+		} else if !builtin {
+			return nil, fmt.Errorf("no built-in template for community file '%s', add one under community.templates", name)
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing community template '%s': %w", name, err)
+		}
+		c.templates[name] = tmpl
+		c.usesMetadata = c.usesMetadata || usesMetadata(text)
+	}
+	return c, nil
+}
+
+// render executes every template for repository t; a missing variable is an error
+func (c *communityTemplates) render(t repoTarget, meta *targetMetadata, overrides repoVariableOverrides) (map[string]string, error) {
+	data := newTemplateData(t, meta, overrides)
+	files := make(map[string]string, len(c.templates))
+	for name, tmpl := range c.templates {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("error rendering community file '%s' for %s: %w", name, t, err)
+		}
+		files[name] = b.String()
+	}
+	return files, nil
+}
+
+// planCommunityFiles renders the community files of every plan before anything is applied.
+// With target "org" the repositories get none; renderOrgCommunityFiles covers their owners.
+func planCommunityFiles(defs *definitions, plans []repoPlan) error {
+	c := defs.Community
+	if c == nil || c.org {
+		return nil
+	}
+	for i := range plans {
+		files, err := c.render(plans[i].Target, plans[i].Metadata, defs.RepoVariables)
+		if err != nil {
+			return err
+		}
+		plans[i].CommunityFiles = files
+	}
+	return nil
+}
+
+// renderOrgCommunityFiles renders the files for the .github repository of every owner of the plans
+func renderOrgCommunityFiles(defs *definitions, plans []repoPlan) (map[repoTarget]map[string]string, error) {
+	c := defs.Community
+	if c == nil || !c.org {
+		return nil, nil
+	}
+	orgFiles := make(map[repoTarget]map[string]string)
+	for _, plan := range plans {
+		t := repoTarget{Owner: plan.Target.Owner, Repo: ".github"}
+		if _, done := orgFiles[t]; done {
+			continue
+		}
+		// The .github repository may not exist yet, its owner is known from the plan
+		var meta *targetMetadata
+		if plan.Metadata != nil {
+			meta = &targetMetadata{Org: plan.Metadata.Org}
+		}
+		files, err := c.render(t, meta, defs.RepoVariables)
+		if err != nil {
+			return nil, err
+		}
+		orgFiles[t] = files
+	}
+	return orgFiles, nil
+}
+
+// commitOrgCommunityFiles commits the community files to each owner's .github repository,
+// where GitHub uses them as defaults for all repositories without their own
+func commitOrgCommunityFiles(ctx context.Context, orgFiles map[repoTarget]map[string]string) (entityCounts, error) {
+	var counts entityCounts
+	targets := make([]repoTarget, 0, len(orgFiles))
+	for t := range orgFiles {
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Owner < targets[j].Owner })
+
+	var failed []string
+	for _, t := range targets {
+		log.Printf("--- Processing Community Files for %s ---", t)
+		files, err := processFiles(ctx, t, orgFiles[t])
+		counts.add(files)
+		if err != nil {
+			log.Printf("Failed to commit community files to %s (does the repository exist?): %v", t, err)
+			failed = append(failed, t.String())
+		}
+	}
+	if len(failed) > 0 {
+		return counts, fmt.Errorf("error committing community files to %s", strings.Join(failed, ", "))
+	}
+	return counts, nil
+}
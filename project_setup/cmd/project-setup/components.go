@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// componentsJSONPath holds the optional component definitions
+const componentsJSONPath = "components.json"
+
+// defaultComponentColor colors component labels without a color of their own
+const defaultComponentColor = "c5def5"
+
+// ComponentData describes one part of the project once, for every provider; issues refer to
+// components by name
+type ComponentData struct {
+	Name        string `json:"name"`
+	Owner       string `json:"owner,omitempty"` // Owning team, e.g. "@acme/payments"
+	Description string `json:"description,omitempty"`
+	Color       string `json:"color,omitempty"` // Color where a provider shows components as labels
+}
+
+// componentMapping turns the components into what a provider offers for them
+type componentMapping func(d *definitions) error
+
+// componentMappings maps components per provider: labels on GitHub. Jira and Bitbucket
+// components and GitLab scoped labels (component::name) belong here once those providers are
+// supported.
+var componentMappings = map[string]componentMapping{
+	"github": componentLabels("component: "),
+}
+
+// loadComponents reads the component definitions; a missing file at the default path
+// means there are none
+func loadComponents(path string) ([]ComponentData, error) {
+	if format := sourceFormat(path); format != "" {
+		return decodeDefinitionFile[ComponentData](path, format, "components")
+	}
+	jsonData, err := readDefinitionFile(path)
+	if errors.Is(err, os.ErrNotExist) && path == componentsJSONPath {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading components file %s: %w", path, err)
+	}
+	var components []ComponentData
+	if err := json.Unmarshal(jsonData, &components); err != nil {
+		return nil, fmt.Errorf("error unmarshalling components JSON: %w", err)
+	}
+	log.Printf("Read %d component definitions from %s.", len(components), path)
+	return components, nil
+}
+
+// loadLayerComponents merges the components.json of every layer that has one, by name
+func loadLayerComponents(layers []string) ([]ComponentData, error) {
+	var components []ComponentData
+	for _, dir := range layers {
+		path, ok := findDefinitionFile(dir, componentsJSONPath)
+		if !ok {
+			continue
+		}
+		layer, err := loadComponents(path)
+		if err != nil {
+			return nil, err
+		}
+		components = mergeByKey(components, layer, func(c ComponentData) string { return strings.ToLower(c.Name) })
+	}
+	return components, nil
+}
+
+// validateComponents rejects unnamed and duplicate components and issues referring to
+// undefined ones
+func validateComponents(components []ComponentData, issues []IssueData) []error {
+	var problems []error
+	defined := make(map[string]bool, len(components))
+	for i, component := range components {
+		key := strings.ToLower(component.Name)
+		switch {
+		case strings.TrimSpace(component.Name) == "":
+			problems = append(problems, fmt.Errorf("component #%d has no name", i+1))
+		case defined[key]:
+			problems = append(problems, fmt.Errorf("component '%s' is defined more than once", component.Name))
+		case component.Color != "" && !labelColorPattern.MatchString(component.Color):
+			problems = append(problems, fmt.Errorf("component '%s' has an invalid color %q, expected 6 hex digits", component.Name, component.Color))
+		}
+		defined[key] = true
+	}
+	for _, issue := range issues {
+		for _, name := range issue.Components {
+			if !defined[strings.ToLower(name)] {
+				problems = append(problems, fmt.Errorf("issue '%s' refers to component '%s', which is not defined", issue.Title, name))
+			}
+		}
+	}
+	return problems
+}
+
+// componentLabels maps every component to a label named prefix+name, described with its
+// owner, and labels the issues of each component. A label defined in labels.json under the
+// same name takes precedence.
+func componentLabels(prefix string) componentMapping {
+	return func(d *definitions) error {
+		defined := make(map[string]bool, len(d.Labels))
+		for _, label := range d.Labels {
+			defined[strings.ToLower(label.Name)] = true
+		}
+		names := make(map[string]string, len(d.Components))
+		for _, component := range d.Components {
+			name := prefix + component.Name
+			names[strings.ToLower(component.Name)] = name
+			if defined[strings.ToLower(name)] {
+				continue
+			}
+			description := component.Description
+			if component.Owner != "" {
+				description = strings.TrimSpace(fmt.Sprintf("%s (owner: %s)", description, component.Owner))
+			}
+			switch {
+			case utf8.RuneCountInString(name) > maxLabelNameLength:
+				return fmt.Errorf("component '%s' gives the label '%s', which is longer than %d characters", component.Name, name, maxLabelNameLength)
+			case utf8.RuneCountInString(description) > maxLabelDescriptionLength:
+				return fmt.Errorf("component '%s' gives a label description of more than %d characters, shorten its description", component.Name, maxLabelDescriptionLength)
+			}
+			color := component.Color
+			if color == "" {
+				color = defaultComponentColor
+			}
+			d.Labels = append(d.Labels, LabelData{Name: name, Description: description, Color: color})
+		}
+		for i := range d.Issues {
+			for _, component := range d.Issues[i].Components {
+				d.Issues[i].Labels = append(d.Issues[i].Labels, names[strings.ToLower(component)])
+			}
+		}
+		return nil
+	}
+}
+
+// mapComponents maps the components to what the provider offers for them
+func (d *definitions) mapComponents(provider string) error {
+	if len(d.Components) == 0 {
+		return nil
+	}
+	mapping, ok := componentMappings[provider]
+	if !ok {
+		return fmt.Errorf("components are not supported by provider %q", provider)
+	}
+	if err := mapping(d); err != nil {
+		return err
+	}
+	log.Printf("Mapped %d components for %s.", len(d.Components), provider)
+	return nil
+}
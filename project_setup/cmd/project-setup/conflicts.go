@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/alcorg/project_setup/project_setup/engine"
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// conflictAction decides what happens when a definition differs from an existing entity
+type conflictAction string
+
+const (
+	conflictPrompt     conflictAction = "prompt"      // Ask interactively for every conflict
+	conflictKeepRemote conflictAction = "keep-remote" // Leave the existing entity as it is
+	conflictTakeLocal  conflictAction = "take-local"  // Overwrite the existing entity with the definition
+	conflictSkip       conflictAction = "skip"        // Leave it untouched but report it as unresolved
+)
+
+// conflictResolver picks a conflictAction for each clash, prompting when possible.
+// It is shared by all repositories of a run, prompts are asked one at a time.
+type conflictResolver struct {
+	mu       sync.Mutex
+	mode     conflictAction // Configured action, may be conflictPrompt
+	fallback conflictAction // Used instead of prompting when stdin is not a terminal
+	applyAll conflictAction // Set once the user answers with an "apply to all" choice
+	in       *bufio.Reader
+	out      io.Writer
+}
+
+// parseConflictAction validates a conflict action given on the command line
+func parseConflictAction(value string, allowPrompt bool) (conflictAction, error) {
+	switch action := conflictAction(value); action {
+	case conflictKeepRemote, conflictTakeLocal, conflictSkip:
+		return action, nil
+	case conflictPrompt:
+		if allowPrompt {
+			return action, nil
+		}
+	}
+	return "", fmt.Errorf("invalid conflict action %q", value)
+}
+
+// newConflictResolver builds a resolver; prompting is only enabled when in is a terminal
+func newConflictResolver(mode, fallback string, in *os.File, out io.Writer) (*conflictResolver, error) {
+	modeAction, err := parseConflictAction(mode, true)
+	if err != nil {
+		return nil, err
+	}
+	fallbackAction, err := parseConflictAction(fallback, false)
+	if err != nil {
+		return nil, err
+	}
+	if modeAction == conflictPrompt && !isTerminal(in) {
+		log.Printf("Input is not a terminal, conflicts will be resolved with %q.", fallbackAction)
+		modeAction = fallbackAction
+	}
+	return &conflictResolver{
+		mode:     modeAction,
+		fallback: fallbackAction,
+		in:       bufio.NewReader(in),
+		out:      out,
+	}, nil
+}
+
+// isTerminal reports whether f is attached to an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// resolve returns the action to take for one conflicting entity of repository t
+func (r *conflictResolver) resolve(t repoTarget, kind, name string, diffs []string) conflictAction {
+	log.Printf("Conflict: %s \"%s\" in %s differs from the definition: %s", kind, name, t, strings.Join(diffs, "; "))
+
+	r.mu.Lock()
+	action := r.mode
+	if r.applyAll != "" {
+		action = r.applyAll
+	} else if action == conflictPrompt {
+		action = r.prompt(t, kind, name)
+	}
+	r.mu.Unlock()
+
+	switch action {
+	case conflictTakeLocal:
+		log.Printf("Taking local definition for %s \"%s\".", kind, name)
+	case conflictKeepRemote:
+		log.Printf("Keeping remote %s \"%s\" as it is.", kind, name)
+	default:
+		log.Printf("Skipping %s \"%s\", conflict left unresolved.", kind, name)
+	}
+	return action
+}
+
+// prompt asks the user until a valid answer is given; upper case answers apply to all remaining conflicts
+func (r *conflictResolver) prompt(t repoTarget, kind, name string) conflictAction {
+	for {
+		fmt.Fprintf(r.out, "%s: %s \"%s\": [k]eep remote, [t]ake local, [s]kip (upper case = apply to all)? ", t, kind, name)
+		line, err := r.in.ReadString('\n')
+		if err != nil && line == "" {
+			log.Printf("Could not read answer (%v), using %q.", err, r.fallback)
+			return r.fallback
+		}
+
+		answer := strings.TrimSpace(line)
+		var action conflictAction
+		switch strings.ToLower(answer) {
+		case "k":
+			action = conflictKeepRemote
+		case "t":
+			action = conflictTakeLocal
+		case "s":
+			action = conflictSkip
+		default:
+			fmt.Fprintln(r.out, "Please answer k, t or s (or K, T, S to apply to all).")
+			continue
+		}
+		if answer != strings.ToLower(answer) {
+			r.applyAll = action
+		}
+		return action
+	}
+}
+
+// labelDifferences lists the fields where the existing label differs from its definition
+func labelDifferences(local LabelData, remote github.Label) []string {
+	return engine.LabelDifferences(local.engineLabel(), engine.Label{Name: remote.Name, Description: remote.Description, Color: remote.Color})
+}
+
+// milestoneDifferences lists the fields where the existing milestone differs from its definition
+func milestoneDifferences(local MilestoneData, remote github.Milestone) []string {
+	return engine.MilestoneDifferences(local.engineMilestone(), engine.Milestone{Number: remote.ID, Title: remote.Title, Description: remote.Description, DueOn: remote.DueOn})
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
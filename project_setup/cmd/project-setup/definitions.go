@@ -0,0 +1,370 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var labelColorPattern = regexp.MustCompile(`^[0-9a-fA-F]{6}$`)
+
+// definitions is the desired state, built and validated once per run from the
+// definition files and shared read-only by every repository the run targets
+type definitions struct {
+	Labels     []LabelData
+	Milestones []MilestoneData
+	Issues     []IssueData
+	Components []ComponentData        // Mapped for the provider by mapComponents
+	Files      map[string]string      // Repository path -> content, from config.json
+	Properties map[string]interface{} // Custom properties from config.json and --property
+	Community  *communityTemplates    // nil when no community health files are configured
+	// Template variables of single repositories, from --repos-file
+	RepoVariables repoVariableOverrides
+	Variables     variableCatalog // nil without a variable schema
+	// Index in the issues file of each issue, -1 for generated ones; nil when the issues
+	// are exactly those of the file
+	IssueIndexes []int
+	Paths        definitionPaths
+	Hash         string // Identical definitions always give the same hash
+}
+
+// GitHub's limits for labels; longer values are rejected with a cryptic 422
+const (
+	maxLabelNameLength        = 50
+	maxLabelDescriptionLength = 100
+)
+
+// labelFixes are the automatic label corrections enabled on the command line
+type labelFixes struct {
+	StripHash            bool // Remove a leading '#' from colors
+	TruncateDescriptions bool // Cut descriptions down to maxLabelDescriptionLength
+}
+
+// apply corrects the labels in place and logs every change
+func (f labelFixes) apply(labels []LabelData) {
+	for i := range labels {
+		label := &labels[i]
+		if f.StripHash && strings.HasPrefix(label.Color, "#") {
+			label.Color = strings.TrimPrefix(label.Color, "#")
+			log.Printf("Stripped '#' from the color of label '%s'.", label.Name)
+		}
+		if f.TruncateDescriptions && utf8.RuneCountInString(label.Description) > maxLabelDescriptionLength {
+			runes := []rune(label.Description)
+			label.Description = string(runes[:maxLabelDescriptionLength-1]) + "…"
+			log.Printf("Truncated the description of label '%s' to %d characters.", label.Name, maxLabelDescriptionLength)
+		}
+	}
+}
+
+// definitionPaths are the definition files to read; "-" reads one of them from stdin
+type definitionPaths struct {
+	Labels     string
+	Milestones string
+	Issues     string
+	Layers     []string // Directories merged in order, used instead of the files when given
+	VarsSchema string   // Declarations of the template variables
+	Components string   // Component definitions, optional
+}
+
+// register adds the --labels, --milestones and --issues flags to fs
+func (p *definitionPaths) register(fs *flag.FlagSet) {
+	fs.StringVar(&p.Labels, "labels", labelsJSONPath, "Label definitions file or pattern such as 'labels/*.json', '-' for stdin")
+	fs.StringVar(&p.Milestones, "milestones", milestonesJSONPath, "Milestone definitions file or pattern, '-' for stdin")
+	fs.StringVar(&p.Issues, "issues", issuesJSONPath, "Issue definitions file or pattern such as 'backlog/*.json', '-' for stdin")
+	fs.StringVar(&p.Components, "components", componentsJSONPath, "Component definitions file, mapped to what the provider offers")
+	fs.StringVar(&p.VarsSchema, "vars-schema", varsSchemaPath, "Declarations of the template variables, checked before any template is rendered")
+}
+
+// check rejects reading more than one file from stdin
+func (p definitionPaths) check() error {
+	fromStdin := 0
+	for _, path := range []string{p.Labels, p.Milestones, p.Issues} {
+		if path == stdinPath {
+			fromStdin++
+		}
+	}
+	if fromStdin > 1 {
+		return fmt.Errorf("only one definitions file can be read from stdin ('-')")
+	}
+	if len(p.Layers) > 0 && (p.Labels != labelsJSONPath || p.Milestones != milestonesJSONPath || p.Issues != issuesJSONPath || p.Components != componentsJSONPath) {
+		return fmt.Errorf("--labels, --milestones, --issues and --components cannot be combined with definition directories")
+	}
+	return nil
+}
+
+// loadDefinitions reads all definition files, applies the enabled fixes and validates them.
+// properties from the command line override those of config.json.
+func loadDefinitions(paths definitionPaths, fixes labelFixes, properties map[string]interface{}) (*definitions, error) {
+	defs := &definitions{Files: make(map[string]string), Properties: make(map[string]interface{}), Paths: paths}
+	var err error
+
+	if err := paths.check(); err != nil {
+		return nil, err
+	}
+	paths.resolveFormats()
+	defs.Paths = paths
+	if len(paths.Layers) > 0 {
+		if defs.Labels, defs.Milestones, defs.Issues, err = loadLayers(paths.Layers); err != nil {
+			return nil, err
+		}
+		if defs.Components, err = loadLayerComponents(paths.Layers); err != nil {
+			return nil, err
+		}
+	} else {
+		if defs.Labels, err = loadLabels(paths.Labels); err != nil {
+			return nil, err
+		}
+		log.Printf("Read %d label definitions from %s.", len(defs.Labels), paths.Labels)
+
+		if defs.Milestones, err = loadMilestones(paths.Milestones); err != nil {
+			return nil, err
+		}
+		log.Printf("Read %d milestones definitions from %s.", len(defs.Milestones), paths.Milestones)
+
+		if defs.Issues, err = loadIssues(paths.Issues); err != nil {
+			return nil, err
+		}
+		log.Printf("Read %d issue definitions from %s.", len(defs.Issues), paths.Issues)
+
+		if defs.Components, err = loadComponents(paths.Components); err != nil {
+			return nil, err
+		}
+	}
+	fixes.apply(defs.Labels)
+	applySeverityColors(defs.Labels, config.SeverityColors)
+	if err := config.Calendar.adjustMilestones(defs.Milestones); err != nil {
+		return nil, err
+	}
+	ceremonies, err := config.Ceremonies.expand(defs.Milestones)
+	if err != nil {
+		return nil, err
+	}
+	if len(ceremonies) > 0 {
+		log.Printf("Generated %d ceremony issues for %d milestones.", len(ceremonies), len(defs.Milestones))
+		defs.IssueIndexes = make([]int, 0, len(defs.Issues)+len(ceremonies))
+		for i := range defs.Issues {
+			defs.IssueIndexes = append(defs.IssueIndexes, i)
+		}
+		for range ceremonies {
+			defs.IssueIndexes = append(defs.IssueIndexes, -1)
+		}
+		defs.Issues = append(defs.Issues, ceremonies...)
+	}
+	if err := expandIssueForms(defs.Issues); err != nil {
+		return nil, err
+	}
+	if err := config.LabelBundles.expand(defs.Issues); err != nil {
+		return nil, err
+	}
+	// Before validation, which checks the length of the expanded names
+	if config.EmojiShortcodes {
+		expandDefinitionShortcodes(defs)
+	}
+
+	for _, file := range config.Files {
+		content := file.Content
+		if file.Source != "" {
+			data, err := os.ReadFile(file.Source)
+			if err != nil {
+				return nil, fmt.Errorf("error reading file source %s: %w", file.Source, err)
+			}
+			content = string(data)
+		}
+		defs.Files[strings.TrimPrefix(file.Path, "/")] = content
+	}
+	if guide := strings.TrimPrefix(config.LabelGuide.Path, "/"); guide != "" {
+		if _, ok := defs.Files[guide]; ok {
+			return nil, fmt.Errorf("label guide %s is also one of the configured files", guide)
+		}
+		source := paths.Labels
+		if len(paths.Layers) > 0 {
+			source = strings.Join(paths.Layers, ", ")
+		}
+		defs.Files[guide] = renderLabelGuide(defs.Labels, source)
+	}
+
+	if defs.Community, err = loadCommunityTemplates(config.Community); err != nil {
+		return nil, err
+	}
+	if defs.Variables, err = loadVariableCatalog(paths.VarsSchema); err != nil {
+		return nil, err
+	}
+
+	for name, value := range config.Properties {
+		defs.Properties[name] = value
+	}
+	for name, value := range properties {
+		defs.Properties[name] = value
+	}
+	if err := validateProperties(defs.Properties); err != nil {
+		return nil, err
+	}
+
+	if err := defs.validate(); err != nil {
+		return nil, err
+	}
+
+	if defs.Hash, err = defs.hash(); err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+// hash computes the template hash over the decoded definitions and config, so
+// formatting changes in the files do not change it.
+// The canonical form is the JSON object {"Labels":...,"Config":...}, written to the
+// hasher piece by piece so a large backlog is never marshalled into one buffer.
+func (d *definitions) hash() (string, error) {
+	h := sha256.New()
+	write := func(prefix string, v interface{}) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("error hashing definitions: %w", err)
+		}
+		io.WriteString(h, prefix)
+		h.Write(data)
+		return nil
+	}
+	if err := write(`{"Labels":`, d.Labels); err != nil {
+		return "", err
+	}
+	if err := write(`,"Milestones":`, d.Milestones); err != nil {
+		return "", err
+	}
+	if err := writeIssuesHash(h, d.Issues); err != nil {
+		return "", err
+	}
+	if d.Components != nil {
+		if err := write(`,"Components":`, d.Components); err != nil {
+			return "", err
+		}
+	}
+	if err := write(`,"Files":`, d.Files); err != nil {
+		return "", err
+	}
+	if err := write(`,"Properties":`, d.Properties); err != nil {
+		return "", err
+	}
+	if err := write(`,"Config":`, config); err != nil {
+		return "", err
+	}
+	io.WriteString(h, "}")
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeIssuesHash writes the issues to the hash one at a time, without the --write-back
+// annotations, which are records rather than definitions
+func writeIssuesHash(h io.Writer, issues []IssueData) error {
+	io.WriteString(h, `,"Issues":`)
+	if issues == nil {
+		io.WriteString(h, "null")
+		return nil
+	}
+	io.WriteString(h, "[")
+	for i, issue := range issues {
+		issue.Output = nil
+		data, err := json.Marshal(issue)
+		if err != nil {
+			return fmt.Errorf("error hashing definitions: %w", err)
+		}
+		if i > 0 {
+			io.WriteString(h, ",")
+		}
+		h.Write(data)
+	}
+	io.WriteString(h, "]")
+	return nil
+}
+
+// validate checks the definitions for mistakes that would fail or confuse every
+// repository, and logs warnings for references that may still resolve remotely
+func (d *definitions) validate() error {
+	var problems []error
+
+	labelNames := make(map[string]bool)
+	for i, label := range d.Labels {
+		key := strings.ToLower(label.Name) // GitHub label names are case-insensitive
+		switch {
+		case strings.TrimSpace(label.Name) == "":
+			problems = append(problems, fmt.Errorf("label #%d has no name", i+1))
+		case labelNames[key]:
+			problems = append(problems, fmt.Errorf("label '%s' is defined more than once", label.Name))
+		case utf8.RuneCountInString(label.Name) > maxLabelNameLength:
+			problems = append(problems, fmt.Errorf("label '%s' is longer than %d characters", label.Name, maxLabelNameLength))
+		}
+		labelNames[key] = true
+
+		switch {
+		case label.Severity != 0 && (label.Severity < minSeverity || label.Severity > maxSeverity):
+			problems = append(problems, fmt.Errorf("label '%s' has severity %d, expected %d to %d", label.Name, label.Severity, minSeverity, maxSeverity))
+		case strings.HasPrefix(label.Color, "#") && labelColorPattern.MatchString(label.Color[1:]):
+			problems = append(problems, fmt.Errorf("label '%s' has color %q, colors must not start with '#' (use --strip-hash to fix automatically)", label.Name, label.Color))
+		case !labelColorPattern.MatchString(label.Color):
+			problems = append(problems, fmt.Errorf("label '%s' has color %q, expected exactly 6 hex digits such as d73a4a", label.Name, label.Color))
+		}
+		if n := utf8.RuneCountInString(label.Description); n > maxLabelDescriptionLength {
+			problems = append(problems, fmt.Errorf("label '%s' has a description of %d characters, the maximum is %d (use --truncate-descriptions to fix automatically)", label.Name, n, maxLabelDescriptionLength))
+		}
+	}
+
+	milestoneTitles := make(map[string]bool)
+	for i, milestone := range d.Milestones {
+		switch {
+		case strings.TrimSpace(milestone.Title) == "":
+			problems = append(problems, fmt.Errorf("milestone #%d has no title", i+1))
+		case milestoneTitles[milestoneKey(milestone.Title)]:
+			problems = append(problems, fmt.Errorf("milestone '%s' is defined more than once", milestone.Title))
+		}
+		milestoneTitles[milestoneKey(milestone.Title)] = true
+		if milestone.DueOn != nil {
+			if _, err := time.Parse(time.RFC3339, *milestone.DueOn); err != nil {
+				problems = append(problems, fmt.Errorf("milestone '%s' has an invalid due_on %q, expected e.g. 2025-05-31T23:59:59Z", milestone.Title, *milestone.DueOn))
+			}
+		}
+	}
+
+	issueTitles := make(map[string]bool)
+	for i, issue := range d.Issues {
+		if strings.TrimSpace(issue.Title) == "" {
+			problems = append(problems, fmt.Errorf("issue #%d has no title", i+1))
+			continue
+		}
+		if issueTitles[issue.Title] {
+			log.Printf("Warning: Issue '%s' is defined more than once.", issue.Title)
+		}
+		issueTitles[issue.Title] = true
+		for _, name := range issue.Labels {
+			if !labelNames[strings.ToLower(name)] {
+				log.Printf("Warning: Label '%s' used by issue '%s' is not defined in %s.", name, issue.Title, d.Paths.Labels)
+			}
+		}
+		if len(issue.Assignees) > 0 && strings.HasPrefix(issue.Assignees[0], fallbackAssigneePrefix) {
+			problems = append(problems, fmt.Errorf("issue '%s' starts its assignees with the fallback '%s', which has nothing to fall back from", issue.Title, issue.Assignees[0]))
+		}
+		if len(issue.ProjectFields) > 0 && !config.Project.enabled() {
+			problems = append(problems, fmt.Errorf("issue '%s' sets project_fields, but config.json has no project", issue.Title))
+		}
+		if issue.MilestoneTitle != nil && *issue.MilestoneTitle != "" && !milestoneTitles[milestoneKey(*issue.MilestoneTitle)] {
+			log.Printf("Warning: Milestone '%s' used by issue '%s' is not defined in %s.", *issue.MilestoneTitle, issue.Title, d.Paths.Milestones)
+		}
+	}
+
+	problems = append(problems, validateEpics(d.Issues)...)
+	problems = append(problems, validateComponents(d.Components, d.Issues)...)
+	problems = append(problems, validateLabelScopes(d.Issues)...)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid definitions: %w", errors.Join(problems...))
+	}
+	return nil
+}
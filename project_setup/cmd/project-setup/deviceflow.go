@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// DeviceFlowConfig is the OAuth app that mints a token with the scopes a run needs when the
+// token given lacks some of them
+type DeviceFlowConfig struct {
+	// Client ID of an OAuth app or GitHub App with the device flow enabled
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// scopeRequirement is an OAuth scope a run needs and the scopes that include it
+type scopeRequirement struct {
+	Scope    string
+	Reason   string
+	Covering []string
+}
+
+// requiredScopes lists the classic OAuth scopes the enabled features need
+func requiredScopes(defs *definitions, orgTargets bool) []scopeRequirement {
+	// public_repo is enough as long as every target is public
+	required := []scopeRequirement{{Scope: "repo", Reason: "issues, labels and milestones", Covering: []string{"repo", "public_repo"}}}
+
+	usesTeams := orgTargets
+	for _, issue := range defs.Issues {
+		for _, assignee := range issue.Assignees {
+			if _, _, isTeam := parseTeamAssignee(strings.TrimPrefix(assignee, fallbackAssigneePrefix)); isTeam {
+				usesTeams = true
+			}
+		}
+	}
+	if (len(defs.Files) > 0 || defs.Community != nil) && config.Commit.Branch != "" && len(config.Commit.PullRequest.TeamReviewers) > 0 {
+		usesTeams = true
+	}
+	if usesTeams {
+		required = append(required, scopeRequirement{Scope: "read:org", Reason: "teams and organization repositories", Covering: []string{"read:org", "write:org", "admin:org"}})
+	}
+	if config.Project.enabled() {
+		required = append(required, scopeRequirement{Scope: "project", Reason: "the organization project", Covering: []string{"project"}})
+	}
+	for path := range defs.Files {
+		if strings.HasPrefix(path, ".github/workflows/") {
+			required = append(required, scopeRequirement{Scope: "workflow", Reason: "files under .github/workflows", Covering: []string{"workflow"}})
+			break
+		}
+	}
+	return required
+}
+
+// grantedScopes returns the scopes of a classic or OAuth token; ok is false for tokens
+// without scopes (fine-grained and GitHub App tokens), whose permissions cannot be listed,
+// and on GHES instances with rate limiting disabled, which answer /rate_limit with a 404
+func grantedScopes(ctx context.Context) (scopes map[string]bool, ok bool, err error) {
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "GET", githubAPIBaseURL+"/rate_limit", nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("error sending request for the token scopes: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("error getting the token scopes: %w", github.NewAPIError(resp, bodyBytes))
+	}
+	header, ok := resp.Header["X-Oauth-Scopes"]
+	if !ok {
+		return nil, false, nil
+	}
+	scopes = make(map[string]bool)
+	for _, scope := range strings.Split(strings.Join(header, ","), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes[scope] = true
+		}
+	}
+	return scopes, true, nil
+}
+
+// missingScopes returns the requirements none of the granted scopes covers
+func missingScopes(required []scopeRequirement, granted map[string]bool) []scopeRequirement {
+	var missing []scopeRequirement
+	for _, r := range required {
+		covered := false
+		for _, scope := range r.Covering {
+			covered = covered || granted[scope]
+		}
+		if !covered {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+// checkTokenScopes makes sure the token has the scopes of the enabled features. When it
+// lacks some and stdin is a terminal, it offers to sign in with the device flow of
+// device_flow.client_id for a token with exactly the scopes needed, used for this run only.
+func checkTokenScopes(ctx context.Context, defs *definitions, orgTargets bool) error {
+	granted, ok, err := grantedScopes(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	required := requiredScopes(defs, orgTargets)
+	missing := missingScopes(required, granted)
+	if len(missing) == 0 {
+		return nil
+	}
+	var names, reasons []string
+	for _, r := range missing {
+		names = append(names, r.Scope)
+		reasons = append(reasons, fmt.Sprintf("%s (%s)", r.Scope, r.Reason))
+	}
+	problem := fmt.Errorf("the token lacks the scopes %s", strings.Join(reasons, ", "))
+	if config.DeviceFlow.ClientID == "" || !isTerminal(os.Stdin) {
+		return fmt.Errorf("%w; use a token with them, or set device_flow.client_id in config.json to sign in for one interactively", problem)
+	}
+
+	scopes := make([]string, 0, len(required))
+	for _, r := range required {
+		scopes = append(scopes, r.Scope)
+	}
+	sort.Strings(scopes)
+	fmt.Fprintf(os.Stderr, "The token lacks the scopes %s.\nSign in with the device flow for a token with the scopes %s, used for this run only? [y/N] ",
+		strings.Join(names, ", "), strings.Join(scopes, " "))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+		return problem
+	}
+	token, err := deviceFlowToken(ctx, config.DeviceFlow.ClientID, scopes, os.Stderr)
+	if err != nil {
+		return err
+	}
+	secrets.add(token)
+	githubToken = token
+
+	granted, ok, err = grantedScopes(ctx)
+	if err != nil {
+		return err
+	}
+	if missing := missingScopes(required, granted); ok && len(missing) > 0 {
+		return fmt.Errorf("the token from the device flow still lacks %d of the scopes, e.g. %s", len(missing), missing[0].Scope)
+	}
+	log.Printf("Using the token from the device flow with the scopes %s for this run.", strings.Join(scopes, " "))
+	return nil
+}
+
+// deviceFlowResponse holds the fields of both device flow endpoints
+type deviceFlowResponse struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+	AccessToken     string `json:"access_token"`
+	Error           string `json:"error"`
+	ErrorText       string `json:"error_description"`
+}
+
+// postDeviceFlow sends a form to a device flow endpoint of the web host; these are not API
+// requests, so they bypass the API client and its token
+func postDeviceFlow(ctx context.Context, endpoint string, form url.Values) (deviceFlowResponse, error) {
+	var result deviceFlowResponse
+	req, err := http.NewRequestWithContext(ctx, "POST", webBaseURL()+endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := (&http.Client{Timeout: defaultRequestTimeout}).Do(req)
+	if err != nil {
+		return result, fmt.Errorf("error sending device flow request: %w", err)
+	}
+	defer resp.Body.Close()
+	bodyBytes, err := readResponseBody(resp.Body)
+	if err != nil {
+		return result, fmt.Errorf("error reading device flow response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("error in device flow request: status %d: %s", resp.StatusCode, bodyBytes)
+	}
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return result, fmt.Errorf("error unmarshalling device flow response: %w", err)
+	}
+	return result, nil
+}
+
+// deviceFlowToken signs the user in with the OAuth device flow: it shows a code to enter
+// in the browser and polls until the user has authorized the app
+func deviceFlowToken(ctx context.Context, clientID string, scopes []string, out io.Writer) (string, error) {
+	code, err := postDeviceFlow(ctx, "/login/device/code", url.Values{"client_id": {clientID}, "scope": {strings.Join(scopes, " ")}})
+	if err != nil {
+		return "", err
+	}
+	if code.Error != "" {
+		return "", fmt.Errorf("error starting the device flow: %s %s", code.Error, code.ErrorText)
+	}
+	fmt.Fprintf(out, "Open %s and enter the code %s\n", code.VerificationURI, code.UserCode)
+
+	interval := time.Duration(max(code.Interval, 1)) * time.Second
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(interval):
+		}
+		token, err := postDeviceFlow(ctx, "/login/oauth/access_token", url.Values{
+			"client_id":   {clientID},
+			"device_code": {code.DeviceCode},
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		})
+		if err != nil {
+			return "", err
+		}
+		switch token.Error {
+		case "":
+			return token.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval = time.Duration(max(token.Interval, code.Interval+5)) * time.Second
+		default: // expired_token, access_denied, ...
+			return "", fmt.Errorf("device flow failed: %s %s", token.Error, token.ErrorText)
+		}
+	}
+	return "", fmt.Errorf("device flow failed: the code expired before it was entered")
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// shortcodePattern matches an emoji shortcode such as :rocket: or :+1:
+var shortcodePattern = regexp.MustCompile(`:[a-z0-9_+-]+:`)
+
+// emojiShortcodes are the shortcodes expanded with emoji_shortcodes, the common ones of
+// GitHub's set. Unknown shortcodes are left as they are.
+var emojiShortcodes = map[string]string{
+	"+1": "👍", "-1": "👎", "thumbsup": "👍", "thumbsdown": "👎", "wave": "👋", "clap": "👏",
+	"pray": "🙏", "muscle": "💪", "raised_hands": "🙌", "point_right": "👉", "ok_hand": "👌",
+	"eyes": "👀", "brain": "🧠", "speech_balloon": "💬", "thought_balloon": "💭",
+
+	"smile": "😄", "grinning": "😀", "joy": "😂", "sweat_smile": "😅", "wink": "😉",
+	"thinking": "🤔", "confused": "😕", "neutral_face": "😐", "sob": "😭", "cry": "😢",
+	"rage": "😡", "scream": "😱", "sleeping": "😴", "nerd_face": "🤓", "sunglasses": "😎",
+	"robot": "🤖", "alien": "👽", "ghost": "👻", "skull": "💀", "see_no_evil": "🙈",
+	"busts_in_silhouette": "👥", "bust_in_silhouette": "👤",
+
+	"heart": "❤️", "broken_heart": "💔", "green_heart": "💚", "blue_heart": "💙",
+	"yellow_heart": "💛", "purple_heart": "💜", "orange_heart": "🧡", "black_heart": "🖤",
+
+	"red_circle": "🔴", "orange_circle": "🟠", "yellow_circle": "🟡", "green_circle": "🟢",
+	"large_blue_circle": "🔵", "blue_circle": "🔵", "purple_circle": "🟣", "brown_circle": "🟤",
+	"white_circle": "⚪", "black_circle": "⚫", "red_square": "🟥", "orange_square": "🟧",
+	"yellow_square": "🟨", "green_square": "🟩", "blue_square": "🟦", "purple_square": "🟪",
+	"large_orange_diamond": "🔶", "large_blue_diamond": "🔷", "small_red_triangle": "🔺",
+	"small_red_triangle_down": "🔻",
+
+	"bug": "🐛", "beetle": "🐞", "ant": "🐜", "bee": "🐝", "snail": "🐌", "turtle": "🐢",
+	"racehorse": "🐎", "butterfly": "🦋", "seedling": "🌱", "herb": "🌿", "evergreen_tree": "🌲",
+	"fallen_leaf": "🍂", "cactus": "🌵", "sunny": "☀️", "cloud": "☁️", "umbrella": "☔",
+	"snowflake": "❄️", "rainbow": "🌈", "ocean": "🌊", "earth_americas": "🌎",
+	"globe_with_meridians": "🌐", "world_map": "🗺️", "volcano": "🌋",
+
+	"rocket": "🚀", "sparkles": "✨", "tada": "🎉", "confetti_ball": "🎊", "balloon": "🎈",
+	"gift": "🎁", "trophy": "🏆", "1st_place_medal": "🥇", "medal_sports": "🏅", "crown": "👑",
+	"gem": "💎", "star": "⭐", "star2": "🌟", "dizzy": "💫", "fire": "🔥", "zap": "⚡",
+	"boom": "💥", "bomb": "💣", "100": "💯", "dart": "🎯", "game_die": "🎲", "art": "🎨",
+	"lipstick": "💄", "ribbon": "🎀", "checkered_flag": "🏁", "triangular_flag_on_post": "🚩",
+	"construction": "🚧", "rotating_light": "🚨", "ambulance": "🚑", "fire_engine": "🚒",
+	"stop_sign": "🛑", "no_entry": "⛔", "no_entry_sign": "🚫", "warning": "⚠️",
+	"construction_worker": "👷", "building_construction": "🏗️", "house": "🏠", "office": "🏢",
+	"hospital": "🏥", "bank": "🏦", "airplane": "✈️", "ship": "🚢", "train": "🚆", "car": "🚗",
+	"bike": "🚲", "truck": "🚚", "anchor": "⚓", "compass": "🧭", "passport_control": "🛂",
+
+	"memo": "📝", "pencil": "📝", "pencil2": "✏️", "books": "📚", "book": "📖",
+	"bookmark": "🔖", "label": "🏷️", "scroll": "📜", "page_facing_up": "📄",
+	"clipboard": "📋", "pushpin": "📌", "round_pushpin": "📍", "paperclip": "📎", "link": "🔗",
+	"file_folder": "📁", "open_file_folder": "📂", "card_file_box": "🗃️", "card_index": "📇",
+	"wastebasket": "🗑️", "package": "📦", "inbox_tray": "📥", "outbox_tray": "📤",
+	"email": "📧", "envelope": "✉️", "mailbox": "📫", "loudspeaker": "📢", "mega": "📣",
+	"bell": "🔔", "no_bell": "🔕", "mute": "🔇", "sound": "🔉", "calendar": "📆", "date": "📅",
+	"spiral_calendar": "🗓️", "bar_chart": "📊", "chart_with_upwards_trend": "📈",
+	"chart_with_downwards_trend": "📉", "mag": "🔍", "mag_right": "🔎", "microscope": "🔬",
+	"telescope": "🔭", "test_tube": "🧪", "dna": "🧬", "pill": "💊", "syringe": "💉",
+	"bulb": "💡", "flashlight": "🔦", "battery": "🔋", "electric_plug": "🔌",
+	"computer": "💻", "desktop_computer": "🖥️", "keyboard": "⌨️", "iphone": "📱",
+	"floppy_disk": "💾", "cd": "💿", "printer": "🖨️", "satellite": "📡",
+
+	"wrench": "🔧", "hammer": "🔨", "hammer_and_wrench": "🛠️", "gear": "⚙️", "nut_and_bolt": "🔩",
+	"toolbox": "🧰", "magnet": "🧲", "chains": "⛓️", "lock": "🔒", "unlock": "🔓",
+	"closed_lock_with_key": "🔐", "lock_with_ink_pen": "🔏", "key": "🔑", "old_key": "🗝️",
+	"shield": "🛡️", "crossed_swords": "⚔️", "moneybag": "💰", "heavy_dollar_sign": "💲",
+	"credit_card": "💳", "receipt": "🧾",
+
+	"hourglass": "⌛", "hourglass_flowing_sand": "⏳", "stopwatch": "⏱️", "timer_clock": "⏲️",
+	"alarm_clock": "⏰", "watch": "⌚", "zzz": "💤", "running": "🏃", "walking": "🚶",
+
+	"white_check_mark": "✅", "heavy_check_mark": "✔️", "ballot_box_with_check": "☑️",
+	"x": "❌", "negative_squared_cross_mark": "❎", "heavy_plus_sign": "➕",
+	"heavy_minus_sign": "➖", "question": "❓", "grey_question": "❔", "exclamation": "❗",
+	"grey_exclamation": "❕", "bangbang": "‼️", "information_source": "ℹ️", "recycle": "♻️",
+	"arrow_up": "⬆️", "arrow_down": "⬇️", "arrow_right": "➡️", "arrow_left": "⬅️",
+	"arrows_counterclockwise": "🔄", "repeat": "🔁", "twisted_rightwards_arrows": "🔀",
+	"new": "🆕", "free": "🆓", "up": "🆙", "cool": "🆒", "ok": "🆗", "sos": "🆘",
+	"wheelchair": "♿", "children_crossing": "🚸", "speaking_head": "🗣️", "pirate_flag": "🏴‍☠️",
+}
+
+// expandShortcodes replaces the known shortcodes of s with their emoji
+func expandShortcodes(s string) string {
+	if !strings.Contains(s, ":") {
+		return s
+	}
+	return shortcodePattern.ReplaceAllStringFunc(s, func(code string) string {
+		if emoji, ok := emojiShortcodes[strings.Trim(code, ":")]; ok {
+			return emoji
+		}
+		return code
+	})
+}
+
+// expandName expands the shortcodes of a label name or milestone title, warning about unknown
+// ones since they end up in the name as typed
+func expandName(kind, name string) string {
+	expanded := expandShortcodes(name)
+	for _, code := range shortcodePattern.FindAllString(expanded, -1) {
+		log.Printf("Warning: Unknown emoji shortcode %s in %s '%s' is kept as it is.", code, kind, name)
+	}
+	return expanded
+}
+
+// expandDefinitionShortcodes expands the shortcodes of label names and descriptions,
+// milestone titles and descriptions, and issue bodies (outside of code) and the labels and
+// milestones they refer to, so templates written with shortcodes render as Unicode
+func expandDefinitionShortcodes(defs *definitions) {
+	for i := range defs.Labels {
+		defs.Labels[i].Name = expandName("label", defs.Labels[i].Name)
+		defs.Labels[i].Description = expandShortcodes(defs.Labels[i].Description)
+	}
+	for i := range defs.Milestones {
+		defs.Milestones[i].Title = expandName("milestone", defs.Milestones[i].Title)
+		defs.Milestones[i].Description = expandShortcodes(defs.Milestones[i].Description)
+	}
+	for i := range defs.Issues {
+		issue := &defs.Issues[i]
+		issue.Description = outsideCode(issue.Description, expandShortcodes)
+		for j, criterion := range issue.AcceptanceCriteria {
+			issue.AcceptanceCriteria[j] = outsideCode(criterion, expandShortcodes)
+		}
+		for j, label := range issue.Labels {
+			issue.Labels[j] = expandShortcodes(label)
+		}
+		if issue.MilestoneTitle != nil {
+			title := expandShortcodes(*issue.MilestoneTitle)
+			issue.MilestoneTitle = &title
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+const (
+	defaultEphemeralTTL = 24 * time.Hour
+	// Marks the issue holding the record of an ephemeral run
+	ephemeralRunMarker = "<!-- project-setup:ephemeral-run -->"
+)
+
+// createdEntityPath matches the REST endpoints that create labels, milestones and issues
+var createdEntityPath = regexp.MustCompile(`/repos/([^/]+)/([^/]+)/(labels|milestones|issues)$`)
+
+// ephemeralRecord lists what an ephemeral run created in one repository
+type ephemeralRecord struct {
+	Run        string           `json:"run"`
+	Expires    time.Time        `json:"expires"`
+	Labels     []string         `json:"labels"`
+	Milestones []int            `json:"milestones"`
+	Issues     []ephemeralIssue `json:"issues"`
+}
+
+// ephemeralIssue is a created issue; deleting one needs its node ID
+type ephemeralIssue struct {
+	Number int    `json:"number"`
+	NodeID string `json:"node_id"`
+}
+
+// ephemeralRun collects what an --ephemeral apply creates, per repository
+type ephemeralRun struct {
+	ID      string
+	Expires time.Time
+	mu      sync.Mutex
+	records map[repoTarget]*ephemeralRecord
+}
+
+// newEphemeralRun starts a run whose entities may be cleaned up once ttl has passed
+func newEphemeralRun(ttl time.Duration) *ephemeralRun {
+	now := time.Now().UTC()
+	return &ephemeralRun{
+		ID:      newRunID(now),
+		Expires: now.Add(ttl).Truncate(time.Second),
+		records: make(map[repoTarget]*ephemeralRecord),
+	}
+}
+
+// ephemeralTransport records every label, milestone and issue created through it, whichever
+// phase created it (mirror labels and tracking issues included)
+type ephemeralTransport struct {
+	next http.RoundTripper
+	run  *ephemeralRun
+}
+
+func (t ephemeralTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || req.Method != http.MethodPost || resp.StatusCode != http.StatusCreated {
+		return resp, err
+	}
+	m := createdEntityPath.FindStringSubmatch(req.URL.Path)
+	if m == nil {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return resp, nil // The client reports the broken body
+	}
+	var created struct {
+		Name   string `json:"name"`
+		Number int    `json:"number"`
+		NodeID string `json:"node_id"`
+	}
+	if json.Unmarshal(body, &created) == nil {
+		t.run.add(repoTarget{Owner: m[1], Repo: m[2]}, m[3], created.Name, created.Number, created.NodeID)
+	}
+	return resp, nil
+}
+
+// add records one created entity of kind "labels", "milestones" or "issues"
+func (r *ephemeralRun) add(t repoTarget, kind, name string, number int, nodeID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.records[t]
+	if !ok {
+		record = &ephemeralRecord{Run: r.ID, Expires: r.Expires}
+		r.records[t] = record
+	}
+	switch kind {
+	case "labels":
+		record.Labels = append(record.Labels, name)
+	case "milestones":
+		record.Milestones = append(record.Milestones, number)
+	case "issues":
+		record.Issues = append(record.Issues, ephemeralIssue{Number: number, NodeID: nodeID})
+	}
+}
+
+// enableEphemeral records what the run creates until the returned function is called
+func enableEphemeral(ttl time.Duration) (*ephemeralRun, func()) {
+	run := newEphemeralRun(ttl)
+	next := httpClient.Transport
+	httpClient.Transport = ephemeralTransport{next: orDefaultTransport(next), run: run}
+	log.Printf("Ephemeral run %s: everything created can be cleaned up after %s.", run.ID, run.Expires.Format(time.RFC3339))
+	return run, func() { httpClient.Transport = next }
+}
+
+func orDefaultTransport(t http.RoundTripper) http.RoundTripper {
+	if t == nil {
+		return http.DefaultTransport
+	}
+	return t
+}
+
+// renderEphemeralRecord writes the body of the record issue
+func renderEphemeralRecord(record *ephemeralRecord) (string, error) {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(ephemeralRunMarker + "\n")
+	fmt.Fprintf(&b, "This repository was seeded by the ephemeral project setup run `%s`. ", record.Run)
+	fmt.Fprintf(&b, "Everything listed below, and this issue, is deleted by `cleanup --run %s`, or by `cleanup --expired` after %s.\n\n", record.Run, record.Expires.Format(time.RFC3339))
+	fmt.Fprintf(&b, "```json\n%s\n```\n", data)
+	return b.String(), nil
+}
+
+// parseEphemeralRecord reads the record from the body of a record issue
+func parseEphemeralRecord(body string) (*ephemeralRecord, error) {
+	_, rest, ok := strings.Cut(body, "```json\n")
+	if !ok {
+		return nil, fmt.Errorf("no record found")
+	}
+	data, _, ok := strings.Cut(rest, "\n```")
+	if !ok {
+		return nil, fmt.Errorf("unterminated record")
+	}
+	var record ephemeralRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// writeEphemeralRecords stores the record of every repository the run created something in
+// as an issue of that repository, so any later run, e.g. a scheduled workflow, can clean up
+func (r *ephemeralRun) writeEphemeralRecords(ctx context.Context) int {
+	failures := 0
+	for t, record := range r.records {
+		body, err := renderEphemeralRecord(record)
+		if err == nil {
+			_, err = createIssue(ctx, t, IssueData{Title: "Ephemeral setup run " + r.ID, Description: body}, nil)
+		}
+		if err != nil {
+			log.Printf("Warning: could not record ephemeral run %s in %s, its entities must be deleted by hand: %v", r.ID, t, err)
+			failures++
+			continue
+		}
+		log.Printf("Recorded ephemeral run %s in %s: %d labels, %d milestones, %d issues.", r.ID, t, len(record.Labels), len(record.Milestones), len(record.Issues))
+	}
+	return failures
+}
+
+// --- Cleanup ---
+
+const deleteIssueMutation = `mutation($issue: ID!) {
+  deleteIssue(input: {issueId: $issue}) { clientMutationId }
+}`
+
+// deleteEntity deletes a label or milestone; one that is already gone counts as deleted
+func deleteEntity(ctx context.Context, what, url string) error {
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("error sending delete request for %s: %w", what, err)
+	}
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("error deleting %s: %w", what, github.NewAPIError(resp, bodyBytes))
+	}
+	return nil
+}
+
+// deleteOrCloseIssue deletes an issue, which needs admin rights; without them it is closed
+func deleteOrCloseIssue(ctx context.Context, t repoTarget, issue ephemeralIssue) error {
+	_, err := sendGraphQLQuery(ctx, deleteIssueMutation, map[string]interface{}{"issue": issue.NodeID})
+	if err == nil {
+		return nil
+	}
+	log.Printf("Could not delete issue #%d in %s (%v), closing it instead.", issue.Number, t, err)
+	return closeIssue(ctx, t, issue.Number)
+}
+
+// cleanupRecord deletes what one record lists, and the record issue itself when nothing failed
+func cleanupRecord(ctx context.Context, t repoTarget, recordIssue github.Issue, record *ephemeralRecord) []string {
+	var problems []string
+	failed := func(err error) {
+		log.Printf("%v", err)
+		problems = append(problems, err.Error())
+	}
+	log.Printf("--- Cleaning up ephemeral run %s in %s ---", record.Run, t)
+	for _, issue := range record.Issues {
+		if err := deleteOrCloseIssue(ctx, t, issue); err != nil {
+			failed(err)
+		}
+		time.Sleep(requestDelay)
+	}
+	for _, number := range record.Milestones {
+		url := fmt.Sprintf("%s/repos/%s/%s/milestones/%d", githubAPIBaseURL, t.Owner, t.Repo, number)
+		if err := deleteEntity(ctx, fmt.Sprintf("milestone #%d", number), url); err != nil {
+			failed(err)
+		}
+		time.Sleep(requestDelay)
+	}
+	for _, name := range record.Labels {
+		url := fmt.Sprintf("%s/repos/%s/%s/labels/%s", githubAPIBaseURL, t.Owner, t.Repo, neturl.PathEscape(name))
+		if err := deleteEntity(ctx, fmt.Sprintf("label '%s'", name), url); err != nil {
+			failed(err)
+		}
+		time.Sleep(requestDelay)
+	}
+	if len(problems) > 0 {
+		log.Printf("Keeping the record of run %s in %s, so the cleanup can be retried.", record.Run, t)
+		return problems
+	}
+	if err := deleteOrCloseIssue(ctx, t, ephemeralIssue{Number: recordIssue.Number, NodeID: recordIssue.NodeID}); err != nil {
+		failed(err)
+	}
+	log.Printf("Cleaned up run %s in %s: %d issues, %d milestones, %d labels.", record.Run, t, len(record.Issues), len(record.Milestones), len(record.Labels))
+	return problems
+}
+
+// findEphemeralRecords returns the open record issues of a repository with their records
+func findEphemeralRecords(ctx context.Context, t repoTarget) (map[int]*ephemeralRecord, map[int]github.Issue, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/issues?state=open&per_page=100", githubAPIBaseURL, t.Owner, t.Repo)
+	issues, err := getAllPages[github.Issue](ctx, "issues", url)
+	if err != nil {
+		return nil, nil, err
+	}
+	records := make(map[int]*ephemeralRecord)
+	byNumber := make(map[int]github.Issue)
+	for _, issue := range issues {
+		if issue.PullRequest != nil || !strings.Contains(issue.Body, ephemeralRunMarker) {
+			continue
+		}
+		record, err := parseEphemeralRecord(issue.Body)
+		if err != nil {
+			log.Printf("Warning: unreadable ephemeral run record in issue #%d of %s: %v", issue.Number, t, err)
+			continue
+		}
+		records[issue.Number] = record
+		byNumber[issue.Number] = issue
+	}
+	return records, byNumber, nil
+}
+
+// runCleanup deletes what ephemeral runs created in the target repositories: one run by id,
+// or every run whose time to live has passed
+func runCleanup(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	runID := fs.String("run", "", "ID of the ephemeral run to clean up, as logged by apply --ephemeral")
+	expired := fs.Bool("expired", false, "Clean up every ephemeral run whose --ttl has passed")
+	var shared targetFlags
+	shared.register(fs)
+	layers, err := parseArgs(fs, args)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	shared.paths.Layers = layers
+	if (*runID == "") == !*expired {
+		log.Fatal("Error: cleanup needs either --run <id> or --expired")
+	}
+	if err := shared.limits.check(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	ctx, cancel := shared.limits.start(ctx)
+	defer cancel()
+	if shared.readOnly {
+		enableReadOnly()
+	}
+	targets, err := resolveApplyTargets(ctx, shared.repos, shared.org, nil)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	now := time.Now()
+	cleaned, failures := 0, 0
+	for _, t := range targets {
+		records, issues, err := findEphemeralRecords(ctx, t)
+		if err != nil {
+			log.Printf("Error finding ephemeral runs in %s: %v", t, err)
+			failures++
+			continue
+		}
+		numbers := make([]int, 0, len(records))
+		for number := range records {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		for _, number := range numbers {
+			record := records[number]
+			if (*runID != "" && record.Run != *runID) || (*expired && now.Before(record.Expires)) {
+				continue
+			}
+			failures += len(cleanupRecord(ctx, t, issues[number], record))
+			cleaned++
+		}
+	}
+	log.Printf("Cleaned up %d ephemeral run records with %d failures.", cleaned, failures)
+	exitOnDeadline(ctx)
+	if *runID != "" && cleaned == 0 && failures == 0 {
+		log.Fatalf("Error: no record of ephemeral run %s found in the target repositories", *runID)
+	}
+	if failures > 0 {
+		log.Fatalf("Error: cleanup finished with %d failures", failures)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// The children of an epic are kept as a task list between these markers in its body.
+// GitHub derives the "tracked by" relationships from the #number references in it.
+const (
+	childTasksStart   = "<!-- project-setup:children -->"
+	childTasksEnd     = "<!-- /project-setup:children -->"
+	childTasksHeading = "## Tasks"
+)
+
+// validateEpics checks the id/parent references between issue definitions
+func validateEpics(issues []IssueData) []error {
+	var problems []error
+	ids := make(map[string]int)
+	for i, issue := range issues {
+		if issue.ID == "" {
+			continue
+		}
+		if _, exists := ids[issue.ID]; exists {
+			problems = append(problems, fmt.Errorf("issue id '%s' is used more than once", issue.ID))
+		}
+		ids[issue.ID] = i
+	}
+	for _, issue := range issues {
+		if issue.Parent == "" {
+			continue
+		}
+		if _, ok := ids[issue.Parent]; !ok {
+			problems = append(problems, fmt.Errorf("issue '%s' has unknown parent '%s'", issue.Title, issue.Parent))
+			continue
+		}
+		// Follow the parents; reaching the issue again means a cycle
+		seen := map[string]bool{issue.ID: issue.ID != ""}
+		for parent := issue.Parent; parent != ""; parent = issues[ids[parent]].Parent {
+			if seen[parent] {
+				problems = append(problems, fmt.Errorf("issue '%s' is its own ancestor via parent '%s'", issue.Title, parent))
+				break
+			}
+			seen[parent] = true
+			if _, ok := ids[parent]; !ok {
+				break
+			}
+		}
+	}
+	return problems
+}
+
+// epicChildren maps the index of every epic to the indexes of its children, in definition order
+func epicChildren(issues []IssueData) map[int][]int {
+	ids := make(map[string]int)
+	for i, issue := range issues {
+		if issue.ID != "" {
+			ids[issue.ID] = i
+		}
+	}
+	children := make(map[int][]int)
+	for i, issue := range issues {
+		if parent, ok := ids[issue.Parent]; ok && issue.Parent != "" {
+			children[parent] = append(children[parent], i)
+		}
+	}
+	return children
+}
+
+// renderChildTasks renders the task list section of an epic; closed children are checked
+func renderChildTasks(children []github.Issue) string {
+	var b strings.Builder
+	b.WriteString(childTasksStart + "\n" + childTasksHeading + "\n")
+	for _, child := range children {
+		check := " "
+		if child.State == "closed" {
+			check = "x"
+		}
+		fmt.Fprintf(&b, "\n- [%s] #%d", check, child.Number)
+	}
+	b.WriteString("\n" + childTasksEnd)
+	return b.String()
+}
+
+// replaceChildTasks swaps the task list section of body for section, appending it when missing.
+// Everything outside the markers is left as it is.
+func replaceChildTasks(body, section string) string {
+	start := strings.Index(body, childTasksStart)
+	end := strings.Index(body, childTasksEnd)
+	if start < 0 || end < start {
+		if strings.TrimSpace(body) == "" {
+			return section
+		}
+		return strings.TrimRight(body, "\n") + "\n\n" + section
+	}
+	return body[:start] + section + body[end+len(childTasksEnd):]
+}
+
+// syncEpics keeps the task list of every epic matching its children, including
+// children added to the definitions after the epic was created
+func syncEpics(ctx context.Context, run *repoRun, issues []IssueData) (entityCounts, error) {
+	var counts entityCounts
+	t := run.target
+	epics := epicChildren(issues)
+	if len(epics) == 0 {
+		return counts, nil
+	}
+	log.Printf("--- Syncing Epic Task Lists ---")
+
+	existing, err := getExistingIssues(ctx, t)
+	if err != nil {
+		return counts, fmt.Errorf("error getting existing issues: %w", err)
+	}
+	byNumber := make(map[int]github.Issue, len(existing))
+	for _, issue := range existing {
+		byNumber[issue.Number] = issue
+	}
+	// find prefers the issue created by this run, then a recorded output or run state, then the title
+	find := func(index int) (github.Issue, bool) {
+		number := run.createdIssues[index].Number
+		if output := issues[index].Output; number == 0 && output != nil && output.Repository == t.String() {
+			number = output.Number
+		}
+		if recorded, ok := run.state.issue(issues[index].Title); number == 0 && ok {
+			number = recorded.Number
+		}
+		if number == 0 {
+			issue, ok := existing[issues[index].Title]
+			return issue, ok
+		}
+		issue, ok := byNumber[number]
+		return issue, ok
+	}
+
+	for epicIndex := range issues {
+		childIndexes, isEpic := epics[epicIndex]
+		if !isEpic {
+			continue
+		}
+		title := issues[epicIndex].Title
+		epic, ok := find(epicIndex)
+		if !ok {
+			log.Printf("Warning: Epic '%s' not found in %s, its task list is not synced.", title, t)
+			continue
+		}
+		var children []github.Issue
+		for _, index := range childIndexes {
+			child, ok := find(index)
+			if !ok {
+				log.Printf("Warning: Child '%s' of epic '%s' not found in %s, left out of the task list.", issues[index].Title, title, t)
+				continue
+			}
+			children = append(children, child)
+		}
+
+		body := replaceChildTasks(epic.Body, renderChildTasks(children))
+		if sameMarkdown(body, epic.Body) {
+			log.Printf("Task list of epic #%d is up to date.", epic.Number)
+			continue
+		}
+		if err := updateIssueBody(ctx, t, epic.Number, body); err != nil {
+			run.failed("Failed to update task list of epic '%s': %v", title, err)
+			counts.Failed++
+			continue
+		}
+		log.Printf("Updated task list of epic #%d with %d children.", epic.Number, len(children))
+		counts.Updated++
+		time.Sleep(requestDelay)
+	}
+	log.Printf("Finished syncing epics. Updated %d.", counts.Updated)
+	return counts, nil
+}
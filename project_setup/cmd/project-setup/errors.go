@@ -0,0 +1,16 @@
+package main
+
+import "strings"
+
+// errorList reports several errors in one line, and errors.Is checks all of them
+type errorList []error
+
+func (l errorList) Error() string {
+	messages := make([]string, len(l))
+	for i, err := range l {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (l errorList) Unwrap() []error { return l }
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// writeDefinitionFiles writes labels.json, milestones.json and issues.json into dir. Existing
+// files are only overwritten with force, and nothing is written when one of them is in the way.
+func writeDefinitionFiles(dir string, force bool, labels []LabelData, milestones []MilestoneData, issues []IssueData) error {
+	files := map[string]interface{}{"labels.json": labels, "milestones.json": milestones, "issues.json": issues}
+	if !force {
+		for _, file := range sortedKeys(files) {
+			if p := filepath.Join(dir, file); fileExists(p) {
+				return fmt.Errorf("%s already exists, use --force to overwrite it", p)
+			}
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, file := range sortedKeys(files) {
+		if err := writeJSONFile(filepath.Join(dir, file), files[file]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportedDefinitions are the definitions read back from a repository
+type exportedDefinitions struct {
+	Labels     []LabelData
+	Milestones []MilestoneData
+	Issues     []IssueData
+}
+
+// exportRepo reads the labels, milestones and issues of a repository as definitions. Issues
+// are listed oldest first, so apply creates them in their original order; pull requests are
+// left out. Closed issues and milestones are only exported with closed, except for closed
+// milestones an exported issue belongs to. Issue bodies are split back into description and
+// acceptance criteria by exportedBody, without the fragments of fragments.
+// The output is ordered and normalized so that exporting the same repository again diffs
+// cleanly: labels by name, milestones by due date (undated last), issue labels sorted,
+// lower-case colors and bodies passed through normalizeMarkdown.
+func exportRepo(ctx context.Context, t repoTarget, closed bool, fragments BodyTemplate) (*exportedDefinitions, error) {
+	labels, err := repoProvider(t).ListLabels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting labels: %w", err)
+	}
+	milestones, err := repoProvider(t).ListMilestones(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting milestones: %w", err)
+	}
+	state := "open"
+	if closed {
+		state = "all"
+	}
+	url := fmt.Sprintf("%s/repos/%s/%s/issues?state=%s&sort=created&direction=asc&per_page=100", githubAPIBaseURL, t.Owner, t.Repo, state)
+	issues, err := getAllPages[github.Issue](ctx, "issues", url)
+	if err != nil {
+		return nil, fmt.Errorf("error getting issues: %w", err)
+	}
+
+	defs := &exportedDefinitions{Labels: []LabelData{}, Milestones: []MilestoneData{}, Issues: []IssueData{}}
+	for _, l := range labels {
+		defs.Labels = append(defs.Labels, LabelData{Name: l.Name, Description: l.Description, Color: strings.ToLower(l.Color)})
+	}
+	sort.SliceStable(defs.Labels, func(i, j int) bool {
+		return strings.ToLower(defs.Labels[i].Name) < strings.ToLower(defs.Labels[j].Name)
+	})
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Number < issues[j].Number })
+	usedMilestones := make(map[int]bool)
+	for _, issue := range issues {
+		if issue.PullRequest != nil {
+			continue
+		}
+		exported := IssueData{Title: issue.Title, Labels: []string{}}
+		exported.Description, exported.AcceptanceCriteria = exportedBody(issue.Body, fragments)
+		for _, l := range issue.Labels {
+			exported.Labels = append(exported.Labels, l.Name)
+		}
+		sort.Strings(exported.Labels)
+		for _, a := range issue.Assignees {
+			exported.Assignees = append(exported.Assignees, a.Login)
+		}
+		if issue.Milestone != nil {
+			title := issue.Milestone.Title
+			exported.MilestoneTitle = &title
+			usedMilestones[issue.Milestone.ID] = true
+		}
+		defs.Issues = append(defs.Issues, exported)
+	}
+	sort.SliceStable(milestones, func(i, j int) bool {
+		a, b := milestones[i], milestones[j]
+		if (a.DueOn == nil) != (b.DueOn == nil) {
+			return b.DueOn == nil // Undated milestones last
+		}
+		if a.DueOn != nil && *a.DueOn != *b.DueOn {
+			return *a.DueOn < *b.DueOn
+		}
+		return a.Title < b.Title
+	})
+	for _, m := range milestones {
+		if m.State != "closed" || closed || usedMilestones[m.ID] {
+			defs.Milestones = append(defs.Milestones, MilestoneData{Title: m.Title, Description: normalizeMarkdown(m.Description), DueOn: m.DueOn})
+		}
+	}
+	return defs, nil
+}
+
+// templateAction matches the actions of a templated body fragment
+var templateAction = regexp.MustCompile(`(?s)\{\{.*?\}\}`)
+
+// exportedBody turns the body of an existing issue back into the description and acceptance
+// criteria of its definition: the attribution footer and the header and footer fragments
+// are removed, and the acceptance criteria section becomes a list again. A body that does
+// not end in such a section is exported as description as it is.
+func exportedBody(body string, fragments BodyTemplate) (string, []string) {
+	body = strings.TrimRight(withoutAttribution(normalizeMarkdown(body)), "\n")
+	if pattern := fragmentPattern(fragments.Footer, fragments.Templates); pattern != "" {
+		// The footer is the shortest suffix of whole lines that matches
+		footer := regexp.MustCompile("^" + pattern + `\z`)
+		for i := len(body); i >= 0; i-- {
+			if (i == 0 || body[i-1] == '\n') && footer.MatchString(body[i:]) {
+				body = body[:i]
+				break
+			}
+		}
+	}
+	if pattern := fragmentPattern(fragments.Header, fragments.Templates); pattern != "" {
+		if loc := regexp.MustCompile("^" + pattern + `(?:\n|\z)`).FindStringIndex(body); loc != nil {
+			body = body[loc[1]:]
+		}
+	}
+	return splitAcceptanceCriteria(strings.Trim(body, "\n"))
+}
+
+// fragmentPattern is a regular expression matching a header or footer fragment as
+// renderIssueBody wrote it, "" for none. Actions of templated fragments match any text, as
+// they rendered to the values of the target.
+func fragmentPattern(fragment string, templates bool) string {
+	fragment = normalizeMarkdown(fragment)
+	if fragment == "" {
+		return ""
+	}
+	pieces := []string{fragment}
+	if templates {
+		pieces = templateAction.Split(fragment, -1)
+	}
+	for i := range pieces {
+		pieces[i] = regexp.QuoteMeta(pieces[i])
+	}
+	return strings.Join(pieces, "(?s:.*?)")
+}
+
+// splitAcceptanceCriteria takes the section renderAcceptanceCriteria writes off the end of
+// a body. Criteria ticked off on GitHub are read like open ones.
+func splitAcceptanceCriteria(body string) (string, []string) {
+	start := strings.LastIndex(body, "\n"+acceptanceCriteriaHeading+"\n") + 1
+	if start == 0 && !strings.HasPrefix(body, acceptanceCriteriaHeading+"\n") {
+		return body, nil
+	}
+	var criteria []string
+	for _, line := range strings.Split(body[start+len(acceptanceCriteriaHeading):], "\n") {
+		if line == "" {
+			continue
+		}
+		criterion, ok := cutTaskListItem(line)
+		if !ok {
+			return body, nil // Not a section this tool wrote
+		}
+		criteria = append(criteria, criterion)
+	}
+	if len(criteria) == 0 {
+		return body, nil
+	}
+	return strings.TrimRight(body[:start], "\n"), criteria
+}
+
+// cutTaskListItem returns the text of a "- [ ] text" or "- [x] text" line
+func cutTaskListItem(line string) (string, bool) {
+	for _, box := range []string{"- [ ] ", "- [x] ", "- [X] "} {
+		if text, ok := strings.CutPrefix(line, box); ok && strings.TrimSpace(text) != "" {
+			return strings.TrimSpace(text), true
+		}
+	}
+	return "", false
+}
+
+// exportCommand is a parsed "export" command line
+type exportCommand struct {
+	repo   string
+	out    string
+	closed bool
+	force  bool
+	tenant *Tenant // Set for JSON-RPC requests of a tenant
+}
+
+// exportResult is what export wrote, also the response of the JSON-RPC method
+type exportResult struct {
+	Repo       string          `json:"repo"`
+	Out        string          `json:"out"`
+	Labels     []LabelData     `json:"labels"`
+	Milestones []MilestoneData `json:"milestones"`
+	Issues     []IssueData     `json:"issues"`
+}
+
+// parseExportArgs parses the arguments of export; with flag.ContinueOnError, usage errors
+// are returned instead of printed, for callers that are not a terminal
+func parseExportArgs(args []string, handling flag.ErrorHandling) (*exportCommand, error) {
+	c := &exportCommand{}
+	fs := flag.NewFlagSet("export", handling)
+	fs.StringVar(&c.repo, "repo", os.Getenv("GITHUB_REPOSITORY"), "Repository to export as owner/repo, URL or SSH remote (defaults to GITHUB_REPOSITORY)")
+	fs.StringVar(&c.out, "out", ".", "Directory to write labels.json, milestones.json and issues.json to")
+	fs.BoolVar(&c.closed, "closed", false, "Also export closed issues and milestones; apply creates them open")
+	fs.BoolVar(&c.force, "force", false, "Overwrite definition files that already exist in --out")
+	if handling == flag.ContinueOnError {
+		fs.SetOutput(io.Discard)
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(positional) > 0 || c.repo == "" {
+		return nil, fmt.Errorf("usage: export --repo owner/repo [--out <directory>] [--closed] [--force]")
+	}
+	return c, nil
+}
+
+// execute exports the repository and writes its definition files
+func (c *exportCommand) execute(ctx context.Context) (*exportResult, error) {
+	t, host, err := parseRepoReference(c.repo)
+	if err != nil {
+		return nil, err
+	}
+	if host != "" {
+		if err := c.tenant.checkAPIHost(host); err != nil {
+			return nil, err
+		}
+		useAPIHost(host)
+	}
+	if err := c.tenant.checkTargetOwners([]repoTarget{t}); err != nil {
+		return nil, err
+	}
+	if !c.force {
+		// Checked before fetching anything; writeDefinitionFiles checks again
+		for _, file := range []string{"labels.json", "milestones.json", "issues.json"} {
+			if p := filepath.Join(c.out, file); fileExists(p) {
+				return nil, fmt.Errorf("%s already exists, use --force to overwrite it", p)
+			}
+		}
+	}
+
+	// The fragments of the working directory's config.json are cut off the issue bodies
+	cfg, err := loadConfig(configJSONPath)
+	if err != nil {
+		return nil, err
+	}
+	defs, err := exportRepo(ctx, t, c.closed, cfg.IssueBody)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting %s: %w", t, err)
+	}
+	if err := writeDefinitionFiles(c.out, c.force, defs.Labels, defs.Milestones, defs.Issues); err != nil {
+		return nil, err
+	}
+	log.Printf("Exported %d labels, %d milestones and %d issues of %s into %s.", len(defs.Labels), len(defs.Milestones), len(defs.Issues), t, c.out)
+	return &exportResult{Repo: t.String(), Out: c.out, Labels: defs.Labels, Milestones: defs.Milestones, Issues: defs.Issues}, nil
+}
+
+// runExport runs "export", the reverse of apply: it writes the labels, milestones and open
+// issues of an existing repository as definitions, so a mature project can be the template
+// of new ones
+func runExport(ctx context.Context, args []string) {
+	c, err := parseExportArgs(args, flag.ExitOnError)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if _, err := c.execute(ctx); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
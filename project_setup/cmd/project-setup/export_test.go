@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExportedBody(t *testing.T) {
+	fragments := BodyTemplate{Header: "**Team:** platform\n", Footer: "---\nHow to work this ticket"}
+	templated := BodyTemplate{Header: "Owned by {{.Vars.team}}", Footer: "See {{.Repo.HTMLURL}}/wiki", Templates: true}
+	attribution := renderAttribution("setup-bot", "https://github.com/acme/templates", "0123456789abcdef", "abc123", "")
+	tests := []struct {
+		name            string
+		body            string
+		fragments       BodyTemplate
+		wantDescription string
+		wantCriteria    []string
+	}{
+		{
+			name:            "plain body",
+			body:            "Set up CI.\r\n\r\n\r\nThen deploy.  ",
+			wantDescription: "Set up CI.\n\nThen deploy.",
+		},
+		{
+			name:            "fragments and attribution",
+			body:            "**Team:** platform\n\nSet up CI.\n\n---\nHow to work this ticket\n\n" + attribution,
+			fragments:       fragments,
+			wantDescription: "Set up CI.",
+		},
+		{
+			name:            "acceptance criteria",
+			body:            "**Team:** platform\n\nSet up CI.\n\n## Acceptance Criteria\n\n- [ ] Builds on push\n- [x] Lint fails the build\n\n---\nHow to work this ticket",
+			fragments:       fragments,
+			wantDescription: "Set up CI.",
+			wantCriteria:    []string{"Builds on push", "Lint fails the build"},
+		},
+		{
+			name:         "only acceptance criteria",
+			body:         "## Acceptance Criteria\n\n- [ ] Builds",
+			wantCriteria: []string{"Builds"},
+		},
+		{
+			name:            "section with other content is description",
+			body:            "Intro\n\n## Acceptance Criteria\n\n- [ ] Builds\n\nMore notes",
+			wantDescription: "Intro\n\n## Acceptance Criteria\n\n- [ ] Builds\n\nMore notes",
+		},
+		{
+			name:            "edited fragments are kept",
+			body:            "**Team:** payments\n\nSet up CI.\n\n---\nHow to work this ticket, updated",
+			fragments:       fragments,
+			wantDescription: "**Team:** payments\n\nSet up CI.\n\n---\nHow to work this ticket, updated",
+		},
+		{
+			name:            "horizontal rule in the description",
+			body:            "Before\n\n---\nAfter\n\n---\nHow to work this ticket",
+			fragments:       fragments,
+			wantDescription: "Before\n\n---\nAfter",
+		},
+		{
+			name:            "templated fragments",
+			body:            "Owned by payments\n\nSet up CI.\n\nSee https://github.com/acme/web/wiki",
+			fragments:       templated,
+			wantDescription: "Set up CI.",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			description, criteria := exportedBody(tc.body, tc.fragments)
+			if description != tc.wantDescription || !reflect.DeepEqual(criteria, tc.wantCriteria) {
+				t.Errorf("exportedBody() = %q, %q; want %q, %q", description, criteria, tc.wantDescription, tc.wantCriteria)
+			}
+		})
+	}
+}
+
+func TestExportedBodyRoundTrip(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.IssueBody = BodyTemplate{Header: "> Part of the platform backlog", Footer: "_Questions? Ask in #platform._"}
+	config.IssueBody.attribution = renderOnBehalfOf("setup-bot", "alice")
+	issue := IssueData{
+		Description:        "Set up CI for the `web` service.\n\n```yaml\non: push\n```",
+		AcceptanceCriteria: []string{"Builds on push", "Runs the tests"},
+	}
+	description, criteria := exportedBody(renderIssueBody(issue), config.IssueBody)
+	if description != issue.Description || !reflect.DeepEqual(criteria, issue.AcceptanceCriteria) {
+		t.Errorf("exportedBody(renderIssueBody()) = %q, %q; want %q, %q", description, criteria, issue.Description, issue.AcceptanceCriteria)
+	}
+}
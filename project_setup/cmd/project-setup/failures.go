@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// failureRecord is one failed entity or phase of a run, kept for the failure report at the
+// end, so failures need not be looked for in hundreds of log lines
+type failureRecord struct {
+	Repo      string `json:"repo"`
+	Message   string `json:"message"`
+	Status    int    `json:"status,omitempty"`     // HTTP status of the failed request
+	RequestID string `json:"request_id,omitempty"` // X-GitHub-Request-Id, which GitHub support asks for
+	Body      string `json:"body,omitempty"`       // Response body, redacted
+}
+
+// newFailureRecord records a failure; the details of the request come from an github.APIError
+// among args, if there is one
+func newFailureRecord(repo, message string, args []interface{}) failureRecord {
+	record := failureRecord{Repo: repo, Message: message}
+	for _, arg := range args {
+		var apiErr *github.APIError
+		if err, ok := arg.(error); ok && errors.As(err, &apiErr) {
+			record.Status, record.RequestID, record.Body = apiErr.StatusCode, apiErr.RequestID, apiErr.Body
+			break
+		}
+	}
+	return record
+}
+
+// writeFailureReport lists every failure of the run, grouped by repository in the order
+// they were given
+func writeFailureReport(w io.Writer, failures []failureRecord) {
+	if len(failures) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "=== Failures (%d) ===\n", len(failures))
+	var repo string
+	for i, failure := range failures {
+		if i == 0 || failure.Repo != repo {
+			repo = failure.Repo
+			fmt.Fprintf(w, "%s:\n", repo)
+		}
+		fmt.Fprintf(w, "  - %s\n", failure.Message)
+		if failure.RequestID != "" {
+			fmt.Fprintf(w, "    request ID: %s\n", failure.RequestID)
+		}
+	}
+}
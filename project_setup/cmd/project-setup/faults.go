@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// faultsEnv enables fault injection for resilience testing, e.g.
+// PROJECT_SETUP_FAULTS="error=0.1,rate-limit=0.05,slow=0.2,delay=3s,seed=42".
+// Rates are probabilities per request; the faults are produced in the HTTP client,
+// so they work against GitHub, GHES or a local mock server alike.
+const faultsEnv = "PROJECT_SETUP_FAULTS"
+
+// faultConfig holds the probabilities of each injected fault
+type faultConfig struct {
+	ErrorRate     float64       // Answer with a 500 without sending the request
+	RateLimitRate float64       // Answer with a 403 rate limit response without sending the request
+	SlowRate      float64       // Send the request after Delay
+	Delay         time.Duration // Delay of slow requests
+	Seed          int64         // Fixed seed for reproducible runs, 0 for a random one
+}
+
+// parseFaultConfig parses the value of faultsEnv
+func parseFaultConfig(value string) (faultConfig, error) {
+	cfg := faultConfig{Delay: 2 * time.Second}
+	for _, part := range strings.Split(value, ",") {
+		name, raw, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return cfg, fmt.Errorf("invalid %s entry %q, expected name=value", faultsEnv, part)
+		}
+		var err error
+		switch name {
+		case "error":
+			cfg.ErrorRate, err = parseFaultRate(raw)
+		case "rate-limit":
+			cfg.RateLimitRate, err = parseFaultRate(raw)
+		case "slow":
+			cfg.SlowRate, err = parseFaultRate(raw)
+		case "delay":
+			cfg.Delay, err = time.ParseDuration(raw)
+		case "seed":
+			cfg.Seed, err = strconv.ParseInt(raw, 10, 64)
+		default:
+			err = fmt.Errorf("unknown fault %q, expected error, rate-limit, slow, delay or seed", name)
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s: %w", faultsEnv, err)
+		}
+	}
+	return cfg, nil
+}
+
+func parseFaultRate(raw string) (float64, error) {
+	rate, err := strconv.ParseFloat(raw, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("rate %q must be between 0 and 1", raw)
+	}
+	return rate, nil
+}
+
+// faultTransport injects faults into the requests of an http.Client
+type faultTransport struct {
+	next http.RoundTripper
+	cfg  faultConfig
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newFaultTransport(next http.RoundTripper, cfg faultConfig) *faultTransport {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	log.Printf("Fault injection enabled: error=%.2f rate-limit=%.2f slow=%.2f delay=%s seed=%d.",
+		cfg.ErrorRate, cfg.RateLimitRate, cfg.SlowRate, cfg.Delay, seed)
+	return &faultTransport{next: next, cfg: cfg, rand: rand.New(rand.NewSource(seed))}
+}
+
+// roll draws one number per request, so each fault gets its own slice of [0, 1)
+func (f *faultTransport) roll() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rand.Float64()
+}
+
+func (f *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	roll := f.roll()
+	switch {
+	case roll < f.cfg.ErrorRate:
+		log.Printf("Injected fault: 500 for %s %s", req.Method, req.URL)
+		return injectedResponse(req, http.StatusInternalServerError, `{"message":"Server Error (injected)"}`, nil), nil
+	case roll < f.cfg.ErrorRate+f.cfg.RateLimitRate:
+		log.Printf("Injected fault: rate limit for %s %s", req.Method, req.URL)
+		headers := http.Header{
+			"X-Ratelimit-Remaining": {"0"},
+			"X-Ratelimit-Reset":     {strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)},
+		}
+		return injectedResponse(req, http.StatusForbidden, `{"message":"API rate limit exceeded (injected)"}`, headers), nil
+	case roll < f.cfg.ErrorRate+f.cfg.RateLimitRate+f.cfg.SlowRate:
+		log.Printf("Injected fault: %s delay for %s %s", f.cfg.Delay, req.Method, req.URL)
+		select {
+		case <-time.After(f.cfg.Delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	return f.next.RoundTrip(req)
+}
+
+// injectedResponse builds a response that never reached the server
+func injectedResponse(req *http.Request, status int, body string, headers http.Header) *http.Response {
+	if headers == nil {
+		headers = http.Header{}
+	}
+	headers.Set("Content-Type", "application/json")
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        headers,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// FileData describes a file committed to the repository, as listed in config.json
+type FileData struct {
+	Path    string `json:"path"`              // Destination path in the repository
+	Source  string `json:"source,omitempty"`  // Local file providing the content
+	Content string `json:"content,omitempty"` // Inline content, used when no source is given
+}
+
+// CommitConfig controls how files are committed. Without a branch the files are
+// committed straight to the default branch.
+type CommitConfig struct {
+	Message     string            `json:"message,omitempty"`
+	Branch      string            `json:"branch,omitempty"`       // Commit to this branch and open a pull request
+	PullRequest PullRequestConfig `json:"pull_request,omitempty"` // Only used together with branch
+}
+
+// PullRequestConfig describes the pull request opened for a commit branch
+type PullRequestConfig struct {
+	Title         string   `json:"title,omitempty"`
+	Body          string   `json:"body,omitempty"`
+	Reviewers     []string `json:"reviewers,omitempty"`      // User logins
+	TeamReviewers []string `json:"team_reviewers,omitempty"` // Team slugs
+}
+
+const defaultCommitMessage = "Project setup: add repository files"
+
+// --- Structs for GitHub Git Data API Payloads & Responses ---
+
+// GitHubRefResponse represents a git reference
+type GitHubRefResponse struct {
+	Ref    string `json:"ref"`
+	Object struct {
+		SHA string `json:"sha"`
+	} `json:"object"`
+}
+
+// GitHubCommitResponse represents a git commit
+type GitHubCommitResponse struct {
+	SHA  string `json:"sha"`
+	Tree struct {
+		SHA string `json:"sha"`
+	} `json:"tree"`
+}
+
+// GitHubTreeEntry is a single file in a tree creation request
+type GitHubTreeEntry struct {
+	Path    string `json:"path"`
+	Mode    string `json:"mode"`
+	Type    string `json:"type"`
+	Content string `json:"content"`
+}
+
+// GitHubTreeRequest is the payload for creating a tree, usually on top of a base tree
+type GitHubTreeRequest struct {
+	BaseTree string            `json:"base_tree,omitempty"` // Empty for a tree of only the given entries
+	Tree     []GitHubTreeEntry `json:"tree"`
+}
+
+// GitHubCommitRequest is the payload for creating a commit
+type GitHubCommitRequest struct {
+	Message string   `json:"message"`
+	Tree    string   `json:"tree"`
+	Parents []string `json:"parents"`
+}
+
+// GitHubPullRequestRequest is the payload for opening a pull request
+type GitHubPullRequestRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+}
+
+// GitHubPullRequestResponse represents a pull request
+type GitHubPullRequestResponse struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+}
+
+// GitHubReviewersRequest is the payload for requesting pull request reviews
+type GitHubReviewersRequest struct {
+	Reviewers     []string `json:"reviewers,omitempty"`
+	TeamReviewers []string `json:"team_reviewers,omitempty"`
+}
+
+// --- Git Data API Helpers ---
+
+// getRepository fetches the target repository
+func getRepository(ctx context.Context, t repoTarget) (github.Repository, error) {
+	var repository github.Repository
+	url := fmt.Sprintf("%s/repos/%s/%s", githubAPIBaseURL, t.Owner, t.Repo)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return repository, fmt.Errorf("error fetching repository %s: %w", t, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return repository, fmt.Errorf("error fetching repository %s: %w", t, github.NewAPIError(resp, bodyBytes))
+	}
+	if err := json.Unmarshal(bodyBytes, &repository); err != nil {
+		return repository, fmt.Errorf("error unmarshalling repository %s: %w", t, err)
+	}
+	return repository, nil
+}
+
+// getBranchHead returns the commit SHA a branch points to; found is false if the branch does not exist
+func getBranchHead(ctx context.Context, t repoTarget, branch string) (sha string, found bool, err error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/ref/heads/%s", githubAPIBaseURL, t.Owner, t.Repo, branch)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("error fetching branch '%s': %w", branch, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("error fetching branch '%s': %w", branch, github.NewAPIError(resp, bodyBytes))
+	}
+	var ref GitHubRefResponse
+	if err := json.Unmarshal(bodyBytes, &ref); err != nil {
+		return "", false, fmt.Errorf("error unmarshalling branch '%s': %w", branch, err)
+	}
+	return ref.Object.SHA, true, nil
+}
+
+// GitHubContentResponse is a file returned by the contents API
+type GitHubContentResponse struct {
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
+}
+
+// getFileContent reads a file of the repository at ref, the default branch when ref is empty;
+// found is false if the file does not exist
+func getFileContent(ctx context.Context, t repoTarget, path, ref string) (data []byte, found bool, err error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", githubAPIBaseURL, t.Owner, t.Repo, path)
+	if ref != "" {
+		url += "?ref=" + neturl.QueryEscape(ref)
+	}
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("error fetching file %s: %w", path, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("error fetching file %s: %w", path, github.NewAPIError(resp, bodyBytes))
+	}
+	var content GitHubContentResponse
+	if err := json.Unmarshal(bodyBytes, &content); err != nil {
+		return nil, false, fmt.Errorf("error unmarshalling file %s: %w", path, err)
+	}
+	data, err = base64.StdEncoding.DecodeString(strings.ReplaceAll(content.Content, "\n", ""))
+	if err != nil {
+		return nil, false, fmt.Errorf("error decoding file %s: %w", path, err)
+	}
+	return data, true, nil
+}
+
+// postGitObject sends a Git Data API creation request and decodes the response into out
+func postGitObject(ctx context.Context, t repoTarget, what, path string, payload, out interface{}) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/%s", githubAPIBaseURL, t.Owner, t.Repo, path)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "POST", url, payload)
+	if err != nil {
+		return fmt.Errorf("error sending create %s request: %w", what, err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("error creating %s: %w", what, github.NewAPIError(resp, bodyBytes))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(bodyBytes, out); err != nil {
+		return fmt.Errorf("error unmarshalling created %s: %w", what, err)
+	}
+	return nil
+}
+
+// updateBranch moves an existing branch to the given commit
+func updateBranch(ctx context.Context, t repoTarget, branch, sha string) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/refs/heads/%s", githubAPIBaseURL, t.Owner, t.Repo, branch)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "PATCH", url, map[string]string{"sha": sha})
+	if err != nil {
+		return fmt.Errorf("error sending update branch request for '%s': %w", branch, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error updating branch '%s': %w", branch, github.NewAPIError(resp, bodyBytes))
+	}
+	return nil
+}
+
+// findOpenPullRequest returns the open pull request from branch into base, if any
+func findOpenPullRequest(ctx context.Context, t repoTarget, branch, base string) (*GitHubPullRequestResponse, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/pulls?state=open&head=%s:%s&base=%s", githubAPIBaseURL, t.Owner, t.Repo, t.Owner, branch, base)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching pull requests for '%s': %w", branch, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching pull requests for '%s': %w", branch, github.NewAPIError(resp, bodyBytes))
+	}
+	var pulls []GitHubPullRequestResponse
+	if err := json.Unmarshal(bodyBytes, &pulls); err != nil {
+		return nil, fmt.Errorf("error unmarshalling pull requests for '%s': %w", branch, err)
+	}
+	if len(pulls) == 0 {
+		return nil, nil
+	}
+	return &pulls[0], nil
+}
+
+// openPullRequest opens a pull request for branch and requests the configured reviews
+func openPullRequest(ctx context.Context, t repoTarget, branch, base string, cfg PullRequestConfig, message string) (GitHubPullRequestResponse, error) {
+	var pull GitHubPullRequestResponse
+	title := cfg.Title
+	if title == "" {
+		title = message
+	}
+	url := fmt.Sprintf("%s/repos/%s/%s/pulls", githubAPIBaseURL, t.Owner, t.Repo)
+	payload := GitHubPullRequestRequest{Title: title, Body: cfg.Body, Head: branch, Base: base}
+
+	log.Printf("Attempting to open pull request: \"%s\" (%s -> %s)", title, branch, base)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "POST", url, payload)
+	if err != nil {
+		return pull, fmt.Errorf("error sending create pull request request for '%s': %w", branch, err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return pull, fmt.Errorf("error creating pull request for '%s': %w", branch, github.NewAPIError(resp, bodyBytes))
+	}
+	if err := json.Unmarshal(bodyBytes, &pull); err != nil {
+		return pull, fmt.Errorf("error unmarshalling created pull request for '%s': %w", branch, err)
+	}
+	log.Printf("Successfully opened pull request #%d: %s", pull.Number, pull.HTMLURL)
+
+	if len(cfg.Reviewers) == 0 && len(cfg.TeamReviewers) == 0 {
+		return pull, nil
+	}
+	url = fmt.Sprintf("%s/repos/%s/%s/pulls/%d/requested_reviewers", githubAPIBaseURL, t.Owner, t.Repo, pull.Number)
+	reviewers := GitHubReviewersRequest{Reviewers: cfg.Reviewers, TeamReviewers: cfg.TeamReviewers}
+	resp, bodyBytes, err = sendGitHubRequest(ctx, "POST", url, reviewers)
+	if err != nil {
+		return pull, fmt.Errorf("error sending review request for pull request #%d: %w", pull.Number, err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return pull, fmt.Errorf("error requesting reviews for pull request #%d: %w", pull.Number, github.NewAPIError(resp, bodyBytes))
+	}
+	log.Printf("Requested reviews on pull request #%d from %s.", pull.Number, strings.Join(append(cfg.Reviewers, cfg.TeamReviewers...), ", "))
+	return pull, nil
+}
+
+// --- File Committing ---
+
+// commitFiles writes all files in a single commit, either to the default branch or,
+// when cfg.Branch is set, to that branch with a pull request into the default branch.
+// It returns false when the files already match the repository content.
+func commitFiles(ctx context.Context, t repoTarget, files map[string]string, cfg CommitConfig) (bool, error) {
+	if len(files) == 0 {
+		return false, nil
+	}
+	message := cfg.Message
+	if message == "" {
+		message = defaultCommitMessage
+	}
+
+	repository, err := getRepository(ctx, t)
+	if err != nil {
+		return false, err
+	}
+	base := repository.DefaultBranch
+	target := base
+	if cfg.Branch != "" {
+		target = cfg.Branch
+	}
+
+	// Build on top of the target branch if it already exists, so reruns add to it
+	parentSHA, targetExists, err := getBranchHead(ctx, t, target)
+	if err != nil {
+		return false, err
+	}
+	if !targetExists {
+		if target == base {
+			return false, fmt.Errorf("error: default branch '%s' not found, is the repository empty?", base)
+		}
+		if parentSHA, _, err = getBranchHead(ctx, t, base); err != nil {
+			return false, err
+		}
+	}
+
+	var parent GitHubCommitResponse
+	url := fmt.Sprintf("%s/repos/%s/%s/git/commits/%s", githubAPIBaseURL, t.Owner, t.Repo, parentSHA)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return false, fmt.Errorf("error fetching commit %s: %w", parentSHA, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("error fetching commit %s: %w", parentSHA, github.NewAPIError(resp, bodyBytes))
+	}
+	if err := json.Unmarshal(bodyBytes, &parent); err != nil {
+		return false, fmt.Errorf("error unmarshalling commit %s: %w", parentSHA, err)
+	}
+
+	treeRequest := GitHubTreeRequest{BaseTree: parent.Tree.SHA}
+	for path, content := range files {
+		treeRequest.Tree = append(treeRequest.Tree, GitHubTreeEntry{Path: path, Mode: "100644", Type: "blob", Content: content})
+	}
+	var tree struct {
+		SHA string `json:"sha"`
+	}
+	if err := postGitObject(ctx, t, "tree", "trees", treeRequest, &tree); err != nil {
+		return false, err
+	}
+	if tree.SHA == parent.Tree.SHA {
+		log.Printf("Files are already up to date on branch '%s', nothing to commit.", target)
+		return false, nil
+	}
+
+	var commit GitHubCommitResponse
+	commitRequest := GitHubCommitRequest{Message: message, Tree: tree.SHA, Parents: []string{parentSHA}}
+	if err := postGitObject(ctx, t, "commit", "commits", commitRequest, &commit); err != nil {
+		return false, err
+	}
+
+	if targetExists {
+		err = updateBranch(ctx, t, target, commit.SHA)
+	} else {
+		err = postGitObject(ctx, t, "branch", "refs", map[string]string{"ref": "refs/heads/" + target, "sha": commit.SHA}, nil)
+	}
+	if err != nil {
+		return false, err
+	}
+	log.Printf("Committed %d file(s) to branch '%s' (%s).", len(files), target, commit.SHA)
+
+	if target == base {
+		return true, nil
+	}
+	existing, err := findOpenPullRequest(ctx, t, target, base)
+	if err != nil {
+		return true, err
+	}
+	if existing != nil {
+		log.Printf("Pull request #%d already open for branch '%s': %s", existing.Number, target, existing.HTMLURL)
+		return true, nil
+	}
+	_, err = openPullRequest(ctx, t, target, base, cfg.PullRequest, message)
+	return true, err
+}
+
+// processFiles commits the files listed in config.json
+func processFiles(ctx context.Context, t repoTarget, files map[string]string) (entityCounts, error) {
+	var counts entityCounts
+	log.Printf("--- Processing Files from %s ---", configJSONPath)
+
+	committed, err := commitFiles(ctx, t, files, config.Commit)
+	if err != nil {
+		counts.Failed = len(files)
+		return counts, err
+	}
+	if !committed {
+		return counts, nil
+	}
+	counts.Created = len(files)
+	log.Printf("Finished processing files. Committed %d files.", len(files))
+	return counts, nil
+}
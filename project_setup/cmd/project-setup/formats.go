@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Label, milestone, issue and component definition files may also be written as YAML
+// (.yaml, .yml) or TOML (.toml), which are easier to maintain by hand for long multi-line
+// issue bodies. They are decoded with decodeTree into the same structs, so every key is
+// named as in JSON.
+
+// definitionFormat returns "YAML" or "TOML" for a definition file in one of these formats,
+// and "" for JSON
+func definitionFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "YAML"
+	case ".toml":
+		return "TOML"
+	}
+	return ""
+}
+
+// definitionExtensions are the extensions a definition file is looked for with, in order
+var definitionExtensions = []string{".json", ".yaml", ".yml", ".toml"}
+
+// findDefinitionFile returns the path of the definition file named like name (e.g.
+// labels.json) in dir, in any of the formats; ok is false when there is none
+func findDefinitionFile(dir, name string) (path string, ok bool) {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	for _, ext := range definitionExtensions {
+		if p := filepath.Join(dir, base+ext); fileExists(p) {
+			return p, true
+		}
+	}
+	return filepath.Join(dir, name), false
+}
+
+// resolveFormats replaces the default definition files that do not exist with a YAML or
+// TOML file of the same name, e.g. issues.yaml when there is no issues.json
+func (p *definitionPaths) resolveFormats() {
+	for _, f := range []struct {
+		path        *string
+		defaultPath string
+	}{{&p.Labels, labelsJSONPath}, {&p.Milestones, milestonesJSONPath}, {&p.Issues, issuesJSONPath}, {&p.Components, componentsJSONPath}} {
+		if *f.path == f.defaultPath {
+			*f.path, _ = findDefinitionFile(".", f.defaultPath)
+		}
+	}
+}
+
+// stdinSniffSize is how much of stdin sourceFormat looks at for its first line
+const stdinSniffSize = 64 << 10
+
+// stdin is standard input as read for "-", buffered so that sourceFormat can look at it
+// before it is decoded
+var stdin = bufio.NewReaderSize(os.Stdin, stdinSniffSize)
+
+// sourceFormat is definitionFormat for a definition file that may be stdin. Stdin has no
+// extension, so its format is told from its first line that is neither blank nor a #
+// comment: [[ starts TOML, [, { and // start JSON, and anything else is YAML.
+func sourceFormat(path string) string {
+	if path != stdinPath {
+		return definitionFormat(path)
+	}
+	data, _ := stdin.Peek(stdinSniffSize)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(line, "\ufeff"))
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "[["):
+			return "TOML"
+		case strings.HasPrefix(line, "[") || strings.HasPrefix(line, "{") || strings.HasPrefix(line, "/"):
+			return ""
+		}
+		return "YAML"
+	}
+	return ""
+}
+
+// decodeDefinitionFile reads a YAML or TOML definition file, or stdin for "-", in the
+// given format. Like JSON files, it may be encrypted with SOPS. A TOML file holds its
+// definitions in the array of tables named after their kind, e.g. [[labels]].
+func decodeDefinitionFile[T any](path, format, kind string) ([]T, error) {
+	data, err := readDefinition(path)
+	if err != nil {
+		return nil, err
+	}
+	var definitions []T
+	if format == "TOML" {
+		err = decodeTOML(data, kind, &definitions)
+	} else {
+		err = decodeYAML(data, &definitions)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", path, err)
+	}
+	return definitions, nil
+}
+
+// checkRewritable refuses to rewrite a YAML or TOML definition file as JSON; purpose names
+// the option that would write it
+func checkRewritable(path, purpose string) error {
+	if format := definitionFormat(path); format != "" {
+		return fmt.Errorf("%s is %s, %s only writes JSON definition files", path, format, purpose)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSourceFormat(t *testing.T) {
+	tests := []struct {
+		name, path, stdin, want string
+	}{
+		{"YAML file", "issues.yml", "", "YAML"},
+		{"TOML file", "labels.toml", "", "TOML"},
+		{"JSON file", "issues.json", "", ""},
+		{"JSON on stdin", "-", "\n[\n  {\"name\": \"bug\"}\n]\n", ""},
+		{"JSONC on stdin", "-", "// Labels\n[]\n", ""},
+		{"SOPS JSON on stdin", "-", "{\"data\": \"...\", \"sops\": {}}\n", ""},
+		{"YAML on stdin", "-", "# Labels\n\n- name: bug\n", "YAML"},
+		{"SOPS YAML on stdin", "-", "data: ENC[AES256_GCM,data:abc,type:str]\nsops:\n  mac: x\n", "YAML"},
+		{"TOML on stdin", "-", "# Labels\n[[labels]]\nname = \"bug\"\n", "TOML"},
+		{"empty stdin", "-", "", ""},
+	}
+	saved := stdin
+	t.Cleanup(func() { stdin = saved })
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stdin = bufio.NewReaderSize(strings.NewReader(tc.stdin), stdinSniffSize)
+			if got := sourceFormat(tc.path); got != tc.want {
+				t.Errorf("sourceFormat(%q) = %q, want %q", tc.path, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeDefinitionFileFromStdin(t *testing.T) {
+	saved := stdin
+	t.Cleanup(func() { stdin = saved })
+	stdin = bufio.NewReaderSize(strings.NewReader("[[labels]]\nname = \"bug\"\ncolor = \"d73a4a\"\n"), stdinSniffSize)
+	format := sourceFormat(stdinPath)
+	got, err := decodeDefinitionFile[LabelData](stdinPath, format, "labels")
+	if err != nil {
+		t.Fatalf("decodeDefinitionFile() failed: %v", err)
+	}
+	if want := []LabelData{{Name: "bug", Color: "d73a4a"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeDefinitionFile() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSOPSInputType(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"JSON document", `{"data": "ENC[AES256_GCM,data:abc,type:str]", "sops": {"mac": "ENC[AES256_GCM,data:def,type:str]", "version": "3.9.0"}}`, "json"},
+		{"YAML document", "data: ENC[AES256_GCM,data:abc,type:str]\nsops:\n    age:\n        - recipient: age1abc\n          enc: |\n            -----BEGIN AGE ENCRYPTED FILE-----\n            -----END AGE ENCRYPTED FILE-----\n    lastmodified: \"2026-10-01T00:00:00Z\"\n    mac: ENC[AES256_GCM,data:def,type:str]\n    version: 3.9.0\n", "yaml"},
+		{"JSON definitions", `[{"name": "sops"}]`, ""},
+		{"JSON object without metadata", `{"sops": {}}`, ""},
+		{"YAML definitions", "- name: sops\n  description: 'sops: encrypted files'\n", ""},
+		{"YAML without a MAC", "data: x\nsops:\n    version: 3.9.0\n", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sopsInputType([]byte(tc.in)); got != tc.want {
+				t.Errorf("sopsInputType() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultCodeMarkers = "TODO|FIXME"
+	maxScannedFileSize = 1 << 20 // Larger files are generated or vendored more often than not
+	maxGeneratedTitle  = 80
+)
+
+// skippedCodeDirs are never scanned, on top of hidden directories
+var skippedCodeDirs = map[string]bool{"node_modules": true, "vendor": true, "third_party": true}
+
+// codeCommentLeader matches the start of a comment in the common languages
+const codeCommentLeader = `(?://|#|/\*|\*|--|;|<!--)`
+
+// codeMarker is one marker comment found in the scanned tree
+type codeMarker struct {
+	Path   string // Slash-separated, relative to the scanned directory
+	Line   int
+	Marker string // e.g. "TODO"
+	Owner  string // From TODO(owner), for the description only
+	Text   string
+	Source string // The whole source line
+}
+
+// markerPattern matches a comment starting with one of markers, optionally followed by an
+// owner in parentheses and a colon
+func markerPattern(markers string) (*regexp.Regexp, error) {
+	return regexp.Compile(codeCommentLeader + `\s*(` + markers + `)\b(?:\(([^)]*)\))?:?\s*(.*)`)
+}
+
+// scanCodeMarkers walks root and returns the marker comments of its text files in path order
+func scanCodeMarkers(root string, pattern *regexp.Regexp) ([]codeMarker, error) {
+	var found []codeMarker
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if p != root && (strings.HasPrefix(name, ".") || skippedCodeDirs[name]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxScannedFileSize {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		markers, err := scanFileMarkers(p, filepath.ToSlash(rel), pattern)
+		if err != nil {
+			return err
+		}
+		found = append(found, markers...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning %s: %w", root, err)
+	}
+	return found, nil
+}
+
+// scanFileMarkers returns the marker comments of one file, or none for a binary file
+func scanFileMarkers(p, rel string, pattern *regexp.Regexp) ([]codeMarker, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return nil, nil
+	}
+	var found []codeMarker
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, maxScannedFileSize)
+	for line := 1; scanner.Scan(); line++ {
+		m := pattern.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		text := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(m[3]), "-->"))
+		text = strings.TrimSpace(strings.TrimSuffix(text, "*/"))
+		found = append(found, codeMarker{
+			Path: rel, Line: line, Marker: m[1], Owner: m[2], Text: text,
+			Source: strings.TrimSpace(scanner.Text()),
+		})
+	}
+	return found, scanner.Err()
+}
+
+// codeMarkerIssue turns a marker comment into an issue definition.
+// blobURL is the base of the file links, empty for plain file:line references.
+func codeMarkerIssue(m codeMarker, blobURL string, labels []string, milestone string) IssueData {
+	ref := fmt.Sprintf("%s:%d", m.Path, m.Line)
+	title := m.Text
+	if title == "" {
+		title = fmt.Sprintf("%s in %s", m.Marker, ref)
+	} else if len(title) > maxGeneratedTitle {
+		title = strings.TrimSpace(truncateUTF8(title, maxGeneratedTitle-3)) + "..."
+	}
+
+	link := "`" + ref + "`"
+	if blobURL != "" {
+		link = fmt.Sprintf("[`%s`](%s/%s#L%d)", ref, blobURL, m.Path, m.Line)
+	}
+	var body strings.Builder
+	fmt.Fprintf(&body, "%s found in %s", m.Marker, link)
+	if m.Owner != "" {
+		fmt.Fprintf(&body, " (%s)", m.Owner)
+	}
+	fmt.Fprintf(&body, ":\n\n```%s\n%s\n```", strings.TrimPrefix(path.Ext(m.Path), "."), m.Source)
+
+	issue := IssueData{Title: title, Description: body.String(), Labels: append([]string{}, labels...)}
+	if milestone != "" {
+		issue.MilestoneTitle = &milestone
+	}
+	return issue
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// codeMarkerIssues converts the markers, making repeated titles unique with their location
+// so the generated issues stay distinct once applied
+func codeMarkerIssues(markers []codeMarker, blobURL string, labels []string, milestone string) []IssueData {
+	issues := make([]IssueData, 0, len(markers))
+	count := make(map[string]int)
+	for _, m := range markers {
+		issue := codeMarkerIssue(m, blobURL, labels, milestone)
+		issues = append(issues, issue)
+		count[issue.Title]++
+	}
+	for i, m := range markers {
+		if count[issues[i].Title] > 1 {
+			issues[i].Title = fmt.Sprintf("%s (%s:%d)", issues[i].Title, m.Path, m.Line)
+		}
+	}
+	return issues
+}
+
+// --- Generate Command ---
+
+// runGenerate runs "generate from-code", which writes issue definitions for the TODO and
+// FIXME comments of a source tree, e.g. to pipe into "apply --issues -", or "generate from-archive"
+func runGenerate(args []string) {
+	if len(args) > 0 && args[0] == "from-archive" {
+		runGenerateFromArchive(args[1:])
+		return
+	}
+	if len(args) == 0 || args[0] != "from-code" {
+		log.Fatal("Error: usage: generate from-code|from-archive [flags] <directory or archive>")
+	}
+	fs := flag.NewFlagSet("generate from-code", flag.ExitOnError)
+	markers := fs.String("marker", defaultCodeMarkers, "Regular expression of the comment markers to collect")
+	output := fs.String("output", stdinPath, "Issues file to write, - for standard output")
+	labelList := fs.String("labels", "", "Comma-separated labels given to every generated issue")
+	milestone := fs.String("milestone", "", "Milestone title given to every generated issue")
+	repo := fs.String("repo", os.Getenv("GITHUB_REPOSITORY"), "owner/name the file references link to, empty for plain references")
+	ref := fs.String("ref", "main", "Branch, tag or commit the file references link to")
+	positional, err := parseArgs(fs, args[1:])
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if len(positional) != 1 {
+		log.Fatal("Error: generate from-code takes exactly one directory")
+	}
+
+	pattern, err := markerPattern(*markers)
+	if err != nil {
+		log.Fatalf("Error: invalid --marker: %v", err)
+	}
+	found, err := scanCodeMarkers(positional[0], pattern)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	var labels []string
+	for _, name := range strings.Split(*labelList, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			labels = append(labels, name)
+		}
+	}
+	blobURL := ""
+	if *repo != "" {
+		blobURL = fmt.Sprintf("%s/%s/blob/%s", webBaseURL(), *repo, *ref)
+	}
+	issues := codeMarkerIssues(found, blobURL, labels, *milestone)
+
+	if *output == stdinPath {
+		if err := encodeIssues(os.Stdout, issues); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	} else if err := writeJSONFile(*output, issues); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	log.Printf("Generated %d issues from %s.", len(issues), positional[0])
+}
+
+// encodeIssues writes the issues as an indented JSON array
+func encodeIssues(w io.Writer, issues []IssueData) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(issues)
+}
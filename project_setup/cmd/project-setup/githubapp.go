@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// Without GITHUB_TOKEN the tool can authenticate as a GitHub App: it signs a JWT with the
+// app's private key and exchanges it for an installation token, so everything it creates is
+// authored by the app's bot account (<slug>[bot]) instead of the owner of a personal token.
+const (
+	appIDEnv             = "GITHUB_APP_ID"
+	appPrivateKeyEnv     = "GITHUB_APP_PRIVATE_KEY"      // PEM contents
+	appPrivateKeyPathEnv = "GITHUB_APP_PRIVATE_KEY_PATH" // Or a file holding them
+	appInstallationEnv   = "GITHUB_APP_INSTALLATION_ID"  // Needed when the app has several installations
+)
+
+// appBotLogin is the login of the bot account of the GitHub App the run authenticated as,
+// empty when it runs with GITHUB_TOKEN
+var appBotLogin string
+
+// usesGitHubApp reports whether the run authenticates as a GitHub App
+func usesGitHubApp() bool {
+	return os.Getenv("GITHUB_TOKEN") == "" && os.Getenv(appIDEnv) != ""
+}
+
+// appPrivateKey reads the private key of the app, PKCS#1 as GitHub generates it or PKCS#8
+func appPrivateKey() (*rsa.PrivateKey, error) {
+	data := []byte(os.Getenv(appPrivateKeyEnv))
+	if len(data) == 0 {
+		path := os.Getenv(appPrivateKeyPathEnv)
+		if path == "" {
+			return nil, fmt.Errorf("%s is set, but neither %s nor %s", appIDEnv, appPrivateKeyEnv, appPrivateKeyPathEnv)
+		}
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("error reading the app private key: %w", err)
+		}
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("the app private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the app private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the app private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// appJWT returns the JWT the app authenticates with, valid for nine minutes. It is backdated
+// by a minute against clock drift, as GitHub recommends.
+func appJWT(appID string, key *rsa.PrivateKey, now time.Time) (string, error) {
+	encode := func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return base64.RawURLEncoding.EncodeToString(data), nil
+	}
+	header, err := encode(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := encode(map[string]interface{}{"iat": now.Add(-time.Minute).Unix(), "exp": now.Add(9 * time.Minute).Unix(), "iss": appID})
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256([]byte(header + "." + claims))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("error signing the app JWT: %w", err)
+	}
+	return header + "." + claims + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// sendAppRequest sends a request authenticated with the app JWT and decodes the response
+func sendAppRequest(ctx context.Context, method, url, jwt string, into interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request for %s %s: %w", method, url, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request for %s %s: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return github.NewAPIError(resp, readGitHubError(resp))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+		return fmt.Errorf("error unmarshalling the response of %s %s: %w", method, url, err)
+	}
+	return nil
+}
+
+// appInstallation picks the installation to act as: GITHUB_APP_INSTALLATION_ID, the only
+// installation of the app, or the one of GITHUB_REPOSITORY
+func appInstallation(ctx context.Context, jwt string) (int64, error) {
+	if id := os.Getenv(appInstallationEnv); id != "" {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q", appInstallationEnv, id)
+		}
+		return n, nil
+	}
+	var installation struct {
+		ID int64 `json:"id"`
+	}
+	if repo := os.Getenv("GITHUB_REPOSITORY"); repo != "" {
+		if err := sendAppRequest(ctx, "GET", fmt.Sprintf("%s/repos/%s/installation", githubAPIBaseURL, repo), jwt, &installation); err != nil {
+			return 0, fmt.Errorf("error getting the app installation of %s: %w", repo, err)
+		}
+		return installation.ID, nil
+	}
+	var installations []struct {
+		ID      int64 `json:"id"`
+		Account struct {
+			Login string `json:"login"`
+		} `json:"account"`
+	}
+	if err := sendAppRequest(ctx, "GET", githubAPIBaseURL+"/app/installations?per_page=100", jwt, &installations); err != nil {
+		return 0, fmt.Errorf("error listing the app installations: %w", err)
+	}
+	if len(installations) == 0 {
+		return 0, fmt.Errorf("the app has no installations")
+	}
+	if len(installations) > 1 {
+		var accounts []string
+		for _, i := range installations {
+			accounts = append(accounts, fmt.Sprintf("%s (%d)", i.Account.Login, i.ID))
+		}
+		return 0, fmt.Errorf("the app has %d installations (%s), pick one with %s", len(installations), strings.Join(accounts, ", "), appInstallationEnv)
+	}
+	return installations[0].ID, nil
+}
+
+// appInstallationToken authenticates as the GitHub App and returns an installation token and
+// the login of the app's bot account. Installation tokens expire after an hour.
+func appInstallationToken(ctx context.Context) (token, bot string, err error) {
+	key, err := appPrivateKey()
+	if err != nil {
+		return "", "", err
+	}
+	jwt, err := appJWT(os.Getenv(appIDEnv), key, time.Now())
+	if err != nil {
+		return "", "", err
+	}
+	secrets.add(jwt)
+	var app struct {
+		Slug string `json:"slug"`
+	}
+	if err := sendAppRequest(ctx, "GET", githubAPIBaseURL+"/app", jwt, &app); err != nil {
+		return "", "", fmt.Errorf("error authenticating as app %s: %w", os.Getenv(appIDEnv), err)
+	}
+	installation, err := appInstallation(ctx, jwt)
+	if err != nil {
+		return "", "", err
+	}
+	var access struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", githubAPIBaseURL, installation)
+	if err := sendAppRequest(ctx, "POST", url, jwt, &access); err != nil {
+		return "", "", fmt.Errorf("error getting a token for installation %d: %w", installation, err)
+	}
+	bot = app.Slug + "[bot]"
+	log.Printf("Authenticated as GitHub App %s (installation %d), writing as %s until %s.", app.Slug, installation, bot, access.ExpiresAt.Local().Format(time.Kitchen))
+	return access.Token, bot, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// gitRemoteName is the remote read when the target repository is auto-detected
+const gitRemoteName = "origin"
+
+// detectGitRemote determines the repository of the git working copy the tool runs in
+// from the URL of its origin remote, as most GitHub tooling does
+func detectGitRemote(ctx context.Context) (repoTarget, string, error) {
+	out, err := exec.CommandContext(ctx, "git", "remote", "get-url", gitRemoteName).Output()
+	if err != nil {
+		return repoTarget{}, "", fmt.Errorf("error reading git remote '%s': %w", gitRemoteName, err)
+	}
+	remote := strings.TrimSpace(string(out))
+	t, host, err := parseRepoReference(remote)
+	if err != nil {
+		return repoTarget{}, "", fmt.Errorf("error parsing git remote '%s': %w", gitRemoteName, err)
+	}
+	if host == "" {
+		return repoTarget{}, "", fmt.Errorf("git remote '%s' (%s) is not a URL", gitRemoteName, remote)
+	}
+	return t, host, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// isGlobPattern reports whether a definitions path is a pattern such as "backlog/*.json"
+func isGlobPattern(path string) bool {
+	return path != stdinPath && strings.ContainsAny(path, "*?[")
+}
+
+// loadGlob reads every file matched by pattern, in lexical order, and concatenates the
+// definitions. An entry defined in more than one file is an error naming both files.
+func loadGlob[T any](pattern, what string, load func(path string) ([]T, error), key func(T) string) ([]T, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s file pattern %q: %w", what, pattern, err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no %s files match %q", what, pattern)
+	}
+	var all []T
+	definedIn := make(map[string]string)
+	var duplicates []string
+	for _, path := range paths {
+		items, err := load(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			k := key(item)
+			if first, ok := definedIn[k]; ok {
+				duplicates = append(duplicates, fmt.Sprintf("%s '%s' is defined in %s and %s", what, k, first, path))
+				continue
+			}
+			definedIn[k] = path
+			all = append(all, item)
+		}
+	}
+	if len(duplicates) > 0 {
+		return nil, fmt.Errorf("duplicate definitions in %s:\n  - %s", pattern, strings.Join(duplicates, "\n  - "))
+	}
+	log.Printf("Read %d %s definitions from %d files matching %s.", len(all), what, len(paths), pattern)
+	return all, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// GraphQL batching limits: aliases per query and queries in flight
+const (
+	graphQLBatchSize   = 100
+	graphQLConcurrency = 4
+)
+
+// graphQLURL is the GraphQL endpoint of the run's API (github.com or GHES)
+func graphQLURL() string {
+	return apiClient().GraphQLURL()
+}
+
+// sendGraphQLQuery runs a query and returns its data. NOT_FOUND errors are not
+// returned, the affected fields are simply null in the data.
+func sendGraphQLQuery(ctx context.Context, query string, variables map[string]interface{}) (map[string]json.RawMessage, error) {
+	return apiClient().Query(ctx, query, variables)
+}
+
+// sendGraphQLRequest runs a query and returns data and errors as they are, for callers
+// that attribute errors to the aliases of a batch themselves
+func sendGraphQLRequest(ctx context.Context, query string, variables map[string]interface{}) (github.GraphQLResponse, error) {
+	return apiClient().GraphQL(ctx, query, variables)
+}
+
+// graphQLCheck is one existence check inside a batched query
+type graphQLCheck struct {
+	Key    string            // Identifies the checked entity in the results
+	Field  string            // Field with $-placeholders, e.g. `user(login: $login) { login }`
+	Args   map[string]string // Placeholder -> value, all of type String!
+	Nested string            // When set, the entity is this child of the field's object, e.g. "team"
+}
+
+// checkExistence runs the checks in batches of aliased fields, several batches at a time,
+// and reports for every check key whether the entity exists
+func checkExistence(ctx context.Context, checks []graphQLCheck) (map[string]bool, error) {
+	exists := make(map[string]bool, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	sem := make(chan struct{}, graphQLConcurrency)
+
+	for start := 0; start < len(checks); start += graphQLBatchSize {
+		batch := checks[start:min(start+graphQLBatchSize, len(checks))]
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(batch []graphQLCheck) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			found, err := runExistenceBatch(ctx, batch)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			for key, ok := range found {
+				exists[key] = ok
+			}
+		}(batch)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return exists, nil
+}
+
+// runExistenceBatch sends one query with an alias per check
+func runExistenceBatch(ctx context.Context, batch []graphQLCheck) (map[string]bool, error) {
+	var decls, fields []string
+	variables := make(map[string]interface{})
+	for i, check := range batch {
+		alias := fmt.Sprintf("c%d", i)
+		field := check.Field
+		// Longest names first so $name does not clobber $nameSuffix
+		names := make([]string, 0, len(check.Args))
+		for name := range check.Args {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(a, b int) bool { return len(names[a]) > len(names[b]) })
+		for _, name := range names {
+			variable := alias + "_" + name
+			field = strings.ReplaceAll(field, "$"+name, "$"+variable)
+			decls = append(decls, fmt.Sprintf("$%s: String!", variable))
+			variables[variable] = check.Args[name]
+		}
+		fields = append(fields, fmt.Sprintf("%s: %s", alias, field))
+	}
+	query := fmt.Sprintf("query(%s) {\n  %s\n}", strings.Join(decls, ", "), strings.Join(fields, "\n  "))
+
+	data, err := sendGraphQLQuery(ctx, query, variables)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(batch))
+	for i, check := range batch {
+		raw := data[fmt.Sprintf("c%d", i)]
+		if check.Nested != "" && len(raw) > 0 && string(raw) != "null" {
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &object); err != nil {
+				return nil, fmt.Errorf("error unmarshalling GraphQL result for %s: %w", check.Key, err)
+			}
+			raw = object[check.Nested]
+		}
+		found[check.Key] = len(raw) > 0 && string(raw) != "null"
+	}
+	return found, nil
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/alcorg/project_setup/project_setup/engine"
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// graphQLWriteBatchSize is how many createIssue mutations share one request. GitHub still
+// counts every mutation against the secondary rate limit, but a large import needs a
+// fraction of the round trips and requests.
+const graphQLWriteBatchSize = 20
+
+const repositoryNodeIDsQuery = `query($owner: String!, $name: String!, $labels: String, $milestones: String) {
+  repository(owner: $owner, name: $name) {
+    id
+    labels(first: 100, after: $labels) { nodes { id name } pageInfo { hasNextPage endCursor } }
+    milestones(first: 100, after: $milestones) { nodes { id number } pageInfo { hasNextPage endCursor } }
+  }
+}`
+
+// graphQLPage is the page info of a connection
+type graphQLPage struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+// pendingIssue is an issue waiting for the next batch
+type pendingIssue struct {
+	index       int // Into the issues of the run
+	issue       IssueData
+	milestoneID *int
+	body        string
+}
+
+// graphQLIssueResult is the outcome of one pending issue
+type graphQLIssueResult struct {
+	pendingIssue
+	created github.Issue
+	err     error
+}
+
+// graphQLIssueWriter creates issues with batches of aliased createIssue mutations. The
+// mutations take node IDs instead of names and numbers; those are looked up once, and
+// again only when an issue refers to a label or milestone created since.
+type graphQLIssueWriter struct {
+	target       repoTarget
+	repositoryID string
+	labels       map[string]string // Lower-case name -> node ID
+	milestones   map[int]string    // Number -> node ID
+	users        map[string]string // Login -> node ID, "" when there is no such user
+	pending      []pendingIssue
+}
+
+func newGraphQLIssueWriter(t repoTarget) *graphQLIssueWriter {
+	return &graphQLIssueWriter{target: t, users: make(map[string]string)}
+}
+
+// add queues an issue and reports whether the batch is full
+func (w *graphQLIssueWriter) add(index int, issue IssueData, milestoneID *int) bool {
+	w.pending = append(w.pending, pendingIssue{index: index, issue: issue, milestoneID: milestoneID, body: renderIssueBody(issue)})
+	return len(w.pending) >= graphQLWriteBatchSize
+}
+
+// flush creates the queued issues with one request. An issue that fails, e.g. because a
+// label does not exist, does not fail the others of its batch.
+func (w *graphQLIssueWriter) flush(ctx context.Context) []graphQLIssueResult {
+	batch := w.pending
+	w.pending = nil
+	results := make([]graphQLIssueResult, len(batch))
+	for i, p := range batch {
+		results[i].pendingIssue = p
+	}
+	if len(batch) == 0 {
+		return results
+	}
+	if err := w.resolve(ctx, batch); err != nil {
+		for i := range results {
+			results[i].err = fmt.Errorf("error creating issue '%s': %w", results[i].issue.Title, err)
+		}
+		return results
+	}
+
+	var decls, fields []string
+	variables := make(map[string]interface{})
+	for i, p := range batch {
+		input, err := w.input(p)
+		if err != nil {
+			results[i].err = fmt.Errorf("error creating issue '%s': %w", p.issue.Title, err)
+			continue
+		}
+		alias := fmt.Sprintf("i%d", i)
+		decls = append(decls, fmt.Sprintf("$%s: CreateIssueInput!", alias))
+		fields = append(fields, fmt.Sprintf("%s: createIssue(input: $%s) { issue { number id title url state } }", alias, alias))
+		variables[alias] = input
+	}
+	if len(fields) == 0 {
+		return results
+	}
+	log.Printf("Creating %d issues in %s with one GraphQL request...", len(fields), w.target)
+	query := fmt.Sprintf("mutation(%s) {\n  %s\n}", strings.Join(decls, ", "), strings.Join(fields, "\n  "))
+	response, err := sendGraphQLRequest(ctx, query, variables)
+
+	// Errors name the alias of the failed mutation as the first element of their path
+	failures := make(map[string][]github.GraphQLErrorEntry)
+	var unattributed []github.GraphQLErrorEntry
+	for _, entry := range response.Errors {
+		if len(entry.Path) > 0 {
+			failures[entry.Path[0]] = append(failures[entry.Path[0]], entry)
+		} else {
+			unattributed = append(unattributed, entry)
+		}
+	}
+	for i := range results {
+		r := &results[i]
+		if r.err != nil {
+			continue
+		}
+		if err != nil {
+			r.err = fmt.Errorf("error creating issue '%s': %w", r.issue.Title, err)
+			continue
+		}
+		alias := fmt.Sprintf("i%d", i)
+		var payload struct {
+			Issue *struct {
+				Number int    `json:"number"`
+				ID     string `json:"id"`
+				Title  string `json:"title"`
+				URL    string `json:"url"`
+				State  string `json:"state"`
+			} `json:"issue"`
+		}
+		if raw := response.Data[alias]; len(raw) > 0 && string(raw) != "null" {
+			if err := json.Unmarshal(raw, &payload); err != nil {
+				r.err = fmt.Errorf("error unmarshalling created issue response for '%s': %w", r.issue.Title, err)
+				continue
+			}
+		}
+		if payload.Issue == nil {
+			entries := failures[alias]
+			if len(entries) == 0 {
+				entries = unattributed
+			}
+			if len(entries) == 0 {
+				entries = []github.GraphQLErrorEntry{{Message: "no issue in the response"}}
+			}
+			r.err = fmt.Errorf("error creating issue '%s': %w", r.issue.Title, &github.GraphQLError{Errors: entries})
+			continue
+		}
+		r.created = github.Issue{
+			Number:  payload.Issue.Number,
+			NodeID:  payload.Issue.ID,
+			Title:   payload.Issue.Title,
+			HTMLURL: payload.Issue.URL,
+			State:   strings.ToLower(payload.Issue.State),
+			Body:    r.body,
+		}
+		for _, name := range r.issue.Labels {
+			r.created.Labels = append(r.created.Labels, github.Label{Name: name})
+		}
+		log.Printf("Successfully created issue: \"%s\" (#%d)\n", r.issue.Title, r.created.Number)
+		engine.ReportCreated(ctx, w.target.String(), "issue", r.issue.Title, r.created.Number)
+	}
+	return results
+}
+
+// input builds the CreateIssueInput of an issue from the resolved node IDs
+func (w *graphQLIssueWriter) input(p pendingIssue) (map[string]interface{}, error) {
+	input := map[string]interface{}{"repositoryId": w.repositoryID, "title": p.issue.Title, "body": p.body}
+	if len(p.issue.Labels) > 0 {
+		ids := make([]string, 0, len(p.issue.Labels))
+		for _, name := range p.issue.Labels {
+			id, ok := w.labels[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("invalid labels: label '%s' does not exist in %s", name, w.target)
+			}
+			ids = append(ids, id)
+		}
+		input["labelIds"] = ids
+	}
+	if p.milestoneID != nil {
+		id, ok := w.milestones[*p.milestoneID]
+		if !ok {
+			return nil, fmt.Errorf("milestone #%d does not exist in %s", *p.milestoneID, w.target)
+		}
+		input["milestoneId"] = id
+	}
+	if len(p.issue.Assignees) > 0 {
+		ids := make([]string, 0, len(p.issue.Assignees))
+		for _, login := range p.issue.Assignees {
+			id := w.users[login]
+			if id == "" {
+				return nil, fmt.Errorf("assignee '%s' does not exist", login)
+			}
+			ids = append(ids, id)
+		}
+		input["assigneeIds"] = ids
+	}
+	return input, nil
+}
+
+// resolve looks up the node IDs the batch needs and does not know yet
+func (w *graphQLIssueWriter) resolve(ctx context.Context, batch []pendingIssue) error {
+	stale := w.repositoryID == ""
+	var logins []string
+	seen := make(map[string]bool)
+	for _, p := range batch {
+		for _, name := range p.issue.Labels {
+			_, known := w.labels[strings.ToLower(name)]
+			stale = stale || !known
+		}
+		if p.milestoneID != nil {
+			_, known := w.milestones[*p.milestoneID]
+			stale = stale || !known
+		}
+		for _, login := range p.issue.Assignees {
+			if _, known := w.users[login]; !known && !seen[login] {
+				seen[login] = true
+				logins = append(logins, login)
+			}
+		}
+	}
+	if stale {
+		if err := w.loadRepository(ctx); err != nil {
+			return err
+		}
+	}
+	return w.loadUsers(ctx, logins)
+}
+
+// loadRepository fetches the node IDs of the repository and all of its labels and milestones
+func (w *graphQLIssueWriter) loadRepository(ctx context.Context) error {
+	w.labels = make(map[string]string)
+	w.milestones = make(map[int]string)
+	variables := map[string]interface{}{"owner": w.target.Owner, "name": w.target.Repo}
+	for more := true; more; {
+		data, err := sendGraphQLQuery(ctx, repositoryNodeIDsQuery, variables)
+		if err != nil {
+			return fmt.Errorf("error fetching node IDs of %s: %w", w.target, err)
+		}
+		var repository *struct {
+			ID     string `json:"id"`
+			Labels struct {
+				Nodes []struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"nodes"`
+				PageInfo graphQLPage `json:"pageInfo"`
+			} `json:"labels"`
+			Milestones struct {
+				Nodes []struct {
+					ID     string `json:"id"`
+					Number int    `json:"number"`
+				} `json:"nodes"`
+				PageInfo graphQLPage `json:"pageInfo"`
+			} `json:"milestones"`
+		}
+		if err := json.Unmarshal(data["repository"], &repository); err != nil {
+			return fmt.Errorf("error unmarshalling node IDs of %s: %w", w.target, err)
+		}
+		if repository == nil {
+			return fmt.Errorf("repository %s not found", w.target)
+		}
+		w.repositoryID = repository.ID
+		for _, label := range repository.Labels.Nodes {
+			w.labels[strings.ToLower(label.Name)] = label.ID
+		}
+		for _, milestone := range repository.Milestones.Nodes {
+			w.milestones[milestone.Number] = milestone.ID
+		}
+		// A finished connection keeps its last cursor and returns no further nodes
+		if page := repository.Labels.PageInfo; page.HasNextPage {
+			variables["labels"] = page.EndCursor
+		}
+		if page := repository.Milestones.PageInfo; page.HasNextPage {
+			variables["milestones"] = page.EndCursor
+		}
+		more = repository.Labels.PageInfo.HasNextPage || repository.Milestones.PageInfo.HasNextPage
+	}
+	return nil
+}
+
+// loadUsers looks up the node IDs of users in batches of aliased fields; a user that
+// does not exist keeps an empty ID
+func (w *graphQLIssueWriter) loadUsers(ctx context.Context, logins []string) error {
+	for start := 0; start < len(logins); start += graphQLBatchSize {
+		batch := logins[start:min(start+graphQLBatchSize, len(logins))]
+		var decls, fields []string
+		variables := make(map[string]interface{})
+		for i, login := range batch {
+			alias := fmt.Sprintf("u%d", i)
+			decls = append(decls, fmt.Sprintf("$%s: String!", alias))
+			fields = append(fields, fmt.Sprintf("%s: user(login: $%s) { id }", alias, alias))
+			variables[alias] = login
+		}
+		query := fmt.Sprintf("query(%s) {\n  %s\n}", strings.Join(decls, ", "), strings.Join(fields, "\n  "))
+		data, err := sendGraphQLQuery(ctx, query, variables)
+		if err != nil {
+			return fmt.Errorf("error fetching node IDs of assignees: %w", err)
+		}
+		for i, login := range batch {
+			var user *struct {
+				ID string `json:"id"`
+			}
+			if raw := data[fmt.Sprintf("u%d", i)]; len(raw) > 0 {
+				if err := json.Unmarshal(raw, &user); err != nil {
+					return fmt.Errorf("error unmarshalling node ID of user '%s': %w", login, err)
+				}
+			}
+			w.users[login] = ""
+			if user != nil {
+				w.users[login] = user.ID
+			}
+		}
+	}
+	return nil
+}
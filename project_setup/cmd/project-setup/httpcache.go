@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPCacheConfig keeps GET responses on disk, so the plan and apply steps of one pipeline
+// run share them instead of reading everything twice
+type HTTPCacheConfig struct {
+	Dir string `json:"dir,omitempty"` // Cache directory, e.g. one per pipeline run; no cache when empty
+}
+
+// cachedResponse is a GET response as stored on disk
+type cachedResponse struct {
+	URL      string      `json:"url"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"stored_at"` // When the request was sent, not when it was answered
+}
+
+// fresh reports whether the response may be used without asking GitHub, per its max-age
+func (c *cachedResponse) fresh(now time.Time) bool {
+	maxAge, ok := cacheControlMaxAge(c.Header.Get("Cache-Control"))
+	return ok && now.Sub(c.StoredAt) < time.Duration(maxAge)*time.Second
+}
+
+// cacheControlMaxAge returns the max-age directive; no-cache and no-store leave nothing fresh
+func cacheControlMaxAge(header string) (int, bool) {
+	maxAge, ok := 0, false
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(strings.ToLower(directive)), "=")
+		switch name {
+		case "no-cache", "no-store":
+			return 0, false
+		case "max-age":
+			if seconds, err := strconv.Atoi(value); err == nil {
+				maxAge, ok = seconds, true
+			}
+		}
+	}
+	return maxAge, ok
+}
+
+// cacheTransport answers GET requests from the cache while they are fresh, and revalidates
+// them with their ETag or Last-Modified once stale; a 304 costs no rate limit. Responses
+// stored before the last change any run made through the cache are always revalidated, so
+// an apply never works from what it read before its own or an earlier run's writes.
+type cacheTransport struct {
+	next http.RoundTripper
+
+	mu  sync.Mutex
+	dir string
+}
+
+// lastWriteFile records in the cache directory when a run last changed something
+const lastWriteFile = "last-write"
+
+// lastWrite returns when a run last changed something, zero when none did
+func lastWrite(dir string) time.Time {
+	data, err := os.ReadFile(filepath.Join(dir, lastWriteFile))
+	if err != nil {
+		return time.Time{}
+	}
+	at, _ := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+	return at
+}
+
+// httpCache is installed by the first run with http_cache.dir
+var httpCache *cacheTransport
+
+// enableHTTPCache puts the cache in front of the API client, or points it at another directory
+func enableHTTPCache(c HTTPCacheConfig) {
+	if httpCache != nil {
+		httpCache.mu.Lock()
+		httpCache.dir = c.Dir
+		httpCache.mu.Unlock()
+		return
+	}
+	if c.Dir == "" {
+		return
+	}
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		log.Printf("Warning: HTTP cache disabled, %s cannot be created: %v", c.Dir, err)
+		return
+	}
+	httpCache = &cacheTransport{next: orDefaultTransport(httpClient.Transport), dir: c.Dir}
+	httpClient.Transport = httpCache
+	log.Printf("HTTP cache: GET responses are kept in %s.", c.Dir)
+}
+
+// cacheKey identifies a response by URL, media type and token, so tokens never share entries;
+// the token itself is only stored hashed
+func cacheKey(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.URL.String() + "\n" + req.Header.Get("Accept") + "\n" + req.Header.Get("Authorization")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	dir := c.dir
+	c.mu.Unlock()
+	if dir == "" {
+		return c.next.RoundTrip(req)
+	}
+	if req.Method != "GET" {
+		if checkReadOnly(req) != nil {
+			// Recorded before the change is sent, so no response read after it counts as fresh
+			stamp := []byte(time.Now().UTC().Format(time.RFC3339Nano))
+			if err := os.WriteFile(filepath.Join(dir, lastWriteFile), stamp, 0o600); err != nil {
+				log.Printf("Warning: could not record a change in the HTTP cache: %v", err)
+			}
+		}
+		return c.next.RoundTrip(req)
+	}
+
+	path := filepath.Join(dir, cacheKey(req)+".json")
+	cached := readCachedResponse(path)
+	if cached != nil && cached.StoredAt.After(lastWrite(dir)) && cached.fresh(time.Now()) {
+		return cached.response(req), nil
+	}
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if modified := cached.Header.Get("Last-Modified"); modified != "" {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	}
+
+	// A write sent while the request was under way may be missing from the response
+	sent := time.Now()
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		resp.Body.Close()
+		// A 304 carries the current caching headers
+		for _, name := range []string{"Cache-Control", "ETag", "Last-Modified"} {
+			if value := resp.Header.Get(name); value != "" {
+				cached.Header.Set(name, value)
+			}
+		}
+		cached.StoredAt = sent
+		writeCachedResponse(path, cached)
+		return cached.response(req), nil
+	case resp.StatusCode == http.StatusOK && !strings.Contains(strings.ToLower(resp.Header.Get("Cache-Control")), "no-store"):
+		body, err := readResponseBody(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		writeCachedResponse(path, &cachedResponse{URL: req.URL.String(), Header: resp.Header, Body: body, StoredAt: sent})
+	}
+	return resp, nil
+}
+
+// response rebuilds the HTTP response of a cached entry
+func (c *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
+
+// readCachedResponse loads an entry; a missing or unreadable one is a miss
+func readCachedResponse(path string) *cachedResponse {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil
+	}
+	return &cached
+}
+
+// writeCachedResponse stores an entry through a temporary file, so parallel phases and
+// processes never read half an entry; failures only cost the next run a request
+func writeCachedResponse(path string, cached *cachedResponse) {
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		log.Printf("Warning: could not write to the HTTP cache: %v", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		log.Printf("Warning: could not write to the HTTP cache: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// defaultFullScanDays is how often the issue cache is rebuilt from scratch by default, which
+// drops issues that were deleted or transferred since; incremental updates cannot see those
+const defaultFullScanDays = 7
+
+// issueCacheClockSkew is subtracted from the local clock when it has to serve as the cursor
+const issueCacheClockSkew = 5 * time.Minute
+
+// IssueCacheConfig keeps the existing issues of every repository on disk between runs, so
+// duplicate detection on repositories with tens of thousands of issues only fetches the
+// issues updated since the previous run
+type IssueCacheConfig struct {
+	Dir          string `json:"dir,omitempty"`            // Cache directory; no cache when empty
+	FullScanDays int    `json:"full_scan_days,omitempty"` // Rebuild after this many days, 7 by default
+}
+
+// issueCache is the cached issue index of one repository
+type issueCache struct {
+	Repository string `json:"repository"`
+	// Cursor for the since= parameter: the latest updated_at seen, as returned by the API
+	Since    string         `json:"since"`
+	FullScan time.Time      `json:"full_scan"` // When the cache was last rebuilt
+	Issues   []github.Issue `json:"issues"`    // Pull requests left out
+}
+
+// issueCacheMu serializes the cache updates of phases that run in parallel
+var issueCacheMu sync.Mutex
+
+// issueCachePath is the cache file of a repository
+func issueCachePath(dir string, t repoTarget) string {
+	return filepath.Join(dir, fmt.Sprintf("%s__%s.json", t.Owner, t.Repo))
+}
+
+// readIssueCache loads the cache of a repository; nil when there is none or it is due for a full scan
+func readIssueCache(c IssueCacheConfig, t repoTarget) (*issueCache, error) {
+	data, err := os.ReadFile(issueCachePath(c.Dir, t))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading the issue cache of %s: %w", t, err)
+	}
+	var cache issueCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		log.Printf("Warning: ignoring the unreadable issue cache of %s: %v", t, err)
+		return nil, nil
+	}
+	days := c.FullScanDays
+	if days <= 0 {
+		days = defaultFullScanDays
+	}
+	if cache.Since == "" || time.Since(cache.FullScan) > time.Duration(days)*24*time.Hour {
+		return nil, nil
+	}
+	return &cache, nil
+}
+
+// writeIssueCache stores the cache of a repository, through a temporary file so an
+// interrupted run never leaves a truncated cache behind
+func writeIssueCache(c IssueCacheConfig, t repoTarget, cache *issueCache) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return fmt.Errorf("error creating the issue cache directory: %w", err)
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return fmt.Errorf("error marshalling the issue cache of %s: %w", t, err)
+	}
+	path := issueCachePath(c.Dir, t)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("error writing the issue cache of %s: %w", t, err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// mergeIssues applies the issues updated since the cursor to the cached ones, by number
+func (c *issueCache) mergeIssues(updated []github.Issue) {
+	index := make(map[int]int, len(c.Issues))
+	for i, issue := range c.Issues {
+		index[issue.Number] = i
+	}
+	for _, issue := range updated {
+		if issue.UpdatedAt > c.Since {
+			c.Since = issue.UpdatedAt // RFC 3339 in UTC, so they sort as strings
+		}
+		if issue.PullRequest != nil {
+			continue
+		}
+		if i, ok := index[issue.Number]; ok {
+			c.Issues[i] = issue
+		} else {
+			index[issue.Number] = len(c.Issues)
+			c.Issues = append(c.Issues, issue)
+		}
+	}
+}
+
+// listRepoIssues lists the issues of a repository; callers skip pull requests, which the API
+// lists as well. With issue_cache, only the issues updated since the previous run are
+// fetched and merged into the cached ones.
+func listRepoIssues(ctx context.Context, t repoTarget) ([]github.Issue, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/issues?state=all&per_page=100", githubAPIBaseURL, t.Owner, t.Repo)
+	c := config.IssueCache
+	if c.Dir == "" {
+		return getAllPages[github.Issue](ctx, "issues", url)
+	}
+	issueCacheMu.Lock()
+	defer issueCacheMu.Unlock()
+
+	cache, err := readIssueCache(c, t)
+	if err != nil {
+		return nil, err
+	}
+	started := time.Now().UTC()
+	if cache == nil {
+		log.Printf("Issue cache of %s: full scan.", t)
+		cache = &issueCache{Repository: t.String(), FullScan: time.Now().UTC()}
+	} else {
+		// since= is inclusive, so the newest cached issues are fetched again and merged
+		url += "&since=" + cache.Since
+		log.Printf("Issue cache of %s: %d cached issues, fetching those updated since %s.", t, len(cache.Issues), cache.Since)
+	}
+	updated, err := getAllPages[github.Issue](ctx, "issues", url)
+	if err != nil {
+		return nil, err
+	}
+	cache.mergeIssues(updated)
+	if cache.Since == "" {
+		// Nothing to take the cursor from yet; the margin allows for clock skew
+		cache.Since = started.Add(-issueCacheClockSkew).Format(time.RFC3339)
+	}
+	if err := writeIssueCache(c, t, cache); err != nil {
+		log.Printf("Warning: %v", err) // The issues are still complete, only the next run scans more
+	}
+	return cache.Issues, nil
+}
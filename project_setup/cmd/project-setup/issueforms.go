@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// noResponse is what GitHub writes for form fields left empty
+const noResponse = "_No response_"
+
+// IssueForm is a GitHub issue form (.github/ISSUE_TEMPLATE/*.yml), see
+// https://docs.github.com/communities/using-templates-to-encourage-useful-issues-and-pull-requests/syntax-for-issue-forms
+type IssueForm struct {
+	Name   string          `json:"name"`
+	Title  string          `json:"title"`  // Prefix of the issue title
+	Labels []string        `json:"labels"` // Added to every issue created from the form
+	Body   []IssueFormItem `json:"body"`
+}
+
+// IssueFormItem is one element of an issue form body
+type IssueFormItem struct {
+	Type       string `json:"type"` // markdown, input, textarea, dropdown or checkboxes
+	ID         string `json:"id"`
+	Attributes struct {
+		Label    string       `json:"label"`
+		Value    string       `json:"value"`  // Default value of inputs and textareas
+		Render   string       `json:"render"` // Textareas: rendered as a code block of this language
+		Multiple bool         `json:"multiple"`
+		Default  *int         `json:"default"` // Dropdowns: index of the preselected option
+		Options  []formOption `json:"options"`
+	} `json:"attributes"`
+	Validations struct {
+		Required bool `json:"required"`
+	} `json:"validations"`
+}
+
+// formOption is a dropdown option (a plain string) or a checkbox ({label: ...})
+type formOption struct {
+	Label string `json:"label"`
+}
+
+// loadIssueForm reads an issue form YAML file
+func loadIssueForm(path string) (*IssueForm, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading issue form %s: %w", path, err)
+	}
+	node, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing issue form %s: %w", path, err)
+	}
+	// Dropdown options are plain strings, checkbox options are mappings
+	if m, ok := node.(map[string]interface{}); ok {
+		if body, ok := m["body"].([]interface{}); ok {
+			for _, item := range body {
+				attrs, _ := item.(map[string]interface{})["attributes"].(map[string]interface{})
+				options, _ := attrs["options"].([]interface{})
+				for i, option := range options {
+					if _, isMap := option.(map[string]interface{}); !isMap {
+						options[i] = map[string]interface{}{"label": option}
+					}
+				}
+			}
+		}
+	}
+	var form IssueForm
+	if err := decodeTree(node, &form); err != nil {
+		return nil, fmt.Errorf("error decoding issue form %s: %w", path, err)
+	}
+	return &form, nil
+}
+
+// expandIssueForms renders the body of every issue that references an issue form,
+// filling the form's fields from the issue's "fields" (or the form defaults).
+// Forms are read once even when many issues use them.
+func expandIssueForms(issues []IssueData) error {
+	forms := make(map[string]*IssueForm)
+	for i := range issues {
+		issue := &issues[i]
+		if issue.Form == "" {
+			if len(issue.Fields) > 0 {
+				return fmt.Errorf("issue '%s' has fields but no form", issue.Title)
+			}
+			continue
+		}
+		form, ok := forms[issue.Form]
+		if !ok {
+			var err error
+			if form, err = loadIssueForm(issue.Form); err != nil {
+				return err
+			}
+			forms[issue.Form] = form
+		}
+
+		body, err := form.render(issue.Fields)
+		if err != nil {
+			return fmt.Errorf("issue '%s': %w", issue.Title, err)
+		}
+		if description := strings.TrimSpace(issue.Description); description != "" {
+			body += "\n\n" + description
+		}
+		issue.Description = body
+		issue.Title = form.Title + issue.Title
+		for _, label := range form.Labels {
+			if !containsFold(issue.Labels, label) {
+				issue.Labels = append(issue.Labels, label)
+			}
+		}
+	}
+	return nil
+}
+
+// render produces the body GitHub would create when the form is submitted with
+// these values: a "### label" section per field, markdown items are not included
+func (f *IssueForm) render(values map[string]interface{}) (string, error) {
+	known := make(map[string]bool)
+	var sections []string
+	for _, item := range f.Body {
+		if item.Type == "markdown" {
+			continue
+		}
+		if item.ID != "" {
+			known[item.ID] = true
+		}
+		value, given := values[item.ID]
+		if item.ID == "" || !given {
+			value = item.defaultValue()
+		}
+		text, err := item.renderValue(value)
+		if err != nil {
+			return "", fmt.Errorf("form field '%s': %w", item.ID, err)
+		}
+		if text == "" {
+			if item.Validations.Required {
+				return "", fmt.Errorf("form field '%s' (%s) is required", item.ID, item.Attributes.Label)
+			}
+			text = noResponse
+		}
+		sections = append(sections, fmt.Sprintf("### %s\n\n%s", item.Attributes.Label, text))
+	}
+	for id := range values {
+		if !known[id] {
+			return "", fmt.Errorf("form '%s' has no field with id '%s'", f.Name, id)
+		}
+	}
+	return strings.Join(sections, "\n\n"), nil
+}
+
+// defaultValue is the value of an untouched field in the web UI
+func (item IssueFormItem) defaultValue() interface{} {
+	switch item.Type {
+	case "dropdown":
+		if d := item.Attributes.Default; d != nil && *d >= 0 && *d < len(item.Attributes.Options) {
+			return item.Attributes.Options[*d].Label
+		}
+		return nil
+	case "checkboxes":
+		return nil
+	}
+	return item.Attributes.Value
+}
+
+// renderValue formats a field value: text for inputs and textareas, the selected
+// option(s) for dropdowns and a task list for checkboxes
+func (item IssueFormItem) renderValue(value interface{}) (string, error) {
+	selected, err := formValues(value)
+	if err != nil {
+		return "", err
+	}
+	switch item.Type {
+	case "checkboxes":
+		var lines []string
+		for _, option := range item.Attributes.Options {
+			mark := " "
+			if containsFold(selected, option.Label) {
+				mark = "X"
+			}
+			lines = append(lines, fmt.Sprintf("- [%s] %s", mark, option.Label))
+		}
+		for _, s := range selected {
+			if !hasOption(item.Attributes.Options, s) {
+				return "", fmt.Errorf("unknown checkbox '%s'", s)
+			}
+		}
+		return strings.Join(lines, "\n"), nil
+	case "dropdown":
+		if len(selected) > 1 && !item.Attributes.Multiple {
+			return "", fmt.Errorf("only one option may be selected")
+		}
+		for _, s := range selected {
+			if !hasOption(item.Attributes.Options, s) {
+				return "", fmt.Errorf("unknown option '%s'", s)
+			}
+		}
+		return strings.Join(selected, ", "), nil
+	}
+	text := strings.TrimSpace(strings.Join(selected, "\n"))
+	if text != "" && item.Type == "textarea" && item.Attributes.Render != "" {
+		text = fmt.Sprintf("```%s\n%s\n```", item.Attributes.Render, text)
+	}
+	return text, nil
+}
+
+// formValues accepts a single value or a list of values from issues.json
+func formValues(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		return []string{v}, nil
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings, got %v", item)
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("expected a string or a list of strings, got %v", value)
+}
+
+func hasOption(options []formOption, label string) bool {
+	for _, option := range options {
+		if option.Label == label {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
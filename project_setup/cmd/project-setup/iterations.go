@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// Defaults of the project iteration field
+const (
+	defaultIterationField     = "Iteration"
+	defaultFirstIterationDays = 14
+)
+
+// ProjectConfig names an organization project (Projects v2) whose iteration field follows the milestones
+type ProjectConfig struct {
+	Owner          string `json:"owner"`           // Organization owning the project
+	Number         int    `json:"number"`          // Project number, as in the project URL
+	IterationField string `json:"iteration_field"` // Name of the iteration field, default "Iteration"
+	// Length of the first iteration, which has no earlier milestone to start from
+	FirstIterationDays int `json:"first_iteration_days"`
+}
+
+// enabled reports whether a project is configured
+func (c ProjectConfig) enabled() bool {
+	return c.Owner != "" || c.Number != 0
+}
+
+// validate checks the project settings and fills in defaults
+func (c *ProjectConfig) validate() error {
+	if !c.enabled() {
+		return nil
+	}
+	if c.Owner == "" || c.Number <= 0 {
+		return fmt.Errorf("project needs both an owner and a positive number")
+	}
+	if c.FirstIterationDays < 0 {
+		return fmt.Errorf("invalid project first_iteration_days %d", c.FirstIterationDays)
+	}
+	if c.IterationField == "" {
+		c.IterationField = defaultIterationField
+	}
+	if c.FirstIterationDays == 0 {
+		c.FirstIterationDays = defaultFirstIterationDays
+	}
+	return nil
+}
+
+// projectIteration is one iteration of an iteration field, in the shape of the GraphQL input
+type projectIteration struct {
+	Title     string `json:"title"`
+	StartDate string `json:"startDate"` // YYYY-MM-DD
+	Duration  int    `json:"duration"`  // Days
+}
+
+// milestoneIterations derives one iteration per milestone with a due date, in due date order:
+// each iteration starts the day after the previous milestone is due and ends on its own due date
+func milestoneIterations(milestones []MilestoneData, firstDays int) []projectIteration {
+	type dated struct {
+		title string
+		due   time.Time
+	}
+	var due []dated
+	for _, milestone := range milestones {
+		if milestone.DueOn == nil {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, *milestone.DueOn)
+		if err != nil {
+			continue // Rejected by definitions.validate
+		}
+		due = append(due, dated{milestone.Title, time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)})
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].due.Before(due[j].due) })
+
+	iterations := make([]projectIteration, 0, len(due))
+	for i, d := range due {
+		start := d.due.AddDate(0, 0, 1-firstDays)
+		if i > 0 {
+			start = due[i-1].due.AddDate(0, 0, 1)
+		}
+		days := int(d.due.Sub(start).Hours()/24) + 1
+		if days < 1 {
+			// Milestones due on the same day share it
+			start, days = d.due, 1
+		}
+		iterations = append(iterations, projectIteration{Title: d.title, StartDate: start.Format("2006-01-02"), Duration: days})
+	}
+	return iterations
+}
+
+// projectIterationField is the iteration field of a project as returned by GraphQL
+type projectIterationField struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Configuration struct {
+		Iterations          []projectIteration `json:"iterations"`
+		CompletedIterations []projectIteration `json:"completedIterations"`
+	} `json:"configuration"`
+}
+
+const projectFieldsQuery = `query($owner: String!, $number: Int!) {
+  organization(login: $owner) {
+    projectV2(number: $number) {
+      id
+      fields(first: 100) {
+        nodes { ... on ProjectV2IterationField { id name configuration {
+          iterations { title startDate duration }
+          completedIterations { title startDate duration }
+        } } }
+      }
+    }
+  }
+}`
+
+const createIterationFieldMutation = `mutation($project: ID!, $name: String!, $config: ProjectV2IterationFieldConfigurationInput!) {
+  createProjectV2Field(input: {projectId: $project, dataType: ITERATION, name: $name, iterationConfiguration: $config}) {
+    projectV2Field { ... on ProjectV2IterationField { id } }
+  }
+}`
+
+const updateIterationFieldMutation = `mutation($field: ID!, $config: ProjectV2IterationFieldConfigurationInput!) {
+  updateProjectV2Field(input: {fieldId: $field, iterationConfiguration: $config}) {
+    projectV2Field { ... on ProjectV2IterationField { id } }
+  }
+}`
+
+// getProjectIterationField returns the project's node ID and its iteration field called name, nil when missing
+func getProjectIterationField(ctx context.Context, project ProjectConfig) (string, *projectIterationField, error) {
+	data, err := sendGraphQLQuery(ctx, projectFieldsQuery, map[string]interface{}{"owner": project.Owner, "number": project.Number})
+	if err != nil {
+		return "", nil, fmt.Errorf("error fetching project %s/%d: %w", project.Owner, project.Number, err)
+	}
+	var org struct {
+		ProjectV2 *struct {
+			ID     string `json:"id"`
+			Fields struct {
+				Nodes []projectIterationField `json:"nodes"`
+			} `json:"fields"`
+		} `json:"projectV2"`
+	}
+	if raw := data["organization"]; len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &org); err != nil {
+			return "", nil, fmt.Errorf("error unmarshalling project %s/%d: %w", project.Owner, project.Number, err)
+		}
+	}
+	if org.ProjectV2 == nil {
+		return "", nil, fmt.Errorf("project %s/%d not found or not accessible with this token", project.Owner, project.Number)
+	}
+	for _, field := range org.ProjectV2.Fields.Nodes {
+		if field.ID != "" && field.Name == project.IterationField {
+			return org.ProjectV2.ID, &field, nil
+		}
+	}
+	return org.ProjectV2.ID, nil, nil
+}
+
+// sameIterations compares the iterations of a field, completed ones included, with the wanted ones
+func sameIterations(field *projectIterationField, want []projectIteration) bool {
+	have := append(append([]projectIteration{}, field.Configuration.CompletedIterations...), field.Configuration.Iterations...)
+	if len(have) != len(want) {
+		return false
+	}
+	sort.Slice(have, func(i, j int) bool { return have[i].StartDate < have[j].StartDate })
+	for i := range have {
+		if have[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// syncProjectIterations creates or updates the iteration field of the configured project,
+// so its iterations match the milestone cadence
+func syncProjectIterations(ctx context.Context, project ProjectConfig, milestones []MilestoneData) error {
+	log.Printf("--- Processing Iterations of Project %s/%d ---", project.Owner, project.Number)
+	iterations := milestoneIterations(milestones, project.FirstIterationDays)
+	if len(iterations) == 0 {
+		log.Printf("No milestone has a due date, no iterations to create.")
+		return nil
+	}
+
+	projectID, field, err := getProjectIterationField(ctx, project)
+	if err != nil {
+		return err
+	}
+	// Iterations GitHub adds on its own continue with the cadence of the last milestone
+	configuration := map[string]interface{}{
+		"startDate":  iterations[0].StartDate,
+		"duration":   iterations[len(iterations)-1].Duration,
+		"iterations": iterations,
+	}
+
+	switch {
+	case field == nil:
+		log.Printf("Creating iteration field \"%s\" with %d iterations.", project.IterationField, len(iterations))
+		variables := map[string]interface{}{"project": projectID, "name": project.IterationField, "config": configuration}
+		if _, err := sendGraphQLQuery(ctx, createIterationFieldMutation, variables); err != nil {
+			return fmt.Errorf("error creating iteration field \"%s\": %w", project.IterationField, err)
+		}
+	case sameIterations(field, iterations):
+		log.Printf("Iteration field \"%s\" already matches the milestones.", project.IterationField)
+	default:
+		log.Printf("Updating iteration field \"%s\" to %d iterations.", project.IterationField, len(iterations))
+		variables := map[string]interface{}{"field": field.ID, "config": configuration}
+		if _, err := sendGraphQLQuery(ctx, updateIterationFieldMutation, variables); err != nil {
+			return fmt.Errorf("error updating iteration field \"%s\": %w", project.IterationField, err)
+		}
+	}
+	return nil
+}
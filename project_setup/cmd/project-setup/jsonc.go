@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"os"
+)
+
+// jsoncReader turns JSONC (JSON with // and /* */ comments and trailing commas) into
+// plain JSON while it is read, so commented definition files stream into the JSON
+// decoder like any other. Plain JSON passes through unchanged.
+type jsoncReader struct {
+	in  *bufio.Reader
+	out []byte
+	err error
+
+	inString, escaped bool
+	// A comma and the whitespace after it, held back until the next token shows whether
+	// it trails
+	comma []byte
+}
+
+func newJSONCReader(r io.Reader) io.Reader {
+	return &jsoncReader{in: bufio.NewReader(r)}
+}
+
+func (j *jsoncReader) Read(p []byte) (int, error) {
+	for len(j.out) == 0 && j.err == nil {
+		j.step()
+	}
+	n := copy(p, j.out)
+	j.out = j.out[n:]
+	if n == 0 {
+		return 0, j.err
+	}
+	return n, nil
+}
+
+// step consumes one byte of the input, or one comment
+func (j *jsoncReader) step() {
+	c, err := j.in.ReadByte()
+	if err != nil {
+		j.out, j.comma = append(j.out, j.comma...), nil
+		j.err = err
+		return
+	}
+	if j.inString {
+		j.out = append(j.out, c)
+		switch {
+		case j.escaped:
+			j.escaped = false
+		case c == '\\':
+			j.escaped = true
+		case c == '"':
+			j.inString = false
+		}
+		return
+	}
+	switch c {
+	case ' ', '\t', '\r', '\n':
+		j.space(c)
+	case ',':
+		j.out = append(j.out, j.comma...) // Two commas in a row stay an error
+		j.comma = []byte{','}
+	case ']', '}':
+		if j.comma != nil {
+			j.out, j.comma = append(j.out, j.comma[1:]...), nil
+		}
+		j.out = append(j.out, c)
+	case '/':
+		if next, err := j.in.Peek(1); err == nil && (next[0] == '/' || next[0] == '*') {
+			j.skipComment(next[0] == '*')
+			return
+		}
+		fallthrough
+	default:
+		j.out, j.comma = append(j.out, j.comma...), nil
+		j.out = append(j.out, c)
+		j.inString = c == '"'
+	}
+}
+
+// space keeps whitespace, after a held back comma when there is one
+func (j *jsoncReader) space(c byte) {
+	if j.comma != nil {
+		j.comma = append(j.comma, c)
+	} else {
+		j.out = append(j.out, c)
+	}
+}
+
+// skipComment drops a comment whose opening '/' was read; a line comment leaves its
+// line break
+func (j *jsoncReader) skipComment(block bool) {
+	j.in.ReadByte()
+	if !block {
+		if _, err := j.in.ReadString('\n'); err == nil {
+			j.space('\n')
+		}
+		return
+	}
+	for previous := byte(0); ; {
+		c, err := j.in.ReadByte()
+		if err != nil {
+			j.err = errors.New("unterminated /* comment")
+			return
+		}
+		if previous == '*' && c == '/' {
+			j.space(' ')
+			return
+		}
+		previous = c
+	}
+}
+
+// stripJSONC returns data as plain JSON
+func stripJSONC(data []byte) ([]byte, error) {
+	return io.ReadAll(newJSONCReader(bytes.NewReader(data)))
+}
+
+// warnDroppedComments warns before a definition file is rewritten as plain JSON, which
+// does not keep its comments
+func warnDroppedComments(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if plain, err := stripJSONC(data); err == nil && !bytes.Equal(plain, data) {
+		log.Printf("Warning: %s is rewritten as plain JSON, its comments and trailing commas are not kept.", path)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStripJSONC(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain JSON", `{"a": [1, 2], "b": "c"}`, `{"a": [1, 2], "b": "c"}`},
+		{"line comment", "{\"a\": 1 // the answer\n}", "{\"a\": 1 \n}"},
+		{"block comment", `[1, /* two */ 2]`, `[1,   2]`},
+		{"trailing comma in an array", "[1, 2,\n]", "[1, 2\n]"},
+		{"trailing comma in an object", `{"a": 1, }`, `{"a": 1 }`},
+		{"trailing comma before a comment", "[1, // more to come\n]", "[1 \n]"},
+		{"comment markers in strings", `{"url": "https://x/*y*/", "c": "// no"}`, `{"url": "https://x/*y*/", "c": "// no"}`},
+		{"escaped quote in a string", `["a\"//b", 1,]`, `["a\"//b", 1]`},
+		{"double commas stay", `[1,,2]`, `[1,,2]`},
+		{"division-like slash", `[1, /2]`, `[1, /2]`},
+		{"comment at the end", "[1] // done", "[1] "},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := stripJSONC([]byte(tc.in))
+			if err != nil {
+				t.Fatalf("stripJSONC(%q) failed: %v", tc.in, err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("stripJSONC(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStripJSONCUnterminatedComment(t *testing.T) {
+	if _, err := stripJSONC([]byte(`[1, /* open`)); err == nil {
+		t.Error("stripJSONC() accepted an unterminated comment")
+	}
+}
+
+func TestJSONCDecodes(t *testing.T) {
+	in := `[
+		// Labels every repository gets
+		{"name": "bug", "color": "d73a4a",},
+		/* {"name": "wontfix"}, */
+		{"name": "docs", "color": "0075ca"},
+	]`
+	plain, err := stripJSONC([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []LabelData
+	if err := json.Unmarshal(plain, &labels); err != nil {
+		t.Fatalf("stripped JSONC does not decode: %v\n%s", err, plain)
+	}
+	if len(labels) != 2 || labels[0].Name != "bug" || labels[1].Name != "docs" {
+		t.Errorf("decoded %+v, want bug and docs", labels)
+	}
+}
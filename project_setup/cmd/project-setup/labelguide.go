@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LabelGuideConfig enables the generated label guide committed to every repository
+type LabelGuideConfig struct {
+	Path string `json:"path,omitempty"` // e.g. "docs/LABELS.md"; empty to disable
+}
+
+// renderLabelGuide documents every defined label with a color swatch and its description,
+// in the order of the definitions
+func renderLabelGuide(labels []LabelData, source string) string {
+	var b strings.Builder
+	b.WriteString("# Label guide\n\n")
+	fmt.Fprintf(&b, "_Generated by the project setup from `%s`, edit that file instead: this one is regenerated on every sync._\n\n", source)
+	if len(labels) == 0 {
+		b.WriteString("No labels are defined.\n")
+		return b.String()
+	}
+	b.WriteString("| Label | Color | Usage |\n|---|---|---|\n")
+	for _, label := range labels {
+		usage := label.Description
+		if usage == "" {
+			usage = "_No description_"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", markdownCell("`"+label.Name+"`"), colorSwatch(label.Color), markdownCell(usage))
+	}
+	return b.String()
+}
+
+// colorSwatch shows a color in Markdown: GitHub renders the colored square with its math
+// support, the code keeps the value readable
+func colorSwatch(color string) string {
+	return fmt.Sprintf("$\\color{#%s}{\\blacksquare}$ `#%s`", color, color)
+}
+
+// markdownCell escapes text for a Markdown table cell
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(text)
+}
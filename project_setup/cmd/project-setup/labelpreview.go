@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// maxPreviewRepos bounds the repositories listed per row of the label preview
+const maxPreviewRepos = 10
+
+// labelColorChange is the color of a label before and after applying; From is empty for
+// a label that is created
+type labelColorChange struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+}
+
+// previewRow is one visual change of the label preview with the repositories it applies to
+type previewRow struct {
+	name, from, to string
+	repos          []string
+}
+
+// labelColorRows groups the planned color changes of every repository by label and colors
+func labelColorRows(plan changePlan) []*previewRow {
+	rows := make(map[string]*previewRow)
+	for _, repo := range plan.Repos {
+		for _, change := range repo.Changes {
+			if change.Kind != "label" || change.Color == nil || (change.Action != actionCreate && change.Action != actionUpdate) {
+				continue
+			}
+			from, to := strings.ToLower(change.Color.From), strings.ToLower(change.Color.To)
+			key := strings.ToLower(change.Name) + "\n" + from + "\n" + to
+			if rows[key] == nil {
+				rows[key] = &previewRow{name: change.Name, from: from, to: to}
+			}
+			rows[key].repos = append(rows[key].repos, repo.Repo)
+		}
+	}
+	sorted := make([]*previewRow, 0, len(rows))
+	for _, row := range rows {
+		sorted = append(sorted, row)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if !strings.EqualFold(sorted[i].name, sorted[j].name) {
+			return strings.ToLower(sorted[i].name) < strings.ToLower(sorted[j].name)
+		}
+		return sorted[i].from < sorted[j].from
+	})
+	return sorted
+}
+
+// previewRepos lists the repositories of a row, shortened for org-wide syncs
+func previewRepos(repos []string) string {
+	if len(repos) <= maxPreviewRepos {
+		return markdownCell(strings.Join(repos, ", "))
+	}
+	return markdownCell(fmt.Sprintf("%s and %d more", strings.Join(repos[:maxPreviewRepos], ", "), len(repos)-maxPreviewRepos))
+}
+
+// renderLabelPreview shows the label color changes of a plan side by side, old and new,
+// so they can be approved before anything is applied
+func renderLabelPreview(plan changePlan) string {
+	var changed, created []*previewRow
+	for _, row := range labelColorRows(plan) {
+		if row.from == "" {
+			created = append(created, row)
+		} else {
+			changed = append(changed, row)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# Label color preview\n\n")
+	fmt.Fprintf(&b, "_Generated by `plan` on %s for template hash `%s` across %d repositories. Nothing has been changed yet._\n\n",
+		plan.GeneratedAt.Format("2006-01-02 15:04 UTC"), plan.TemplateHash, len(plan.Repos))
+	if len(changed) == 0 && len(created) == 0 {
+		b.WriteString("No label colors change.\n")
+		return b.String()
+	}
+	if len(changed) > 0 {
+		b.WriteString("## Changed colors\n\n")
+		b.WriteString("Existing labels whose color differs from the definitions. They are recolored as the label policy and `--on-conflict` decide.\n\n")
+		b.WriteString("| Label | Old | New | Repositories |\n|---|---|---|---|\n")
+		for _, row := range changed {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", markdownCell("`"+row.name+"`"), colorSwatch(row.from), colorSwatch(row.to), previewRepos(row.repos))
+		}
+		b.WriteString("\n")
+	}
+	if len(created) > 0 {
+		b.WriteString("## New labels\n\n")
+		b.WriteString("| Label | Color | Repositories |\n|---|---|---|\n")
+		for _, row := range created {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", markdownCell("`"+row.name+"`"), colorSwatch(row.to), previewRepos(row.repos))
+		}
+	}
+	return b.String()
+}
+
+// writeLabelPreview writes the label color preview of a plan to path
+func writeLabelPreview(path string, plan changePlan) error {
+	if err := os.WriteFile(path, []byte(renderLabelPreview(plan)), 0o644); err != nil {
+		return fmt.Errorf("error writing label preview %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+const (
+	// maxIssueLabels is the most labels GitHub allows on one issue
+	maxIssueLabels = 100
+	// issueLabelsPerRequest bounds the labels added by one request; larger payloads are
+	// rejected or time out on busy repositories
+	issueLabelsPerRequest = 30
+)
+
+// labelMerge is what merging the defined labels into an existing issue does
+type labelMerge struct {
+	Add       []string // Defined labels the issue does not have yet
+	Replaced  []string // Labels of the issue that go because an added scoped label has their scope
+	Conflicts []string // Defined labels that are not added, and why
+}
+
+// mergeIssueLabels works out which defined labels an existing issue is missing. Its own
+// labels are kept, except that a defined scoped label replaces the issue's labels of the
+// same scope, as everywhere else labels are added. Labels beyond GitHub's limit per issue
+// are reported as conflicts instead.
+func mergeIssueLabels(existing github.Issue, defined []string) labelMerge {
+	var merge labelMerge
+	have := make([]string, 0, len(existing.Labels))
+	for _, label := range existing.Labels {
+		have = append(have, label.Name)
+	}
+	for _, name := range defined {
+		if containsFold(have, name) || containsFold(merge.Add, name) {
+			continue
+		}
+		var siblings []string
+		if scope, ok := labelScope(name); ok {
+			if other := labelOfScope(merge.Add, scope); other != "" {
+				merge.Conflicts = append(merge.Conflicts, fmt.Sprintf("'%s' not added, '%s' of the same scope is", name, other))
+				continue
+			}
+			siblings = scopeSiblings(existing, name)
+		}
+		if len(have)-len(siblings)+len(merge.Add) >= maxIssueLabels {
+			merge.Conflicts = append(merge.Conflicts, fmt.Sprintf("'%s' not added, the issue would have more than %d labels", name, maxIssueLabels))
+			continue
+		}
+		for _, sibling := range siblings {
+			have = removeFold(have, sibling)
+		}
+		merge.Replaced = append(merge.Replaced, siblings...)
+		merge.Add = append(merge.Add, name)
+	}
+	return merge
+}
+
+// removeFold returns list without the entries equal to s, ignoring case
+func removeFold(list []string, s string) []string {
+	kept := list[:0:0]
+	for _, item := range list {
+		if !strings.EqualFold(item, s) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// labelOfScope returns the first of labels with the given scope, or ""
+func labelOfScope(labels []string, scope string) string {
+	for _, name := range labels {
+		if other, ok := labelScope(name); ok && other == scope {
+			return name
+		}
+	}
+	return ""
+}
+
+// chunkLabels splits labels into requests of at most issueLabelsPerRequest
+func chunkLabels(labels []string) [][]string {
+	var chunks [][]string
+	for len(labels) > issueLabelsPerRequest {
+		chunks = append(chunks, labels[:issueLabelsPerRequest])
+		labels = labels[issueLabelsPerRequest:]
+	}
+	if len(labels) > 0 {
+		chunks = append(chunks, labels)
+	}
+	return chunks
+}
+
+// syncIssueLabels adds the defined labels an existing issue is missing, with the
+// merge-labels issue policy; it reports whether labels were added
+func syncIssueLabels(ctx context.Context, run *repoRun, existing github.Issue, issue IssueData) (bool, error) {
+	merge := mergeIssueLabels(existing, issue.Labels)
+	for _, conflict := range merge.Conflicts {
+		log.Printf("Label conflict on issue #%d \"%s\": %s.", existing.Number, issue.Title, conflict)
+		run.mu.Lock()
+		run.skipped = append(run.skipped, fmt.Sprintf("issue #%d label %s", existing.Number, conflict))
+		run.mu.Unlock()
+	}
+	if len(merge.Add) == 0 {
+		return false, nil
+	}
+	if err := addIssueLabels(ctx, run.target, existing, merge.Add); err != nil {
+		return false, err
+	}
+	log.Printf("Added %d labels to existing issue #%d \"%s\": %s", len(merge.Add), existing.Number, issue.Title, strings.Join(merge.Add, ", "))
+	time.Sleep(requestDelay)
+	return true, nil
+}
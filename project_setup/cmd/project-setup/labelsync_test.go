@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+func TestMergeIssueLabels(t *testing.T) {
+	issueWith := func(names ...string) github.Issue {
+		issue := github.Issue{Number: 7}
+		for _, name := range names {
+			issue.Labels = append(issue.Labels, github.Label{Name: name})
+		}
+		return issue
+	}
+	var full []string
+	for i := 0; i < maxIssueLabels; i++ {
+		full = append(full, fmt.Sprintf("label-%d", i))
+	}
+
+	tests := []struct {
+		name          string
+		existing      github.Issue
+		defined       []string
+		wantAdd       []string
+		wantReplaced  []string
+		wantConflicts int
+	}{
+		{"missing labels are added", issueWith("bug"), []string{"Bug", "ui"}, []string{"ui"}, nil, 0},
+		{"scoped label replaces the issue's own", issueWith("priority::low", "bug"), []string{"Priority::high"}, []string{"Priority::high"}, []string{"priority::low"}, 0},
+		{"scoped label already there", issueWith("priority::high"), []string{"priority::high"}, nil, nil, 0},
+		{"two defined labels of a scope", issueWith(), []string{"priority::high", "priority::low"}, []string{"priority::high"}, nil, 1},
+		{"label limit", issueWith(full...), []string{"ui"}, nil, nil, 1},
+		{"replacement frees a slot at the limit", issueWith(append(full[1:], "priority::low")...), []string{"priority::high"}, []string{"priority::high"}, []string{"priority::low"}, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			merge := mergeIssueLabels(tc.existing, tc.defined)
+			if !reflect.DeepEqual(merge.Add, tc.wantAdd) || !reflect.DeepEqual(merge.Replaced, tc.wantReplaced) || len(merge.Conflicts) != tc.wantConflicts {
+				t.Errorf("mergeIssueLabels() = %+v, want add %q, replaced %q and %d conflicts", merge, tc.wantAdd, tc.wantReplaced, tc.wantConflicts)
+			}
+		})
+	}
+}
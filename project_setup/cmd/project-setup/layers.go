@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Definition layers are directories with any of labels.json, milestones.json, issues.json
+// and config.json, merged in the order given (e.g. org base, stack, project):
+//   - labels, milestones and issues are matched by name/title; a later layer replaces
+//     a matching entry in place and appends new ones
+//   - config.json objects are merged key by key, lists and values of a later layer replace earlier ones
+//   - relative file paths in a layer (issue forms, body fragments, file sources,
+//     ceremony descriptions, community templates) are relative to the layer's directory
+
+// loadLayeredConfig merges the config.json of every layer that has one
+func loadLayeredConfig(layers []string) (Config, error) {
+	merged := make(map[string]interface{})
+	for _, dir := range layers {
+		path := filepath.Join(dir, configJSONPath)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Config{}, fmt.Errorf("error reading config file %s: %w", path, err)
+		}
+		var layer map[string]interface{}
+		if data, err = stripJSONC(data); err != nil {
+			return Config{}, fmt.Errorf("error reading config file %s: %w", path, err)
+		}
+		if err := json.Unmarshal(data, &layer); err != nil {
+			return Config{}, fmt.Errorf("error unmarshalling config JSON %s: %w", path, err)
+		}
+		rebaseConfigPaths(layer, dir)
+		mergeJSONObjects(merged, layer)
+		log.Printf("Merged config layer %s.", path)
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return Config{}, fmt.Errorf("error marshalling merged config: %w", err)
+	}
+	return parseConfig(data, strings.Join(layers, "+"))
+}
+
+// mergeJSONObjects merges overlay into base: nested objects recursively, anything else replaced
+func mergeJSONObjects(base, overlay map[string]interface{}) {
+	for key, value := range overlay {
+		if nested, ok := value.(map[string]interface{}); ok {
+			if existing, ok := base[key].(map[string]interface{}); ok {
+				mergeJSONObjects(existing, nested)
+				continue
+			}
+		}
+		base[key] = value
+	}
+}
+
+// rebaseConfigPaths makes the relative file paths of a layer's config relative to its directory
+func rebaseConfigPaths(cfg map[string]interface{}, dir string) {
+	if body, ok := cfg["issue_body"].(map[string]interface{}); ok {
+		rebasePath(body, "header_file", dir)
+		rebasePath(body, "footer_file", dir)
+	}
+	if files, ok := cfg["files"].([]interface{}); ok {
+		for _, file := range files {
+			if file, ok := file.(map[string]interface{}); ok {
+				rebasePath(file, "source", dir)
+			}
+		}
+	}
+	if ceremonies, ok := cfg["ceremonies"].([]interface{}); ok {
+		for _, ceremony := range ceremonies {
+			if ceremony, ok := ceremony.(map[string]interface{}); ok {
+				rebasePath(ceremony, "description_file", dir)
+			}
+		}
+	}
+	if calendar, ok := cfg["calendar"].(map[string]interface{}); ok {
+		rebasePath(calendar, "path", dir)
+	}
+	if community, ok := cfg["community"].(map[string]interface{}); ok {
+		if templates, ok := community["templates"].(map[string]interface{}); ok {
+			for name := range templates {
+				rebasePath(templates, name, dir)
+			}
+		}
+	}
+}
+
+func rebasePath(m map[string]interface{}, key, dir string) {
+	if path, ok := m[key].(string); ok && path != "" && !filepath.IsAbs(path) {
+		m[key] = filepath.Join(dir, path)
+	}
+}
+
+// loadLayers reads the label, milestone and issue definitions of every layer and merges them
+func loadLayers(layers []string) (labels []LabelData, milestones []MilestoneData, issues []IssueData, err error) {
+	for _, dir := range layers {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, nil, nil, fmt.Errorf("definition layer %s is not a directory", dir)
+		}
+		if path, ok := findDefinitionFile(dir, labelsJSONPath); ok {
+			layer, err := loadLabels(path)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			labels = mergeByKey(labels, layer, func(l LabelData) string { return strings.ToLower(l.Name) })
+		}
+		if path, ok := findDefinitionFile(dir, milestonesJSONPath); ok {
+			layer, err := loadMilestones(path)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			milestones = mergeByKey(milestones, layer, func(m MilestoneData) string { return m.Title })
+		}
+		if path, ok := findDefinitionFile(dir, issuesJSONPath); ok {
+			layer, err := loadIssues(path)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			for i := range layer {
+				if form := layer[i].Form; form != "" && !filepath.IsAbs(form) {
+					layer[i].Form = filepath.Join(dir, form)
+				}
+			}
+			issues = mergeByKey(issues, layer, func(i IssueData) string { return i.Title })
+		}
+		log.Printf("Merged definition layer %s (%d labels, %d milestones, %d issues so far).", dir, len(labels), len(milestones), len(issues))
+	}
+	return labels, milestones, issues, nil
+}
+
+// mergeByKey replaces the entries of base that overlay redefines, in place, and appends the rest
+func mergeByKey[T any](base, overlay []T, key func(T) string) []T {
+	index := make(map[string]int, len(base))
+	for i, item := range base {
+		index[key(item)] = i
+	}
+	for _, item := range overlay {
+		if i, ok := index[key(item)]; ok {
+			base[i] = item
+			continue
+		}
+		index[key(item)] = len(base)
+		base = append(base, item)
+	}
+	return base
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
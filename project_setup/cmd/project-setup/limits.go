@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// defaultRequestTimeout bounds a single API request unless --request-timeout says otherwise
+const defaultRequestTimeout = 20 * time.Second
+
+// exitDeadline is the exit code of a run stopped by --run-deadline (EX_TEMPFAIL), so a
+// scheduler can tell it from a failure and resume the run later
+const exitDeadline = 75
+
+// runLimits are the time budgets given on the command line
+type runLimits struct {
+	requestTimeout time.Duration
+	runDeadline    time.Duration
+}
+
+func (l *runLimits) register(fs *flag.FlagSet) {
+	fs.DurationVar(&l.requestTimeout, "request-timeout", defaultRequestTimeout, "Timeout of a single API request")
+	fs.DurationVar(&l.runDeadline, "run-deadline", 0, "Total wall-clock budget of the run, e.g. 30m (0 for none); when it is reached the run checkpoints and exits with code 75")
+}
+
+// check rejects impossible budgets
+func (l *runLimits) check() error {
+	if l.requestTimeout <= 0 {
+		return errors.New("--request-timeout must be positive")
+	}
+	if l.runDeadline < 0 {
+		return errors.New("--run-deadline must not be negative")
+	}
+	return nil
+}
+
+// start applies the request timeout and returns a context that ends at the run deadline
+func (l *runLimits) start(ctx context.Context) (context.Context, context.CancelFunc) {
+	httpClient.Timeout = l.requestTimeout
+	if l.runDeadline == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, l.runDeadline)
+}
+
+// deadlineReached reports whether the run deadline of ctx has passed
+func deadlineReached(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// exitOnDeadline ends a run stopped by --run-deadline with exitDeadline; it must be called
+// after everything worth keeping (run state, write-back) has been saved
+func exitOnDeadline(ctx context.Context) {
+	if deadlineReached(ctx) {
+		log.Printf("Error: the run deadline was reached before all work was done; run again to resume.")
+		os.Exit(exitDeadline)
+	}
+}
+
+// readResponseBody reads a whole response body within config.json's max_response_bytes
+func readResponseBody(r io.Reader) ([]byte, error) {
+	return apiClient().ReadBody(r)
+}
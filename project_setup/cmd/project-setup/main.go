@@ -0,0 +1,842 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/engine"
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// --- Configuration ---
+const (
+	issuesJSONPath     = "issues.json"
+	milestonesJSONPath = "milestones.json"
+	labelsJSONPath     = "labels.json"
+	configJSONPath     = "config.json"   // Optional, run-wide settings
+	requestDelay       = 1 * time.Second // Delay to avoid hitting rate limits
+)
+
+// --- Structs for JSON Data ---
+
+// LabelData matches the structure in labels.json
+type LabelData struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Color       string `json:"color"` // Color hex code without '#'
+	// 1 (lowest) to 5, colors the label from config.json's severity_colors when color is empty
+	Severity int `json:"severity,omitempty"`
+}
+
+// MilestoneData matches the structure in milestones.json
+type MilestoneData struct {
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	DueOn       *string `json:"due_on,omitempty"` // Use pointer for optionality
+}
+
+// engineLabel is the label as the engine reconciles it
+func (l LabelData) engineLabel() engine.Label {
+	return engine.Label{Name: l.Name, Description: l.Description, Color: l.Color}
+}
+
+// engineMilestone is the milestone as the engine reconciles it
+func (m MilestoneData) engineMilestone() engine.Milestone {
+	return engine.Milestone{Title: m.Title, Description: m.Description, DueOn: m.DueOn}
+}
+
+// IssueData matches the structure in issues.json, uses Milestone Title
+type IssueData struct {
+	Title          string   `json:"title"`
+	Description    string   `json:"description"`
+	Labels         []string `json:"labels"`                    // Uses label names
+	Assignees      []string `json:"assignees,omitempty"`       // User logins or "@org/team", "?" marks fallbacks
+	MilestoneTitle *string  `json:"milestone_title,omitempty"` // Link by title
+	Reactions      []string `json:"reactions,omitempty"`       // e.g. "rocket", added after creation
+	Components     []string `json:"components,omitempty"`      // Names from components.json
+	// Rendered as a task list under acceptanceCriteriaHeading
+	AcceptanceCriteria []string `json:"acceptance_criteria,omitempty"`
+	// Path to a GitHub issue form; its fields are rendered into the body
+	Form   string                 `json:"form,omitempty"`
+	Fields map[string]interface{} `json:"fields,omitempty"` // Form field id -> value
+	Output *IssueOutput           `json:"output,omitempty"` // Created issue, from --write-back
+	ID     string                 `json:"id,omitempty"`     // Key other issues refer to as parent
+	Parent string                 `json:"parent,omitempty"` // ID of the epic this issue belongs to
+	// Values of fields of config.json's project by field name, e.g. {"Estimate": 3}; the issue is
+	// added to the project when set
+	ProjectFields map[string]interface{} `json:"project_fields,omitempty"`
+
+	muteMentions bool          // Set by processIssues with --mute-mentions
+	templateData *templateData // Set by processIssues with issue_body.templates
+}
+
+// Config matches the structure in config.json. Every setting is optional.
+type Config struct {
+	IssueBody BodyTemplate    `json:"issue_body"` // Fragments added to every issue body
+	Files     []FileData      `json:"files"`      // Files committed to the repository
+	Commit    CommitConfig    `json:"commit"`     // Branch / pull request settings for committed files
+	Community CommunityConfig `json:"community"`  // Community health files generated from templates
+	// Organization custom properties set on the repository, e.g. {"team": "payments"}
+	Properties map[string]interface{} `json:"properties"`
+	Policies   EntityPolicies         `json:"policies"` // What may be created or changed, per entity type
+	// How "@org/team" assignees are expanded into team members
+	TeamAssignees TeamAssigneeConfig `json:"team_assignees"`
+	// Organization project whose iteration field is generated from the milestones
+	Project ProjectConfig `json:"project"`
+	// Overview of the seeded plan committed to the repository
+	ProjectPlan ProjectPlanConfig `json:"project_plan"`
+	// Gradient endpoints for labels with a severity
+	SeverityColors SeverityColorConfig `json:"severity_colors"`
+	// Working days milestone due dates are moved to, skipping weekends and holidays
+	Calendar CalendarConfig `json:"calendar"`
+	// Generated documentation of the labels committed like the files
+	LabelGuide LabelGuideConfig `json:"label_guide"`
+	// Named label sets issues refer to as "bundle:<name>"
+	LabelBundles LabelBundles `json:"label_bundles"`
+	// Quiet hours and an hourly budget for the writes of a run
+	Throttle ThrottleConfig `json:"throttle"`
+	// Run-as check and the footer tracing issues back to their definitions
+	Attribution AttributionConfig `json:"attribution"`
+	// Existing issues kept on disk between runs and refreshed incrementally
+	IssueCache IssueCacheConfig `json:"issue_cache"`
+	// Match milestone titles exactly; by default "Sprint 1" and "sprint 1 " are the same milestone
+	StrictMilestoneTitles bool `json:"strict_milestone_titles,omitempty"`
+	// Expand emoji shortcodes such as :rocket: in label names, milestone titles and bodies
+	EmojiShortcodes bool `json:"emoji_shortcodes,omitempty"`
+	// OAuth app offering a token with the missing scopes when the token given lacks them
+	DeviceFlow DeviceFlowConfig `json:"device_flow"`
+	// GET responses kept on disk and shared by the runs of a pipeline
+	HTTPCache HTTPCacheConfig `json:"http_cache"`
+	// Record of the applied templates committed to every repository
+	Manifest ManifestConfig `json:"manifest"`
+	// Largest API response body read, in bytes; 32MB when unset
+	MaxResponseBytes int64 `json:"max_response_bytes,omitempty"`
+	// Issues generated for every milestone, e.g. sprint planning, retro and release checklist
+	Ceremonies Ceremonies `json:"ceremonies"`
+	// Most labels and milestones --prune deletes and issues --close-removed closes per repository
+	MassChange MassChangeConfig `json:"mass_change"`
+}
+
+// BodyTemplate holds Markdown prepended/appended to every issue body.
+// The *_file variants read the fragment from a file instead.
+type BodyTemplate struct {
+	Header     string `json:"header,omitempty"`
+	HeaderFile string `json:"header_file,omitempty"`
+	Footer     string `json:"footer,omitempty"`
+	FooterFile string `json:"footer_file,omitempty"`
+	// Render header, footer and issue descriptions as templates with the data of the target
+	Templates bool `json:"templates,omitempty"`
+
+	attribution string // Footer of config.attribution, set by checkAttribution
+}
+
+// repoTarget identifies a repository the tool operates on
+type repoTarget struct {
+	Owner string
+	Repo  string
+}
+
+func (t repoTarget) String() string {
+	return t.Owner + "/" + t.Repo
+}
+
+// parseRepoTarget parses an "owner/repo" string
+func parseRepoTarget(s string) (repoTarget, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return repoTarget{}, fmt.Errorf("invalid repository %q, expected 'owner/repo'", s)
+	}
+	return repoTarget{Owner: parts[0], Repo: parts[1]}, nil
+}
+
+// parseRepoReference parses "owner/repo", a repository URL such as
+// https://github.com/owner/repo or an SSH remote such as git@github.com:owner/repo.git.
+// host is empty for plain "owner/repo".
+func parseRepoReference(s string) (t repoTarget, host string, err error) {
+	path := s
+	switch {
+	case strings.Contains(s, "://"):
+		u, err := neturl.Parse(s)
+		if err != nil || u.Host == "" {
+			return repoTarget{}, "", fmt.Errorf("invalid repository URL %q", s)
+		}
+		host = u.Host
+		if u.Scheme == "ssh" {
+			host = u.Hostname() // The SSH port says nothing about the web/API port
+		}
+		path = u.Path
+	case strings.Contains(s, "@") && strings.Contains(s, ":"):
+		// scp-like SSH syntax: git@host:owner/repo.git
+		userHost, repoPath, _ := strings.Cut(s, ":")
+		_, host, _ = strings.Cut(userHost, "@")
+		path = repoPath
+	}
+	if host == "" {
+		t, err := parseRepoTarget(s)
+		return t, "", err
+	}
+
+	// Anything after owner/repo (e.g. /issues or /tree/main) is ignored
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return repoTarget{}, "", fmt.Errorf("invalid repository %q, expected a URL ending in owner/repo", s)
+	}
+	return repoTarget{Owner: parts[0], Repo: strings.TrimSuffix(parts[1], ".git")}, host, nil
+}
+
+// --- Global Variables ---
+var (
+	// REST API base; GITHUB_API_URL or the host of a --repo URL point it at GitHub Enterprise Server
+	githubAPIBaseURL = github.DefaultBaseURL
+	githubToken      string
+	httpClient       *http.Client
+	config           Config
+)
+
+// --- Helper Functions ---
+
+// apiClient is the GitHub client of the current run: its token, API and HTTP client
+func apiClient() *github.Client {
+	return &github.Client{
+		BaseURL:          githubAPIBaseURL,
+		Token:            githubToken,
+		HTTP:             httpClient,
+		MaxResponseBytes: config.MaxResponseBytes,
+		PageDelay:        requestDelay,
+		Redact:           secrets.redact,
+	}
+}
+
+// repoProvider is the GitHub provider of a target repository
+func repoProvider(t repoTarget) *github.Provider {
+	return github.NewProvider(apiClient(), t.Owner, t.Repo)
+}
+
+// sendGitHubRequest sends a request to the GitHub API with the client of the run
+func sendGitHubRequest(ctx context.Context, method, url string, payload interface{}) (*http.Response, []byte, error) {
+	return apiClient().Send(ctx, method, url, payload)
+}
+
+// openGitHubRequest sends an API request and returns the response with its body still
+// to be read and closed, for responses decoded as they arrive
+func openGitHubRequest(ctx context.Context, method, url string, payload interface{}) (*http.Response, error) {
+	return apiClient().Open(ctx, method, url, payload)
+}
+
+// readGitHubError reads the redacted body of an error response
+func readGitHubError(resp *http.Response) []byte {
+	return apiClient().ReadError(resp)
+}
+
+// getAllPages fetches every page of a list endpoint and decodes the items
+func getAllPages[T any](ctx context.Context, what, url string) ([]T, error) {
+	return github.GetAllPages[T](ctx, apiClient(), what, url)
+}
+
+// getExistingLabels fetches all labels from the repo, keyed by name
+func getExistingLabels(ctx context.Context, t repoTarget) (map[string]github.Label, error) {
+	labels, err := repoProvider(t).ListLabels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	labelsMap := make(map[string]github.Label)
+	for _, l := range labels {
+		labelsMap[l.Name] = l
+	}
+	log.Printf("Found %d existing labels.", len(labelsMap))
+	return labelsMap, nil
+}
+
+// createLabel creates a single label
+func createLabel(ctx context.Context, t repoTarget, label LabelData) error {
+	if err := repoProvider(t).CreateLabel(ctx, label.engineLabel()); err != nil {
+		return err
+	}
+	engine.ReportCreated(ctx, t.String(), "label", label.Name, 0)
+	return nil
+}
+
+// updateLabel overwrites the color and description of an existing label
+func updateLabel(ctx context.Context, t repoTarget, label LabelData) error {
+	return repoProvider(t).UpdateLabel(ctx, label.engineLabel())
+}
+
+// getExistingMilestones fetches all open and closed milestones from the repo, keyed by milestoneKey
+func getExistingMilestones(ctx context.Context, t repoTarget) (map[string]github.Milestone, error) {
+	milestones, err := repoProvider(t).ListMilestones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	milestonesMap := make(map[string]github.Milestone)
+	for _, m := range milestones {
+		key := milestoneKey(m.Title)
+		if other, ok := milestonesMap[key]; ok {
+			log.Printf("Warning: Milestones \"%s\" (#%d) and \"%s\" (#%d) in %s have the same title, using #%d.", other.Title, other.ID, m.Title, m.ID, t, other.ID)
+			continue
+		}
+		milestonesMap[key] = m
+	}
+	log.Printf("Found %d existing milestones.", len(milestonesMap))
+	return milestonesMap, nil
+}
+
+// updateMilestone overwrites the description and due date of an existing milestone
+func updateMilestone(ctx context.Context, t repoTarget, id int, milestone MilestoneData) error {
+	return repoProvider(t).UpdateMilestone(ctx, id, milestone.engineMilestone())
+}
+
+// createIssue creates a single issue and returns it as created by the API
+func createIssue(ctx context.Context, t repoTarget, issue IssueData, milestoneID *int) (github.Issue, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/issues", githubAPIBaseURL, t.Owner, t.Repo)
+	payload := github.IssueRequest{
+		Title:     issue.Title,
+		Body:      renderIssueBody(issue),
+		Labels:    issue.Labels, // Pass label names directly
+		Milestone: milestoneID,  // Assign the actual ID (pointer)
+		Assignees: issue.Assignees,
+	}
+
+	var createdIssue github.Issue
+	log.Printf("Attempting to create issue: \"%s\" (Milestone ID: %v, Labels: %v)", issue.Title, milestoneID, issue.Labels)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "POST", url, payload)
+	if err != nil {
+		return createdIssue, fmt.Errorf("error sending create issue request for '%s': %w", issue.Title, err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		apiErr := github.NewAPIError(resp, bodyBytes)
+		// Check for label validation errors (often 422)
+		if errors.Is(apiErr, github.ErrValidation) && apiErr.HasFieldError("Label", "") {
+			log.Printf("Error creating issue '%s': One or more labels might not exist or are invalid. Body: %s", issue.Title, string(bodyBytes))
+			return createdIssue, fmt.Errorf("error creating issue '%s': invalid labels: %w", issue.Title, apiErr)
+		}
+		return createdIssue, fmt.Errorf("error creating issue '%s': %w", issue.Title, apiErr)
+	}
+
+	if err := json.Unmarshal(bodyBytes, &createdIssue); err != nil {
+		return createdIssue, fmt.Errorf("error unmarshalling created issue response for '%s': %w", issue.Title, err)
+	}
+
+	log.Printf("Successfully created issue: \"%s\" (#%d)\n", issue.Title, createdIssue.Number)
+	engine.ReportCreated(ctx, t.String(), "issue", issue.Title, createdIssue.Number)
+	return createdIssue, nil
+}
+
+// addIssueReaction adds a reaction (e.g. "rocket") to an issue
+func addIssueReaction(ctx context.Context, t repoTarget, issueNumber int, content string) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/reactions", githubAPIBaseURL, t.Owner, t.Repo, issueNumber)
+	payload := github.ReactionRequest{Content: content}
+
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "POST", url, payload)
+	if err != nil {
+		return fmt.Errorf("error sending reaction request for issue #%d: %w", issueNumber, err)
+	}
+
+	// 200 is returned when the reaction already exists
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error adding reaction '%s' to issue #%d: %w", content, issueNumber, github.NewAPIError(resp, bodyBytes))
+	}
+
+	log.Printf("Added reaction '%s' to issue #%d.", content, issueNumber)
+	return nil
+}
+
+// --- Definition Loading ---
+
+// loadConfig reads config.json; a missing file yields the default configuration
+func loadConfig(path string) (Config, error) {
+	var cfg Config
+	jsonData, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+	return parseConfig(jsonData, path)
+}
+
+// parseConfig decodes and validates config.json content; path is only used in messages
+func parseConfig(jsonData []byte, path string) (Config, error) {
+	var cfg Config
+	jsonData, err := stripJSONC(jsonData)
+	if err != nil {
+		return cfg, fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(jsonData, &cfg); err != nil {
+		return cfg, fmt.Errorf("error unmarshalling config JSON: %w", err)
+	}
+	if err := cfg.Policies.validate(); err != nil {
+		return cfg, fmt.Errorf("error in config file %s: %w", path, err)
+	}
+	if err := cfg.TeamAssignees.validate(); err != nil {
+		return cfg, fmt.Errorf("error in config file %s: %w", path, err)
+	}
+	if err := cfg.Project.validate(); err != nil {
+		return cfg, fmt.Errorf("error in config file %s: %w", path, err)
+	}
+	if err := cfg.SeverityColors.validate(); err != nil {
+		return cfg, fmt.Errorf("error in config file %s: %w", path, err)
+	}
+	if err := cfg.LabelBundles.validate(); err != nil {
+		return cfg, fmt.Errorf("error in config file %s: %w", path, err)
+	}
+	if err := cfg.Throttle.validate(); err != nil {
+		return cfg, fmt.Errorf("error in config file %s: %w", path, err)
+	}
+	if err := cfg.Calendar.load(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Ceremonies.load(); err != nil {
+		return cfg, fmt.Errorf("error in config file %s: %w", path, err)
+	}
+
+	// Resolve fragments stored in separate files once, so every issue reuses them
+	for _, fragment := range []struct{ text, file *string }{
+		{&cfg.IssueBody.Header, &cfg.IssueBody.HeaderFile},
+		{&cfg.IssueBody.Footer, &cfg.IssueBody.FooterFile},
+	} {
+		if *fragment.file == "" {
+			continue
+		}
+		if *fragment.text != "" {
+			return cfg, fmt.Errorf("error in config file %s: both text and file set for an issue body fragment (%s)", path, *fragment.file)
+		}
+		content, err := os.ReadFile(*fragment.file)
+		if err != nil {
+			return cfg, fmt.Errorf("error reading issue body fragment %s: %w", *fragment.file, err)
+		}
+		*fragment.text = string(content)
+	}
+	return cfg, nil
+}
+
+// stdinPath as a definition file path reads the definitions from standard input
+const stdinPath = "-"
+
+// readDefinitionFile reads a definition file, or standard input for stdinPath, as JSON
+// with comments and trailing commas stripped
+func readDefinitionFile(path string) ([]byte, error) {
+	in, closeFile, err := openDefinition(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile()
+	return io.ReadAll(in)
+}
+
+// loadLabels reads the label definitions from a JSON, YAML or TOML file, or from every file matching a pattern
+func loadLabels(path string) ([]LabelData, error) {
+	if isGlobPattern(path) {
+		return loadGlob(path, "label", loadLabelsFile, func(l LabelData) string { return strings.ToLower(l.Name) })
+	}
+	return loadLabelsFile(path)
+}
+
+// loadLabelsFile reads a single label definitions file
+func loadLabelsFile(path string) ([]LabelData, error) {
+	if format := sourceFormat(path); format != "" {
+		return decodeDefinitionFile[LabelData](path, format, "labels")
+	}
+	jsonData, err := readDefinitionFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading labels file %s: %w", path, err)
+	}
+	var labels []LabelData
+	if err := json.Unmarshal(jsonData, &labels); err != nil {
+		return nil, fmt.Errorf("error unmarshalling labels JSON: %w", err)
+	}
+	return labels, nil
+}
+
+// loadMilestones reads the milestone definitions from a JSON, YAML or TOML file, or from every file matching a pattern
+func loadMilestones(path string) ([]MilestoneData, error) {
+	if isGlobPattern(path) {
+		return loadGlob(path, "milestone", loadMilestonesFile, func(m MilestoneData) string { return m.Title })
+	}
+	return loadMilestonesFile(path)
+}
+
+// loadMilestonesFile reads a single milestone definitions file
+func loadMilestonesFile(path string) ([]MilestoneData, error) {
+	if format := sourceFormat(path); format != "" {
+		return decodeDefinitionFile[MilestoneData](path, format, "milestones")
+	}
+	jsonData, err := readDefinitionFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading milestones file %s: %w", path, err)
+	}
+	var milestones []MilestoneData
+	if err := json.Unmarshal(jsonData, &milestones); err != nil {
+		return nil, fmt.Errorf("error unmarshalling milestones JSON: %w", err)
+	}
+	return milestones, nil
+}
+
+// loadIssues reads the issue definitions from a JSON, YAML or TOML file, or from every file matching a pattern
+func loadIssues(path string) ([]IssueData, error) {
+	if isGlobPattern(path) {
+		return loadGlob(path, "issue", loadIssuesFile, func(i IssueData) string { return i.Title })
+	}
+	return loadIssuesFile(path)
+}
+
+// loadIssuesFile reads a single issue definitions file
+// Generated backlogs can exceed 100MB, so the file is decoded one issue at a time
+// instead of being read into memory as a whole first. The decoded issues are all
+// returned, as validation and every target repository need the complete list.
+func loadIssuesFile(path string) ([]IssueData, error) {
+	if format := sourceFormat(path); format != "" {
+		return decodeDefinitionFile[IssueData](path, format, "issues")
+	}
+	in, closeFile, err := openDefinition(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading issues file %s: %w", path, err)
+	}
+	defer closeFile()
+	var issues []IssueData
+	err = github.DecodeJSONArray(in, func(issue IssueData) {
+		issues = append(issues, issue)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling issues JSON: %w", err)
+	}
+	return issues, nil
+}
+
+// --- Processing Functions ---
+
+// reconciler reconciles the labels and milestones of the run's repository, resolving
+// differences with existing ones through the conflict resolver
+func (r *repoRun) reconciler() *engine.Reconciler {
+	return &engine.Reconciler{
+		Provider: repoProvider(r.target),
+		Repo:     r.target.String(),
+		Resolve: func(kind, name string, diffs []string) bool {
+			return r.resolveConflict(kind, name, diffs) == conflictTakeLocal
+		},
+		Failed: func(kind, name, action string, err error) {
+			r.failed("Failed to %s %s '%s': %v", action, kind, name, err)
+		},
+		Created: func(kind, name string, number int) {
+			if kind == "milestone" && r.options.WaitForMilestones {
+				if r.newMilestones == nil {
+					r.newMilestones = make(map[int]bool)
+				}
+				r.newMilestones[number] = true
+			}
+		},
+		Updated:      r.recordUpdate,
+		MilestoneKey: milestoneKey,
+		Delay:        requestDelay,
+	}
+}
+
+// processLabels ensures labels defined in labels.json exist
+// and resolves differences with existing labels through the conflict resolver
+func processLabels(ctx context.Context, run *repoRun, labelsToProcess []LabelData) (entityCounts, error) {
+	log.Printf("--- Processing Labels ---")
+	if config.Policies.Labels == policySkip {
+		log.Printf("Skipping labels (policy %q).", policySkip)
+		return entityCounts{}, nil
+	}
+
+	labels := make([]engine.Label, len(labelsToProcess))
+	for i, label := range labelsToProcess {
+		labels[i] = label.engineLabel()
+	}
+	counts, err := run.reconciler().Labels(ctx, labels)
+	return entityCounts{Created: counts.Created, Updated: counts.Updated, Failed: counts.Failed}, err
+}
+
+// processMilestones ensures milestones defined in milestones.json exist and returns a map
+func processMilestones(ctx context.Context, run *repoRun, milestonesToProcess []MilestoneData) (map[string]int, entityCounts, error) {
+	log.Printf("--- Processing Milestones ---")
+	if config.Policies.Milestones == policySkip {
+		log.Printf("Skipping milestones (policy %q), only existing ones are linked.", policySkip)
+		milestonesToProcess = nil
+	}
+
+	milestones := make([]engine.Milestone, len(milestonesToProcess))
+	for i, milestone := range milestonesToProcess {
+		milestones[i] = milestone.engineMilestone()
+	}
+	milestoneTitleToIDMap, counts, err := run.reconciler().Milestones(ctx, milestones)
+	return milestoneTitleToIDMap, entityCounts{Created: counts.Created, Updated: counts.Updated, Failed: counts.Failed}, err
+}
+
+// processIssues creates issues defined in issues.json, linking to milestones
+func processIssues(ctx context.Context, run *repoRun, issuesToCreate []IssueData, milestoneTitleToIDMap map[string]int) (entityCounts, error) {
+	var counts entityCounts
+	t := run.target
+	log.Printf("--- Processing Issues ---")
+	var existingTitles map[string]github.Issue
+	switch config.Policies.Issues {
+	case policySkip:
+		log.Printf("Skipping issues (policy %q).", policySkip)
+		return counts, nil
+	case policyCreateIfMissing, policyMergeLabels:
+		var err error
+		if existingTitles, err = getExistingIssues(ctx, t); err != nil {
+			return counts, fmt.Errorf("error getting existing issues: %w", err)
+		}
+	}
+
+	var knownLabels map[string]bool
+	if run.options.CreateMissingLabels {
+		var err error
+		if knownLabels, err = existingLabelNames(ctx, t); err != nil {
+			return counts, fmt.Errorf("error getting existing labels: %w", err)
+		}
+	}
+
+	finish := func(index int, issue IssueData, created github.Issue, err error) {
+		if err != nil {
+			run.issueFailed(index, issue, err)
+			counts.Failed++
+			return
+		}
+		counts.Created++
+		run.createdIssues[index] = created
+		run.recordIssue(issue.Title, created)
+		// Reactions are cosmetic, a failure does not fail the issue
+		if len(issue.Reactions) > 0 && run.degraded[capReactions] {
+			log.Printf("Skipping reactions on issue '%s', not supported by %s.", issue.Title, t)
+			issue.Reactions = nil
+		}
+		for _, reaction := range issue.Reactions {
+			if !github.ValidReactions[reaction] {
+				log.Printf("Warning: Unknown reaction '%s' on issue '%s' skipped.", reaction, issue.Title)
+				continue
+			}
+			time.Sleep(requestDelay)
+			if err := addIssueReaction(ctx, t, created.Number, reaction); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+		// Planning lives in the project fields, so a failure is reported, but the issue stays
+		if len(issue.ProjectFields) > 0 && run.options.ProjectFields != nil {
+			if err := run.options.ProjectFields.addToProject(ctx, issue, created); err != nil {
+				run.failed("Failed to add issue '%s' to the project: %v", issue.Title, err)
+			}
+		}
+	}
+	// With --graphql-writes the issues are queued and created a batch at a time
+	var writer *graphQLIssueWriter
+	flush := func() {
+		for _, r := range writer.flush(ctx) {
+			finish(r.index, r.issue, r.created, r.err)
+		}
+		time.Sleep(requestDelay) // Delay between batches
+	}
+	if run.options.GraphQLWrites {
+		writer = newGraphQLIssueWriter(t)
+	}
+
+	for index, issue := range issuesToCreate {
+		if issue.Output != nil && issue.Output.Repository == t.String() {
+			log.Printf("Issue \"%s\" was already created as #%d, skipping.", issue.Title, issue.Output.Number)
+			continue
+		}
+		if existing, exists := existingTitles[issue.Title]; exists {
+			if config.Policies.Issues != policyMergeLabels {
+				log.Printf("Issue \"%s\" already exists as #%d, skipping.", issue.Title, existing.Number)
+				continue
+			}
+			if added, err := syncIssueLabels(ctx, run, existing, issue); err != nil {
+				run.failed("Failed to add labels to existing issue #%d '%s': %v", existing.Number, issue.Title, err)
+				counts.Failed++
+			} else if added {
+				counts.Updated++
+			}
+			continue
+		}
+		if recorded, ok := run.state.issue(issue.Title); ok {
+			log.Printf("Issue \"%s\" was created as #%d by an earlier run (run state), skipping.", issue.Title, recorded.Number)
+			continue
+		}
+		var milestoneID *int // Pointer to int, defaults to nil
+
+		// Find the milestone ID using the title from the map
+		if issue.MilestoneTitle != nil && *issue.MilestoneTitle != "" {
+			if id, found, err := lookupMilestoneID(milestoneTitleToIDMap, *issue.MilestoneTitle); err != nil {
+				log.Printf("Warning: %v. Issue '%s' will be created without a milestone.", err, issue.Title)
+			} else if found {
+				milestoneID = &id // Assign the address of the found ID
+			} else {
+				log.Printf("Warning: Milestone title '%s' specified for issue '%s' not found or failed to create. Issue will be created without a milestone.", *issue.MilestoneTitle, issue.Title)
+			}
+		}
+
+		if run.options.MirrorMilestoneLabels && milestoneID != nil {
+			issue.Labels = append(append([]string(nil), issue.Labels...), milestoneLabelName(*issue.MilestoneTitle))
+		}
+
+		if resolved, ok := run.assignees[index]; ok {
+			issue.Assignees = resolved
+		}
+		assignees, err := expandAssignees(ctx, run, issue)
+		if err != nil {
+			run.issueFailed(index, issue, err)
+			counts.Failed++
+			continue
+		}
+		issue.Assignees = assignees
+		issue.muteMentions = run.options.MuteMentions
+		issue.templateData = run.templateData
+
+		if knownLabels != nil {
+			if err := createMissingLabels(ctx, run, issue, knownLabels); err != nil {
+				run.issueFailed(index, issue, err)
+				counts.Failed++
+				continue
+			}
+		}
+
+		run.awaitNewMilestone(ctx, milestoneID)
+		if writer != nil {
+			if writer.add(index, issue, milestoneID) {
+				flush()
+			}
+			continue
+		}
+
+		// Create the issue, passing label names directly
+		created, err := createIssueAwaitingMilestone(ctx, run, issue, milestoneID)
+		finish(index, issue, created, err)
+		time.Sleep(requestDelay) // Delay between issue creations
+	}
+	if writer != nil && len(writer.pending) > 0 {
+		flush()
+	}
+	log.Printf("Finished processing issues. Created %d new issues.", counts.Created)
+	return counts, nil
+}
+
+// --- Command Line Handling ---
+
+// parseArgs parses flags that may be interleaved with positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// --- Main Execution ---
+
+func main() {
+	ctx := context.Background()
+	log.SetOutput(styled(os.Stderr, os.Stderr))
+	httpClient = &http.Client{Timeout: defaultRequestTimeout} // Changed per command by --request-timeout
+	if faults := os.Getenv(faultsEnv); faults != "" {
+		cfg, err := parseFaultConfig(faults)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		httpClient.Transport = newFaultTransport(http.DefaultTransport, cfg)
+	}
+	enableAPIMeter()
+
+	if apiURL := os.Getenv("GITHUB_API_URL"); apiURL != "" { // Set by GitHub Actions, also on GHES
+		githubAPIBaseURL = strings.TrimSuffix(apiURL, "/")
+	}
+	// Generating definitions is local and needs no token
+	if len(os.Args) > 1 && os.Args[1] == "generate" {
+		runGenerate(os.Args[2:])
+		return
+	}
+	// So is approving a saved plan
+	if len(os.Args) > 1 && os.Args[1] == "approve" {
+		runApprove(os.Args[2:])
+		return
+	}
+	// And validating or rendering the definitions
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		runValidate(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		runRender(os.Args[2:])
+		return
+	}
+
+	// --- Configuration ---
+	githubToken = os.Getenv("GITHUB_TOKEN")
+	if usesGitHubApp() {
+		token, bot, err := appInstallationToken(ctx)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		githubToken, appBotLogin = token, bot
+	}
+	if githubToken == "" && !servesTenants(os.Args[1:]) {
+		log.Fatalf("Error: GITHUB_TOKEN environment variable not set (or %s to authenticate as a GitHub App).", appIDEnv)
+	}
+	// Every log line goes through the redactor, error bodies included
+	secrets.add(githubToken)
+	log.SetOutput(styled(redacted(os.Stderr), os.Stderr))
+
+	// The command defaults to "apply" so existing workflows keep working unchanged
+	command, args := "apply", os.Args[1:]
+	if len(args) > 0 && (!strings.HasPrefix(args[0], "-") || args[0] == "--json-rpc") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "apply":
+		runApply(ctx, args)
+	case "plan":
+		runPlan(ctx, args)
+	case "audit":
+		runAudit(ctx, args)
+	case "sunset":
+		runSunset(ctx, args)
+	case "shift-milestones":
+		runShiftMilestones(ctx, args)
+	case "cleanup":
+		runCleanup(ctx, args)
+	case "restore-mentions":
+		runRestoreMentions(ctx, args)
+	case "rename-milestone":
+		runRenameMilestone(ctx, args)
+	case "verify":
+		runVerify(ctx, args)
+	case "export":
+		runExport(ctx, args)
+	case "--json-rpc":
+		runJSONRPC(ctx, args)
+	default:
+		log.Fatalf("Error: unknown command %q. Expected one of: apply, plan, approve, audit, sunset, shift-milestones, cleanup, restore-mentions, rename-milestone, verify, export, generate, validate, render.", command)
+	}
+}
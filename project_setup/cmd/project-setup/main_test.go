@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+func TestParseRepoReference(t *testing.T) {
+	tests := []struct {
+		in       string
+		want     repoTarget
+		wantHost string
+		wantErr  bool
+	}{
+		{in: "acme/web", want: repoTarget{"acme", "web"}},
+		{in: "https://github.com/acme/web", want: repoTarget{"acme", "web"}, wantHost: "github.com"},
+		{in: "https://github.com/acme/web.git", want: repoTarget{"acme", "web"}, wantHost: "github.com"},
+		{in: "https://ghe.acme.dev/acme/web/issues/12", want: repoTarget{"acme", "web"}, wantHost: "ghe.acme.dev"},
+		{in: "https://ghe.acme.dev:8443/acme/web", want: repoTarget{"acme", "web"}, wantHost: "ghe.acme.dev:8443"},
+		{in: "ssh://git@ghe.acme.dev:2222/acme/web.git", want: repoTarget{"acme", "web"}, wantHost: "ghe.acme.dev"},
+		{in: "git@github.com:acme/web.git", want: repoTarget{"acme", "web"}, wantHost: "github.com"},
+		{in: "acme", wantErr: true},
+		{in: "acme/web/extra", wantErr: true},
+		{in: "/web", wantErr: true},
+		{in: "https://github.com/acme", wantErr: true},
+		{in: "https:///acme/web", wantErr: true},
+		{in: "git@github.com:acme", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, host, err := parseRepoReference(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parseRepoReference(%q) = %v, %q, want an error", tc.in, got, host)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRepoReference(%q) failed: %v", tc.in, err)
+			}
+			if got != tc.want || host != tc.wantHost {
+				t.Errorf("parseRepoReference(%q) = %v, %q, want %v, %q", tc.in, got, host, tc.want, tc.wantHost)
+			}
+		})
+	}
+}
+
+func TestAPIBaseURLForHost(t *testing.T) {
+	tests := map[string]string{
+		"github.com":        "https://api.github.com",
+		"www.github.com":    "https://api.github.com",
+		"ghe.acme.dev":      "https://ghe.acme.dev/api/v3",
+		"ghe.acme.dev:8443": "https://ghe.acme.dev:8443/api/v3",
+	}
+	for host, want := range tests {
+		if got := github.BaseURLForHost(host); got != want {
+			t.Errorf("github.BaseURLForHost(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const manifestCommitMessage = "Project setup: record the applied templates"
+
+// ManifestConfig enables the manifest committed to every repository after a successful
+// apply, recording how the repository was provisioned
+type ManifestConfig struct {
+	Path    string `json:"path,omitempty"`    // e.g. ".github/project-setup.yaml"; empty to disable
+	Source  string `json:"source,omitempty"`  // Where the templates live, e.g. the template repository URL
+	Version string `json:"version,omitempty"` // Version of the templates, e.g. a tag or commit
+}
+
+// setupManifest is the content of the manifest
+type setupManifest struct {
+	Source       string   `json:"source"`
+	Version      string   `json:"version"`
+	TemplateHash string   `json:"template_hash"`
+	Profile      []string `json:"profile"` // Layers applied, or the definition files
+	AppliedAt    string   `json:"applied_at"`
+}
+
+// newSetupManifest describes the run that applied defs
+func newSetupManifest(defs *definitions, now time.Time) setupManifest {
+	profile := defs.Paths.Layers
+	if len(profile) == 0 {
+		profile = []string{defs.Paths.Labels, defs.Paths.Milestones, defs.Paths.Issues}
+	}
+	return setupManifest{
+		Source: config.Manifest.Source, Version: config.Manifest.Version, TemplateHash: defs.Hash,
+		Profile: profile, AppliedAt: now.UTC().Format(time.RFC3339),
+	}
+}
+
+// sameProvisioning reports whether two manifests record the same templates, whenever
+// they were applied
+func (m setupManifest) sameProvisioning(other setupManifest) bool {
+	return m.Source == other.Source && m.Version == other.Version && m.TemplateHash == other.TemplateHash &&
+		strings.Join(m.Profile, "\n") == strings.Join(other.Profile, "\n")
+}
+
+// render writes the manifest as YAML; every value is quoted, so no path or URL needs care
+func (m setupManifest) render() string {
+	var b strings.Builder
+	b.WriteString("# Written by the project setup after applying its templates, do not edit.\n")
+	b.WriteString("# Run apply with the same source, version and profile to re-sync this repository.\n")
+	fmt.Fprintf(&b, "source: %s\n", strconv.Quote(m.Source))
+	fmt.Fprintf(&b, "version: %s\n", strconv.Quote(m.Version))
+	fmt.Fprintf(&b, "template_hash: %s\n", strconv.Quote(m.TemplateHash))
+	b.WriteString("profile:\n")
+	for _, entry := range m.Profile {
+		fmt.Fprintf(&b, "  - %s\n", strconv.Quote(entry))
+	}
+	fmt.Fprintf(&b, "applied_at: %s\n", strconv.Quote(m.AppliedAt))
+	return b.String()
+}
+
+// syncManifest commits the manifest unless the repository already records the same
+// templates, so a run that changes nothing does not commit a new timestamp
+func syncManifest(ctx context.Context, t repoTarget, defs *definitions) (entityCounts, error) {
+	var counts entityCounts
+	path := strings.TrimPrefix(config.Manifest.Path, "/")
+	log.Printf("--- Updating Manifest %s ---", path)
+
+	manifest := newSetupManifest(defs, time.Now())
+	current, found, err := getFileContent(ctx, t, path, config.Commit.Branch)
+	if err != nil {
+		return counts, err
+	}
+	if found {
+		var recorded setupManifest
+		if err := decodeYAML(current, &recorded); err != nil {
+			log.Printf("Warning: %s of %s cannot be read and is replaced: %v", path, t, err)
+		} else if recorded.sameProvisioning(manifest) {
+			log.Printf("Manifest %s already records template hash %s, nothing to commit.", path, defs.Hash)
+			return counts, nil
+		}
+	}
+
+	commit := config.Commit
+	commit.Message = manifestCommitMessage
+	committed, err := commitFiles(ctx, t, map[string]string{path: manifest.render()}, commit)
+	if err != nil {
+		counts.Failed++
+		return counts, err
+	}
+	if committed {
+		counts.Created++
+	}
+	return counts, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// normalizeMarkdown removes the differences GitHub introduces when it stores or re-saves a
+// body, and whitespace that does not change how Markdown renders: CRLF line endings (bodies
+// edited in the web UI), trailing whitespace, runs of blank lines outside code blocks and
+// blank lines at either end. Muted mentions count as the mentions they stand for.
+func normalizeMarkdown(body string) string {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	body = strings.ReplaceAll(body, "\r", "\n")
+	body = restoreMentions(body)
+	var lines []string
+	fence, blank := "", false
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(line, " \t")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+		case line == "" && blank:
+			continue // Several blank lines render like one
+		}
+		blank = line == "" && fence == ""
+		lines = append(lines, line)
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// sameMarkdown reports whether two bodies only differ in the ways normalizeMarkdown ignores
+func sameMarkdown(a, b string) bool {
+	return a == b || normalizeMarkdown(a) == normalizeMarkdown(b)
+}
+
+// markdownDifference describes the first line where two bodies really differ, "" when they
+// are the same Markdown
+func markdownDifference(have, want string) string {
+	haveLines := strings.Split(normalizeMarkdown(have), "\n")
+	wantLines := strings.Split(normalizeMarkdown(want), "\n")
+	for i := 0; i < max(len(haveLines), len(wantLines)); i++ {
+		var h, w string
+		if i < len(haveLines) {
+			h = haveLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if h != w || i >= len(haveLines) || i >= len(wantLines) {
+			return fmt.Sprintf("body differs from line %d: %q -> %q (%d lines -> %d)", i+1, h, w, len(haveLines), len(wantLines))
+		}
+	}
+	return ""
+}
+
+// withoutAttribution cuts the attribution footer off a body; it names the commit of the
+// definitions, so it changes with every commit without the issue being any different
+func withoutAttribution(body string) string {
+	if i := strings.LastIndex(body, "---\n<sub>Created by "); i >= 0 {
+		return body[:i]
+	}
+	return body
+}
+
+// issueBodyDrift compares the body of an existing issue with the one its definition
+// renders; data is the template data of the target, nil without issue_body.templates
+func issueBodyDrift(existing string, issue IssueData, data *templateData) string {
+	issue.templateData = data
+	return markdownDifference(withoutAttribution(normalizeMarkdown(existing)), withoutAttribution(renderIssueBody(issue)))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Limits of a run's deletions and closures per repository when mass_change does not set them
+const (
+	defaultMaxDeletedLabels     = 10
+	defaultMaxDeletedMilestones = 5
+	defaultMaxClosedIssues      = 10
+)
+
+// MassChangeConfig caps what one run may delete or close in a repository, so a truncated
+// definitions file is not taken as "delete almost everything" by --prune or --close-removed.
+// apply refuses to run past a limit without --allow-mass-change.
+type MassChangeConfig struct {
+	MaxDeletedLabels     int `json:"max_deleted_labels,omitempty"`     // 10 when unset
+	MaxDeletedMilestones int `json:"max_deleted_milestones,omitempty"` // 5 when unset
+	MaxClosedIssues      int `json:"max_closed_issues,omitempty"`      // 10 when unset
+}
+
+// orDefault returns limit, or fallback when it is unset
+func orDefault(limit, fallback int) int {
+	if limit > 0 {
+		return limit
+	}
+	return fallback
+}
+
+// removedIssueTitles returns the titles of the issues earlier runs created whose definitions
+// are gone, the issues --close-removed closes unless they were closed already
+func removedIssueTitles(state *repoState, issues []IssueData) []string {
+	defined := make(map[string]bool, len(issues))
+	for _, issue := range issues {
+		defined[issue.Title] = true
+	}
+	var removed []string
+	for _, title := range sortedKeys(state.Issues) {
+		if !defined[title] {
+			removed = append(removed, title)
+		}
+	}
+	return removed
+}
+
+// checkMassChanges counts what --prune and --close-removed would delete and close in every
+// target before anything is changed, and refuses the run when a count exceeds its limit
+func checkMassChanges(ctx context.Context, plans []repoPlan, defs *definitions, options applyOptions) error {
+	closeRemoved := options.CloseRemoved && options.State != nil
+	if !options.Prune && !closeRemoved {
+		return nil
+	}
+	c := config.MassChange
+	maxLabels := orDefault(c.MaxDeletedLabels, defaultMaxDeletedLabels)
+	maxMilestones := orDefault(c.MaxDeletedMilestones, defaultMaxDeletedMilestones)
+	maxIssues := orDefault(c.MaxClosedIssues, defaultMaxClosedIssues)
+	var violations []string
+	exceeds := func(t repoTarget, n, limit int, what string) {
+		if n > limit {
+			violations = append(violations, fmt.Sprintf("%s: %d %s (limit %d)", t, n, what, limit))
+		}
+	}
+	for _, plan := range plans {
+		t := plan.Target
+		if options.Prune && config.Policies.Labels != policySkip {
+			existing, err := getExistingLabels(ctx, t)
+			if err != nil {
+				return fmt.Errorf("error getting existing labels of %s: %w", t, err)
+			}
+			exceeds(t, len(prunableLabels(defs, existing, options)), maxLabels, "labels to delete")
+		}
+		if options.Prune && config.Policies.Milestones != policySkip {
+			existing, err := getExistingMilestones(ctx, t)
+			if err != nil {
+				return fmt.Errorf("error getting existing milestones of %s: %w", t, err)
+			}
+			exceeds(t, len(prunableMilestones(defs, existing)), maxMilestones, "milestones to delete")
+		}
+		if closeRemoved {
+			state, err := loadState(ctx, options.State, t)
+			if err != nil {
+				return err
+			}
+			exceeds(t, len(removedIssueTitles(state, defs.Issues)), maxIssues, "removed issues to close")
+		}
+	}
+	if len(violations) > 0 {
+		for _, v := range violations {
+			log.Printf("Mass change: %s", v)
+		}
+		return fmt.Errorf("refusing a mass change, nothing was changed: %s. Check that the definitions are complete, raise the limits in config.json's mass_change or pass --allow-mass-change", strings.Join(violations, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// mutedMention replaces the "@" of mentions in issues created with --mute-mentions. The
+// hidden comment breaks the mention, so nobody is notified, while the rendered text still
+// reads "@name"; restore-mentions turns them back into real mentions.
+const mutedMention = "@<!-- muted -->"
+
+// mentionPattern matches @user and @org/team mentions; the leading group keeps e-mail
+// addresses out
+var mentionPattern = regexp.MustCompile(`(^|[^\w@/])@([A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:/[A-Za-z0-9_.-]+)?)`)
+
+// muteMentions breaks every mention of body outside of code, where mentions do not notify anyway
+func muteMentions(body string) string {
+	return outsideCode(body, func(text string) string {
+		return mentionPattern.ReplaceAllString(text, "${1}"+mutedMention+"${2}")
+	})
+}
+
+// outsideCode applies replace to the Markdown of body outside of fenced code blocks and
+// inline code spans
+func outsideCode(body string, replace func(string) string) string {
+	lines := strings.Split(body, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		// Odd parts are inline code spans
+		parts := strings.Split(line, "`")
+		for j := 0; j < len(parts); j += 2 {
+			parts[j] = replace(parts[j])
+		}
+		lines[i] = strings.Join(parts, "`")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// restoreMentions turns muted mentions back into real ones
+func restoreMentions(body string) string {
+	return strings.ReplaceAll(body, mutedMention, "@")
+}
+
+// restoreRepoMentions restores the mentions of every issue of a repository that has muted ones
+func restoreRepoMentions(ctx context.Context, t repoTarget) (restored int, problems []string) {
+	issues, err := listRepoIssues(ctx, t)
+	if err != nil {
+		return 0, []string{fmt.Sprintf("error listing issues: %v", err)}
+	}
+	for _, issue := range issues {
+		if issue.PullRequest != nil || !strings.Contains(issue.Body, mutedMention) {
+			continue
+		}
+		if err := updateIssueBody(ctx, t, issue.Number, restoreMentions(issue.Body)); err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		log.Printf("Restored the mentions of issue #%d in %s.", issue.Number, t)
+		restored++
+	}
+	return restored, problems
+}
+
+// runRestoreMentions edits the issues created with --mute-mentions so their mentions
+// notify after all. Pace it with the throttle of config.json to spread the notifications.
+func runRestoreMentions(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("restore-mentions", flag.ExitOnError)
+	var shared targetFlags
+	shared.register(fs)
+	layers, err := parseArgs(fs, args)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	shared.paths.Layers = layers
+	if err := shared.limits.check(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	ctx, cancel := shared.limits.start(ctx)
+	defer cancel()
+
+	_, targets, err := shared.load(ctx)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	failures := 0
+	for _, t := range targets {
+		restored, problems := restoreRepoMentions(ctx, t)
+		fmt.Fprintf(redacted(os.Stdout), "%s: restored the mentions of %d issues\n", t, restored)
+		for _, problem := range problems {
+			fmt.Fprintf(redacted(os.Stdout), "  failed: %s\n", problem)
+		}
+		failures += len(problems)
+	}
+	exitOnDeadline(ctx)
+	if failures > 0 {
+		log.Fatalf("Error: %d issues could not be restored", failures)
+	}
+}
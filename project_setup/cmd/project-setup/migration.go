@@ -0,0 +1,307 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrationRecordFile matches the files of a GitHub migration archive read by from-archive,
+// e.g. issues_000001.json; large archives split each kind over several files
+var migrationRecordFile = regexp.MustCompile(`^(repositories|labels|milestones|issues)_\d+\.json$`)
+
+// Records of a GitHub migration archive. They refer to each other by URL, e.g. an issue to
+// its labels as https://github.com/<owner>/<repo>/labels/<name>.
+type migrationRepository struct {
+	URL string `json:"url"`
+}
+
+type migrationLabel struct {
+	URL         string `json:"url"`
+	Repository  string `json:"repository"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Color       string `json:"color"`
+}
+
+type migrationMilestone struct {
+	URL         string  `json:"url"`
+	Repository  string  `json:"repository"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	State       string  `json:"state"`
+	DueOn       *string `json:"due_on"`
+}
+
+type migrationIssue struct {
+	URL        string   `json:"url"`
+	Repository string   `json:"repository"`
+	Title      string   `json:"title"`
+	Body       string   `json:"body"`
+	Assignee   string   `json:"assignee"`
+	Assignees  []string `json:"assignees"`
+	Milestone  string   `json:"milestone"`
+	Labels     []string `json:"labels"`
+	ClosedAt   *string  `json:"closed_at"`
+}
+
+// migrationArchive holds the records of an archive that become definitions
+type migrationArchive struct {
+	Repositories []migrationRepository
+	Labels       []migrationLabel
+	Milestones   []migrationMilestone
+	Issues       []migrationIssue
+}
+
+// add decodes one record file of the archive
+func (a *migrationArchive) add(name string, r io.Reader) error {
+	m := migrationRecordFile.FindStringSubmatch(path.Base(name))
+	if m == nil {
+		return nil
+	}
+	var err error
+	dec := json.NewDecoder(r)
+	switch m[1] {
+	case "repositories":
+		var records []migrationRepository
+		err = dec.Decode(&records)
+		a.Repositories = append(a.Repositories, records...)
+	case "labels":
+		var records []migrationLabel
+		err = dec.Decode(&records)
+		a.Labels = append(a.Labels, records...)
+	case "milestones":
+		var records []migrationMilestone
+		err = dec.Decode(&records)
+		a.Milestones = append(a.Milestones, records...)
+	case "issues":
+		var records []migrationIssue
+		err = dec.Decode(&records)
+		a.Issues = append(a.Issues, records...)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s of the archive: %w", name, err)
+	}
+	return nil
+}
+
+// readMigrationArchive reads an archive as downloaded (.tar.gz) or extracted into a directory
+func readMigrationArchive(p string) (*migrationArchive, error) {
+	archive := &migrationArchive{}
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, fmt.Errorf("error reading archive: %w", err)
+	}
+	if info.IsDir() {
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, fmt.Errorf("error reading archive: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !migrationRecordFile.MatchString(entry.Name()) {
+				continue
+			}
+			f, err := os.Open(filepath.Join(p, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("error reading archive: %w", err)
+			}
+			err = archive.add(entry.Name(), f)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return archive, nil
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("error reading archive: %w", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading archive %s, expected a .tar.gz or a directory: %w", p, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading archive %s: %w", p, err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if err := archive.add(header.Name, tr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return archive, nil
+}
+
+// migrationRepoName returns owner/repo of a repository URL
+func migrationRepoName(repoURL string) string {
+	parts := strings.Split(strings.TrimSuffix(repoURL, "/"), "/")
+	if len(parts) < 2 {
+		return repoURL
+	}
+	return parts[len(parts)-2] + "/" + parts[len(parts)-1]
+}
+
+// lastURLSegment returns the unescaped last path segment of a URL, e.g. a login or label name
+func lastURLSegment(u string) string {
+	segment := path.Base(strings.TrimSuffix(u, "/"))
+	if unescaped, err := url.PathUnescape(segment); err == nil {
+		return unescaped
+	}
+	return segment
+}
+
+// selectRepo picks the repository to import: the given one, or the only one of the archive
+func (a *migrationArchive) selectRepo(repo string) (string, error) {
+	var names []string
+	for _, r := range a.Repositories {
+		names = append(names, migrationRepoName(r.URL))
+	}
+	if repo != "" {
+		if !containsFold(names, repo) {
+			return "", fmt.Errorf("the archive has no repository %s (it has %s)", repo, orNone(strings.Join(names, ", ")))
+		}
+		return repo, nil
+	}
+	if len(names) != 1 {
+		return "", fmt.Errorf("the archive has %d repositories (%s), pick one with --repo", len(names), strings.Join(names, ", "))
+	}
+	return names[0], nil
+}
+
+// migrationDefinitions are the definitions converted from one repository of an archive
+type migrationDefinitions struct {
+	Labels        []LabelData
+	Milestones    []MilestoneData
+	Issues        []IssueData
+	SkippedClosed int // Closed issues left out
+}
+
+// convert turns the records of one repository into definitions. Issues are ordered by
+// number so they are created in their original order. Closed issues, and closed milestones
+// no imported issue uses, are only included with closed.
+func (a *migrationArchive) convert(repo string, closed bool) migrationDefinitions {
+	defs := migrationDefinitions{Labels: []LabelData{}, Milestones: []MilestoneData{}, Issues: []IssueData{}}
+	inRepo := func(repoURL string) bool { return strings.EqualFold(migrationRepoName(repoURL), repo) }
+
+	labelNames := make(map[string]string)
+	for _, l := range a.Labels {
+		if inRepo(l.Repository) {
+			labelNames[l.URL] = l.Name
+			defs.Labels = append(defs.Labels, LabelData{Name: l.Name, Description: l.Description, Color: l.Color})
+		}
+	}
+	milestoneTitles := make(map[string]string)
+	for _, m := range a.Milestones {
+		if inRepo(m.Repository) {
+			milestoneTitles[m.URL] = m.Title
+		}
+	}
+
+	issues := make([]migrationIssue, 0, len(a.Issues))
+	for _, issue := range a.Issues {
+		if !inRepo(issue.Repository) {
+			continue
+		}
+		if issue.ClosedAt != nil && !closed {
+			defs.SkippedClosed++
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	number := func(issue migrationIssue) int {
+		n, _ := strconv.Atoi(lastURLSegment(issue.URL))
+		return n
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return number(issues[i]) < number(issues[j]) })
+
+	usedMilestones := make(map[string]bool)
+	for _, issue := range issues {
+		converted := IssueData{Title: issue.Title, Description: issue.Body, Labels: []string{}}
+		for _, labelURL := range issue.Labels {
+			name, ok := labelNames[labelURL]
+			if !ok {
+				name = lastURLSegment(labelURL)
+				log.Printf("Warning: Label '%s' of issue \"%s\" is not in the archive's labels, use apply --create-missing-labels to create it.", name, issue.Title)
+			}
+			converted.Labels = append(converted.Labels, name)
+		}
+		assignees := issue.Assignees
+		if len(assignees) == 0 && issue.Assignee != "" {
+			assignees = []string{issue.Assignee}
+		}
+		for _, userURL := range assignees {
+			converted.Assignees = append(converted.Assignees, lastURLSegment(userURL))
+		}
+		if issue.Milestone != "" {
+			if title, ok := milestoneTitles[issue.Milestone]; ok {
+				converted.MilestoneTitle = &title
+				usedMilestones[issue.Milestone] = true
+			}
+		}
+		defs.Issues = append(defs.Issues, converted)
+	}
+	for _, m := range a.Milestones {
+		if inRepo(m.Repository) && (m.State != "closed" || closed || usedMilestones[m.URL]) {
+			defs.Milestones = append(defs.Milestones, MilestoneData{Title: m.Title, Description: m.Description, DueOn: m.DueOn})
+		}
+	}
+	return defs
+}
+
+// runGenerateFromArchive runs "generate from-archive", which converts the labels, milestones
+// and issues of a GitHub migration archive into definitions, so a metadata-only migration
+// can be replayed onto a new repository with apply
+func runGenerateFromArchive(args []string) {
+	fs := flag.NewFlagSet("generate from-archive", flag.ExitOnError)
+	repo := fs.String("repo", "", "owner/name of the repository to import, needed when the archive has several")
+	outDir := fs.String("out", ".", "Directory to write labels.json, milestones.json and issues.json to")
+	closed := fs.Bool("closed", false, "Also import closed issues and milestones; they are created open")
+	force := fs.Bool("force", false, "Overwrite definition files that already exist in --out")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if len(positional) != 1 {
+		log.Fatal("Error: generate from-archive takes exactly one archive (.tar.gz or extracted directory)")
+	}
+
+	archive, err := readMigrationArchive(positional[0])
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	name, err := archive.selectRepo(*repo)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defs := archive.convert(name, *closed)
+
+	if err := writeDefinitionFiles(*outDir, *force, defs.Labels, defs.Milestones, defs.Issues); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	log.Printf("Imported %d labels, %d milestones and %d issues of %s into %s.", len(defs.Labels), len(defs.Milestones), len(defs.Issues), name, *outDir)
+	if defs.SkippedClosed > 0 {
+		log.Printf("Skipped %d closed issues, use --closed to import them too.", defs.SkippedClosed)
+	}
+}
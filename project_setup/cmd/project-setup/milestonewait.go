@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/engine"
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// milestonePropagationAttempts bounds how often a new milestone is looked for, with
+// doubling delays starting at milestonePropagationDelay (about 8 seconds in all)
+const (
+	milestonePropagationAttempts = 5
+	milestonePropagationDelay    = 500 * time.Millisecond
+)
+
+// awaitMilestone waits until a milestone this run created can be read back. Some GHES
+// versions briefly reject issues referring to a milestone created a moment earlier.
+func awaitMilestone(ctx context.Context, t repoTarget, number int) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/milestones/%d", githubAPIBaseURL, t.Owner, t.Repo, number)
+	delay := milestonePropagationDelay
+	for attempt := 1; ; attempt++ {
+		resp, bodyBytes, err := sendGitHubRequest(ctx, "GET", url, nil)
+		if err != nil {
+			return fmt.Errorf("error sending request for milestone #%d: %w", number, err)
+		}
+		if resp.StatusCode == http.StatusOK {
+			return nil
+		}
+		if resp.StatusCode != http.StatusNotFound || attempt == milestonePropagationAttempts {
+			return fmt.Errorf("error waiting for milestone #%d to become visible: %w", number, github.NewAPIError(resp, bodyBytes))
+		}
+		log.Printf("Milestone #%d of %s is not visible yet, checking again in %s.", number, t, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// isMilestoneRejection reports whether an issue was rejected because of its milestone
+func isMilestoneRejection(err error) bool {
+	var apiErr *github.APIError
+	if !errors.As(err, &apiErr) || !errors.Is(apiErr, github.ErrValidation) {
+		return false
+	}
+	for _, field := range apiErr.Errors {
+		if strings.EqualFold(field.Field, "milestone") {
+			return true
+		}
+	}
+	return false
+}
+
+// awaitNewMilestone verifies a milestone this run created before its first issue refers
+// to it, with --wait-for-milestones; a milestone that does not show up is only warned
+// about, since the issue is retried when it is rejected
+func (r *repoRun) awaitNewMilestone(ctx context.Context, milestoneID *int) {
+	if !r.options.WaitForMilestones || milestoneID == nil || !r.newMilestones[*milestoneID] {
+		return
+	}
+	if err := awaitMilestone(ctx, r.target, *milestoneID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	delete(r.newMilestones, *milestoneID)
+}
+
+// createIssueAwaitingMilestone creates an issue; with --wait-for-milestones an issue
+// rejected for its milestone is created again after a growing delay
+func createIssueAwaitingMilestone(ctx context.Context, run *repoRun, issue IssueData, milestoneID *int) (github.Issue, error) {
+	created, err := createIssue(ctx, run.target, issue, milestoneID)
+	delay := milestonePropagationDelay
+	for attempt := 1; run.options.WaitForMilestones && milestoneID != nil && isMilestoneRejection(err) && attempt < milestonePropagationAttempts; attempt++ {
+		log.Printf("Issue \"%s\" was rejected for milestone #%d, which may not have propagated yet; retrying in %s.", issue.Title, *milestoneID, delay)
+		engine.Report(ctx, engine.Event{Kind: engine.EventRetry, Repo: run.target.String(), Entity: "issue", Name: issue.Title, Attempt: attempt + 1, Wait: delay, Reason: "milestone not propagated yet"})
+		select {
+		case <-ctx.Done():
+			return created, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+		created, err = createIssue(ctx, run.target, issue, milestoneID)
+	}
+	return created, err
+}
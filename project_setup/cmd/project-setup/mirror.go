@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// Labels mirroring milestones, for tools that can only filter issues by label
+const (
+	milestoneLabelPrefix = "milestone:"
+	milestoneLabelColor  = "c5def5"
+	// The description ties a mirror label to its milestone number, so renaming the
+	// milestone renames the label instead of leaving a stale one behind
+	milestoneLabelDescription = "Mirrors milestone #%d"
+)
+
+// applyOptions are the optional behaviours of an apply run
+type applyOptions struct {
+	MirrorMilestoneLabels bool         // Keep a milestone:<title> label on every milestone's issues
+	TrackingIssues        bool         // Keep a tracking issue listing the issues of every milestone
+	State                 stateBackend // Where run state is kept, nil without --state
+	RunID                 string       // Identifies the run in the run state
+	CreateMissingLabels   bool         // Create undefined labels used by issues with a default color
+	PhaseWorkers          int          // Phases of one repository run in parallel, see runPhases
+	GraphQLWrites         bool         // Create issues with batched GraphQL mutations instead of one REST request each
+	MuteMentions          bool         // Break @-mentions in created issue bodies, see restore-mentions
+	CloseRemoved          bool         // Close issues recorded in the run state whose definitions were removed
+	EnableIssues          bool         // Turn on issues in target repositories that have them turned off
+	WaitForMilestones     bool         // Absorb the delay before new milestones can be used by issues
+	Prune                 bool         // Delete labels and milestones that are not defined, see prunableLabels
+	// Project fields set by issues, resolved by prepare; nil when no issue sets one
+	ProjectFields *projectItemFields
+}
+
+// milestoneKey is what milestone titles are matched by: trimmed, with single spaces and
+// case-insensitive, since the GitHub UI easily adds a trailing space; the title itself
+// with strict_milestone_titles
+func milestoneKey(title string) string {
+	if config.StrictMilestoneTitles {
+		return title
+	}
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
+
+// lookupMilestoneID finds a milestone by title, falling back to milestoneKey matching;
+// it is an error when several milestones match the title that way
+func lookupMilestoneID(milestoneTitleToIDMap map[string]int, title string) (int, bool, error) {
+	if id, ok := milestoneTitleToIDMap[title]; ok {
+		return id, true, nil
+	}
+	var matches []string
+	for _, existing := range sortedKeys(milestoneTitleToIDMap) {
+		if milestoneKey(existing) == milestoneKey(title) {
+			matches = append(matches, existing)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return 0, false, nil
+	case 1:
+		return milestoneTitleToIDMap[matches[0]], true, nil
+	}
+	return 0, false, fmt.Errorf("milestone title '%s' is ambiguous, it matches \"%s\"", title, strings.Join(matches, "\" and \""))
+}
+
+// milestoneLabelName returns the name of the label mirroring a milestone
+func milestoneLabelName(title string) string {
+	return milestoneLabelPrefix + title
+}
+
+// mirroredMilestone returns the milestone number a mirror label belongs to
+func mirroredMilestone(label github.Label) (int, bool) {
+	var number int
+	if _, err := fmt.Sscanf(label.Description, milestoneLabelDescription, &number); err != nil {
+		return 0, false
+	}
+	return number, true
+}
+
+// renameLabel renames a label; GitHub keeps it applied to all of its issues
+func renameLabel(ctx context.Context, t repoTarget, oldName string, label LabelData) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/labels/%s", githubAPIBaseURL, t.Owner, t.Repo, neturl.PathEscape(oldName))
+	payload := github.LabelRequest{
+		NewName:     label.Name,
+		Description: label.Description,
+		Color:       label.Color,
+	}
+
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "PATCH", url, payload)
+	if err != nil {
+		return fmt.Errorf("error sending rename label request for '%s': %w", oldName, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error renaming label '%s' to '%s': %w", oldName, label.Name, github.NewAPIError(resp, bodyBytes))
+	}
+
+	log.Printf("Renamed label \"%s\" to \"%s\".", oldName, label.Name)
+	return nil
+}
+
+// listMilestoneIssues fetches all open and closed issues of a milestone
+func listMilestoneIssues(ctx context.Context, t repoTarget, milestoneNumber int) ([]github.Issue, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/issues?milestone=%d&state=all&per_page=100", githubAPIBaseURL, t.Owner, t.Repo, milestoneNumber)
+	return getAllPages[github.Issue](ctx, "issues", url)
+}
+
+// addIssueLabels adds labels to an existing issue, keeping its current ones except those
+// of the same scope as an added scoped label. Many labels are added a chunk at a time.
+func addIssueLabels(ctx context.Context, t repoTarget, issue github.Issue, labels []string) error {
+	issueNumber := issue.Number
+	for _, label := range labels {
+		for _, sibling := range scopeSiblings(issue, label) {
+			if err := removeIssueLabel(ctx, t, issueNumber, sibling); err != nil {
+				return err
+			}
+		}
+	}
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/labels", githubAPIBaseURL, t.Owner, t.Repo, issueNumber)
+	for i, chunk := range chunkLabels(labels) {
+		if i > 0 {
+			time.Sleep(requestDelay)
+		}
+		// POST adds to the labels of the issue, where PUT would replace them
+		resp, bodyBytes, err := sendGitHubRequest(ctx, "POST", url, map[string][]string{"labels": chunk})
+		if err != nil {
+			return fmt.Errorf("error sending add labels request for issue #%d: %w", issueNumber, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("error adding labels to issue #%d: %w", issueNumber, github.NewAPIError(resp, bodyBytes))
+		}
+	}
+	return nil
+}
+
+// syncMilestoneLabels creates or renames a milestone:<title> label for every
+// milestone and adds it to the milestone's existing issues
+func syncMilestoneLabels(ctx context.Context, run *repoRun, milestoneTitleToIDMap map[string]int) (entityCounts, error) {
+	var counts entityCounts
+	t := run.target
+	log.Printf("--- Mirroring Milestones as Labels ---")
+
+	existingLabels, err := getExistingLabels(ctx, t)
+	if err != nil {
+		return counts, fmt.Errorf("error getting existing labels: %w", err)
+	}
+	mirrorLabels := make(map[int]github.Label)
+	for _, label := range existingLabels {
+		if number, ok := mirroredMilestone(label); ok {
+			mirrorLabels[number] = label
+		}
+	}
+
+	titles := make([]string, 0, len(milestoneTitleToIDMap))
+	for title := range milestoneTitleToIDMap {
+		titles = append(titles, title)
+	}
+	// Renames go first, so a milestone recreated under the old title gets a new label
+	sort.Slice(titles, func(i, j int) bool {
+		_, iMirrored := mirrorLabels[milestoneTitleToIDMap[titles[i]]]
+		_, jMirrored := mirrorLabels[milestoneTitleToIDMap[titles[j]]]
+		if iMirrored != jMirrored {
+			return iMirrored
+		}
+		return titles[i] < titles[j]
+	})
+
+	for _, title := range titles {
+		number := milestoneTitleToIDMap[title]
+		label := LabelData{
+			Name:        milestoneLabelName(title),
+			Color:       milestoneLabelColor,
+			Description: fmt.Sprintf(milestoneLabelDescription, number),
+		}
+
+		if existing, ok := mirrorLabels[number]; ok {
+			if existing.Name != label.Name {
+				if err := renameLabel(ctx, t, existing.Name, label); err != nil {
+					run.failed("Failed to rename mirror label for milestone '%s': %v", title, err)
+					counts.Failed++
+					continue
+				}
+				delete(existingLabels, existing.Name)
+				counts.Updated++
+				time.Sleep(requestDelay)
+			}
+		} else if _, exists := existingLabels[label.Name]; exists {
+			// A hand-made label with the right name is adopted as the mirror
+			if err := updateLabel(ctx, t, label); err != nil {
+				run.failed("Failed to adopt label '%s' as milestone mirror: %v", label.Name, err)
+				counts.Failed++
+				continue
+			}
+			counts.Updated++
+			time.Sleep(requestDelay)
+		} else {
+			if err := createLabel(ctx, t, label); err != nil {
+				run.failed("Failed to create mirror label for milestone '%s': %v", title, err)
+				counts.Failed++
+				continue
+			}
+			counts.Created++
+			time.Sleep(requestDelay)
+		}
+
+		issues, err := listMilestoneIssues(ctx, t, number)
+		if err != nil {
+			log.Printf("Warning: Could not list issues of milestone '%s': %v", title, err)
+			continue
+		}
+		for _, issue := range issues {
+			if issueHasLabel(issue, label.Name) {
+				continue
+			}
+			if err := addIssueLabels(ctx, t, issue, []string{label.Name}); err != nil {
+				log.Printf("Warning: %v", err)
+				continue
+			}
+			log.Printf("Added label \"%s\" to issue #%d.", label.Name, issue.Number)
+			time.Sleep(requestDelay)
+		}
+	}
+
+	for number, label := range mirrorLabels {
+		if !milestoneExists(milestoneTitleToIDMap, number) {
+			log.Printf("Warning: Label \"%s\" mirrors milestone #%d, which no longer exists.", label.Name, number)
+		}
+	}
+	log.Printf("Finished mirroring milestones. Created %d labels, renamed or adopted %d.", counts.Created, counts.Updated)
+	return counts, nil
+}
+
+func issueHasLabel(issue github.Issue, name string) bool {
+	for _, label := range issue.Labels {
+		if label.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func milestoneExists(milestoneTitleToIDMap map[string]int, number int) bool {
+	for _, id := range milestoneTitleToIDMap {
+		if id == number {
+			return true
+		}
+	}
+	return false
+}
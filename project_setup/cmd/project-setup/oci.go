@@ -0,0 +1,400 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Definition bundles can be published as OCI artifacts and pulled with --from
+// oci://registry/repository:tag or @sha256:<digest>. The artifact's layers are either
+// tar archives of a definition directory or single files named by their title annotation
+// (as pushed by oras). Pulled bundles are kept by manifest digest, so a pinned bundle is
+// read from disk without asking the registry again.
+const (
+	ociScheme           = "oci://"
+	ociManifestType     = "application/vnd.oci.image.manifest.v1+json"
+	ociTitleAnnotation  = "org.opencontainers.image.title"
+	ociCacheDirName     = "project-setup/oci"
+	ociUsernameEnv      = "OCI_USERNAME" // Registry credentials; anonymous pulls without them
+	ociPasswordEnv      = "OCI_PASSWORD"
+	maxOCIManifestBytes = 4 << 20
+)
+
+// ociDigestPattern matches the digests this tool verifies
+var ociDigestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// ociReference is a parsed oci:// reference
+type ociReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string // Pins the manifest when set
+}
+
+func (r ociReference) String() string {
+	s := ociScheme + r.Registry + "/" + r.Repository
+	if r.Digest != "" {
+		return s + "@" + r.Digest
+	}
+	return s + ":" + r.Tag
+}
+
+// ref is what the manifest is requested by
+func (r ociReference) ref() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// baseURL is the registry API; registries on the local machine are spoken to over plain HTTP
+func (r ociReference) baseURL() string {
+	host := r.Registry
+	if h, _, found := strings.Cut(host, ":"); found {
+		host = h
+	}
+	if host == "localhost" || host == "127.0.0.1" {
+		return "http://" + r.Registry
+	}
+	return "https://" + r.Registry
+}
+
+// parseOCIReference parses oci://registry/repository[:tag][@sha256:digest]; the tag
+// defaults to latest
+func parseOCIReference(s string) (ociReference, error) {
+	var ref ociReference
+	rest, ok := strings.CutPrefix(s, ociScheme)
+	if !ok {
+		return ref, fmt.Errorf("invalid --from %q, expected %sregistry/repository:tag", s, ociScheme)
+	}
+	ref.Registry, rest, ok = strings.Cut(rest, "/")
+	if !ok || ref.Registry == "" || rest == "" {
+		return ref, fmt.Errorf("invalid --from %q, expected %sregistry/repository:tag", s, ociScheme)
+	}
+	if name, digest, found := strings.Cut(rest, "@"); found {
+		if !ociDigestPattern.MatchString(digest) {
+			return ref, fmt.Errorf("invalid digest %q in --from, expected sha256:<64 hex digits>", digest)
+		}
+		rest, ref.Digest = name, digest
+	}
+	ref.Repository, ref.Tag = rest, "latest"
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		ref.Repository, ref.Tag = rest[:i], rest[i+1:]
+	}
+	if ref.Repository == "" || ref.Tag == "" {
+		return ref, fmt.Errorf("invalid --from %q, expected %sregistry/repository:tag", s, ociScheme)
+	}
+	return ref, nil
+}
+
+// ociManifest is the part of an OCI image manifest needed to pull an artifact
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+	Manifests []ociDescriptor `json:"manifests"` // Set for an index, which is not an artifact
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ociClient pulls from one registry, answering its token challenges
+type ociClient struct {
+	ref   ociReference
+	http  *http.Client
+	token string // Bearer token once a challenge was answered
+}
+
+// redactOCICredentials keeps the registry password out of logs and errors, also in the
+// basic auth form it is sent in
+func redactOCICredentials() {
+	password := os.Getenv(ociPasswordEnv)
+	if password == "" {
+		return
+	}
+	secrets.add(password)
+	secrets.add(base64.StdEncoding.EncodeToString([]byte(os.Getenv(ociUsernameEnv) + ":" + password)))
+}
+
+// get sends a GET to the registry API, authenticating when challenged
+func (c *ociClient) get(ctx context.Context, path, accept string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.ref.baseURL()+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		} else if user := os.Getenv(ociUsernameEnv); user != "" {
+			req.SetBasicAuth(user, os.Getenv(ociPasswordEnv))
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
+		}
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := c.authenticate(ctx, challenge); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ociChallengeParam matches the key="value" pairs of a WWW-Authenticate header
+var ociChallengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authenticate fetches a bearer token for a challenge of the registry
+func (c *ociClient) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("registry %s refused the credentials (set %s and %s)", c.ref.Registry, ociUsernameEnv, ociPasswordEnv)
+	}
+	values := make(map[string]string)
+	for _, m := range ociChallengeParam.FindAllStringSubmatch(params, -1) {
+		values[m[1]] = m[2]
+	}
+	if values["realm"] == "" {
+		return fmt.Errorf("registry %s sent a token challenge without a realm", c.ref.Registry)
+	}
+	query := url.Values{}
+	for _, key := range []string{"service", "scope"} {
+		if values[key] != "" {
+			query.Set(key, values[key])
+		}
+	}
+	if query.Get("scope") == "" {
+		query.Set("scope", "repository:"+c.ref.Repository+":pull")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, values["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if user := os.Getenv(ociUsernameEnv); user != "" {
+		req.SetBasicAuth(user, os.Getenv(ociPasswordEnv))
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("error getting a token from %s: %w", values["realm"], err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error getting a token from %s: %s", values["realm"], resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("error reading the token from %s: %w", values["realm"], err)
+	}
+	if c.token = token.Token; c.token == "" {
+		c.token = token.AccessToken
+	}
+	secrets.add(c.token)
+	return nil
+}
+
+// fetchManifest gets the artifact manifest and its digest, checked against a pinned digest
+func (c *ociClient) fetchManifest(ctx context.Context) (ociManifest, string, error) {
+	var manifest ociManifest
+	resp, err := c.get(ctx, fmt.Sprintf("/v2/%s/manifests/%s", c.ref.Repository, c.ref.ref()), ociManifestType)
+	if err != nil {
+		return manifest, "", fmt.Errorf("error getting the manifest of %s: %w", c.ref, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxOCIManifestBytes))
+	if err != nil {
+		return manifest, "", fmt.Errorf("error reading the manifest of %s: %w", c.ref, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return manifest, "", fmt.Errorf("error getting the manifest of %s: %s: %s", c.ref, resp.Status, bytes.TrimSpace(data))
+	}
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if c.ref.Digest != "" && digest != c.ref.Digest {
+		return manifest, "", fmt.Errorf("the manifest of %s has digest %s, refusing a bundle that does not match the pinned digest", c.ref, digest)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, "", fmt.Errorf("error unmarshalling the manifest of %s: %w", c.ref, err)
+	}
+	if len(manifest.Manifests) > 0 {
+		return manifest, "", fmt.Errorf("%s is an image index, expected the manifest of a single artifact", c.ref)
+	}
+	return manifest, digest, nil
+}
+
+// fetchBlob downloads a layer and checks it against its digest
+func (c *ociClient) fetchBlob(ctx context.Context, layer ociDescriptor) ([]byte, error) {
+	if !ociDigestPattern.MatchString(layer.Digest) {
+		return nil, fmt.Errorf("layer %q of %s has an unsupported digest, only sha256 is verified", layer.Digest, c.ref)
+	}
+	resp, err := c.get(ctx, fmt.Sprintf("/v2/%s/blobs/%s", c.ref.Repository, layer.Digest), "")
+	if err != nil {
+		return nil, fmt.Errorf("error getting layer %s of %s: %w", layer.Digest, c.ref, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error getting layer %s of %s: %s", layer.Digest, c.ref, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, layer.Size+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading layer %s of %s: %w", layer.Digest, c.ref, err)
+	}
+	sum := sha256.Sum256(data)
+	if digest := "sha256:" + hex.EncodeToString(sum[:]); digest != layer.Digest || int64(len(data)) != layer.Size {
+		return nil, fmt.Errorf("layer %s of %s does not match its digest or size", layer.Digest, c.ref)
+	}
+	return data, nil
+}
+
+// extractOCILayer writes one layer into dir: a tar archive (optionally gzipped) is
+// unpacked, any other layer is written as the file named by its title annotation
+func extractOCILayer(dir string, layer ociDescriptor, data []byte) error {
+	if strings.Contains(layer.MediaType, "tar") {
+		var r io.Reader = bytes.NewReader(data)
+		if strings.HasSuffix(layer.MediaType, "gzip") {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				return fmt.Errorf("error reading layer %s: %w", layer.Digest, err)
+			}
+			r = gz
+		}
+		tr := tar.NewReader(r)
+		for {
+			header, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("error reading layer %s: %w", layer.Digest, err)
+			}
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := writeOCIFile(dir, header.Name, tr); err != nil {
+				return err
+			}
+		}
+	}
+	title := layer.Annotations[ociTitleAnnotation]
+	if title == "" {
+		return fmt.Errorf("layer %s is neither a tar archive nor has a %s annotation", layer.Digest, ociTitleAnnotation)
+	}
+	return writeOCIFile(dir, title, bytes.NewReader(data))
+}
+
+// writeOCIFile writes a file of a bundle, refusing paths that leave its directory
+func writeOCIFile(dir, name string, r io.Reader) error {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("bundle file %q is outside of the bundle", name)
+	}
+	path := filepath.Join(dir, clean)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing bundle file %s: %w", name, err)
+	}
+	return f.Close()
+}
+
+// ociCacheDir returns the directory a bundle with the given manifest digest is kept in
+func ociCacheDir(digest string) (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding the cache directory for OCI bundles: %w", err)
+	}
+	return filepath.Join(base, ociCacheDirName, strings.Replace(digest, ":", "-", 1)), nil
+}
+
+// pullDefinitionBundle pulls the bundle of an oci:// reference and returns its directory,
+// to be read as the first definition layer
+func pullDefinitionBundle(ctx context.Context, reference string) (string, error) {
+	ref, err := parseOCIReference(reference)
+	if err != nil {
+		return "", err
+	}
+	if ref.Digest != "" {
+		dir, err := ociCacheDir(ref.Digest)
+		if err != nil {
+			return "", err
+		}
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			log.Printf("Using definition bundle %s from %s.", ref, dir)
+			return dir, nil
+		}
+	}
+
+	redactOCICredentials()
+	c := &ociClient{ref: ref, http: &http.Client{Timeout: httpClient.Timeout}}
+	manifest, digest, err := c.fetchManifest(ctx)
+	if err != nil {
+		return "", err
+	}
+	dir, err := ociCacheDir(digest)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		log.Printf("Using definition bundle %s (digest %s) from %s.", ref, digest, dir)
+		return dir, nil
+	}
+	// Unpacked next to the cache entry and renamed into place, so an interrupted pull never
+	// leaves a partial bundle behind
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return "", fmt.Errorf("error creating the cache directory for OCI bundles: %w", err)
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".pull-*")
+	if err != nil {
+		return "", fmt.Errorf("error creating the cache directory for OCI bundles: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	for _, layer := range manifest.Layers {
+		data, err := c.fetchBlob(ctx, layer)
+		if err != nil {
+			return "", err
+		}
+		if err := extractOCILayer(tmp, layer, data); err != nil {
+			return "", err
+		}
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return "", fmt.Errorf("error caching definition bundle %s: %w", ref, err)
+	}
+	log.Printf("Pulled definition bundle %s (digest %s, %d layers) into %s.", ref, digest, len(manifest.Layers), dir)
+	if ref.Digest == "" {
+		log.Printf("Pin it with --from %s%s/%s@%s to make sure it never changes.", ociScheme, ref.Registry, ref.Repository, digest)
+	}
+	return dir, nil
+}
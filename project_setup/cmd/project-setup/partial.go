@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// selectMilestones narrows the definitions down to what the given milestones need: the
+// milestones themselves, their issues and the labels those issues use. Everything else
+// stays undefined in the repositories until a later run selects it, so a backlog can be
+// staged one sprint at a time.
+func (d *definitions) selectMilestones(titles []string) error {
+	if len(titles) == 0 {
+		return nil
+	}
+	selected := make(map[string]bool, len(titles))
+	for _, title := range titles {
+		selected[milestoneKey(title)] = true
+	}
+	var milestones []MilestoneData
+	for _, m := range d.Milestones {
+		if selected[milestoneKey(m.Title)] {
+			milestones = append(milestones, m)
+			delete(selected, milestoneKey(m.Title))
+		}
+	}
+	for _, title := range titles {
+		if selected[milestoneKey(title)] {
+			return fmt.Errorf("milestone '%s' is not defined", title)
+		}
+	}
+
+	var issues []IssueData
+	var indexes []int
+	used := make(map[string]bool)
+	for i, issue := range d.Issues {
+		if issue.MilestoneTitle == nil {
+			continue
+		}
+		for _, m := range milestones {
+			if milestoneKey(*issue.MilestoneTitle) == milestoneKey(m.Title) {
+				issues = append(issues, issue)
+				source := i
+				if d.IssueIndexes != nil {
+					source = d.IssueIndexes[i]
+				}
+				indexes = append(indexes, source)
+				for _, name := range issue.Labels {
+					used[strings.ToLower(name)] = true
+				}
+				break
+			}
+		}
+	}
+	var labels []LabelData
+	for _, label := range d.Labels {
+		if used[strings.ToLower(label.Name)] {
+			labels = append(labels, label)
+		}
+	}
+
+	log.Printf("Selected %d of %d milestones, %d of %d issues and %d of %d labels (--milestone).",
+		len(milestones), len(d.Milestones), len(issues), len(d.Issues), len(labels), len(d.Labels))
+	d.Milestones, d.Issues, d.Labels, d.IssueIndexes = milestones, issues, labels, indexes
+	return nil
+}
+
+// sourceIndexes maps issues created by index in the definitions back to their index in
+// the issues file; generated issues are not written back
+func (d *definitions) sourceIndexes(created map[int]github.Issue) map[int]github.Issue {
+	if d.IssueIndexes == nil {
+		return created
+	}
+	mapped := make(map[int]github.Issue, len(created))
+	for index, issue := range created {
+		if source := d.sourceIndex(index); source >= 0 {
+			mapped[source] = issue
+		}
+	}
+	return mapped
+}
+
+// sourceIndex returns the index in the issues file of an issue of the definitions, -1 for
+// a generated one
+func (d *definitions) sourceIndex(index int) int {
+	if d.IssueIndexes == nil {
+		return index
+	}
+	return d.IssueIndexes[index]
+}
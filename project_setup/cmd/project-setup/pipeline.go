@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/alcorg/project_setup/project_setup/engine"
+)
+
+// phase is one step of applying the definitions to a repository
+type phase struct {
+	name  string   // Used in logs, e.g. "label processing"
+	needs []string // Phases that must succeed first; the phase is skipped when one of them fails
+	after []string // Phases that must be done first, whether they succeed or not
+	run   func(ctx context.Context) error
+}
+
+// runPhases runs the phases as a dependency graph: every phase starts as soon as the
+// phases it depends on are done, with at most workers phases running at the same time.
+// Dependencies must be declared earlier in the list, which rules out cycles.
+// failed is called for every phase that returned an error; it returns the number of failed phases.
+func runPhases(ctx context.Context, phases []phase, workers int, failed func(p phase, err error)) (int, error) {
+	index := make(map[string]int, len(phases))
+	for i, p := range phases {
+		for _, dep := range append(append([]string{}, p.needs...), p.after...) {
+			if _, ok := index[dep]; !ok {
+				return 0, fmt.Errorf("phase %q depends on %q, which is not declared before it", p.name, dep)
+			}
+		}
+		index[p.name] = i
+	}
+
+	done := make([]chan struct{}, len(phases))
+	succeeded := make([]bool, len(phases)) // Written before done[i] is closed, read after
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	sem := make(chan struct{}, max(workers, 1))
+	var mu sync.Mutex
+	failures := 0
+	var wg sync.WaitGroup
+	for i, p := range phases {
+		wg.Add(1)
+		go func(i int, p phase) {
+			defer wg.Done()
+			defer close(done[i])
+			for _, dep := range p.after {
+				<-done[index[dep]]
+			}
+			for _, dep := range p.needs {
+				if <-done[index[dep]]; !succeeded[index[dep]] {
+					log.Printf("Skipping %s because %s did not succeed.", p.name, phases[index[dep]].name)
+					return
+				}
+			}
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				log.Printf("Skipping %s: %v.", p.name, ctx.Err())
+				return
+			}
+			engine.Report(ctx, engine.Event{Kind: engine.EventPhaseStarted, Phase: p.name})
+			if err := p.run(withAPIPhase(ctx, p.name)); err != nil {
+				mu.Lock()
+				failures++
+				mu.Unlock()
+				failed(p, err)
+				engine.Report(ctx, engine.Event{Kind: engine.EventPhaseFinished, Phase: p.name, Err: err.Error()})
+				return
+			}
+			succeeded[i] = true
+			engine.Report(ctx, engine.Event{Kind: engine.EventPhaseFinished, Phase: p.name})
+		}(i, p)
+	}
+	wg.Wait()
+	return failures, nil
+}
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// Actions of a planned change
+const (
+	actionCreate    = "create"
+	actionUpdate    = "update" // Differs from the definition, updated or resolved with --on-conflict depending on the policy
+	actionCommit    = "commit"
+	actionUnchanged = "unchanged"
+	actionSkip      = "skip"
+	actionDelete    = "delete" // With --prune
+)
+
+// plannedChange is what applying would do to one entity
+type plannedChange struct {
+	Kind   string   `json:"kind"` // label, milestone, issue, file or property
+	Name   string   `json:"name"`
+	Action string   `json:"action"`
+	Diffs  []string `json:"diffs,omitempty"`
+	Note   string   `json:"note,omitempty"`
+	// Color of a label that is created or recolored, for the label preview
+	Color *labelColorChange `json:"color,omitempty"`
+}
+
+// repoChangePlan lists the planned changes of one repository
+type repoChangePlan struct {
+	Repo     string          `json:"repo"`
+	Changes  []plannedChange `json:"changes"`
+	Warnings []string        `json:"warnings,omitempty"`
+	Error    string          `json:"error,omitempty"` // Set when the repository could not be read
+	Since    *runComparison  `json:"since,omitempty"` // With --against
+}
+
+// changePlan is the full result of `plan`
+type changePlan struct {
+	TemplateHash string           `json:"template_hash"`
+	GeneratedAt  time.Time        `json:"generated_at"`
+	Repos        []repoChangePlan `json:"repos"`
+	// Set on plans saved with --out for 'approve' and 'apply --plan'
+	CreatedBy string         `json:"created_by,omitempty"`
+	Approvals []planApproval `json:"approvals,omitempty"`
+}
+
+// Count returns the number of changes with the given action
+func (p repoChangePlan) Count(action string) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// ByKind returns the changes of one kind, in definition order
+func (p repoChangePlan) ByKind(kind string) []plannedChange {
+	var changes []plannedChange
+	for _, c := range p.Changes {
+		if c.Kind == kind {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// planRepo reads the current state of one repository and works out what applying
+// the definitions would change, without changing anything
+func planRepo(ctx context.Context, rp repoPlan, defs *definitions, options applyOptions) repoChangePlan {
+	t := rp.Target
+	result := repoChangePlan{Repo: t.String()}
+	for c := range rp.Degraded {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%s not supported, will be skipped", c))
+	}
+	sort.Strings(result.Warnings)
+
+	existingLabels, err := getExistingLabels(ctx, t)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	var newLabels []string
+	for _, label := range defs.Labels {
+		change := plannedChange{Kind: "label", Name: label.Name, Action: actionUnchanged}
+		if existing, ok := existingLabels[label.Name]; !ok {
+			change.Action, change.Color = actionCreate, &labelColorChange{To: label.Color}
+			newLabels = append(newLabels, label.Name)
+		} else if diffs := labelDifferences(label, existing); len(diffs) > 0 {
+			change.Action, change.Diffs = actionUpdate, diffs
+			if !strings.EqualFold(label.Color, existing.Color) {
+				change.Color = &labelColorChange{From: existing.Color, To: label.Color}
+			}
+		}
+		applyPolicy(&change, config.Policies.Labels)
+		result.Changes = append(result.Changes, change)
+	}
+	result.Warnings = append(result.Warnings, nearDuplicateLabelWarnings(newLabels, existingLabels)...)
+	if options.MirrorMilestoneLabels && !rp.Degraded[capMirrorLabels] {
+		for _, milestone := range defs.Milestones {
+			name := milestoneLabelName(milestone.Title)
+			change := plannedChange{Kind: "label", Name: name, Action: actionUnchanged, Note: "mirrors a milestone"}
+			if _, ok := existingLabels[name]; !ok {
+				change.Action = actionCreate
+			}
+			result.Changes = append(result.Changes, change)
+		}
+	}
+
+	if options.CreateMissingLabels {
+		present := make(map[string]bool, len(existingLabels))
+		for name := range existingLabels {
+			present[strings.ToLower(name)] = true
+		}
+		for _, name := range undefinedIssueLabels(defs) {
+			if !present[strings.ToLower(name)] {
+				result.Changes = append(result.Changes, plannedChange{Kind: "label", Name: name, Action: actionCreate, Note: "used by issues, default color"})
+			}
+		}
+	}
+
+	existingMilestones, err := getExistingMilestones(ctx, t)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	for _, milestone := range defs.Milestones {
+		change := plannedChange{Kind: "milestone", Name: milestone.Title, Action: actionUnchanged}
+		if existing, ok := existingMilestones[milestoneKey(milestone.Title)]; !ok {
+			change.Action = actionCreate
+		} else if diffs := milestoneDifferences(milestone, existing); len(diffs) > 0 {
+			change.Action, change.Diffs = actionUpdate, diffs
+		}
+		applyPolicy(&change, config.Policies.Milestones)
+		result.Changes = append(result.Changes, change)
+	}
+	if options.Prune {
+		result.Changes = append(result.Changes, planPrune(defs, existingLabels, existingMilestones, options)...)
+	}
+
+	definedMilestones := make(map[string]bool, len(defs.Milestones))
+	for _, milestone := range defs.Milestones {
+		definedMilestones[milestoneKey(milestone.Title)] = true
+	}
+	var existingTitles map[string]github.Issue
+	if (config.Policies.Issues == policyCreateIfMissing || config.Policies.Issues == policyMergeLabels) && len(defs.Issues) > 0 {
+		if existingTitles, err = getExistingIssues(ctx, t); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+	var state *repoState
+	if options.State != nil {
+		if state, err = loadState(ctx, options.State, t); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("run state could not be read: %v", err))
+		} else if state.Lock != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("locked by %s since %s", state.Lock.Holder, state.Lock.Since.Format(time.RFC3339)))
+		}
+	}
+	knownLabels := make(map[string]bool, len(defs.Labels)+len(existingLabels))
+	for _, label := range defs.Labels {
+		knownLabels[strings.ToLower(label.Name)] = true
+	}
+	for name := range existingLabels {
+		knownLabels[strings.ToLower(name)] = true
+	}
+	epics := epicChildren(defs.Issues)
+	var data *templateData
+	if config.IssueBody.Templates {
+		d := newTemplateData(t, rp.Metadata, defs.RepoVariables)
+		data = &d
+	}
+	for index, issue := range defs.Issues {
+		change := plannedChange{Kind: "issue", Name: issue.Title, Action: actionCreate}
+		if existing, exists := existingTitles[issue.Title]; exists {
+			change.Action, change.Note = actionSkip, fmt.Sprintf("already exists as #%d", existing.Number)
+			if config.Policies.Issues == policyMergeLabels {
+				merge := mergeIssueLabels(existing, issue.Labels)
+				if len(merge.Add) > 0 {
+					change.Action = actionUpdate
+					change.Diffs = append(change.Diffs, fmt.Sprintf("labels added: %s", strings.Join(merge.Add, ", ")))
+				}
+				if len(merge.Replaced) > 0 {
+					change.Diffs = append(change.Diffs, fmt.Sprintf("labels removed for a scoped label: %s", strings.Join(merge.Replaced, ", ")))
+				}
+				for _, conflict := range merge.Conflicts {
+					result.Warnings = append(result.Warnings, fmt.Sprintf("issue #%d: label %s", existing.Number, conflict))
+				}
+			}
+			// Epics carry their synced task list, so only other bodies are compared
+			if _, isEpic := epics[index]; !isEpic {
+				if diff := issueBodyDrift(existing.Body, issue, data); diff != "" {
+					change.Diffs = []string{diff}
+				}
+			}
+		} else if recorded, ok := state.issue(issue.Title); ok {
+			change.Action, change.Note = actionSkip, fmt.Sprintf("created as #%d by an earlier run", recorded.Number)
+		} else if issue.Output != nil && issue.Output.Repository == t.String() {
+			change.Action, change.Note = actionSkip, fmt.Sprintf("already created as #%d", issue.Output.Number)
+		} else if config.Policies.Issues == policySkip {
+			change.Action, change.Note = actionSkip, fmt.Sprintf("policy %q", policySkip)
+		} else if m := issue.MilestoneTitle; m != nil && *m != "" && !definedMilestones[milestoneKey(*m)] {
+			if _, ok := existingMilestones[milestoneKey(*m)]; !ok {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("issue '%s' will be created without its milestone '%s'", issue.Title, *m))
+			}
+		}
+		if change.Action == actionCreate {
+			change.Diffs = issueCreationDiffs(issue, existingMilestones, definedMilestones)
+			if !options.CreateMissingLabels {
+				for _, name := range issue.Labels {
+					if !knownLabels[strings.ToLower(name)] {
+						result.Warnings = append(result.Warnings, fmt.Sprintf("issue '%s': label '%s' is neither defined nor present in the repository", issue.Title, name))
+					}
+				}
+			}
+		}
+		if children, isEpic := epics[index]; isEpic && config.Policies.Issues != policySkip {
+			change.Note = strings.TrimPrefix(change.Note+"; ", "; ") + fmt.Sprintf("epic, task list of %d children synced", len(children))
+		}
+		result.Changes = append(result.Changes, change)
+	}
+
+	if options.TrackingIssues {
+		result.Changes = append(result.Changes, planTrackingIssues(ctx, t, defs, existingMilestones)...)
+	}
+
+	files := mergeFiles(defs.Files, rp.CommunityFiles)
+	for _, path := range sortedKeys(files) {
+		result.Changes = append(result.Changes, plannedChange{Kind: "file", Name: path, Action: actionCommit, Note: commitNote()})
+	}
+	if path := strings.TrimPrefix(config.ProjectPlan.Path, "/"); path != "" {
+		// The content depends on what apply creates, so it is only known afterwards
+		result.Changes = append(result.Changes, plannedChange{Kind: "file", Name: path, Action: actionCommit, Note: "project plan section; " + commitNote()})
+	}
+
+	if len(defs.Properties) > 0 && !rp.Degraded[capProperties] {
+		current, err := getPropertyValues(ctx, t)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("custom properties could not be read: %v", err))
+		}
+		for _, name := range sortedKeys(defs.Properties) {
+			change := plannedChange{Kind: "property", Name: name, Action: actionUnchanged}
+			if err == nil && !reflect.DeepEqual(current[name], defs.Properties[name]) {
+				change.Action = actionUpdate
+				change.Diffs = []string{fmt.Sprintf("%v (remote) vs %v (local)", current[name], defs.Properties[name])}
+			}
+			result.Changes = append(result.Changes, change)
+		}
+	}
+	return result
+}
+
+// issueCreationDiffs lists the milestone an issue would be created in, resolved to its
+// number or to the milestone the run creates, and its labels
+func issueCreationDiffs(issue IssueData, existingMilestones map[string]github.Milestone, definedMilestones map[string]bool) []string {
+	var diffs []string
+	if m := issue.MilestoneTitle; m != nil && *m != "" {
+		if existing, ok := existingMilestones[milestoneKey(*m)]; ok {
+			diffs = append(diffs, fmt.Sprintf("milestone '%s' (#%d)", *m, existing.ID))
+		} else if definedMilestones[milestoneKey(*m)] {
+			diffs = append(diffs, fmt.Sprintf("milestone '%s' (created by this run)", *m))
+		}
+	}
+	if len(issue.Labels) > 0 {
+		diffs = append(diffs, "labels "+strings.Join(issue.Labels, ", "))
+	}
+	return diffs
+}
+
+// planTrackingIssues works out which tracking issues would be created or regenerated.
+// Milestones that do not exist yet get one when a defined issue is assigned to them.
+func planTrackingIssues(ctx context.Context, t repoTarget, defs *definitions, existingMilestones map[string]github.Milestone) []plannedChange {
+	var changes []plannedChange
+	for _, milestone := range defs.Milestones {
+		change := plannedChange{Kind: "issue", Name: fmt.Sprintf(trackingIssueTitle, milestone.Title), Action: actionUnchanged}
+		var tracking *github.Issue
+		var tracked []github.Issue
+		if existing, ok := existingMilestones[milestoneKey(milestone.Title)]; ok {
+			issues, err := listMilestoneIssues(ctx, t, existing.ID)
+			if err != nil {
+				change.Note = fmt.Sprintf("could not list issues: %v", err)
+				changes = append(changes, change)
+				continue
+			}
+			tracking, tracked = splitTrackingIssue(issues)
+		}
+		switch {
+		case tracking == nil && (len(tracked) > 0 || hasMilestoneIssues(defs.Issues, milestone.Title)):
+			change.Action = actionCreate
+		case tracking != nil:
+			// Issues created by the run are added as well, so only a changed list is certain
+			change.Action, change.Note = actionUpdate, "task list regenerated"
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// applyPolicy adjusts a planned label/milestone change to the configured policy of its type
+func applyPolicy(change *plannedChange, policy entityPolicy) {
+	switch {
+	case policy == policySkip && change.Action != actionUnchanged:
+		change.Action, change.Note = actionSkip, fmt.Sprintf("policy %q", policy)
+	case policy == policyCreateIfMissing && change.Action == actionUpdate:
+		change.Action, change.Note = actionUnchanged, fmt.Sprintf("differs, kept by policy %q", policy)
+	case policy == policyAsk && change.Action == actionUpdate:
+		change.Note = "resolved with --on-conflict"
+	}
+}
+
+// commitNote tells where files would be committed
+func commitNote() string {
+	if config.Commit.Branch != "" {
+		return fmt.Sprintf("via pull request from branch '%s', if the content differs", config.Commit.Branch)
+	}
+	return "to the default branch, if the content differs"
+}
+
+// sortedKeys returns the keys of a string-keyed map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writePlanText prints the plan, one line per change that is not a no-op
+func writePlanText(w io.Writer, plan changePlan) {
+	symbols := map[string]string{actionCreate: "+", actionUpdate: "~", actionCommit: "*", actionSkip: "-", actionDelete: "x"}
+	fmt.Fprintf(w, "=== Plan (template hash %s) ===\n", plan.TemplateHash)
+	for _, repo := range plan.Repos {
+		fmt.Fprintf(w, "\n%s:\n", repo.Repo)
+		if repo.Error != "" {
+			fmt.Fprintf(w, "  error: %s\n", repo.Error)
+			continue
+		}
+		for _, c := range repo.Changes {
+			if c.Action == actionUnchanged {
+				continue
+			}
+			line := fmt.Sprintf("  %s %s \"%s\"", symbols[c.Action], c.Kind, c.Name)
+			if len(c.Diffs) > 0 {
+				line += ": " + strings.Join(c.Diffs, "; ")
+			}
+			if c.Note != "" {
+				line += " (" + c.Note + ")"
+			}
+			fmt.Fprintln(w, line)
+		}
+		for _, warning := range repo.Warnings {
+			fmt.Fprintf(w, "  warning: %s\n", warning)
+		}
+		if repo.Since != nil {
+			writeComparisonText(w, repo.Since)
+		}
+		deletions := ""
+		if n := repo.Count(actionDelete); n > 0 {
+			deletions = fmt.Sprintf(", %d to delete", n)
+		}
+		fmt.Fprintf(w, "  %d to create, %d to update%s, %d files to commit, %d unchanged, %d skipped\n",
+			repo.Count(actionCreate), repo.Count(actionUpdate), deletions, repo.Count(actionCommit), repo.Count(actionUnchanged), repo.Count(actionSkip))
+	}
+}
+
+// planCommand is a parsed plan invocation
+type planCommand struct {
+	jsonOutput bool
+	reportHTML string
+	preview    string // Markdown preview of the label color changes
+	out        string // Save the plan for approval
+	against    string // Also compare with the repositories as a recorded run left them
+	shared     targetFlags
+	// Called as soon as a repository is planned, e.g. to stream results; nil to ignore
+	onRepoDone func(repo repoChangePlan)
+}
+
+// parsePlanArgs parses and checks the command line of plan
+func parsePlanArgs(args []string, handling flag.ErrorHandling) (*planCommand, error) {
+	c := &planCommand{}
+	fs := flag.NewFlagSet("plan", handling)
+	fs.BoolVar(&c.jsonOutput, "json", false, "Print the plan as JSON instead of text")
+	fs.StringVar(&c.reportHTML, "report-html", "", "Also write the plan as a standalone HTML report to this file")
+	fs.StringVar(&c.preview, "label-preview", "", "Also write a Markdown preview of the label color changes, old and new side by side, to this file")
+	fs.StringVar(&c.out, "out", "", "Also save the plan to this file, to be approved with 'approve' and applied with 'apply --plan'")
+	fs.StringVar(&c.against, "against", "", "Also show what changed in the repositories since the run with this ID (see --state), outside the templates and by them")
+	c.shared.register(fs)
+	fs.Var(&c.shared.milestones, "milestone", "Only plan what apply --milestone would create for this milestone (repeatable)")
+	if handling == flag.ContinueOnError {
+		fs.SetOutput(io.Discard)
+	}
+	layers, err := parseArgs(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	c.shared.paths.Layers = layers
+	if err := c.shared.limits.check(); err != nil {
+		return nil, err
+	}
+	if c.against != "" && c.shared.state == "" {
+		return nil, fmt.Errorf("--against needs the --state the run was recorded in")
+	}
+	return c, nil
+}
+
+// runPlan shows what apply would change in the target repositories. Nothing is changed.
+func runPlan(ctx context.Context, args []string) {
+	c, err := parsePlanArgs(args, flag.ExitOnError)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	ctx, cancel := c.shared.limits.start(ctx)
+	defer cancel()
+	plan, err := c.execute(ctx)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if c.jsonOutput {
+		enc := json.NewEncoder(redacted(os.Stdout))
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(plan); err != nil {
+			log.Fatalf("Error encoding plan: %v", err)
+		}
+	} else {
+		writePlanText(redacted(os.Stdout), plan)
+	}
+	if c.out != "" {
+		plan.CreatedBy = currentUser()
+		if err := writeSavedPlan(c.out, plan); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		log.Printf("Saved the plan to %s (digest %s). Have it approved with 'approve %s', then run 'apply --plan %s'.", c.out, planDigest(plan), c.out, c.out)
+	}
+	exitOnDeadline(ctx)
+}
+
+// execute plans every target and writes the HTML report and label preview when asked to
+func (c *planCommand) execute(ctx context.Context) (changePlan, error) {
+	prepared, err := c.shared.prepare(ctx)
+	if err != nil {
+		return changePlan{}, err
+	}
+
+	onRepoDone := c.onRepoDone
+	var comparisons map[string]*runComparison
+	var problems map[string]string
+	if c.against != "" {
+		comparisons, problems = compareTargets(ctx, prepared, c.shared.options.State, c.against)
+		onRepoDone = func(repo repoChangePlan) {
+			if c.onRepoDone != nil {
+				c.onRepoDone(withComparison(repo, comparisons, problems))
+			}
+		}
+	}
+	plan := planTargets(ctx, prepared, c.shared.options, onRepoDone)
+	if c.against != "" {
+		for i, repo := range plan.Repos {
+			plan.Repos[i] = withComparison(repo, comparisons, problems)
+		}
+	}
+	if c.reportHTML != "" {
+		if err := writePlanHTMLFile(c.reportHTML, plan); err != nil {
+			return plan, err
+		}
+		log.Printf("Wrote HTML report to %s.", c.reportHTML)
+	}
+	if c.preview != "" {
+		if err := writeLabelPreview(c.preview, plan); err != nil {
+			return plan, err
+		}
+		log.Printf("Wrote label color preview to %s.", c.preview)
+	}
+	return plan, nil
+}
+
+// planTargets plans every prepared target, calling onRepoDone (unless nil) for each
+func planTargets(ctx context.Context, prepared *preparedRun, options applyOptions, onRepoDone func(repo repoChangePlan)) changePlan {
+	plan := changePlan{TemplateHash: prepared.defs.Hash, GeneratedAt: time.Now().UTC()}
+	for _, rp := range prepared.plans {
+		log.Printf("Planning %s...", rp.Target)
+		repo := planRepo(ctx, rp, prepared.defs, options)
+		plan.Repos = append(plan.Repos, repo)
+		if onRepoDone != nil {
+			onRepoDone(repo)
+		}
+	}
+	for _, t := range sortedTargets(prepared.orgFiles) {
+		repo := repoChangePlan{Repo: t.String()}
+		for _, path := range sortedKeys(prepared.orgFiles[t]) {
+			repo.Changes = append(repo.Changes, plannedChange{Kind: "file", Name: path, Action: actionCommit, Note: "organization default community file"})
+		}
+		plan.Repos = append(plan.Repos, repo)
+	}
+	return plan
+}
+
+// sortedTargets returns the repositories of a per-repository map in order
+func sortedTargets[V any](m map[repoTarget]V) []repoTarget {
+	targets := make([]repoTarget, 0, len(m))
+	for t := range m {
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].String() < targets[j].String() })
+	return targets
+}
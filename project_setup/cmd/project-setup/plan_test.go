@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+func TestIssueCreationDiffs(t *testing.T) {
+	existing := map[string]github.Milestone{milestoneKey("v1.0"): {ID: 3, Title: "v1.0"}}
+	defined := map[string]bool{milestoneKey("v2.0"): true}
+	title := func(s string) *string { return &s }
+	tests := []struct {
+		name  string
+		issue IssueData
+		want  []string
+	}{
+		{"existing milestone", IssueData{MilestoneTitle: title("v1.0"), Labels: []string{"bug", "ui"}}, []string{"milestone 'v1.0' (#3)", "labels bug, ui"}},
+		{"created milestone", IssueData{MilestoneTitle: title("v2.0")}, []string{"milestone 'v2.0' (created by this run)"}},
+		{"unknown milestone", IssueData{MilestoneTitle: title("v3.0")}, nil},
+		{"no milestone", IssueData{Labels: []string{"docs"}}, []string{"labels docs"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := issueCreationDiffs(tc.issue, existing, defined); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("issueCreationDiffs() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// planApprovalKeyEnv holds the shared secret approvals are signed with. Without it an
+// approval is only a recorded name, checked against the digest of the plan.
+const planApprovalKeyEnv = "PLAN_APPROVAL_KEY"
+
+// planApproval records that someone reviewed a saved plan
+type planApproval struct {
+	By        string    `json:"by"`
+	At        time.Time `json:"at"`
+	Digest    string    `json:"digest"`              // planDigest of the approved plan
+	Signature string    `json:"signature,omitempty"` // HMAC-SHA256 with PLAN_APPROVAL_KEY, when set
+}
+
+// currentUser names whoever runs the command, for plans and approvals
+func currentUser() string {
+	for _, env := range []string{"GITHUB_ACTOR", "USER", "USERNAME"} {
+		if name := os.Getenv(env); name != "" {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// planDigest is the SHA-256 of a plan without its approvals
+func planDigest(plan changePlan) string {
+	plan.Approvals = nil
+	data, _ := json.Marshal(plan) // Plain structs, cannot fail
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sign returns the signature of an approval, "" without a key
+func (a planApproval) sign(key string) string {
+	if key == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%s\n%s\n%s", a.Digest, a.By, a.At.UTC().Format(time.RFC3339))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func readSavedPlan(path string) (changePlan, error) {
+	var plan changePlan
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return plan, fmt.Errorf("error reading plan %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return plan, fmt.Errorf("error unmarshalling plan %s: %w", path, err)
+	}
+	return plan, nil
+}
+
+func writeSavedPlan(path string, plan changePlan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling plan: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing plan %s: %w", path, err)
+	}
+	return nil
+}
+
+// checkApprovals returns the valid approvals of a plan. An approval counts when it is for
+// this very plan, by someone other than its author and, with a key, correctly signed.
+func checkApprovals(plan changePlan, key string) ([]planApproval, []string) {
+	digest := planDigest(plan)
+	var valid []planApproval
+	var problems []string
+	for _, a := range plan.Approvals {
+		switch {
+		case a.Digest != digest:
+			problems = append(problems, fmt.Sprintf("approval by %s is for a different plan", a.By))
+		case strings.EqualFold(a.By, plan.CreatedBy):
+			problems = append(problems, fmt.Sprintf("approval by %s is by the author of the plan", a.By))
+		case key != "" && !hmac.Equal([]byte(a.Signature), []byte(a.sign(key))):
+			problems = append(problems, fmt.Sprintf("approval by %s has no valid signature", a.By))
+		default:
+			valid = append(valid, a)
+		}
+	}
+	return valid, problems
+}
+
+// runApprove records the approval of a saved plan after showing it
+func runApprove(args []string) {
+	fs := flag.NewFlagSet("approve", flag.ExitOnError)
+	by := fs.String("by", currentUser(), "Name of the approver")
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 1 {
+		log.Fatal("Error: usage: approve [--by name] <plan.json>")
+	}
+	path := positional[0]
+	plan, err := readSavedPlan(path)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if strings.EqualFold(*by, plan.CreatedBy) {
+		log.Fatalf("Error: %s created the plan and cannot approve it, a second person has to", *by)
+	}
+	for _, a := range plan.Approvals {
+		if strings.EqualFold(a.By, *by) && a.Digest == planDigest(plan) {
+			log.Fatalf("Error: %s already approved this plan on %s", a.By, a.At.Format(time.RFC3339))
+		}
+	}
+	writePlanText(redacted(os.Stdout), plan)
+
+	key := os.Getenv(planApprovalKeyEnv)
+	approval := planApproval{By: *by, At: time.Now().UTC().Truncate(time.Second), Digest: planDigest(plan)}
+	approval.Signature = approval.sign(key)
+	plan.Approvals = append(plan.Approvals, approval)
+	if err := writeSavedPlan(path, plan); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	signed := "unsigned"
+	if key != "" {
+		signed = "signed"
+	}
+	log.Printf("Recorded the %s approval of %s by %s (digest %s).", signed, path, *by, approval.Digest)
+}
+
+// checkSavedPlan refuses to apply unless the saved plan is approved, was made from the
+// same definitions and still describes exactly what applying would change now
+func checkSavedPlan(ctx context.Context, path string, prepared *preparedRun, options applyOptions) error {
+	saved, err := readSavedPlan(path)
+	if err != nil {
+		return err
+	}
+	valid, problems := checkApprovals(saved, os.Getenv(planApprovalKeyEnv))
+	if len(valid) == 0 {
+		if len(problems) == 0 {
+			problems = []string{"it has no approvals"}
+		}
+		return fmt.Errorf("plan %s is not approved: %s", path, strings.Join(problems, "; "))
+	}
+	if saved.TemplateHash != prepared.defs.Hash {
+		return fmt.Errorf("the definitions changed since plan %s was made (template hash %s, plan has %s)", path, prepared.defs.Hash, saved.TemplateHash)
+	}
+	log.Printf("Plan %s was approved by %s; checking that it still holds...", path, valid[0].By)
+	current := planTargets(ctx, prepared, options, nil)
+	if drift := planDrift(saved, current); len(drift) > 0 {
+		return fmt.Errorf("the repositories changed since plan %s was made, plan and approve again:\n  %s", path, strings.Join(drift, "\n  "))
+	}
+	return nil
+}
+
+// planDrift lists how the current plan differs from the saved one
+func planDrift(saved, current changePlan) []string {
+	var drift []string
+	savedRepos := make(map[string]repoChangePlan, len(saved.Repos))
+	for _, repo := range saved.Repos {
+		savedRepos[repo.Repo] = repo
+	}
+	for _, repo := range current.Repos {
+		old, ok := savedRepos[repo.Repo]
+		delete(savedRepos, repo.Repo)
+		switch {
+		case !ok:
+			drift = append(drift, fmt.Sprintf("%s: not in the plan", repo.Repo))
+			continue
+		case repo.Error != "":
+			drift = append(drift, fmt.Sprintf("%s: %s", repo.Repo, repo.Error))
+			continue
+		}
+		changes := make(map[string]plannedChange, len(old.Changes))
+		for _, c := range old.Changes {
+			changes[c.Kind+"\x00"+c.Name] = c
+		}
+		for _, c := range repo.Changes {
+			key := c.Kind + "\x00" + c.Name
+			planned, ok := changes[key]
+			delete(changes, key)
+			switch {
+			case !ok:
+				drift = append(drift, fmt.Sprintf("%s: %s \"%s\" would now %s, it is not in the plan", repo.Repo, c.Kind, c.Name, c.Action))
+			case planned.Action != c.Action:
+				drift = append(drift, fmt.Sprintf("%s: %s \"%s\" was planned to %s, would now %s", repo.Repo, c.Kind, c.Name, planned.Action, c.Action))
+			case strings.Join(planned.Diffs, "; ") != strings.Join(c.Diffs, "; "):
+				drift = append(drift, fmt.Sprintf("%s: %s \"%s\" now differs by %s", repo.Repo, c.Kind, c.Name, strings.Join(c.Diffs, "; ")))
+			}
+		}
+		for _, c := range old.Changes {
+			if _, left := changes[c.Kind+"\x00"+c.Name]; left {
+				drift = append(drift, fmt.Sprintf("%s: planned %s of %s \"%s\" is gone", repo.Repo, c.Action, c.Kind, c.Name))
+			}
+		}
+	}
+	for _, repo := range sortedKeys(savedRepos) {
+		drift = append(drift, fmt.Sprintf("%s: in the plan, but not a target of this run", repo))
+	}
+	return drift
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckApprovals(t *testing.T) {
+	plan := changePlan{
+		TemplateHash: "abc",
+		CreatedBy:    "alice",
+		Repos:        []repoChangePlan{{Repo: "acme/web", Changes: []plannedChange{{Kind: "label", Name: "bug", Action: "create"}}}},
+	}
+	digest := planDigest(plan)
+	at := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	signed := func(a planApproval, key string) planApproval {
+		a.Signature = a.sign(key)
+		return a
+	}
+	tests := []struct {
+		name         string
+		approval     planApproval
+		key          string
+		wantValid    int
+		wantProblems int
+	}{
+		{"by someone else", planApproval{By: "bob", At: at, Digest: digest}, "", 1, 0},
+		{"by the author", planApproval{By: "Alice", At: at, Digest: digest}, "", 0, 1},
+		{"for another plan", planApproval{By: "bob", At: at, Digest: "0000"}, "", 0, 1},
+		{"unsigned with a key", planApproval{By: "bob", At: at, Digest: digest}, "k3y", 0, 1},
+		{"signed with another key", signed(planApproval{By: "bob", At: at, Digest: digest}, "other"), "k3y", 0, 1},
+		{"signed", signed(planApproval{By: "bob", At: at, Digest: digest}, "k3y"), "k3y", 1, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			approved := plan
+			approved.Approvals = []planApproval{tc.approval}
+			valid, problems := checkApprovals(approved, tc.key)
+			if len(valid) != tc.wantValid || len(problems) != tc.wantProblems {
+				t.Errorf("checkApprovals() = %d valid, problems %q; want %d valid, %d problems", len(valid), problems, tc.wantValid, tc.wantProblems)
+			}
+		})
+	}
+}
+
+func TestPlanDigestIgnoresApprovals(t *testing.T) {
+	plan := changePlan{TemplateHash: "abc", CreatedBy: "alice"}
+	approved := plan
+	approved.Approvals = []planApproval{{By: "bob"}}
+	if planDigest(plan) != planDigest(approved) {
+		t.Error("planDigest() changed with an approval")
+	}
+	plan.TemplateHash = "abd"
+	if planDigest(plan) == planDigest(approved) {
+		t.Error("planDigest() did not change with the plan")
+	}
+}
+
+func TestPlanDrift(t *testing.T) {
+	create := func(kind, name string, diffs ...string) plannedChange {
+		return plannedChange{Kind: kind, Name: name, Action: "create", Diffs: diffs}
+	}
+	update := func(kind, name string, diffs ...string) plannedChange {
+		return plannedChange{Kind: kind, Name: name, Action: "update", Diffs: diffs}
+	}
+	saved := changePlan{Repos: []repoChangePlan{
+		{Repo: "acme/web", Changes: []plannedChange{create("label", "bug"), update("milestone", "v1", "due date")}},
+		{Repo: "acme/api", Changes: []plannedChange{create("issue", "Setup")}},
+	}}
+	tests := []struct {
+		name    string
+		current []repoChangePlan
+		want    []string
+	}{
+		{"unchanged", saved.Repos, nil},
+		{"new change", []repoChangePlan{
+			{Repo: "acme/web", Changes: []plannedChange{create("label", "bug"), update("milestone", "v1", "due date"), create("label", "ui")}},
+			saved.Repos[1],
+		}, []string{`acme/web: label "ui" would now create, it is not in the plan`}},
+		{"other action", []repoChangePlan{
+			{Repo: "acme/web", Changes: []plannedChange{update("label", "bug"), update("milestone", "v1", "due date")}},
+			saved.Repos[1],
+		}, []string{`acme/web: label "bug" was planned to create, would now update`}},
+		{"other differences", []repoChangePlan{
+			{Repo: "acme/web", Changes: []plannedChange{create("label", "bug"), update("milestone", "v1", "title")}},
+			saved.Repos[1],
+		}, []string{`acme/web: milestone "v1" now differs by title`}},
+		{"change gone", []repoChangePlan{
+			{Repo: "acme/web", Changes: []plannedChange{update("milestone", "v1", "due date")}},
+			saved.Repos[1],
+		}, []string{`acme/web: planned create of label "bug" is gone`}},
+		{"repository unreadable", []repoChangePlan{
+			{Repo: "acme/web", Error: "status 404"},
+			saved.Repos[1],
+		}, []string{"acme/web: status 404"}},
+		{"targets differ", []repoChangePlan{
+			saved.Repos[0],
+			{Repo: "acme/docs"},
+		}, []string{"acme/docs: not in the plan", "acme/api: in the plan, but not a target of this run"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := planDrift(saved, changePlan{Repos: tc.current})
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("planDrift() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
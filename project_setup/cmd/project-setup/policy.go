@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// entityPolicy controls what a run may do to one type of entity
+type entityPolicy string
+
+const (
+	policyAsk             entityPolicy = "ask"               // Create missing ones, resolve differences with --on-conflict (labels/milestones default)
+	policyUpdate          entityPolicy = "update"            // Create missing ones and overwrite differing ones
+	policyCreateIfMissing entityPolicy = "create-if-missing" // Create missing ones, never touch existing ones
+	policyCreate          entityPolicy = "create"            // Always create, even when one with the same title exists (issues default)
+	policySkip            entityPolicy = "skip"              // Neither create nor change anything of this type
+	policyMergeLabels     entityPolicy = "merge-labels"      // Create missing issues, add the defined labels to existing ones
+)
+
+// EntityPolicies sets the policy per entity type in config.json, e.g.
+// {"labels": "update", "milestones": "skip", "issues": "create-if-missing"}
+type EntityPolicies struct {
+	Labels     entityPolicy `json:"labels,omitempty"`
+	Milestones entityPolicy `json:"milestones,omitempty"`
+	Issues     entityPolicy `json:"issues,omitempty"`
+}
+
+// validPolicies lists the policies each entity type supports; the first one is the default
+var validPolicies = map[string][]entityPolicy{
+	"label":     {policyAsk, policyUpdate, policyCreateIfMissing, policySkip},
+	"milestone": {policyAsk, policyUpdate, policyCreateIfMissing, policySkip},
+	"issue":     {policyCreate, policyCreateIfMissing, policyMergeLabels, policySkip},
+}
+
+// validate fills in the defaults and rejects policies a type does not support
+func (p *EntityPolicies) validate() error {
+	for _, entry := range []struct {
+		kind   string
+		policy *entityPolicy
+	}{{"label", &p.Labels}, {"milestone", &p.Milestones}, {"issue", &p.Issues}} {
+		valid := validPolicies[entry.kind]
+		if *entry.policy == "" {
+			*entry.policy = valid[0]
+			continue
+		}
+		found := false
+		for _, v := range valid {
+			found = found || v == *entry.policy
+		}
+		if !found {
+			return fmt.Errorf("invalid %s policy %q, expected one of %v", entry.kind, *entry.policy, valid)
+		}
+	}
+	return nil
+}
+
+// forKind returns the policy for a kind name as used in log messages ("label", ...)
+func (p EntityPolicies) forKind(kind string) entityPolicy {
+	switch kind {
+	case "label":
+		return p.Labels
+	case "milestone":
+		return p.Milestones
+	case "issue":
+		return p.Issues
+	}
+	return ""
+}
+
+// getExistingIssues fetches all issues (open and closed, without pull requests) by title
+func getExistingIssues(ctx context.Context, t repoTarget) (map[string]github.Issue, error) {
+	issues, err := listRepoIssues(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	byTitle := make(map[string]github.Issue, len(issues))
+	for _, issue := range issues {
+		if issue.PullRequest == nil {
+			byTitle[issue.Title] = issue
+		}
+	}
+	return byTitle, nil
+}
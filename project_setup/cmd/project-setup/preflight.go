@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// preflightCheck verifies before anything is applied that every user and team the
+// definitions refer to exists, using a few batched GraphQL queries instead of one
+// REST call each. Labels used by issues but not defined are looked up per repository;
+// those are created on demand or else rejected per issue, so they only produce warnings.
+func preflightCheck(ctx context.Context, plans []repoPlan, defs *definitions, options applyOptions) error {
+	var checks []graphQLCheck
+	users := make(map[string]bool)
+	addUser := func(login string) {
+		if login == "" || users[strings.ToLower(login)] {
+			return
+		}
+		users[strings.ToLower(login)] = true
+		checks = append(checks, graphQLCheck{
+			Key:   "user " + login,
+			Field: `user(login: $login) { login }`,
+			Args:  map[string]string{"login": login},
+		})
+	}
+	teams := make(map[string]bool)
+	addTeam := func(org, slug string) {
+		key := org + "/" + slug
+		if teams[strings.ToLower(key)] {
+			return
+		}
+		teams[strings.ToLower(key)] = true
+		checks = append(checks, graphQLCheck{
+			Key:    "team " + key,
+			Field:  `organization(login: $org) { team(slug: $slug) { slug } }`,
+			Args:   map[string]string{"org": org, "slug": slug},
+			Nested: "team",
+		})
+	}
+	for _, issue := range defs.Issues {
+		for _, assignee := range issue.Assignees {
+			assignee = strings.TrimPrefix(assignee, fallbackAssigneePrefix)
+			if org, slug, isTeam := parseTeamAssignee(assignee); isTeam {
+				addTeam(org, slug)
+			} else {
+				addUser(assignee)
+			}
+		}
+	}
+
+	usesPullRequests := (len(defs.Files) > 0 || defs.Community != nil) && config.Commit.Branch != ""
+	if usesPullRequests {
+		for _, login := range config.Commit.PullRequest.Reviewers {
+			addUser(login)
+		}
+		owners := make(map[string]bool)
+		for _, plan := range plans {
+			if owners[plan.Target.Owner] {
+				continue
+			}
+			owners[plan.Target.Owner] = true
+			for _, slug := range config.Commit.PullRequest.TeamReviewers {
+				addTeam(plan.Target.Owner, slug)
+			}
+		}
+	}
+
+	for _, label := range undefinedIssueLabels(defs) {
+		for _, plan := range plans {
+			checks = append(checks, graphQLCheck{
+				Key:    "label " + plan.Target.String() + " " + label,
+				Field:  `repository(owner: $owner, name: $name) { label(name: $label) { name } }`,
+				Args:   map[string]string{"owner": plan.Target.Owner, "name": plan.Target.Repo, "label": label},
+				Nested: "label",
+			})
+		}
+	}
+
+	if len(checks) == 0 {
+		return nil
+	}
+	log.Printf("--- Preflight: checking %d references to users, teams and labels ---", len(checks))
+	exists, err := checkExistence(ctx, checks)
+	if err != nil {
+		return fmt.Errorf("error during preflight checks: %w", err)
+	}
+
+	// Keys are case-insensitive like logins; entries of fallback chains may be missing, as
+	// long as each chain has another one to stand in, unless they are also used on their own
+	found := make(map[string]bool, len(exists))
+	for key, ok := range exists {
+		found[strings.ToLower(key)] = ok
+	}
+	optional := make(map[string]bool)
+	for _, issue := range defs.Issues {
+		for _, chain := range assigneeChains(issue.Assignees) {
+			for _, entry := range chain {
+				key := assigneeCheckKey(entry)
+				previous, seen := optional[key]
+				optional[key] = (!seen || previous) && len(chain) > 1
+			}
+		}
+	}
+	if usesPullRequests {
+		for _, login := range config.Commit.PullRequest.Reviewers {
+			optional[assigneeCheckKey(login)] = false
+		}
+	}
+
+	var problems []error
+	for _, check := range checks {
+		if exists[check.Key] || optional[strings.ToLower(check.Key)] {
+			continue
+		}
+		kind, name, _ := strings.Cut(check.Key, " ")
+		switch kind {
+		case "label":
+			repo, label, _ := strings.Cut(name, " ")
+			if options.CreateMissingLabels {
+				log.Printf("Label '%s' is neither defined nor present in %s, it will be created with color %s.", label, repo, autoLabelColor)
+			} else {
+				log.Printf("Warning: Label '%s' is neither defined nor present in %s, issues using it may fail (use --create-missing-labels to create it).", label, repo)
+			}
+		default:
+			problems = append(problems, fmt.Errorf("%s '%s' does not exist", kind, name))
+		}
+	}
+	if err := resolveAssigneeChains(ctx, plans, defs.Issues, found); err != nil {
+		problems = append(problems, err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("preflight checks failed: %w", errors.Join(problems...))
+	}
+	return nil
+}
+
+// undefinedIssueLabels returns the labels issues refer to that labels.json does not define
+func undefinedIssueLabels(defs *definitions) []string {
+	defined := make(map[string]bool, len(defs.Labels))
+	for _, label := range defs.Labels {
+		defined[strings.ToLower(label.Name)] = true
+	}
+	seen := make(map[string]bool)
+	var undefined []string
+	for _, issue := range defs.Issues {
+		for _, name := range issue.Labels {
+			key := strings.ToLower(name)
+			if defined[key] || seen[key] {
+				continue
+			}
+			seen[key] = true
+			undefined = append(undefined, name)
+		}
+	}
+	sort.Strings(undefined)
+	return undefined
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// projectField is a field of the configured project that issues can set
+type projectField struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	DataType string `json:"dataType"` // NUMBER, DATE, TEXT, SINGLE_SELECT, ...
+	Options  []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"options"` // Only for SINGLE_SELECT
+}
+
+// projectItemFields is the configured project with its fields, resolved once per run
+type projectItemFields struct {
+	ProjectID string
+	Fields    map[string]projectField // By name
+}
+
+const projectItemFieldsQuery = `query($owner: String!, $number: Int!) {
+  organization(login: $owner) {
+    projectV2(number: $number) {
+      id
+      fields(first: 100) {
+        nodes {
+          ... on ProjectV2Field { id name dataType }
+          ... on ProjectV2SingleSelectField { id name dataType options { id name } }
+        }
+      }
+    }
+  }
+}`
+
+const addProjectItemMutation = `mutation($project: ID!, $content: ID!) {
+  addProjectV2ItemById(input: {projectId: $project, contentId: $content}) { item { id } }
+}`
+
+const updateProjectItemFieldMutation = `mutation($project: ID!, $item: ID!, $field: ID!, $value: ProjectV2FieldValue!) {
+  updateProjectV2ItemFieldValue(input: {projectId: $project, itemId: $item, fieldId: $field, value: $value}) {
+    projectV2Item { id }
+  }
+}`
+
+// resolveProjectFields looks up the project fields the issues set and checks every value
+// against its field, so mistakes are reported before anything is created.
+// It returns nil when no issue sets a project field.
+func resolveProjectFields(ctx context.Context, issues []IssueData) (*projectItemFields, error) {
+	used := false
+	for _, issue := range issues {
+		used = used || len(issue.ProjectFields) > 0
+	}
+	if !used {
+		return nil, nil
+	}
+	project := config.Project
+	data, err := sendGraphQLQuery(ctx, projectItemFieldsQuery, map[string]interface{}{"owner": project.Owner, "number": project.Number})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching the fields of project %s/%d: %w", project.Owner, project.Number, err)
+	}
+	var org struct {
+		ProjectV2 *struct {
+			ID     string `json:"id"`
+			Fields struct {
+				Nodes []projectField `json:"nodes"`
+			} `json:"fields"`
+		} `json:"projectV2"`
+	}
+	if raw := data["organization"]; len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &org); err != nil {
+			return nil, fmt.Errorf("error unmarshalling project %s/%d: %w", project.Owner, project.Number, err)
+		}
+	}
+	if org.ProjectV2 == nil {
+		return nil, fmt.Errorf("project %s/%d not found or not accessible with this token", project.Owner, project.Number)
+	}
+	resolved := &projectItemFields{ProjectID: org.ProjectV2.ID, Fields: make(map[string]projectField)}
+	for _, field := range org.ProjectV2.Fields.Nodes {
+		if field.ID != "" {
+			resolved.Fields[field.Name] = field
+		}
+	}
+
+	var problems []string
+	for _, issue := range issues {
+		for name, value := range issue.ProjectFields {
+			if _, _, err := resolved.fieldValue(name, value); err != nil {
+				problems = append(problems, fmt.Sprintf("issue '%s': %v", issue.Title, err))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("invalid project fields:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return resolved, nil
+}
+
+// fieldValue returns the field ID and the GraphQL ProjectV2FieldValue setting name to value
+func (p *projectItemFields) fieldValue(name string, value interface{}) (string, map[string]interface{}, error) {
+	field, ok := p.Fields[name]
+	if !ok {
+		return "", nil, fmt.Errorf("project has no field \"%s\"", name)
+	}
+	switch field.DataType {
+	case "NUMBER":
+		if number, ok := value.(float64); ok {
+			return field.ID, map[string]interface{}{"number": number}, nil
+		}
+		return "", nil, fmt.Errorf("field \"%s\" needs a number, got %v", name, value)
+	case "DATE":
+		if date, ok := value.(string); ok {
+			if _, err := time.Parse(calendarDateLayout, date); err == nil {
+				return field.ID, map[string]interface{}{"date": date}, nil
+			}
+		}
+		return "", nil, fmt.Errorf("field \"%s\" needs a date such as 2026-05-31, got %v", name, value)
+	case "TEXT":
+		if text, ok := value.(string); ok {
+			return field.ID, map[string]interface{}{"text": text}, nil
+		}
+		return "", nil, fmt.Errorf("field \"%s\" needs text, got %v", name, value)
+	case "SINGLE_SELECT":
+		option, _ := value.(string)
+		for _, o := range field.Options {
+			if o.Name == option {
+				return field.ID, map[string]interface{}{"singleSelectOptionId": o.ID}, nil
+			}
+		}
+		return "", nil, fmt.Errorf("field \"%s\" has no option %v", name, value)
+	default:
+		return "", nil, fmt.Errorf("field \"%s\" has type %s, which cannot be set from issues.json", name, field.DataType)
+	}
+}
+
+// addToProject adds a created issue to the project and sets its field values
+func (p *projectItemFields) addToProject(ctx context.Context, issue IssueData, created github.Issue) error {
+	data, err := sendGraphQLQuery(ctx, addProjectItemMutation, map[string]interface{}{"project": p.ProjectID, "content": created.NodeID})
+	if err != nil {
+		return fmt.Errorf("error adding issue #%d to the project: %w", created.Number, err)
+	}
+	var added struct {
+		Item struct {
+			ID string `json:"id"`
+		} `json:"item"`
+	}
+	if err := json.Unmarshal(data["addProjectV2ItemById"], &added); err != nil {
+		return fmt.Errorf("error unmarshalling project item of issue #%d: %w", created.Number, err)
+	}
+
+	// Sorted, so the fields are set in the same order on every run
+	names := make([]string, 0, len(issue.ProjectFields))
+	for name := range issue.ProjectFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fieldID, value, err := p.fieldValue(name, issue.ProjectFields[name])
+		if err != nil {
+			return err // Checked by resolveProjectFields
+		}
+		variables := map[string]interface{}{"project": p.ProjectID, "item": added.Item.ID, "field": fieldID, "value": value}
+		if _, err := sendGraphQLQuery(ctx, updateProjectItemFieldMutation, variables); err != nil {
+			return fmt.Errorf("error setting project field \"%s\" of issue #%d: %w", name, created.Number, err)
+		}
+	}
+	log.Printf("Added issue #%d to project %s/%d with %d field values.", created.Number, config.Project.Owner, config.Project.Number, len(names))
+	return nil
+}
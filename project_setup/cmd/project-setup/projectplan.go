@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/alcorg/project_setup/project_setup/engine"
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// Markers around the generated section, so it can live inside a hand-written README
+const (
+	projectPlanStart = "<!-- project-setup:plan:start -->"
+	projectPlanEnd   = "<!-- project-setup:plan:end -->"
+)
+
+const projectPlanCommitMessage = "Project setup: update project plan"
+
+// ProjectPlanConfig enables the generated overview of the seeded plan
+type ProjectPlanConfig struct {
+	// File holding the overview, e.g. "PROJECT_PLAN.md" or "README.md"; empty to disable
+	Path string `json:"path,omitempty"`
+}
+
+// webBaseURL derives the web address of github.com or a GHES host from the API base
+func webBaseURL() string {
+	if githubAPIBaseURL == "https://api.github.com" {
+		return "https://github.com"
+	}
+	return strings.TrimSuffix(githubAPIBaseURL, "/api/v3")
+}
+
+// markdownLink links text to url, or returns the text alone without a url
+func markdownLink(text, url string) string {
+	text = strings.NewReplacer("[", "\\[", "]", "\\]", "|", "\\|").Replace(text)
+	if url == "" {
+		return text
+	}
+	return fmt.Sprintf("[%s](%s)", text, url)
+}
+
+// renderProjectPlan writes the overview of the defined milestones, the project board and
+// the tracking issues and epics, between the section markers
+func renderProjectPlan(milestones []MilestoneData, existing map[string]github.Milestone, keyIssues []github.Issue) string {
+	var b strings.Builder
+	b.WriteString(projectPlanStart + "\n")
+	b.WriteString("## Project plan\n\n")
+	b.WriteString("_Generated by the project setup, edits inside this section will be overwritten._\n\n")
+	if config.Project.enabled() {
+		url := fmt.Sprintf("%s/orgs/%s/projects/%d", webBaseURL(), config.Project.Owner, config.Project.Number)
+		fmt.Fprintf(&b, "Project board: %s\n\n", markdownLink(fmt.Sprintf("%s project #%d", config.Project.Owner, config.Project.Number), url))
+	}
+
+	b.WriteString("### Milestones\n\n")
+	b.WriteString("| Milestone | Due | Open issues | Closed issues |\n|---|---|---|---|\n")
+	for _, milestone := range milestones {
+		remote, ok := existing[milestoneKey(milestone.Title)]
+		if !ok {
+			continue
+		}
+		due := engine.DueDate(remote.DueOn)
+		if due == "" {
+			due = "-"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %d | %d |\n", markdownLink(remote.Title, remote.HTMLURL), due, remote.OpenIssues, remote.ClosedIssues)
+	}
+
+	if len(keyIssues) > 0 {
+		b.WriteString("\n### Tracking issues\n\n")
+		for _, issue := range keyIssues {
+			fmt.Fprintf(&b, "- %s (#%d)\n", markdownLink(issue.Title, issue.HTMLURL), issue.Number)
+		}
+	}
+	b.WriteString(projectPlanEnd + "\n")
+	return b.String()
+}
+
+// mergeProjectPlan puts the section into the current file content: it replaces an earlier
+// section, or is appended when the file has none
+func mergeProjectPlan(current, section string) string {
+	start := strings.Index(current, projectPlanStart)
+	end := strings.Index(current, projectPlanEnd)
+	if start >= 0 && end > start {
+		rest := strings.TrimPrefix(current[end+len(projectPlanEnd):], "\n")
+		return current[:start] + section + rest
+	}
+	if current == "" {
+		return section
+	}
+	return strings.TrimRight(current, "\n") + "\n\n" + section
+}
+
+// keyIssues returns the tracking issues and the epics (issues with children) of a repository
+func keyIssues(existing map[string]github.Issue, issues []IssueData) []github.Issue {
+	var key []github.Issue
+	for _, issue := range existing {
+		if strings.HasPrefix(issue.Body, trackingIssueMarker) {
+			key = append(key, issue)
+		}
+	}
+	for parent := range epicChildren(issues) {
+		if issue, ok := existing[issues[parent].Title]; ok {
+			key = append(key, issue)
+		}
+	}
+	sort.Slice(key, func(i, j int) bool { return key[i].Number < key[j].Number })
+	return key
+}
+
+// syncProjectPlan commits the overview of the seeded plan to the configured file
+func syncProjectPlan(ctx context.Context, t repoTarget, defs *definitions) (entityCounts, error) {
+	var counts entityCounts
+	path := strings.TrimPrefix(config.ProjectPlan.Path, "/")
+	log.Printf("--- Updating Project Plan %s ---", path)
+
+	existingMilestones, err := getExistingMilestones(ctx, t)
+	if err != nil {
+		return counts, err
+	}
+	existingIssues, err := getExistingIssues(ctx, t)
+	if err != nil {
+		return counts, err
+	}
+	current, _, err := getFileContent(ctx, t, path, config.Commit.Branch)
+	if err != nil {
+		return counts, err
+	}
+	section := renderProjectPlan(defs.Milestones, existingMilestones, keyIssues(existingIssues, defs.Issues))
+
+	commit := config.Commit
+	commit.Message = projectPlanCommitMessage
+	committed, err := commitFiles(ctx, t, map[string]string{path: mergeProjectPlan(string(current), section)}, commit)
+	if err != nil {
+		counts.Failed++
+		return counts, err
+	}
+	if committed {
+		counts.Created++
+	}
+	return counts, nil
+}
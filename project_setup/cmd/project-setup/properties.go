@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// GitHubPropertyValue is one custom property value of a repository
+type GitHubPropertyValue struct {
+	PropertyName string      `json:"property_name"`
+	Value        interface{} `json:"value"` // string, list of strings for multi_select, or null to unset
+}
+
+// propertyList is a flag.Value collecting repeatable name=value custom properties
+type propertyList map[string]interface{}
+
+func (p propertyList) String() string {
+	pairs := make([]string, 0, len(p))
+	for name, value := range p {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", name, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (p propertyList) Set(value string) error {
+	name, v, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected name=value, got %q", value)
+	}
+	p[strings.TrimSpace(name)] = v
+	return nil
+}
+
+// validateProperties checks that every value is a string or a list of strings
+func validateProperties(properties map[string]interface{}) error {
+	for name, value := range properties {
+		switch v := value.(type) {
+		case string, nil:
+		case []interface{}:
+			for _, item := range v {
+				if _, ok := item.(string); !ok {
+					return fmt.Errorf("custom property '%s' must be a string or a list of strings", name)
+				}
+			}
+		default:
+			return fmt.Errorf("custom property '%s' must be a string or a list of strings", name)
+		}
+	}
+	return nil
+}
+
+// getPropertyValues fetches the custom property values of a repository, keyed by name
+func getPropertyValues(ctx context.Context, t repoTarget) (map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/properties/values", githubAPIBaseURL, t.Owner, t.Repo)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching custom properties: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching custom properties: %w", github.NewAPIError(resp, bodyBytes))
+	}
+	var values []GitHubPropertyValue
+	if err := json.Unmarshal(bodyBytes, &values); err != nil {
+		return nil, fmt.Errorf("error unmarshalling custom properties: %w", err)
+	}
+	current := make(map[string]interface{}, len(values))
+	for _, v := range values {
+		current[v.PropertyName] = v.Value
+	}
+	return current, nil
+}
+
+// setPropertyValues creates or updates custom property values of a repository in one request
+func setPropertyValues(ctx context.Context, t repoTarget, values []GitHubPropertyValue) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/properties/values", githubAPIBaseURL, t.Owner, t.Repo)
+	payload := map[string][]GitHubPropertyValue{"properties": values}
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "PATCH", url, payload)
+	if err != nil {
+		return fmt.Errorf("error sending custom properties request: %w", err)
+	}
+	// The properties must be defined by the organization first, otherwise GitHub answers 422
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error setting custom properties: %w", github.NewAPIError(resp, bodyBytes))
+	}
+	return nil
+}
+
+// processProperties sets the organization custom properties that differ from the definitions
+func processProperties(ctx context.Context, t repoTarget, properties map[string]interface{}) (entityCounts, error) {
+	var counts entityCounts
+	log.Printf("--- Processing Custom Properties ---")
+
+	current, err := getPropertyValues(ctx, t)
+	if err != nil {
+		return counts, err
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var changed []GitHubPropertyValue
+	for _, name := range names {
+		if reflect.DeepEqual(current[name], properties[name]) {
+			log.Printf("Custom property \"%s\" is already %v.", name, properties[name])
+			continue
+		}
+		log.Printf("Setting custom property \"%s\" to %v (was %v).", name, properties[name], current[name])
+		changed = append(changed, GitHubPropertyValue{PropertyName: name, Value: properties[name]})
+	}
+	if len(changed) == 0 {
+		return counts, nil
+	}
+
+	if err := setPropertyValues(ctx, t, changed); err != nil {
+		counts.Failed = len(changed)
+		return counts, err
+	}
+	counts.Updated = len(changed)
+	log.Printf("Finished processing custom properties. Updated %d.", counts.Updated)
+	return counts, nil
+}
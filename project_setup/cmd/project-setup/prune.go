@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// prunableLabels returns the existing labels --prune deletes: those neither defined nor used
+// by a defined issue, except the labels mirroring milestones with --mirror-milestone-labels
+func prunableLabels(defs *definitions, existing map[string]github.Label, options applyOptions) []github.Label {
+	keep := make(map[string]bool, len(defs.Labels))
+	for _, label := range defs.Labels {
+		keep[strings.ToLower(label.Name)] = true
+	}
+	for _, issue := range defs.Issues {
+		for _, name := range issue.Labels {
+			keep[strings.ToLower(name)] = true
+		}
+	}
+	var prunable []github.Label
+	for _, label := range existing {
+		if keep[strings.ToLower(label.Name)] {
+			continue
+		}
+		if _, mirror := mirroredMilestone(label); mirror && options.MirrorMilestoneLabels {
+			continue
+		}
+		prunable = append(prunable, label)
+	}
+	sort.Slice(prunable, func(i, j int) bool { return prunable[i].Name < prunable[j].Name })
+	return prunable
+}
+
+// prunableMilestones returns the existing milestones --prune deletes: those neither defined
+// nor used by a defined issue
+func prunableMilestones(defs *definitions, existing map[string]github.Milestone) []github.Milestone {
+	keep := make(map[string]bool, len(defs.Milestones))
+	for _, milestone := range defs.Milestones {
+		keep[milestoneKey(milestone.Title)] = true
+	}
+	for _, issue := range defs.Issues {
+		if issue.MilestoneTitle != nil {
+			keep[milestoneKey(*issue.MilestoneTitle)] = true
+		}
+	}
+	var prunable []github.Milestone
+	for key, milestone := range existing {
+		if !keep[key] {
+			prunable = append(prunable, milestone)
+		}
+	}
+	sort.Slice(prunable, func(i, j int) bool { return prunable[i].ID < prunable[j].ID })
+	return prunable
+}
+
+// pruneNote describes what deleting a milestone does to its issues
+func pruneNote(milestone github.Milestone) string {
+	if issues := milestone.OpenIssues + milestone.ClosedIssues; issues > 0 {
+		return fmt.Sprintf("not defined, %d issues lose their milestone", issues)
+	}
+	return "not defined"
+}
+
+// pruneLabels deletes the labels that are no longer defined, with --prune
+func pruneLabels(ctx context.Context, run *repoRun, defs *definitions) (entityCounts, error) {
+	var counts entityCounts
+	t := run.target
+	log.Printf("--- Pruning Labels ---")
+	existing, err := getExistingLabels(ctx, t)
+	if err != nil {
+		return counts, fmt.Errorf("error getting existing labels: %w", err)
+	}
+	for _, label := range prunableLabels(defs, existing, run.options) {
+		url := fmt.Sprintf("%s/repos/%s/%s/labels/%s", githubAPIBaseURL, t.Owner, t.Repo, neturl.PathEscape(label.Name))
+		if err := deleteEntity(ctx, fmt.Sprintf("label '%s'", label.Name), url); err != nil {
+			run.failed("Failed to prune label '%s': %v", label.Name, err)
+			counts.Failed++
+			continue
+		}
+		log.Printf("Deleted label \"%s\", it is not defined.", label.Name)
+		counts.Deleted++
+		time.Sleep(requestDelay)
+	}
+	log.Printf("Finished pruning labels. Deleted %d.", counts.Deleted)
+	return counts, nil
+}
+
+// pruneMilestones deletes the milestones that are no longer defined, with --prune. Their
+// issues are kept, without a milestone.
+func pruneMilestones(ctx context.Context, run *repoRun, defs *definitions) (entityCounts, error) {
+	var counts entityCounts
+	t := run.target
+	log.Printf("--- Pruning Milestones ---")
+	existing, err := getExistingMilestones(ctx, t)
+	if err != nil {
+		return counts, fmt.Errorf("error getting existing milestones: %w", err)
+	}
+	for _, milestone := range prunableMilestones(defs, existing) {
+		url := fmt.Sprintf("%s/repos/%s/%s/milestones/%d", githubAPIBaseURL, t.Owner, t.Repo, milestone.ID)
+		if err := deleteEntity(ctx, fmt.Sprintf("milestone '%s'", milestone.Title), url); err != nil {
+			run.failed("Failed to prune milestone '%s': %v", milestone.Title, err)
+			counts.Failed++
+			continue
+		}
+		log.Printf("Deleted milestone \"%s\" (#%d), %s.", milestone.Title, milestone.ID, pruneNote(milestone))
+		counts.Deleted++
+		time.Sleep(requestDelay)
+	}
+	log.Printf("Finished pruning milestones. Deleted %d.", counts.Deleted)
+	return counts, nil
+}
+
+// planPrune lists the labels and milestones --prune would delete
+func planPrune(defs *definitions, labels map[string]github.Label, milestones map[string]github.Milestone, options applyOptions) []plannedChange {
+	var changes []plannedChange
+	if config.Policies.Labels != policySkip {
+		for _, label := range prunableLabels(defs, labels, options) {
+			changes = append(changes, plannedChange{Kind: "label", Name: label.Name, Action: actionDelete, Note: "not defined"})
+		}
+	}
+	if config.Policies.Milestones != policySkip {
+		for _, milestone := range prunableMilestones(defs, milestones) {
+			changes = append(changes, plannedChange{Kind: "milestone", Name: milestone.Title, Action: actionDelete, Note: pruneNote(milestone)})
+		}
+	}
+	return changes
+}
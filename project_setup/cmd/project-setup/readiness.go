@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// readinessHint explains what keeps a repository from taking the run and what to do about
+// it; "" when nothing does
+func readinessHint(t repoTarget, repository github.Repository, needsIssues bool) string {
+	switch {
+	case repository.Archived:
+		return fmt.Sprintf("%s is archived and read-only; unarchive it (Settings > Danger Zone) or remove it from the targets", t)
+	case needsIssues && !repository.HasIssues && repository.Fork:
+		return fmt.Sprintf("%s is a fork of %s and forks start with issues turned off; turn on Issues (Settings > General > Features) or run apply with --enable-issues", t, repository.Parent.FullName)
+	case needsIssues && !repository.HasIssues:
+		return fmt.Sprintf("issues are turned off in %s; turn on Issues (Settings > General > Features) or run apply with --enable-issues", t)
+	}
+	return ""
+}
+
+// warnMovedRepository warns when a target was transferred or renamed: the API follows the
+// redirect, but the old name breaks once it is reused
+func warnMovedRepository(t repoTarget, repository github.Repository) {
+	if repository.FullName != "" && !strings.EqualFold(repository.FullName, t.String()) {
+		log.Printf("Warning: %s has moved to %s; update the targets to the new name.", t, repository.FullName)
+	}
+}
+
+// enableIssues turns on the Issues feature of a repository
+func enableIssues(ctx context.Context, t repoTarget) error {
+	url := fmt.Sprintf("%s/repos/%s/%s", githubAPIBaseURL, t.Owner, t.Repo)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "PATCH", url, map[string]bool{"has_issues": true})
+	if err != nil {
+		return fmt.Errorf("error sending request to enable issues in %s: %w", t, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error enabling issues in %s: %w", t, github.NewAPIError(resp, bodyBytes))
+	}
+	log.Printf("Turned on issues in %s.", t)
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// errReadOnly is returned for every request --read-only refuses to send
+var errReadOnly = errors.New("blocked by --read-only")
+
+// readOnlyTransport refuses every request that could change something, below all of the
+// planning logic, so a run with a powerful token cannot mutate anything even through a bug
+type readOnlyTransport struct {
+	next http.RoundTripper
+}
+
+func (t readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := checkReadOnly(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+// checkReadOnly allows GET and HEAD requests, and GraphQL POSTs that only query
+func checkReadOnly(req *http.Request) error {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return nil
+	case http.MethodPost:
+		if strings.HasSuffix(req.URL.Path, "/graphql") && isGraphQLQuery(req) {
+			return nil
+		}
+	}
+	// The client already names the method and URL in its error
+	return errReadOnly
+}
+
+// isGraphQLQuery reports whether the body of a GraphQL request is a query rather than a mutation
+func isGraphQLQuery(req *http.Request) bool {
+	if req.GetBody == nil {
+		return false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return false
+	}
+	var payload github.GraphQLRequest
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return false
+	}
+	// Skip leading comments; an anonymous "{ ... }" operation is a query too. A document
+	// that mentions a mutation anywhere is refused, erring on the safe side.
+	query := strings.TrimSpace(payload.Query)
+	for strings.HasPrefix(query, "#") {
+		_, rest, _ := strings.Cut(query, "\n")
+		query = strings.TrimSpace(rest)
+	}
+	return (strings.HasPrefix(query, "{") || strings.HasPrefix(query, "query")) && !strings.Contains(query, "mutation")
+}
+
+// enableReadOnly puts the read-only guard in front of the API client
+func enableReadOnly() {
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	httpClient.Transport = readOnlyTransport{next: next}
+	log.Printf("Read-only mode: every request that could change something is blocked.")
+}
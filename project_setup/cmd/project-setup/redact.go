@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	neturl "net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// redactedPlaceholder replaces every secret in output
+const redactedPlaceholder = "[REDACTED]"
+
+// minSecretLength keeps short values from redacting unrelated text
+const minSecretLength = 8
+
+// tokenPatterns catch GitHub credentials that were never registered, e.g. a token
+// echoed back by a proxy error page
+var tokenPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{20,}`),
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{20,}`),
+}
+
+// authorizationPattern matches an echoed Authorization header; the scheme is kept
+var authorizationPattern = regexp.MustCompile(`(?i)(authorization["']?\s*[:=]\s*["']?(?:bearer|token|basic)\s+)[^\s"'<>,;]+`)
+
+// redactor removes registered secrets, and anything that looks like a GitHub
+// token, from text before it is logged, reported or written to a file
+type redactor struct {
+	mu      sync.RWMutex
+	secrets []string // Longest first, so a secret containing another is replaced whole
+}
+
+// secrets is the redactor of the run; main registers the token with it
+var secrets = &redactor{}
+
+// add registers a secret value together with the encodings it may show up in
+func (r *redactor) add(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, variant := range []string{
+		secret,
+		neturl.QueryEscape(secret),
+		base64.StdEncoding.EncodeToString([]byte(secret)),
+		base64.StdEncoding.EncodeToString([]byte("x-access-token:" + secret)), // Basic auth as used by git
+	} {
+		if !containsString(r.secrets, variant) {
+			r.secrets = append(r.secrets, variant)
+		}
+	}
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+// redact returns s with every secret replaced by redactedPlaceholder
+func (r *redactor) redact(s string) string {
+	r.mu.RLock()
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redactedPlaceholder)
+	}
+	r.mu.RUnlock()
+	for _, pattern := range tokenPatterns {
+		s = pattern.ReplaceAllString(s, redactedPlaceholder)
+	}
+	return authorizationPattern.ReplaceAllString(s, "${1}"+redactedPlaceholder)
+}
+
+// redactingWriter redacts everything written through it. Each Write is redacted on its own,
+// which is safe for the log package and the encoders used here as they write whole lines.
+type redactingWriter struct {
+	w io.Writer
+	r *redactor
+}
+
+func (rw redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, rw.r.redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// redacted wraps w so nothing written to it can leak a secret
+func redacted(w io.Writer) io.Writer {
+	return redactingWriter{w: w, r: secrets}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
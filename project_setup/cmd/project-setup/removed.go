@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// removedIssueComment is posted on every issue closed because its definition was removed
+const removedIssueComment = "This issue was closed as not planned: it was removed from the project setup definitions."
+
+// closeRemovedIssues closes the issues earlier runs created (see run state) whose
+// definitions are gone. They are closed as not planned with an explanatory comment, so
+// reporting can tell them apart from completed work.
+func closeRemovedIssues(ctx context.Context, run *repoRun, issues []IssueData) (entityCounts, error) {
+	var counts entityCounts
+	t := run.target
+	removed := removedIssueTitles(run.state, issues)
+	if len(removed) == 0 {
+		return counts, nil
+	}
+	log.Printf("--- Closing Removed Issues ---")
+
+	existing, err := getExistingIssues(ctx, t)
+	if err != nil {
+		return counts, fmt.Errorf("error getting existing issues: %w", err)
+	}
+	byNumber := make(map[int]github.Issue, len(existing))
+	for _, issue := range existing {
+		byNumber[issue.Number] = issue
+	}
+	for _, title := range removed {
+		recorded := run.state.Issues[title]
+		issue, ok := byNumber[recorded.Number]
+		if !ok || issue.State == "closed" {
+			// Deleted, transferred or closed by hand: nothing left to do, so stop tracking it
+			delete(run.state.Issues, title)
+			continue
+		}
+		if err := addIssueComment(ctx, t, issue.Number, removedIssueComment); err != nil {
+			run.failed("Failed to comment on removed issue '%s' (#%d): %v", title, issue.Number, err)
+			counts.Failed++
+			continue
+		}
+		time.Sleep(requestDelay)
+		if err := closeIssue(ctx, t, issue.Number); err != nil {
+			run.failed("Failed to close removed issue '%s' (#%d): %v", title, issue.Number, err)
+			counts.Failed++
+			continue
+		}
+		log.Printf("Closed issue \"%s\" (#%d) as not planned, its definition was removed.", title, issue.Number)
+		delete(run.state.Issues, title)
+		counts.Updated++
+		time.Sleep(requestDelay)
+	}
+	return counts, nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// milestoneRename is the report of one repository
+type milestoneRename struct {
+	Repo     string
+	Renamed  bool     // The live milestone now has the new title
+	Updated  []string // Tracking issue and mirror label changes
+	Problems []string
+}
+
+// definitionFiles lists the files behind a definitions path, which may be a pattern
+func definitionFiles(path string) ([]string, error) {
+	if path == stdinPath {
+		return nil, fmt.Errorf("definitions read from stdin cannot be rewritten")
+	}
+	if !isGlobPattern(path) {
+		return []string{path}, nil
+	}
+	return filepath.Glob(path)
+}
+
+// renameMilestoneDefinitions rewrites the milestone and every issue referring to it in the
+// definition files; a file that does not mention the milestone is left untouched
+func renameMilestoneDefinitions(paths definitionPaths, from, to string) error {
+	milestoneFiles, err := definitionFiles(paths.Milestones)
+	if err != nil {
+		return err
+	}
+	for _, path := range milestoneFiles {
+		milestones, err := loadMilestonesFile(path)
+		if err != nil {
+			return err
+		}
+		changed := false
+		for i := range milestones {
+			if milestoneKey(milestones[i].Title) == milestoneKey(from) {
+				milestones[i].Title, changed = to, true
+			}
+		}
+		if changed {
+			if err := writeJSONFile(path, milestones); err != nil {
+				return fmt.Errorf("error rewriting %s: %w", path, err)
+			}
+			log.Printf("Renamed milestone \"%s\" in %s.", from, path)
+		}
+	}
+
+	issueFiles, err := definitionFiles(paths.Issues)
+	if err != nil {
+		return err
+	}
+	for _, path := range issueFiles {
+		issues, err := loadIssuesFile(path)
+		if err != nil {
+			return err
+		}
+		references := 0
+		for i := range issues {
+			if issues[i].MilestoneTitle != nil && milestoneKey(*issues[i].MilestoneTitle) == milestoneKey(from) {
+				title := to
+				issues[i].MilestoneTitle = &title
+				references++
+			}
+		}
+		if references > 0 {
+			if err := writeJSONFile(path, issues); err != nil {
+				return fmt.Errorf("error rewriting %s: %w", path, err)
+			}
+			log.Printf("Updated %d issues referring to milestone \"%s\" in %s.", references, from, path)
+		}
+	}
+	return nil
+}
+
+// findMilestoneToRename returns the live milestone to rename in t; nil when it already has
+// the new title, so an interrupted rename can be run again
+func findMilestoneToRename(ctx context.Context, t repoTarget, from, to string) (*github.Milestone, error) {
+	existing, err := getExistingMilestones(ctx, t)
+	if err != nil {
+		return nil, fmt.Errorf("error listing milestones of %s: %w", t, err)
+	}
+	old, hasOld := existing[milestoneKey(from)]
+	current, hasNew := existing[milestoneKey(to)]
+	sameKey := milestoneKey(from) == milestoneKey(to) // e.g. fixing the case or a trailing space
+	switch {
+	case sameKey && hasOld && old.Title != to:
+		return &old, nil
+	case hasOld && hasNew && !sameKey:
+		return nil, fmt.Errorf("%s has both milestone \"%s\" and \"%s\"", t, old.Title, current.Title)
+	case hasNew:
+		log.Printf("Milestone \"%s\" in %s is already renamed.", to, t)
+		return nil, nil
+	case !hasOld:
+		return nil, fmt.Errorf("%s has no milestone \"%s\"", t, from)
+	}
+	return &old, nil
+}
+
+// renameTrackingIssue retitles the tracking issue of a renamed milestone and regenerates its list
+func renameTrackingIssue(ctx context.Context, t repoTarget, number int, to string) (string, error) {
+	issues, err := listMilestoneIssues(ctx, t, number)
+	if err != nil {
+		return "", err
+	}
+	tracking, tracked := splitTrackingIssue(issues)
+	if tracking == nil {
+		return "", nil
+	}
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d", githubAPIBaseURL, t.Owner, t.Repo, tracking.Number)
+	payload := map[string]string{"title": fmt.Sprintf(trackingIssueTitle, to), "body": trackingIssueBody(to, tracked)}
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "PATCH", url, payload)
+	if err != nil {
+		return "", fmt.Errorf("error sending update request for tracking issue #%d: %w", tracking.Number, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error updating tracking issue #%d: %w", tracking.Number, github.NewAPIError(resp, bodyBytes))
+	}
+	return fmt.Sprintf("tracking issue #%d", tracking.Number), nil
+}
+
+// renameMirrorLabel renames the label mirroring a renamed milestone, if there is one
+func renameMirrorLabel(ctx context.Context, t repoTarget, from, to string) (string, error) {
+	labels, err := getExistingLabels(ctx, t)
+	if err != nil {
+		return "", err
+	}
+	label, ok := labels[milestoneLabelName(from)]
+	if !ok {
+		return "", nil
+	}
+	renamed := LabelData{Name: milestoneLabelName(to), Color: label.Color, Description: label.Description}
+	if err := renameLabel(ctx, t, label.Name, renamed); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("label \"%s\"", renamed.Name), nil
+}
+
+// renameLiveMilestones renames the milestone in every target, or in none: when one rename
+// fails, the ones already done are renamed back
+func renameLiveMilestones(ctx context.Context, targets []repoTarget, from, to string) ([]milestoneRename, map[repoTarget]int, error) {
+	found := make(map[repoTarget]*github.Milestone, len(targets))
+	for _, t := range targets {
+		m, err := findMilestoneToRename(ctx, t, from, to)
+		if err != nil {
+			return nil, nil, err
+		}
+		found[t] = m
+	}
+
+	results := make([]milestoneRename, 0, len(targets))
+	numbers := make(map[repoTarget]int, len(targets))
+	var done []repoTarget
+	for _, t := range targets {
+		m := found[t]
+		if m == nil {
+			results = append(results, milestoneRename{Repo: t.String()})
+			continue
+		}
+		err := updateMilestone(ctx, t, m.ID, MilestoneData{Title: to, Description: m.Description, DueOn: m.DueOn})
+		if err != nil {
+			for _, d := range done {
+				m := found[d]
+				if err := updateMilestone(context.WithoutCancel(ctx), d, m.ID, MilestoneData{Title: m.Title, Description: m.Description, DueOn: m.DueOn}); err != nil {
+					log.Printf("Error: could not rename milestone \"%s\" in %s back to \"%s\": %v", to, d, from, err)
+				}
+			}
+			return nil, nil, fmt.Errorf("error renaming the milestone in %s, nothing was renamed: %w", t, err)
+		}
+		done = append(done, t)
+		numbers[t] = m.ID
+		results = append(results, milestoneRename{Repo: t.String(), Renamed: true})
+		time.Sleep(requestDelay)
+	}
+	return results, numbers, nil
+}
+
+// writeRenameReport prints the final report of a rename-milestone run
+func writeRenameReport(w io.Writer, results []milestoneRename, from, to string) {
+	fmt.Fprintf(w, "=== Milestone rename \"%s\" -> \"%s\" (%d repositories) ===\n", from, to, len(results))
+	for _, r := range results {
+		state := "already renamed"
+		if r.Renamed {
+			state = "renamed"
+		}
+		fmt.Fprintf(w, "\n%s: %s\n", r.Repo, state)
+		for _, updated := range r.Updated {
+			fmt.Fprintf(w, "  updated %s\n", updated)
+		}
+		for _, problem := range r.Problems {
+			fmt.Fprintf(w, "  failed: %s\n", problem)
+		}
+	}
+}
+
+// runRenameMilestone renames a milestone in the target repositories, in the definition
+// files and in the tracking issue and mirror label that carry its title
+func runRenameMilestone(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("rename-milestone", flag.ExitOnError)
+	var shared targetFlags
+	shared.register(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if len(positional) != 2 || positional[0] == "" || positional[1] == "" || positional[0] == positional[1] {
+		log.Fatal("Error: usage: rename-milestone [flags] <old title> <new title>")
+	}
+	from, to := positional[0], positional[1]
+	if err := shared.limits.check(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	ctx, cancel := shared.limits.start(ctx)
+	defer cancel()
+
+	defs, targets, err := shared.load(ctx)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	for _, m := range defs.Milestones {
+		if milestoneKey(m.Title) == milestoneKey(to) && milestoneKey(to) != milestoneKey(from) {
+			log.Fatalf("Error: milestone \"%s\" is already defined", to)
+		}
+	}
+	// Checked up front, so the files can be rewritten once the live milestones are renamed
+	for _, path := range []string{shared.paths.Milestones, shared.paths.Issues} {
+		files, err := definitionFiles(path)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		for _, file := range files {
+			if err := checkRewritable(file, "rename-milestone"); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+		}
+	}
+
+	results, numbers, err := renameLiveMilestones(ctx, targets, from, to)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	failures := 0
+	for i, t := range targets {
+		number, ok := numbers[t]
+		if !ok {
+			continue
+		}
+		for _, update := range []func() (string, error){
+			func() (string, error) { return renameTrackingIssue(ctx, t, number, to) },
+			func() (string, error) { return renameMirrorLabel(ctx, t, from, to) },
+		} {
+			updated, err := update()
+			switch {
+			case err != nil:
+				log.Printf("Error in %s: %v", t, err)
+				results[i].Problems = append(results[i].Problems, err.Error())
+				failures++
+			case updated != "":
+				results[i].Updated = append(results[i].Updated, updated)
+			}
+		}
+	}
+	if err := renameMilestoneDefinitions(shared.paths, from, to); err != nil {
+		log.Printf("Error: %v", err)
+		failures++
+	}
+	writeRenameReport(redacted(os.Stdout), results, from, to)
+	exitOnDeadline(ctx)
+	if failures > 0 {
+		log.Fatalf("Error: rename-milestone finished with %d failures", failures)
+	}
+}
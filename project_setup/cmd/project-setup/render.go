@@ -0,0 +1,53 @@
+package main
+
+import "strings"
+
+// acceptanceCriteriaHeading starts the acceptance criteria section. Reporting
+// scripts rely on this exact heading, keep it stable.
+const acceptanceCriteriaHeading = "## Acceptance Criteria"
+
+// renderIssueBody builds the final issue body from its definition,
+// wrapping the description with the header/footer fragments from config.json
+func renderIssueBody(issue IssueData) string {
+	header, description, footer := config.IssueBody.Header, issue.Description, config.IssueBody.Footer
+	if issue.templateData != nil {
+		// Checked for every target by checkBodyTemplates, so they render
+		for _, text := range []*string{&header, &description, &footer} {
+			if rendered, err := renderBodyTemplate(*text, *issue.templateData); err == nil {
+				*text = rendered
+			}
+		}
+	}
+	// Only the fragments are trimmed, the description keeps its leading
+	// indentation (e.g. an indented code block) and trailing line breaks
+	var sections []string
+	for _, section := range []string{
+		strings.TrimSpace(header),
+		description,
+		renderAcceptanceCriteria(issue.AcceptanceCriteria),
+		strings.TrimSpace(footer),
+		strings.TrimSpace(config.IssueBody.attribution),
+	} {
+		if strings.TrimSpace(section) != "" {
+			sections = append(sections, section)
+		}
+	}
+	body := strings.Join(sections, "\n\n")
+	if issue.muteMentions {
+		body = muteMentions(body)
+	}
+	return body
+}
+
+// renderAcceptanceCriteria renders the criteria as an unchecked task list
+func renderAcceptanceCriteria(criteria []string) string {
+	if len(criteria) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(acceptanceCriteriaHeading + "\n")
+	for _, criterion := range criteria {
+		b.WriteString("\n- [ ] " + strings.TrimSpace(criterion))
+	}
+	return b.String()
+}
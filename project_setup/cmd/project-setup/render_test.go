@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestRenderIssueBody(t *testing.T) {
+	tests := []struct {
+		name           string
+		header, footer string
+		issue          IssueData
+		want           string
+	}{
+		{
+			name:   "fragments are trimmed",
+			header: "\n**Team:** platform\n\n",
+			footer: "  ---\nHow to work this ticket\n",
+			issue:  IssueData{Description: "Set up CI."},
+			want:   "**Team:** platform\n\nSet up CI.\n\n---\nHow to work this ticket",
+		},
+		{
+			name:  "indented code block is kept",
+			issue: IssueData{Description: "    go run ./cmd/project-setup\n"},
+			want:  "    go run ./cmd/project-setup\n",
+		},
+		{
+			name:   "blank description is left out",
+			header: "Header",
+			issue:  IssueData{Description: " \n", AcceptanceCriteria: []string{" Builds "}},
+			want:   "Header\n\n## Acceptance Criteria\n\n- [ ] Builds",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			saved := config
+			t.Cleanup(func() { config = saved })
+			config.IssueBody = BodyTemplate{Header: tc.header, Footer: tc.footer}
+			if got := renderIssueBody(tc.issue); got != tc.want {
+				t.Errorf("renderIssueBody() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+)
+
+// planReportKinds are the sections of the HTML report, in order
+var planReportKinds = []struct{ Kind, Title string }{
+	{"label", "Labels"},
+	{"milestone", "Milestones"},
+	{"issue", "Issues"},
+	{"file", "Files"},
+	{"property", "Custom Properties"},
+}
+
+// planReportTemplate renders a self-contained page (no external assets) that can be
+// attached to a change-management ticket
+var planReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"kinds": func() interface{} { return planReportKinds },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Project setup plan</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #1f2328; }
+h1 { font-size: 1.6em; } h2 { border-bottom: 1px solid #d0d7de; padding-bottom: .3em; margin-top: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1em; }
+th, td { border: 1px solid #d0d7de; padding: .4em .6em; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+.create { color: #1a7f37; font-weight: 600; } .update { color: #9a6700; font-weight: 600; }
+.commit { color: #0969da; font-weight: 600; } .unchanged, .skip { color: #656d76; }
+.delete { color: #cf222e; font-weight: 600; }
+.warning { background: #fff8c5; padding: .5em; border-left: 4px solid #d4a72c; }
+.error { background: #ffebe9; padding: .5em; border-left: 4px solid #cf222e; }
+.meta { color: #656d76; }
+.swatch { display: inline-block; width: 1em; height: 1em; border: 1px solid #d0d7de; border-radius: 3px; vertical-align: middle; }
+</style>
+</head>
+<body>
+<h1>Project setup plan</h1>
+<p class="meta">Generated {{.GeneratedAt.Format "2006-01-02 15:04 UTC"}} &middot; template hash <code>{{.TemplateHash}}</code> &middot; {{len .Repos}} repositories</p>
+<p>Nothing has been changed yet. <span class="create">create</span> and <span class="commit">commit</span> add content,
+<span class="update">update</span> marks existing entities that differ from the definitions (handled according to the conflict setting when applied),
+<span class="delete">delete</span> those removed with --prune.</p>
+{{range .Repos}}
+<h2>{{.Repo}}</h2>
+{{if .Error}}<p class="error">Could not be read: {{.Error}}</p>{{else}}
+<p>{{.Count "create"}} to create, {{.Count "update"}} to update, {{with .Count "delete"}}{{.}} to delete, {{end}}{{.Count "commit"}} files to commit, {{.Count "unchanged"}} unchanged, {{.Count "skip"}} skipped.</p>
+{{range .Warnings}}<p class="warning">{{.}}</p>{{end}}
+{{$repo := .}}
+{{range kinds}}{{$title := .Title}}{{with $repo.ByKind .Kind}}
+<h3>{{$title}}</h3>
+<table>
+<tr><th>Name</th><th>Action</th><th>Details</th></tr>
+{{range .}}<tr><td>{{.Name}}</td><td class="{{.Action}}">{{.Action}}</td><td>{{with .Color}}{{if .From}}<span class="swatch" style="background: #{{.From}}"></span> &rarr; {{end}}<span class="swatch" style="background: #{{.To}}"></span><br>{{end}}{{range .Diffs}}{{.}}<br>{{end}}{{.Note}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// writePlanHTMLFile writes the plan as a standalone HTML report.
+// The page is rendered in full first, so redaction sees every secret in one piece.
+func writePlanHTMLFile(path string, plan changePlan) error {
+	var page bytes.Buffer
+	if err := planReportTemplate.Execute(&page, plan); err != nil {
+		return fmt.Errorf("error rendering HTML report: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(secrets.redact(page.String())), 0o644); err != nil {
+		return fmt.Errorf("error writing HTML report %s: %w", path, err)
+	}
+	return nil
+}
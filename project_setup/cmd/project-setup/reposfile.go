@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// repoVariableOverrides are template variables of single repositories, from --repos-file;
+// they override community.variables of config.json
+type repoVariableOverrides map[repoTarget]map[string]string
+
+// loadReposFile reads the target repositories of a batch run, one per line, each
+// optionally followed by variable overrides:
+//
+//	# comment
+//	acme/web
+//	acme/api security_contact=api-team@acme.example support_url=https://acme.example/api
+func loadReposFile(path string) ([]string, repoVariableOverrides, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading repos file %s: %w", path, err)
+	}
+	defer f.Close()
+	return parseReposFile(f, path)
+}
+
+func parseReposFile(r io.Reader, path string) ([]string, repoVariableOverrides, error) {
+	var repos []string
+	overrides := make(repoVariableOverrides)
+	seen := make(map[repoTarget]int)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		t, _, err := parseRepoReference(fields[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if first, ok := seen[t]; ok {
+			return nil, nil, fmt.Errorf("%s:%d: %s is already listed on line %d", path, line, t, first)
+		}
+		seen[t] = line
+		repos = append(repos, fields[0])
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "#") {
+				break // Trailing comment
+			}
+			name, value, ok := strings.Cut(field, "=")
+			if !ok || name == "" {
+				return nil, nil, fmt.Errorf("%s:%d: invalid variable %q, expected name=value", path, line, field)
+			}
+			if overrides[t] == nil {
+				overrides[t] = make(map[string]string)
+			}
+			overrides[t][name] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error reading repos file %s: %w", path, err)
+	}
+	if len(repos) == 0 {
+		return nil, nil, fmt.Errorf("repos file %s lists no repositories", path)
+	}
+	return repos, overrides, nil
+}
+
+// writeBatchReport prints whether every repository of a batch run succeeded; it returns
+// the number of repositories with failures
+func writeBatchReport(w io.Writer, repos []repoApplyResult) int {
+	failed := 0
+	fmt.Fprintf(w, "=== Batch result (%d repositories) ===\n", len(repos))
+	for _, r := range repos {
+		if n := r.Summary.Failures(); n > 0 {
+			failed++
+			fmt.Fprintf(w, "FAILED  %s (%d failures)\n", r.Repo, n)
+		} else {
+			fmt.Fprintf(w, "ok      %s\n", r.Repo)
+		}
+	}
+	fmt.Fprintf(w, "%d succeeded, %d failed\n", len(repos)-failed, failed)
+	return failed
+}
@@ -0,0 +1,57 @@
+package main
+
+import "sync"
+
+// resultCollector gathers the summary of every repository of a run. Workers
+// record their results concurrently; reports are built once all are done.
+type resultCollector struct {
+	mu      sync.Mutex
+	order   []repoTarget // Report order, as the targets were given
+	results map[repoTarget]runSummary
+}
+
+// newResultCollector prepares a collector for the given plans
+func newResultCollector(plans []repoPlan) *resultCollector {
+	c := &resultCollector{results: make(map[repoTarget]runSummary, len(plans))}
+	for _, plan := range plans {
+		c.order = append(c.order, plan.Target)
+	}
+	return c
+}
+
+// record stores the summary of one repository; safe for concurrent use
+func (c *resultCollector) record(t repoTarget, summary runSummary) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results[t] = summary
+}
+
+// get returns the summary recorded for t
+func (c *resultCollector) get(t repoTarget) runSummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.results[t]
+}
+
+// each calls fn for every repository in report order
+func (c *resultCollector) each(fn func(t repoTarget, summary runSummary)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range c.order {
+		fn(t, c.results[t])
+	}
+}
+
+// total adds up the counts of all repositories. The details (skipped conflicts,
+// updates, failures, created issues) are kept only when there is a single repository.
+func (c *resultCollector) total() runSummary {
+	var total runSummary
+	c.each(func(_ repoTarget, summary runSummary) {
+		total.add(summary)
+	})
+	if len(c.order) == 1 {
+		single := c.get(c.order[0])
+		total.Skipped, total.Updated, total.Problems, total.CreatedIssues = single.Skipped, single.Updated, single.Problems, single.CreatedIssues
+	}
+	return total
+}
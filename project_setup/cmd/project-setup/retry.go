@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// retryFilePath names the retry file of one repository: path itself for a single target,
+// otherwise with the repository before the extension, e.g. failed.acme__web.json
+func retryFilePath(path string, repo string, single bool) string {
+	if single {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(path, ext), strings.Replace(repo, "/", "__", 1), ext)
+}
+
+// writeRetryFiles writes the definitions of the issues that failed in each repository to a
+// file in the format of issues.json, so 'apply --issues <file>' retries exactly those. The
+// issues are taken from the issues file as written, before forms and bundles are expanded.
+// A retry file left by an earlier run is removed once nothing fails.
+func writeRetryFiles(path string, defs *definitions, repos []repoApplyResult) error {
+	issues, err := loadIssues(defs.Paths.Issues)
+	if err != nil {
+		return err
+	}
+	if err := checkNotDecrypted(defs.Paths.Issues, "--failed-out"); err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		file := retryFilePath(path, repo.Repo, len(repos) == 1)
+		var failed []IssueData
+		generated := 0
+		for _, index := range repo.Summary.FailedIssues {
+			source := defs.sourceIndex(index)
+			if source < 0 || source >= len(issues) {
+				generated++
+				continue
+			}
+			failed = append(failed, issues[source])
+		}
+		if generated > 0 {
+			log.Printf("Warning: %d failed issues of %s were generated (ceremonies) and are not in %s; the next run generates them again.", generated, repo.Repo, file)
+		}
+		if len(failed) == 0 {
+			if err := os.Remove(file); err == nil {
+				log.Printf("No issues failed in %s, removed the retry file %s.", repo.Repo, file)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("error removing retry file %s: %w", file, err)
+			}
+			continue
+		}
+		if err := writeJSONFile(file, failed); err != nil {
+			return fmt.Errorf("error writing retry file %s: %w", file, err)
+		}
+		log.Printf("Wrote the %d failed issues of %s to %s, retry them with: apply --repo %s --issues %s", len(failed), repo.Repo, file, repo.Repo, file)
+	}
+	return nil
+}
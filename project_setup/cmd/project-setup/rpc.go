@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/engine"
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// JSON-RPC 2.0 error codes; the -320xx ones are specific to this tool
+const (
+	rpcParseError      = -32700
+	rpcInvalidRequest  = -32600
+	rpcMethodNotFound  = -32601
+	rpcInvalidParams   = -32602
+	rpcRunFailed       = -32000 // The command failed before changing anything
+	rpcDeadlineReached = -32001 // --run-deadline was reached, the partial result is in data
+)
+
+// maxRPCMessageSize bounds a single request line
+const maxRPCMessageSize = 16 << 20
+
+// rpcRequest is one JSON-RPC request (or notification, without ID) read from stdin
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcParams are the parameters of every method: the command line arguments of the command,
+// and the tenant it runs for when the server has tenants
+type rpcParams struct {
+	Args   []string `json:"args"`
+	Tenant string   `json:"tenant,omitempty"`
+}
+
+// rpcError is the error member of a response
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// rpcMessage is a response or a notification written to stdout
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcServer answers requests one at a time; logs and per-repository results of the running
+// request are streamed as notifications carrying its ID
+type rpcServer struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	current json.RawMessage // ID of the request being handled
+	tenants tenantSet       // nil when the server runs with the token of the process
+	tenant  *Tenant         // Tenant of the request being handled
+}
+
+// send writes one message per line; safe for concurrent use
+func (s *rpcServer) send(msg rpcMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg.JSONRPC = "2.0"
+	if err := s.enc.Encode(msg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing JSON-RPC message: %v\n", err)
+	}
+}
+
+// notify streams a notification about the current request
+func (s *rpcServer) notify(method string, params map[string]interface{}) {
+	s.mu.Lock()
+	params["id"] = s.current
+	if s.tenant != nil {
+		params["tenant"] = s.tenant.Name
+	}
+	s.mu.Unlock()
+	s.send(rpcMessage{Method: method, Params: params})
+}
+
+// Write turns every log line into a "log" notification
+func (s *rpcServer) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		s.notify("log", map[string]interface{}{"time": time.Now().UTC().Format(time.RFC3339), "message": string(line)})
+	}
+	return len(p), nil
+}
+
+// runJSONRPC serves JSON-RPC 2.0 requests, one per line on stdin, until stdin is closed.
+// Methods are "plan", "apply" and "export" with {"args": [...]}, the same arguments as on the
+// command line.
+func runJSONRPC(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("--json-rpc", flag.ExitOnError)
+	tenantsFile := fs.String("tenants", "", "Serve the tenants of this file, each with its own token, directory and owners; requests name their tenant")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		log.Fatalf("Error: --json-rpc takes no arguments besides --tenants, pass them with each request")
+	}
+	server := &rpcServer{enc: json.NewEncoder(redacted(os.Stdout))}
+	server.enc.SetEscapeHTML(false)
+	if *tenantsFile != "" {
+		tenants, err := loadTenants(*tenantsFile)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		server.tenants = tenants
+	}
+	log.SetFlags(0)
+	log.SetOutput(server)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRPCMessageSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		server.handle(ctx, line)
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading JSON-RPC requests: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// handle runs one request and writes its response
+func (s *rpcServer) handle(ctx context.Context, line []byte) {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		s.send(rpcMessage{ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}})
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		s.send(rpcMessage{ID: idOrNull(req.ID), Error: &rpcError{Code: rpcInvalidRequest, Message: "expected a JSON-RPC 2.0 request with a method"}})
+		return
+	}
+	var params rpcParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			s.reply(req, nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()})
+			return
+		}
+	}
+
+	tenant, rpcErr := s.requestTenant(params.Tenant)
+	if rpcErr != nil {
+		s.reply(req, nil, rpcErr)
+		return
+	}
+
+	s.mu.Lock()
+	s.current, s.tenant = idOrNull(req.ID), tenant
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.tenant = nil
+		s.mu.Unlock()
+	}()
+	if tenant != nil {
+		leave, err := tenant.enter(s)
+		if err != nil {
+			s.reply(req, nil, &rpcError{Code: rpcRunFailed, Message: err.Error()})
+			return
+		}
+		defer leave()
+	}
+	// Flags such as --read-only change the shared client, so every request starts from the same one
+	transport := httpClient.Transport
+	defer func() { httpClient.Transport = transport }()
+
+	var result interface{}
+	switch req.Method {
+	case "plan":
+		result, rpcErr = s.plan(ctx, params.Args)
+	case "apply":
+		result, rpcErr = s.apply(ctx, params.Args)
+	case "export":
+		result, rpcErr = s.export(ctx, params.Args)
+	default:
+		rpcErr = &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method %q, expected plan, apply or export", req.Method)}
+	}
+	s.reply(req, result, rpcErr)
+}
+
+// requestTenant looks up the tenant a request names; with tenants every request needs one
+func (s *rpcServer) requestTenant(name string) (*Tenant, *rpcError) {
+	switch {
+	case s.tenants == nil && name != "":
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "this server has no tenants, leave out tenant"}
+	case s.tenants == nil:
+		return nil, nil
+	case name == "":
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "tenant is required"}
+	case s.tenants[name] == nil:
+		return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("unknown tenant %q", name)}
+	}
+	return s.tenants[name], nil
+}
+
+// reply answers a request; notifications (requests without ID) get no answer
+func (s *rpcServer) reply(req rpcRequest, result interface{}, rpcErr *rpcError) {
+	if len(req.ID) == 0 {
+		return
+	}
+	s.send(rpcMessage{ID: req.ID, Result: result, Error: rpcErr})
+}
+
+func idOrNull(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+// rpcOutcome maps the result of a command to a response
+func rpcOutcome(ctx context.Context, result interface{}, err error) (interface{}, *rpcError) {
+	switch {
+	case err != nil:
+		rpcErr := &rpcError{Code: rpcRunFailed, Message: err.Error()}
+		if kind := github.ErrorKind(err); kind != "" {
+			rpcErr.Data = map[string]string{"kind": kind}
+		}
+		return nil, rpcErr
+	case deadlineReached(ctx):
+		return nil, &rpcError{Code: rpcDeadlineReached, Message: "the run deadline was reached before all work was done", Data: result}
+	}
+	return result, nil
+}
+
+func (s *rpcServer) plan(ctx context.Context, args []string) (interface{}, *rpcError) {
+	c, err := parsePlanArgs(args, flag.ContinueOnError)
+	if err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	c.shared.tenant = s.currentTenant()
+	if err := c.shared.tenant.checkPaths(map[string]string{"--report-html": c.reportHTML, "--label-preview": c.preview, "--out": c.out}); err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	c.onRepoDone = func(repo repoChangePlan) {
+		s.notify("plan.repo", map[string]interface{}{"repo": repo})
+	}
+	ctx, cancel := c.shared.limits.start(ctx)
+	defer cancel()
+	plan, err := c.execute(ctx)
+	return rpcOutcome(ctx, plan, err)
+}
+
+func (s *rpcServer) apply(ctx context.Context, args []string) (interface{}, *rpcError) {
+	c, err := parseApplyArgs(args, flag.ContinueOnError)
+	if err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	c.shared.tenant = s.currentTenant()
+	if err := c.shared.tenant.checkPaths(map[string]string{"--failed-out": c.failedOut, "--plan": c.planFile}); err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	c.onRepoDone = func(t repoTarget, summary runSummary) {
+		s.notify("apply.repo", map[string]interface{}{"repo": t.String(), "summary": summary})
+	}
+	ctx = engine.WithProgressFunc(ctx, func(event engine.Event) {
+		s.notify("apply.progress", map[string]interface{}{"event": event})
+	})
+	ctx, cancel := c.shared.limits.start(ctx)
+	defer cancel()
+	if c.dryRun {
+		plan, err := c.dryRunPlan(ctx)
+		return rpcOutcome(ctx, plan, err)
+	}
+	// Stdin carries the requests, so there is no terminal to prompt on
+	result, err := c.execute(ctx, nil)
+	return rpcOutcome(ctx, result, err)
+}
+
+func (s *rpcServer) export(ctx context.Context, args []string) (interface{}, *rpcError) {
+	c, err := parseExportArgs(args, flag.ContinueOnError)
+	if err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	c.tenant = s.currentTenant()
+	if err := c.tenant.checkPaths(map[string]string{"--out": c.out}); err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	result, err := c.execute(ctx)
+	return rpcOutcome(ctx, result, err)
+}
+
+// currentTenant returns the tenant of the request being handled, nil without tenants
+func (s *rpcServer) currentTenant() *Tenant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tenant
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRPCHandleErrors(t *testing.T) {
+	t.Setenv("GITHUB_REPOSITORY", "")
+	saved := httpClient
+	httpClient = &http.Client{}
+	t.Cleanup(func() { httpClient = saved })
+	out := t.TempDir()
+	if err := os.WriteFile(filepath.Join(out, "labels.json"), []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		request     string
+		wantCode    int
+		wantMessage string
+	}{
+		{"unknown method", `{"jsonrpc":"2.0","id":1,"method":"import"}`, rpcMethodNotFound, `unknown method "import", expected plan, apply or export`},
+		{"export without a repository", `{"jsonrpc":"2.0","id":2,"method":"export","params":{"args":[]}}`, rpcInvalidParams, "usage: export --repo"},
+		{"export with an unknown flag", `{"jsonrpc":"2.0","id":3,"method":"export","params":{"args":["--repo","acme/web","--bogus"]}}`, rpcInvalidParams, "bogus"},
+		{"export over existing files", `{"jsonrpc":"2.0","id":4,"method":"export","params":{"args":["--repo","acme/web","--out",` + quoteJSON(t, out) + `]}}`, rpcRunFailed, "already exists"},
+		{"tenant without tenants", `{"jsonrpc":"2.0","id":5,"method":"export","params":{"tenant":"acme","args":["--repo","acme/web"]}}`, rpcInvalidParams, "no tenants"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			server := &rpcServer{enc: json.NewEncoder(&buf)}
+			server.handle(context.Background(), []byte(tc.request))
+			var response rpcMessage
+			if err := json.Unmarshal(buf.Bytes(), &response); err != nil {
+				t.Fatalf("response %q: %v", buf.String(), err)
+			}
+			if response.Error == nil || response.Error.Code != tc.wantCode || !strings.Contains(response.Error.Message, tc.wantMessage) {
+				t.Errorf("response = %s, want error %d containing %q", buf.String(), tc.wantCode, tc.wantMessage)
+			}
+		})
+	}
+}
+
+func quoteJSON(t *testing.T, s string) string {
+	t.Helper()
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3StateBackend keeps the state as s3://<bucket>/<prefix>/<owner>/<repo>.json.
+// Credentials and region come from the usual AWS_* environment variables;
+// AWS_ENDPOINT_URL points it at an S3 compatible store instead of AWS.
+type s3StateBackend struct {
+	bucket, prefix                   string
+	endpoint, region                 string
+	accessKey, secretKey, sessionKey string
+}
+
+func newS3StateBackend(location string) (*s3StateBackend, error) {
+	bucket, prefix, _ := strings.Cut(location, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid --state s3://%s, expected s3://<bucket>/<prefix>", location)
+	}
+	b := &s3StateBackend{
+		bucket:     bucket,
+		prefix:     strings.Trim(prefix, "/"),
+		region:     firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		endpoint:   strings.TrimSuffix(firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"), "/"),
+		accessKey:  os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:  os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionKey: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if b.accessKey == "" || b.secretKey == "" {
+		return nil, fmt.Errorf("--state s3:// needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if b.region == "" {
+		b.region = "us-east-1"
+	}
+	if b.endpoint == "" {
+		b.endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", b.region)
+	}
+	secrets.add(b.secretKey)
+	secrets.add(b.sessionKey)
+	return b, nil
+}
+
+// firstEnv returns the first of the environment variables that is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func (b *s3StateBackend) key(t repoTarget) string {
+	return strings.TrimPrefix(b.prefix+"/"+t.Owner+"/"+t.Repo+".json", "/")
+}
+
+func (b *s3StateBackend) load(ctx context.Context, t repoTarget) ([]byte, bool, error) {
+	resp, body, err := b.send(ctx, "GET", b.key(t), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("error fetching state from %s: status %d, body: %s", b, resp.StatusCode, string(body))
+	}
+	return body, true, nil
+}
+
+func (b *s3StateBackend) save(ctx context.Context, t repoTarget, data []byte) error {
+	resp, body, err := b.send(ctx, "PUT", b.key(t), data)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error storing state in %s: status %d, body: %s", b, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (b *s3StateBackend) String() string {
+	return strings.TrimSuffix("s3://"+b.bucket+"/"+b.prefix, "/")
+}
+
+// send makes a path-style request signed with AWS Signature Version 4
+func (b *s3StateBackend) send(ctx context.Context, method, key string, payload []byte) (*http.Response, []byte, error) {
+	path := "/" + awsURIEncode(b.bucket) + "/" + awsURIEncode(key)
+	req, err := http.NewRequestWithContext(ctx, method, b.endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating S3 request for %s: %w", key, err)
+	}
+	b.sign(req, payload, time.Now().UTC())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error sending S3 request for %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	body, err := readResponseBody(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading S3 response for %s: %w", key, err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		body = []byte(secrets.redact(string(body)))
+	}
+	return resp, body, nil
+}
+
+// sign adds the SigV4 headers for the s3 service
+func (b *s3StateBackend) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if b.sessionKey != "" {
+		req.Header.Set("X-Amz-Security-Token", b.sessionKey)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), req.URL.RawQuery,
+		canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	scope := day + "/" + b.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+b.secretKey), day)
+	for _, part := range []string{b.region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.accessKey, scope, signedHeaders, signature))
+}
+
+// awsURIEncode escapes everything but unreserved characters, keeping the slashes of a key
+func awsURIEncode(s string) string {
+	segments := strings.Split(s, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(neturl.QueryEscape(segment), "+", "%20")
+	}
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// scopedLabelSeparator separates the scope of a GitLab-style scoped label from its value,
+// e.g. "priority::high"; an issue has at most one label of every scope
+const scopedLabelSeparator = "::"
+
+// labelScope returns the scope of a scoped label, case-insensitive. As on GitLab, the scope
+// ends at the last separator, so "team::api::owner" has the scope "team::api".
+func labelScope(name string) (string, bool) {
+	i := strings.LastIndex(name, scopedLabelSeparator)
+	if i <= 0 || i+len(scopedLabelSeparator) == len(name) {
+		return "", false
+	}
+	return strings.ToLower(name[:i]), true
+}
+
+// validateLabelScopes rejects issues with more than one label of the same scope
+func validateLabelScopes(issues []IssueData) []error {
+	var problems []error
+	for _, issue := range issues {
+		first := make(map[string]string)
+		for _, name := range issue.Labels {
+			scope, ok := labelScope(name)
+			if !ok {
+				continue
+			}
+			if other, seen := first[scope]; seen && !strings.EqualFold(other, name) {
+				problems = append(problems, fmt.Errorf("issue '%s' has the labels '%s' and '%s', but only one label of scope '%s' can apply", issue.Title, other, name, scope))
+				continue
+			}
+			first[scope] = name
+		}
+	}
+	return problems
+}
+
+// scopeSiblings returns the labels of an issue that share a scope with label, which have
+// to go when label is added
+func scopeSiblings(issue github.Issue, label string) []string {
+	scope, ok := labelScope(label)
+	if !ok {
+		return nil
+	}
+	var siblings []string
+	for _, existing := range issue.Labels {
+		if other, ok := labelScope(existing.Name); ok && other == scope && !strings.EqualFold(existing.Name, label) {
+			siblings = append(siblings, existing.Name)
+		}
+	}
+	return siblings
+}
+
+// removeIssueLabel removes a label from an existing issue
+func removeIssueLabel(ctx context.Context, t repoTarget, issueNumber int, label string) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/labels/%s", githubAPIBaseURL, t.Owner, t.Repo, issueNumber, neturl.PathEscape(label))
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("error sending remove label request for issue #%d: %w", issueNumber, err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("error removing label '%s' from issue #%d: %w", label, issueNumber, github.NewAPIError(resp, bodyBytes))
+	}
+	log.Printf("Removed label \"%s\" from issue #%d, it has the same scope as a label being added.", label, issueNumber)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Severity ladder of labels declaring a "severity" instead of a color
+const (
+	minSeverity              = 1
+	maxSeverity              = 5
+	defaultLowSeverityColor  = "fef2c0" // Pale yellow
+	defaultHighSeverityColor = "b60205" // GitHub's dark red
+)
+
+// SeverityColorConfig sets the endpoints of the color gradient computed for labels with a
+// severity; severity 1 gets Low, severity 5 gets High
+type SeverityColorConfig struct {
+	Low  string `json:"low,omitempty"`  // Hex code without '#'
+	High string `json:"high,omitempty"` // Hex code without '#'
+}
+
+// validate checks the endpoints and fills in the defaults
+func (c *SeverityColorConfig) validate() error {
+	if c.Low == "" {
+		c.Low = defaultLowSeverityColor
+	}
+	if c.High == "" {
+		c.High = defaultHighSeverityColor
+	}
+	for _, color := range []string{c.Low, c.High} {
+		if !labelColorPattern.MatchString(color) {
+			return fmt.Errorf("invalid severity_colors color %q, expected exactly 6 hex digits such as d73a4a", color)
+		}
+	}
+	return nil
+}
+
+// color interpolates the gradient for severity, which must be within the ladder
+func (c SeverityColorConfig) color(severity int) string {
+	low, _ := strconv.ParseUint(c.Low, 16, 32)
+	high, _ := strconv.ParseUint(c.High, 16, 32)
+	position := float64(severity-minSeverity) / float64(maxSeverity-minSeverity)
+	var rgb uint64
+	for shift := 16; shift >= 0; shift -= 8 {
+		from := float64((low >> shift) & 0xff)
+		to := float64((high >> shift) & 0xff)
+		rgb |= uint64(math.Round(from+(to-from)*position)) << shift
+	}
+	return fmt.Sprintf("%06x", rgb)
+}
+
+// applySeverityColors gives every label with a severity but no color its place on the gradient.
+// An explicit color wins; severities outside the ladder are left to validate.
+func applySeverityColors(labels []LabelData, colors SeverityColorConfig) {
+	colors.validate() // Fills in the defaults without a config.json; parseConfig reported bad colors
+	for i := range labels {
+		label := &labels[i]
+		if label.Severity < minSeverity || label.Severity > maxSeverity || label.Color != "" {
+			continue
+		}
+		label.Color = colors.color(label.Severity)
+	}
+}
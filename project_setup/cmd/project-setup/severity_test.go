@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestSeverityColor(t *testing.T) {
+	defaults := SeverityColorConfig{}
+	if err := defaults.validate(); err != nil {
+		t.Fatalf("validate() of the defaults failed: %v", err)
+	}
+	grey := SeverityColorConfig{Low: "000000", High: "ffffff"}
+	tests := []struct {
+		name     string
+		colors   SeverityColorConfig
+		severity int
+		want     string
+	}{
+		{"lowest is low", defaults, 1, "fef2c0"},
+		{"highest is high", defaults, 5, "b60205"},
+		{"middle", defaults, 3, "da7a63"},
+		{"quarter rounds", grey, 2, "404040"},
+		{"half rounds up", grey, 3, "808080"},
+		{"upper case endpoints", SeverityColorConfig{Low: "FFFFFF", High: "000000"}, 5, "000000"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.colors.color(tc.severity); got != tc.want {
+				t.Errorf("color(%d) = %q, want %q", tc.severity, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSeverityColorValidate(t *testing.T) {
+	tests := []struct {
+		colors  SeverityColorConfig
+		wantErr bool
+	}{
+		{SeverityColorConfig{}, false},
+		{SeverityColorConfig{Low: "c2e0c6", High: "5319e7"}, false},
+		{SeverityColorConfig{Low: "#c2e0c6"}, true},
+		{SeverityColorConfig{High: "fff"}, true},
+	}
+	for _, tc := range tests {
+		if err := tc.colors.validate(); (err != nil) != tc.wantErr {
+			t.Errorf("validate() of %+v = %v, want error %v", tc.colors, err, tc.wantErr)
+		}
+	}
+}
+
+func TestApplySeverityColors(t *testing.T) {
+	labels := []LabelData{
+		{Name: "sev1", Severity: 1},
+		{Name: "sev5", Severity: 5, Color: "123456"},
+		{Name: "sev9", Severity: 9},
+		{Name: "plain"},
+	}
+	applySeverityColors(labels, SeverityColorConfig{})
+	for i, want := range []string{"fef2c0", "123456", "", ""} {
+		if labels[i].Color != want {
+			t.Errorf("label %q got color %q, want %q", labels[i].Name, labels[i].Color, want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/engine"
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// shiftPattern matches a due date shift such as "2w", "-3d" or "+1w2d"
+var shiftPattern = regexp.MustCompile(`^([+-]?)(?:(\d+)w)?(?:(\d+)d)?$`)
+
+// parseShift returns the number of days a shift like "2w" or "-3d" moves due dates by
+func parseShift(value string) (int, error) {
+	m := shiftPattern.FindStringSubmatch(value)
+	if m == nil || (m[2] == "" && m[3] == "") {
+		return 0, fmt.Errorf("invalid shift %q, expected weeks and/or days such as 2w, -3d or 1w2d", value)
+	}
+	weeks, _ := strconv.Atoi(m[2])
+	days, _ := strconv.Atoi(m[3])
+	days += 7 * weeks
+	if m[1] == "-" {
+		days = -days
+	}
+	return days, nil
+}
+
+// milestoneShift is one due date moved by shift-milestones
+type milestoneShift struct {
+	Title string
+	From  string
+	To    string
+}
+
+// shiftResult is the report of one repository
+type shiftResult struct {
+	Repo     string
+	Shifted  []milestoneShift
+	Problems []string
+}
+
+// milestonesToShift selects the open milestones with a due date: all of them without from,
+// otherwise the one called from, plus every one due after it when cascade is set
+func milestonesToShift(milestones []github.Milestone, from string, cascade bool) ([]github.Milestone, error) {
+	var open []github.Milestone
+	for _, m := range milestones {
+		if m.State == "open" && m.DueOn != nil {
+			open = append(open, m)
+		}
+	}
+	sort.SliceStable(open, func(i, j int) bool { return *open[i].DueOn < *open[j].DueOn })
+	if from == "" {
+		return open, nil
+	}
+	for i, m := range open {
+		if milestoneKey(m.Title) != milestoneKey(from) {
+			continue
+		}
+		if !cascade {
+			return open[i : i+1], nil
+		}
+		// Milestones due on the same day as from count as later ones too
+		start := i
+		for start > 0 && engine.DueDate(open[start-1].DueOn) == engine.DueDate(m.DueOn) {
+			start--
+		}
+		return open[start:], nil
+	}
+	return nil, fmt.Errorf("no open milestone \"%s\" with a due date", from)
+}
+
+// shiftRepoMilestones moves the due dates of the selected milestones of one repository by days
+func shiftRepoMilestones(ctx context.Context, t repoTarget, days int, from string, cascade, dryRun bool) shiftResult {
+	result := shiftResult{Repo: t.String()}
+	failed := func(format string, args ...interface{}) {
+		message := fmt.Sprintf(format, args...)
+		log.Printf("%s", message)
+		result.Problems = append(result.Problems, message)
+	}
+	log.Printf("--- Shifting Milestones of %s ---", t)
+
+	milestones, err := repoProvider(t).ListMilestones(ctx)
+	if err != nil {
+		failed("Error listing milestones of %s: %v", t, err)
+		return result
+	}
+	selected, err := milestonesToShift(milestones, from, cascade)
+	if err != nil {
+		failed("Error in %s: %v", t, err)
+		return result
+	}
+	for _, m := range selected {
+		due, err := time.Parse(time.RFC3339, *m.DueOn)
+		if err != nil {
+			failed("Milestone \"%s\" has an unreadable due date %q: %v", m.Title, *m.DueOn, err)
+			continue
+		}
+		moved, reason, err := config.Calendar.nextWorkingDay(due.AddDate(0, 0, days))
+		if err != nil {
+			failed("Cannot shift milestone \"%s\": %v", m.Title, err)
+			continue
+		}
+		if reason != "" {
+			log.Printf("Milestone \"%s\" would be due on a %s, moved to %s.", m.Title, reason, moved.Format(calendarDateLayout))
+		}
+		shifted := moved.UTC().Format(time.RFC3339)
+		if !dryRun {
+			update := MilestoneData{Title: m.Title, Description: m.Description, DueOn: &shifted}
+			if err := updateMilestone(ctx, t, m.ID, update); err != nil {
+				failed("Failed to shift milestone \"%s\": %v", m.Title, err)
+				continue
+			}
+			time.Sleep(requestDelay)
+		}
+		result.Shifted = append(result.Shifted, milestoneShift{Title: m.Title, From: *m.DueOn, To: shifted})
+	}
+	return result
+}
+
+// writeShiftReport prints the final report of a shift-milestones run
+func writeShiftReport(w io.Writer, results []shiftResult, dryRun bool) {
+	verb := "shifted"
+	if dryRun {
+		verb = "would shift (dry run)"
+	}
+	fmt.Fprintf(w, "=== Milestone shift report (%d repositories) ===\n", len(results))
+	for _, r := range results {
+		fmt.Fprintf(w, "\n%s:\n", r.Repo)
+		fmt.Fprintf(w, "  %s %d milestones\n", verb, len(r.Shifted))
+		for _, s := range r.Shifted {
+			fmt.Fprintf(w, "    %q: %s -> %s\n", s.Title, engine.DueDate(&s.From), engine.DueDate(&s.To))
+		}
+		for _, problem := range r.Problems {
+			fmt.Fprintf(w, "  failed: %s\n", problem)
+		}
+	}
+}
+
+// runShiftMilestones moves the due dates of open milestones in the target repositories,
+// e.g. after a schedule slipped
+func runShiftMilestones(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("shift-milestones", flag.ExitOnError)
+	by := fs.String("by", "", "How far to move the due dates, in weeks and/or days, e.g. 2w, -3d or 1w2d (required)")
+	from := fs.String("from", "", "Only shift this milestone (default: every open milestone with a due date)")
+	cascade := fs.Bool("cascade", false, "With --from, also shift every open milestone due after it")
+	dryRun := fs.Bool("dry-run", false, "Only report the new due dates, change nothing")
+	writeBack := fs.Bool("write-back", false, "Also update the due dates in the milestones file, so apply does not report them as drift")
+	var shared targetFlags
+	shared.register(fs)
+	layers, err := parseArgs(fs, args)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	shared.paths.Layers = layers
+	if err := shared.limits.check(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	ctx, cancel := shared.limits.start(ctx)
+	defer cancel()
+	days, err := parseShift(*by)
+	if err != nil {
+		log.Fatalf("Error: --by: %v", err)
+	}
+	if *cascade && *from == "" {
+		log.Fatal("Error: --cascade needs --from")
+	}
+	if *writeBack && (len(shared.repos) > 1 || shared.org != "" || shared.paths.Milestones == stdinPath || isGlobPattern(shared.paths.Milestones) || len(layers) > 0) {
+		log.Fatal("Error: --write-back needs a single target repository and a milestones file")
+	}
+
+	_, targets, err := shared.load(ctx)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *writeBack {
+		if err := checkRewritable(shared.paths.Milestones, "--write-back"); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	var results []shiftResult
+	failures := 0
+	for _, t := range targets {
+		result := shiftRepoMilestones(ctx, t, days, *from, *cascade, *dryRun)
+		failures += len(result.Problems)
+		results = append(results, result)
+	}
+	writeShiftReport(redacted(os.Stdout), results, *dryRun)
+
+	if *writeBack && !*dryRun && len(results) == 1 {
+		dueDates := make(map[string]string)
+		for _, s := range results[0].Shifted {
+			dueDates[s.Title] = s.To
+		}
+		if err := writeBackMilestoneDueDates(shared.paths.Milestones, dueDates); err != nil {
+			log.Printf("Warning: %v", err)
+			failures++
+		}
+	}
+	exitOnDeadline(ctx)
+	if failures > 0 {
+		log.Fatalf("Error: shift-milestones finished with %d failures", failures)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// labelWords normalizes a label name for comparison: lower case, with "-", "_" and
+// repeated spaces collapsed into single spaces
+func labelWords(name string) string {
+	name = strings.NewReplacer("-", " ", "_", " ").Replace(strings.ToLower(name))
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// scopeSeparators turns "priority: high" and "priority/high" into plain words
+var scopeSeparators = strings.NewReplacer(":", " ", "/", " ")
+
+// unscoped drops a scope prefix such as "type: " or "area/"
+func unscoped(name string) string {
+	if i := strings.LastIndexAny(name, ":/"); i >= 0 {
+		return strings.TrimSpace(name[i+1:])
+	}
+	return name
+}
+
+// singular strips a plural ending from every word, e.g. "bugs" -> "bug", "fixes" -> "fix"
+func singular(name string) string {
+	words := strings.Fields(name)
+	for i, w := range words {
+		switch {
+		case strings.HasSuffix(w, "ies") && len(w) > 4:
+			words[i] = strings.TrimSuffix(w, "ies") + "y"
+		case strings.HasSuffix(w, "sses") || strings.HasSuffix(w, "xes") || strings.HasSuffix(w, "ches") || strings.HasSuffix(w, "shes"):
+			words[i] = strings.TrimSuffix(w, "es")
+		case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && len(w) > 3:
+			words[i] = strings.TrimSuffix(w, "s")
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// withinOneEdit reports whether a and b differ by at most one inserted, removed or replaced character
+func withinOneEdit(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < len(rb) {
+		ra, rb = rb, ra
+	}
+	if len(ra)-len(rb) > 1 {
+		return false
+	}
+	i := 0
+	for i < len(rb) && ra[i] == rb[i] {
+		i++
+	}
+	if i == len(ra) {
+		return true
+	}
+	if len(ra) == len(rb) {
+		return string(ra[i+1:]) == string(rb[i+1:])
+	}
+	return string(ra[i+1:]) == string(rb[i:])
+}
+
+// labelSimilarity explains why two different label names look alike, "" when they do not
+func labelSimilarity(name, other string) string {
+	a, b := labelWords(name), labelWords(other)
+	switch {
+	case strings.EqualFold(name, other):
+		return "differs only in case"
+	case a == b || labelWords(scopeSeparators.Replace(a)) == labelWords(scopeSeparators.Replace(b)):
+		return "differs only in separators"
+	case singular(a) == singular(b):
+		return "singular/plural of the same name"
+	}
+	// "bug" vs "type: bug"; two different scopes with the same value ("priority: high",
+	// "severity: high") are usually intended
+	if (unscoped(a) == a) != (unscoped(b) == b) && singular(unscoped(a)) == singular(unscoped(b)) {
+		return "same name with a scope prefix"
+	}
+	// Typos, but not numbered series such as "sprint 1" and "sprint 2"
+	if len(a) >= 6 && !strings.ContainsAny(a+b, "0123456789") && withinOneEdit(a, b) {
+		return "differs by one character"
+	}
+	return ""
+}
+
+// nearDuplicateLabelWarnings warns about every label that would be created although an existing
+// label looks almost the same, so it can be renamed or aliased instead of adding a look-alike
+func nearDuplicateLabelWarnings(created []string, existing map[string]github.Label) []string {
+	names := make([]string, 0, len(existing))
+	for name := range existing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var warnings []string
+	for _, name := range created {
+		for _, other := range names {
+			if reason := labelSimilarity(name, other); reason != "" {
+				warnings = append(warnings, fmt.Sprintf("label \"%s\" is a near-duplicate of existing label \"%s\" (%s); consider renaming or aliasing it instead", name, other, reason))
+			}
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestLabelSimilarity(t *testing.T) {
+	tests := []struct {
+		name, other, want string
+	}{
+		{"Bug", "bug", "differs only in case"},
+		{"good-first-issue", "good first issue", "differs only in separators"},
+		{"help_wanted", "help-wanted", "differs only in separators"},
+		{"priority: high", "priority/high", "differs only in separators"},
+		{"bugs", "bug", "singular/plural of the same name"},
+		{"story", "stories", "singular/plural of the same name"},
+		{"bug", "type: bug", "same name with a scope prefix"},
+		{"area/docs", "doc", "same name with a scope prefix"},
+		{"documentation", "documentaton", "differs by one character"},
+		{"enhancement", "enhancements", "singular/plural of the same name"},
+		{"priority: high", "severity: high", ""},
+		{"sprint 1", "sprint 2", ""},
+		{"fix", "fox", ""},
+		{"bug", "feature", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name+"/"+tc.other, func(t *testing.T) {
+			if got := labelSimilarity(tc.name, tc.other); got != tc.want {
+				t.Errorf("labelSimilarity(%q, %q) = %q, want %q", tc.name, tc.other, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWithinOneEdit(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"", "", true},
+		{"", "a", true},
+		{"abc", "abc", true},
+		{"abc", "abd", true},
+		{"abc", "ab", true},
+		{"abc", "abcd", true},
+		{"abc", "xabc", true},
+		{"abc", "axd", false},
+		{"abc", "a", false},
+		{"ab", "ba", false},
+		{"größe", "grösse", false},
+		{"größe", "größe", true},
+	}
+	for _, tc := range tests {
+		if got := withinOneEdit(tc.a, tc.b); got != tc.want {
+			t.Errorf("withinOneEdit(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+		if got := withinOneEdit(tc.b, tc.a); got != tc.want {
+			t.Errorf("withinOneEdit(%q, %q) = %v, want %v", tc.b, tc.a, got, tc.want)
+		}
+	}
+}
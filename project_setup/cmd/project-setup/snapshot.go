@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// renderedIssue is an issue as apply would create it, for snapshots
+type renderedIssue struct {
+	Title          string   `json:"title"`
+	Labels         []string `json:"labels"`
+	Assignees      []string `json:"assignees,omitempty"`
+	MilestoneTitle *string  `json:"milestone_title,omitempty"`
+	Reactions      []string `json:"reactions,omitempty"`
+	ID             string   `json:"id,omitempty"`
+	Parent         string   `json:"parent,omitempty"`
+	// Values of project fields by field name
+	ProjectFields map[string]interface{} `json:"project_fields,omitempty"`
+	BodyFile      string                 `json:"body_file"` // The rendered body, relative to the snapshot
+}
+
+var nonSlugCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+// bodyFileName names the Markdown file of an issue body after its title
+func bodyFileName(title string) string {
+	slug := strings.Trim(nonSlugCharacters.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if slug == "" {
+		slug = "issue"
+	}
+	return slug + ".md"
+}
+
+// writeSnapshot writes the fully rendered definitions of one target to dir, replacing
+// what an earlier snapshot left there
+func writeSnapshot(dir string, t repoTarget, defs *definitions) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("error clearing %s: %w", dir, err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "bodies"), 0o755); err != nil {
+		return err
+	}
+	data := newTemplateData(t, nil, defs.RepoVariables)
+
+	issues := make([]renderedIssue, 0, len(defs.Issues))
+	bodies := make(map[string]string, len(defs.Issues))
+	for _, issue := range defs.Issues {
+		if config.IssueBody.Templates {
+			issue.templateData = &data
+		}
+		name := bodyFileName(issue.Title)
+		for i := 2; bodies[name] != ""; i++ { // Titles differing only in punctuation
+			name = strings.TrimSuffix(bodyFileName(issue.Title), ".md") + fmt.Sprintf("-%d.md", i)
+		}
+		bodies[name] = "# " + issue.Title + "\n\n" + renderIssueBody(issue) + "\n"
+		issues = append(issues, renderedIssue{
+			Title: issue.Title, Labels: issue.Labels, Assignees: issue.Assignees, MilestoneTitle: issue.MilestoneTitle,
+			Reactions: issue.Reactions, ID: issue.ID, Parent: issue.Parent, ProjectFields: issue.ProjectFields,
+			BodyFile: "bodies/" + name,
+		})
+	}
+
+	for name, v := range map[string]interface{}{
+		"labels.json":     defs.Labels,
+		"milestones.json": defs.Milestones,
+		"issues.json":     issues,
+	} {
+		if err := writeJSONFile(filepath.Join(dir, name), v); err != nil {
+			return fmt.Errorf("error writing %s: %w", name, err)
+		}
+	}
+	if len(defs.Properties) > 0 {
+		if err := writeJSONFile(filepath.Join(dir, "properties.json"), defs.Properties); err != nil {
+			return fmt.Errorf("error writing properties.json: %w", err)
+		}
+	}
+	for name, body := range bodies {
+		if err := os.WriteFile(filepath.Join(dir, "bodies", name), []byte(body), 0o644); err != nil {
+			return err
+		}
+	}
+
+	files := defs.Files
+	if defs.Community != nil {
+		community, err := defs.Community.render(t, nil, defs.RepoVariables)
+		if err != nil {
+			return err
+		}
+		files = mergeFiles(files, community)
+	}
+	for path, content := range files {
+		path = filepath.Join(dir, "files", filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runRender writes the definitions as apply would use them for each target, with templates
+// expanded, layers and bundles merged and defaults applied, so changes to templates can be
+// reviewed as diffs of the final content. It does not contact GitHub.
+func runRender(args []string) {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	out := fs.String("out", "", "Directory the rendered definitions are written to, one subdirectory per target")
+	var paths definitionPaths
+	paths.register(fs)
+	var repos stringList
+	fs.Var(&repos, "repo", "Target repository as owner/repo, URL or SSH remote (repeatable, defaults to GITHUB_REPOSITORY)")
+	reposFile := fs.String("repos-file", "", "File listing target repositories, with optional variable overrides")
+	var fixes labelFixes
+	fs.BoolVar(&fixes.StripHash, "strip-hash", false, "Remove a leading '#' from label colors instead of rejecting them")
+	fs.BoolVar(&fixes.TruncateDescriptions, "truncate-descriptions", false, "Truncate label descriptions longer than 100 characters instead of rejecting them")
+	layers, err := parseArgs(fs, args)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *out == "" {
+		log.Fatal("Error: usage: render --out <directory> [flags] [layer directories]")
+	}
+	paths.Layers = layers
+
+	if len(layers) > 0 {
+		config, err = loadLayeredConfig(layers)
+	} else {
+		config, err = loadConfig(configJSONPath)
+	}
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defs, err := loadDefinitions(paths, fixes, nil)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := defs.mapComponents("github"); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *reposFile != "" {
+		listed, overrides, err := loadReposFile(*reposFile)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		repos = append(repos, listed...)
+		defs.RepoVariables = overrides
+	}
+	if len(repos) == 0 && os.Getenv("GITHUB_REPOSITORY") != "" {
+		repos = append(repos, os.Getenv("GITHUB_REPOSITORY"))
+	}
+	if len(repos) == 0 {
+		log.Fatal("Error: render needs a --repo, a --repos-file or GITHUB_REPOSITORY, the targets templates are rendered for")
+	}
+	targets := make([]repoTarget, 0, len(repos))
+	for _, r := range repos {
+		t, _, err := parseRepoReference(r)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		targets = append(targets, t)
+	}
+	if err := applyVariableCatalog(defs, targets); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	plans := make([]repoPlan, 0, len(targets))
+	for _, t := range targets {
+		plans = append(plans, repoPlan{Target: t})
+	}
+	if err := checkBodyTemplates(defs.Issues, plans, defs.RepoVariables); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	for _, t := range targets {
+		dir := filepath.Join(*out, fmt.Sprintf("%s__%s", t.Owner, t.Repo))
+		if err := writeSnapshot(dir, t, defs); err != nil {
+			log.Fatalf("Error rendering %s: %v", t, err)
+		}
+		log.Printf("Rendered the definitions for %s to %s.", t, dir)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// sopsCommand decrypts SOPS files; its keys (e.g. SOPS_AGE_KEY_FILE) come from the environment
+const sopsCommand = "sops"
+
+// decryptedPaths are the definition files of the run that were SOPS-encrypted, so they are
+// never rewritten or copied in plaintext
+var decryptedPaths = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: make(map[string]bool)}
+
+// openDefinition opens a definition file, or stdin for "-", as a reader of JSONC. Files
+// encrypted with SOPS are decrypted in memory by the sops command; the plaintext is
+// never written to disk.
+func openDefinition(path string) (io.Reader, func() error, error) {
+	in, closeFile, err := openDefinitionSource(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Definition files are arrays; an object may be a SOPS document. Arrays are not
+	// buffered, so large backlogs still stream.
+	buffered := bufio.NewReader(in)
+	if first, err := firstNonSpace(buffered); err != nil || first != '{' {
+		return newJSONCReader(buffered), closeFile, nil
+	}
+	data, err := io.ReadAll(buffered)
+	closeFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	plain, err := decryptDefinition(path, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newJSONCReader(bytes.NewReader(plain)), func() error { return nil }, nil
+}
+
+// readDefinition reads a whole definition file, or stdin for "-", like openDefinition but
+// without stripping JSONC; YAML and TOML files are read with it
+func readDefinition(path string) ([]byte, error) {
+	in, closeFile, err := openDefinitionSource(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(in)
+	closeFile()
+	if err != nil {
+		return nil, err
+	}
+	return decryptDefinition(path, data)
+}
+
+// openDefinitionSource opens a definition file, or stdin for "-"
+func openDefinitionSource(path string) (io.Reader, func() error, error) {
+	if path == stdinPath {
+		return stdin, func() error { return nil }, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// decryptDefinition returns the plaintext of a definition file read from path: data itself,
+// or data decrypted when it is a SOPS document
+func decryptDefinition(path string, data []byte) ([]byte, error) {
+	inputType := sopsInputType(data)
+	if inputType == "" {
+		return data, nil
+	}
+	plain, err := decryptSOPS(path, data, inputType)
+	if err != nil {
+		return nil, err
+	}
+	decryptedPaths.Lock()
+	decryptedPaths.paths[path] = true
+	decryptedPaths.Unlock()
+	log.Printf("Decrypted %s with %s.", path, sopsCommand)
+	return plain, nil
+}
+
+// firstNonSpace peeks at the first byte that is not whitespace, without consuming it
+func firstNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			return c, r.UnreadByte()
+		}
+	}
+}
+
+// isSOPSDocument reports whether data is a document encrypted by SOPS
+func isSOPSDocument(data []byte) bool {
+	return sopsInputType(data) != ""
+}
+
+// sopsInputType returns the format of a document encrypted by SOPS, which records its
+// metadata under the "sops" key: "json" or "yaml", the --input-type to decrypt it with,
+// or "" when data is not encrypted. SOPS has no TOML format, so a TOML file encrypted
+// with --input-type binary is stored in one of the two as well.
+func sopsInputType(data []byte) string {
+	var document struct {
+		SOPS *struct {
+			MAC string `json:"mac"`
+		} `json:"sops"`
+	}
+	if json.Unmarshal(data, &document) == nil {
+		if document.SOPS != nil && document.SOPS.MAC != "" {
+			return "json"
+		}
+		return ""
+	}
+	if !bytes.Contains(data, []byte("sops:")) {
+		return ""
+	}
+	tree, err := parseYAML(data)
+	if err != nil {
+		return ""
+	}
+	root, _ := tree.(map[string]interface{})
+	metadata, _ := root["sops"].(map[string]interface{})
+	if yamlText(metadata["mac"]) != "" {
+		return "yaml"
+	}
+	return ""
+}
+
+// yamlText returns a YAML scalar as a string, and "" for anything else
+func yamlText(node interface{}) string {
+	switch s := node.(type) {
+	case string:
+		return s
+	case yamlPlain:
+		return string(s)
+	}
+	return ""
+}
+
+// decryptSOPS decrypts a SOPS document of the given input type, "json" or "yaml".
+// Definition files are encrypted as a whole with --input-type binary, which SOPS stores
+// as {"data": "<file>"} (data: <file> in YAML); the file is unwrapped again.
+func decryptSOPS(path string, data []byte, inputType string) ([]byte, error) {
+	cmd := exec.CommandContext(context.Background(), sopsCommand, "--decrypt", "--input-type", inputType, "--output-type", inputType, "/dev/stdin")
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("%s is encrypted with SOPS, but the %s command is not installed", path, sopsCommand)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting %s with %s: %v: %s", path, sopsCommand, err, strings.TrimSpace(stderr.String()))
+	}
+	if inputType == "yaml" {
+		if tree, err := parseYAML(out); err == nil {
+			if wrapped, ok := tree.(map[string]interface{}); ok && len(wrapped) == 1 {
+				if _, ok := wrapped["data"]; ok {
+					return []byte(yamlText(wrapped["data"])), nil
+				}
+			}
+		}
+		return out, nil
+	}
+	var wrapped map[string]json.RawMessage
+	if json.Unmarshal(out, &wrapped) == nil && len(wrapped) == 1 {
+		var file string
+		if json.Unmarshal(wrapped["data"], &file) == nil {
+			return []byte(file), nil
+		}
+	}
+	return out, nil
+}
+
+// checkNotDecrypted refuses to write the plaintext of encrypted definitions matched by
+// path, a file or pattern, for the given purpose
+func checkNotDecrypted(path, purpose string) error {
+	decryptedPaths.Lock()
+	defer decryptedPaths.Unlock()
+	for decrypted := range decryptedPaths.paths {
+		if decrypted == path {
+			return fmt.Errorf("%s is encrypted with SOPS, %s would write it in plaintext", decrypted, purpose)
+		}
+		if matched, _ := filepath.Match(path, decrypted); matched && isGlobPattern(path) {
+			return fmt.Errorf("%s is encrypted with SOPS, %s would write it in plaintext", decrypted, purpose)
+		}
+	}
+	return nil
+}
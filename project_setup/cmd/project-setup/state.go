@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// Run state keeps what earlier runs did per repository, so ephemeral CI runners
+// can resume where a previous run stopped and audit what happened across runs
+const (
+	maxStateRuns  = 50            // Run records kept per repository, oldest dropped first
+	staleLockAge  = 2 * time.Hour // A lock older than this is left over from a crashed run
+	stateFileName = "state.json"  // File on the state branch
+	stateBranch   = "project-setup-state"
+)
+
+// repoState is the stored state of one repository
+type repoState struct {
+	Repository string                 `json:"repository"`
+	Lock       *stateLock             `json:"lock,omitempty"`   // Set while a run is applying
+	Issues     map[string]IssueOutput `json:"issues,omitempty"` // Created issues by title, skipped on later runs
+	Runs       []runRecord            `json:"runs,omitempty"`   // Oldest first
+}
+
+// stateLock marks a repository as being applied to
+type stateLock struct {
+	Holder string    `json:"holder"`
+	Since  time.Time `json:"since"`
+}
+
+// runRecord is the audit entry of one finished run
+type runRecord struct {
+	ID           string       `json:"id,omitempty"` // See newRunID; plan --against compares with a run by it
+	Finished     time.Time    `json:"finished"`
+	Holder       string       `json:"holder"`
+	TemplateHash string       `json:"template_hash"`
+	Labels       entityCounts `json:"labels"`
+	Milestones   entityCounts `json:"milestones"`
+	Issues       entityCounts `json:"issues"`
+	Files        entityCounts `json:"files"`
+	Properties   entityCounts `json:"properties"`
+	Problems     []string     `json:"problems,omitempty"`
+	// Labels and milestones when the run finished, unless they could not be read
+	Snapshot *repoSnapshot `json:"snapshot,omitempty"`
+}
+
+// stateBackend loads and stores the raw state of a repository; found is false when there is none yet
+type stateBackend interface {
+	load(ctx context.Context, t repoTarget) (data []byte, found bool, err error)
+	save(ctx context.Context, t repoTarget, data []byte) error
+	String() string
+}
+
+// openStateBackend selects the backend for --state:
+// a directory (optionally file://), s3://bucket/prefix, or github-branch[:name]
+func openStateBackend(location string) (stateBackend, error) {
+	switch {
+	case location == "":
+		return nil, nil
+	case strings.HasPrefix(location, "s3://"):
+		return newS3StateBackend(strings.TrimPrefix(location, "s3://"))
+	case location == "github-branch":
+		return branchStateBackend{branch: stateBranch}, nil
+	case strings.HasPrefix(location, "github-branch:"):
+		branch := strings.TrimPrefix(location, "github-branch:")
+		if branch == "" {
+			return nil, fmt.Errorf("invalid --state %q, expected github-branch:<branch>", location)
+		}
+		return branchStateBackend{branch: branch}, nil
+	default:
+		return fileStateBackend{dir: strings.TrimPrefix(location, "file://")}, nil
+	}
+}
+
+// stateHolder identifies this run in locks and run records
+func stateHolder() string {
+	if id := os.Getenv("GITHUB_RUN_ID"); id != "" {
+		return fmt.Sprintf("%s run %s", os.Getenv("GITHUB_REPOSITORY"), id)
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s pid %d", host, os.Getpid())
+}
+
+// newRunID identifies a run started at now, e.g. 20240102-150405-a1b2c3
+func newRunID(now time.Time) string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// loadState reads the state of a repository; a repository without state starts empty
+func loadState(ctx context.Context, backend stateBackend, t repoTarget) (*repoState, error) {
+	state := &repoState{Repository: t.String(), Issues: make(map[string]IssueOutput)}
+	data, found, err := backend.load(ctx, t)
+	if err != nil || !found {
+		return state, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("error unmarshalling state of %s from %s: %w", t, backend, err)
+	}
+	if state.Issues == nil {
+		state.Issues = make(map[string]IssueOutput)
+	}
+	return state, nil
+}
+
+// saveState writes the state of a repository
+func saveState(ctx context.Context, backend stateBackend, t repoTarget, state *repoState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling state of %s: %w", t, err)
+	}
+	return backend.save(ctx, t, append(data, '\n'))
+}
+
+// lockState loads the state of a repository and locks it for this run.
+// A lock held by another run fails, unless it is stale.
+func lockState(ctx context.Context, backend stateBackend, t repoTarget) (*repoState, error) {
+	state, err := loadState(ctx, backend, t)
+	if err != nil {
+		return nil, err
+	}
+	holder := stateHolder()
+	if lock := state.Lock; lock != nil && lock.Holder != holder {
+		if age := time.Since(lock.Since); age < staleLockAge {
+			return nil, fmt.Errorf("%s is locked by %s since %s, another run is applying to it", t, lock.Holder, lock.Since.Format(time.RFC3339))
+		}
+		log.Printf("Warning: Taking over the stale lock of %s held by %s since %s.", t, lock.Holder, lock.Since.Format(time.RFC3339))
+	}
+	state.Lock = &stateLock{Holder: holder, Since: time.Now().UTC()}
+	if err := saveState(ctx, backend, t, state); err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded run state of %s from %s (%d earlier runs, %d issues recorded).", t, backend, len(state.Runs), len(state.Issues))
+	return state, nil
+}
+
+// finishState records the run and releases the lock; snapshot may be nil
+func finishState(ctx context.Context, backend stateBackend, t repoTarget, state *repoState, summary runSummary, hash, runID string, snapshot *repoSnapshot) error {
+	state.Lock = nil
+	state.Runs = append(state.Runs, runRecord{
+		ID:           runID,
+		Finished:     time.Now().UTC(),
+		Holder:       stateHolder(),
+		TemplateHash: hash,
+		Labels:       summary.Labels,
+		Milestones:   summary.Milestones,
+		Issues:       summary.Issues,
+		Files:        summary.Files,
+		Properties:   summary.Properties,
+		Problems:     summary.Problems,
+		Snapshot:     snapshot,
+	})
+	if len(state.Runs) > maxStateRuns {
+		state.Runs = state.Runs[len(state.Runs)-maxStateRuns:]
+	}
+	return saveState(ctx, backend, t, state)
+}
+
+// issue returns the issue an earlier run created for the title, if any; s may be nil
+func (s *repoState) issue(title string) (IssueOutput, bool) {
+	if s == nil {
+		return IssueOutput{}, false
+	}
+	output, ok := s.Issues[title]
+	return output, ok
+}
+
+// recordIssue remembers a created issue in the run state
+func (r *repoRun) recordIssue(title string, created github.Issue) {
+	if r.state != nil {
+		r.state.Issues[title] = IssueOutput{Repository: r.target.String(), Number: created.Number, URL: created.HTMLURL}
+	}
+}
+
+// --- File Backend ---
+
+// fileStateBackend keeps the state as <dir>/<owner>/<repo>.json
+type fileStateBackend struct {
+	dir string
+}
+
+func (b fileStateBackend) path(t repoTarget) string {
+	return filepath.Join(b.dir, t.Owner, t.Repo+".json")
+}
+
+func (b fileStateBackend) load(_ context.Context, t repoTarget) ([]byte, bool, error) {
+	data, err := os.ReadFile(b.path(t))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading state file: %w", err)
+	}
+	return data, true, nil
+}
+
+// save writes through a temporary file, so an interrupted run never leaves half a state file
+func (b fileStateBackend) save(_ context.Context, t repoTarget, data []byte) error {
+	path := b.path(t)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating state directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error replacing state file: %w", err)
+	}
+	return nil
+}
+
+func (b fileStateBackend) String() string { return "directory " + b.dir }
+
+// --- GitHub Branch Backend ---
+
+// branchStateBackend commits the state to a branch of the target repository itself.
+// The branch holds nothing but the state file and does not share history with the code.
+type branchStateBackend struct {
+	branch string
+}
+
+func (b branchStateBackend) load(ctx context.Context, t repoTarget) ([]byte, bool, error) {
+	data, found, err := getFileContent(ctx, t, stateFileName, b.branch)
+	if err != nil {
+		return nil, false, fmt.Errorf("error fetching state from branch '%s': %w", b.branch, err)
+	}
+	return data, found, nil
+}
+
+func (b branchStateBackend) save(ctx context.Context, t repoTarget, data []byte) error {
+	head, exists, err := getBranchHead(ctx, t, b.branch)
+	if err != nil {
+		return err
+	}
+	// No base tree: the commit contains the state file only
+	treeRequest := GitHubTreeRequest{Tree: []GitHubTreeEntry{{Path: stateFileName, Mode: "100644", Type: "blob", Content: string(data)}}}
+	var tree struct {
+		SHA string `json:"sha"`
+	}
+	if err := postGitObject(ctx, t, "tree", "trees", treeRequest, &tree); err != nil {
+		return err
+	}
+	commitRequest := GitHubCommitRequest{Message: "Update project setup state", Tree: tree.SHA, Parents: []string{}}
+	if exists {
+		commitRequest.Parents = []string{head}
+	}
+	var commit GitHubCommitResponse
+	if err := postGitObject(ctx, t, "commit", "commits", commitRequest, &commit); err != nil {
+		return err
+	}
+	if exists {
+		return updateBranch(ctx, t, b.branch, commit.SHA)
+	}
+	return postGitObject(ctx, t, "branch", "refs", map[string]string{"ref": "refs/heads/" + b.branch, "sha": commit.SHA}, nil)
+}
+
+func (b branchStateBackend) String() string { return "branch " + b.branch }
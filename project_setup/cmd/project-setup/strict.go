@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// warningCount counts the warnings logged while it is installed as the log output, for
+// --strict; every log line starting with "Warning" counts
+type warningCount struct {
+	out io.Writer
+	n   atomic.Int64
+}
+
+// countWarnings starts counting the logged warnings until stop is called
+func countWarnings() *warningCount {
+	c := &warningCount{out: log.Writer()}
+	log.SetOutput(c)
+	return c
+}
+
+func (c *warningCount) Write(p []byte) (int, error) {
+	if strings.HasPrefix(logTimestamp.ReplaceAllString(string(p), ""), "Warning") {
+		c.n.Add(1)
+	}
+	return c.out.Write(p)
+}
+
+// count returns the warnings logged so far; c may be nil when not counting
+func (c *warningCount) count() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.n.Load()
+}
+
+// stop restores the log output
+func (c *warningCount) stop() {
+	log.SetOutput(c.out)
+}
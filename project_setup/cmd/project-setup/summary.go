@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// entityCounts tallies what happened to one kind of entity during a run
+type entityCounts struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Failed  int `json:"failed"`
+	Deleted int `json:"deleted,omitempty"` // With --prune
+}
+
+// add accumulates the counts of other into c
+func (c *entityCounts) add(other entityCounts) {
+	c.Created += other.Created
+	c.Updated += other.Updated
+	c.Failed += other.Failed
+	c.Deleted += other.Deleted
+}
+
+// runSummary collects the outcome of an apply run
+type runSummary struct {
+	Labels     entityCounts `json:"labels"`
+	Milestones entityCounts `json:"milestones"`
+	Issues     entityCounts `json:"issues"`
+	Files      entityCounts `json:"files"`
+	Properties entityCounts `json:"properties"`
+	Errors     int          `json:"errors"`             // Phases that failed as a whole, e.g. an unreadable definitions file
+	Drift      int          `json:"drift"`              // Existing labels/milestones that still differ from the definitions
+	Skipped    []string     `json:"skipped,omitempty"`  // Conflicts left unresolved, only kept for single repository summaries
+	Updated    []string     `json:"updated,omitempty"`  // Labels and milestones updated to match their definitions, likewise
+	Problems   []string     `json:"problems,omitempty"` // What failed and why, only kept for single repository summaries
+	// The failures with their requests, only kept for single repository summaries
+	FailureRecords []failureRecord `json:"failure_records,omitempty"`
+	// Indexes in the issue definitions of the issues that could not be created
+	FailedIssues []int `json:"failed_issues,omitempty"`
+	// Issues created, by index in the issue definitions; only kept for single repository summaries
+	CreatedIssues map[int]github.Issue `json:"created_issues,omitempty"`
+}
+
+// add accumulates the counts of another summary into s
+func (s *runSummary) add(other runSummary) {
+	for _, pair := range []struct{ into, from *entityCounts }{
+		{&s.Labels, &other.Labels},
+		{&s.Milestones, &other.Milestones},
+		{&s.Issues, &other.Issues},
+		{&s.Files, &other.Files},
+		{&s.Properties, &other.Properties},
+	} {
+		pair.into.add(*pair.from)
+	}
+	s.Errors += other.Errors
+	s.Drift += other.Drift
+}
+
+// Failures is the number of failed entities plus failed phases
+func (s runSummary) Failures() int {
+	return s.Labels.Failed + s.Milestones.Failed + s.Issues.Failed + s.Files.Failed + s.Properties.Failed + s.Errors
+}
+
+// qualityGates are the CI thresholds given on the command line; negative values disable a gate
+type qualityGates struct {
+	MaxFailures int
+	MaxDrift    int
+}
+
+// check returns a description of every exceeded threshold
+func (g qualityGates) check(s runSummary) []string {
+	var violations []string
+	if g.MaxFailures >= 0 && s.Failures() > g.MaxFailures {
+		violations = append(violations, fmt.Sprintf("%d failures exceed --max-failures=%d", s.Failures(), g.MaxFailures))
+	}
+	if g.MaxDrift >= 0 && s.Drift > g.MaxDrift {
+		violations = append(violations, fmt.Sprintf("drift of %d exceeds --max-drift=%d", s.Drift, g.MaxDrift))
+	}
+	return violations
+}
+
+// writeActionsOutputs exposes the counts as GitHub Actions step outputs.
+// Nothing is written when not running in Actions.
+func writeActionsOutputs(s runSummary) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening GITHUB_OUTPUT file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	outputs := []struct {
+		name  string
+		value int
+	}{
+		{"labels_created", s.Labels.Created},
+		{"labels_updated", s.Labels.Updated},
+		{"milestones_created", s.Milestones.Created},
+		{"milestones_updated", s.Milestones.Updated},
+		{"issues_created", s.Issues.Created},
+		{"files_committed", s.Files.Created},
+		{"properties_updated", s.Properties.Updated},
+		{"failures", s.Failures()},
+		{"drift", s.Drift},
+	}
+	for _, o := range outputs {
+		if _, err := fmt.Fprintf(f, "%s=%d\n", o.name, o.value); err != nil {
+			return fmt.Errorf("error writing GITHUB_OUTPUT file %s: %w", path, err)
+		}
+	}
+	return nil
+}
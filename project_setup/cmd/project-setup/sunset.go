@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// defaultSunsetComment is posted on every issue closed by sunset
+const defaultSunsetComment = "This issue was closed because the project has been sunset."
+
+// sunsetResult is the final report of one repository
+type sunsetResult struct {
+	Repo             string   `json:"repo"`
+	IssuesClosed     []int    `json:"issues_closed"`
+	MilestonesClosed []string `json:"milestones_closed"`
+	Archived         bool     `json:"archived"`
+	Problems         []string `json:"problems,omitempty"`
+}
+
+// addIssueComment posts a comment on an issue
+func addIssueComment(ctx context.Context, t repoTarget, issueNumber int, body string) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/comments", githubAPIBaseURL, t.Owner, t.Repo, issueNumber)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "POST", url, map[string]string{"body": body})
+	if err != nil {
+		return fmt.Errorf("error sending comment request for issue #%d: %w", issueNumber, err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("error commenting on issue #%d: %w", issueNumber, github.NewAPIError(resp, bodyBytes))
+	}
+	return nil
+}
+
+// closeIssue closes an issue as not planned
+func closeIssue(ctx context.Context, t repoTarget, issueNumber int) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d", githubAPIBaseURL, t.Owner, t.Repo, issueNumber)
+	payload := map[string]string{"state": "closed", "state_reason": "not_planned"}
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "PATCH", url, payload)
+	if err != nil {
+		return fmt.Errorf("error sending close request for issue #%d: %w", issueNumber, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error closing issue #%d: %w", issueNumber, github.NewAPIError(resp, bodyBytes))
+	}
+	return nil
+}
+
+// closeMilestone closes a milestone
+func closeMilestone(ctx context.Context, t repoTarget, id int) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/milestones/%d", githubAPIBaseURL, t.Owner, t.Repo, id)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "PATCH", url, github.MilestoneRequest{State: "closed"})
+	if err != nil {
+		return fmt.Errorf("error sending close request for milestone #%d: %w", id, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error closing milestone #%d: %w", id, github.NewAPIError(resp, bodyBytes))
+	}
+	return nil
+}
+
+// archiveRepository makes the repository read-only
+func archiveRepository(ctx context.Context, t repoTarget) error {
+	url := fmt.Sprintf("%s/repos/%s/%s", githubAPIBaseURL, t.Owner, t.Repo)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "PATCH", url, map[string]bool{"archived": true})
+	if err != nil {
+		return fmt.Errorf("error sending archive request for %s: %w", t, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error archiving %s: %w", t, github.NewAPIError(resp, bodyBytes))
+	}
+	return nil
+}
+
+// seededIssueNumbers finds the open issues the project setup created in a repository:
+// issues matching a definition (by recorded output, run state or title) and tracking issues
+func seededIssueNumbers(ctx context.Context, t repoTarget, defs *definitions, state *repoState) ([]int, error) {
+	existing, err := getExistingIssues(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	open := make(map[int]bool)
+	for _, issue := range existing {
+		if issue.State == "open" {
+			open[issue.Number] = true
+		}
+	}
+
+	var numbers []int
+	seen := make(map[int]bool)
+	add := func(number int) {
+		if open[number] && !seen[number] {
+			seen[number] = true
+			numbers = append(numbers, number)
+		}
+	}
+	for _, issue := range defs.Issues {
+		if issue.Output != nil && issue.Output.Repository == t.String() {
+			add(issue.Output.Number)
+		}
+		if recorded, ok := state.issue(issue.Title); ok {
+			add(recorded.Number)
+		}
+		if found, ok := existing[issue.Title]; ok {
+			add(found.Number)
+		}
+	}
+	for _, issue := range existing {
+		if strings.HasPrefix(issue.Body, trackingIssueMarker) {
+			add(issue.Number)
+		}
+	}
+	return numbers, nil
+}
+
+// sunsetRepo closes the seeded issues and the defined milestones of one repository,
+// and archives it when asked to
+func sunsetRepo(ctx context.Context, t repoTarget, defs *definitions, state stateBackend, comment string, archive bool) sunsetResult {
+	result := sunsetResult{Repo: t.String()}
+	failed := func(format string, args ...interface{}) {
+		message := fmt.Sprintf(format, args...)
+		log.Printf("%s", message)
+		result.Problems = append(result.Problems, message)
+	}
+	log.Printf("--- Sunsetting %s ---", t)
+
+	var recorded *repoState
+	if state != nil {
+		var err error
+		if recorded, err = loadState(ctx, state, t); err != nil {
+			log.Printf("Warning: Could not read run state of %s: %v", t, err)
+		}
+	}
+	numbers, err := seededIssueNumbers(ctx, t, defs, recorded)
+	if err != nil {
+		failed("Error listing issues of %s: %v", t, err)
+		return result
+	}
+	for _, number := range numbers {
+		if comment != "" {
+			if err := addIssueComment(ctx, t, number, comment); err != nil {
+				failed("Failed to comment on issue #%d: %v", number, err)
+				continue
+			}
+			time.Sleep(requestDelay)
+		}
+		if err := closeIssue(ctx, t, number); err != nil {
+			failed("Failed to close issue #%d: %v", number, err)
+			continue
+		}
+		log.Printf("Closed issue #%d.", number)
+		result.IssuesClosed = append(result.IssuesClosed, number)
+		time.Sleep(requestDelay)
+	}
+
+	existingMilestones, err := getExistingMilestones(ctx, t)
+	if err != nil {
+		failed("Error listing milestones of %s: %v", t, err)
+		return result
+	}
+	for _, milestone := range defs.Milestones {
+		existing, ok := existingMilestones[milestoneKey(milestone.Title)]
+		if !ok || existing.State == "closed" {
+			continue
+		}
+		if err := closeMilestone(ctx, t, existing.ID); err != nil {
+			failed("Failed to close milestone '%s': %v", milestone.Title, err)
+			continue
+		}
+		log.Printf("Closed milestone \"%s\".", milestone.Title)
+		result.MilestonesClosed = append(result.MilestonesClosed, milestone.Title)
+		time.Sleep(requestDelay)
+	}
+
+	// Archiving makes the repository read-only, so it has to come last and only after a clean run
+	if archive {
+		if len(result.Problems) > 0 {
+			failed("Not archiving %s because of the failures above.", t)
+		} else if err := archiveRepository(ctx, t); err != nil {
+			failed("Failed to archive %s: %v", t, err)
+		} else {
+			log.Printf("Archived %s.", t)
+			result.Archived = true
+		}
+	}
+	return result
+}
+
+// writeSunsetReport prints the final report of a sunset run
+func writeSunsetReport(w io.Writer, results []sunsetResult) {
+	fmt.Fprintf(w, "=== Sunset report (%d repositories) ===\n", len(results))
+	for _, r := range results {
+		fmt.Fprintf(w, "\n%s:\n", r.Repo)
+		fmt.Fprintf(w, "  issues closed (%d)", len(r.IssuesClosed))
+		for i, number := range r.IssuesClosed {
+			if i == 0 {
+				fmt.Fprint(w, ":")
+			}
+			fmt.Fprintf(w, " #%d", number)
+		}
+		fmt.Fprintln(w)
+		writeAuditLine(w, "milestones closed", r.MilestonesClosed)
+		if r.Archived {
+			fmt.Fprintln(w, "  archived")
+		}
+		for _, problem := range r.Problems {
+			fmt.Fprintf(w, "  failed: %s\n", problem)
+		}
+	}
+}
+
+// runSunset is the reverse of apply: it winds the target repositories down
+func runSunset(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("sunset", flag.ExitOnError)
+	comment := fs.String("comment", defaultSunsetComment, "Comment posted on every closed issue, empty for none")
+	archive := fs.Bool("archive", false, "Archive the repository once its issues and milestones are closed")
+	jsonOutput := fs.Bool("json", false, "Print the final report as JSON")
+	var shared targetFlags
+	shared.register(fs)
+	layers, err := parseArgs(fs, args)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	shared.paths.Layers = layers
+	if err := shared.limits.check(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	ctx, cancel := shared.limits.start(ctx)
+	defer cancel()
+
+	defs, targets, err := shared.load(ctx)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	var results []sunsetResult
+	failures := 0
+	for _, t := range targets {
+		result := sunsetRepo(ctx, t, defs, shared.options.State, *comment, *archive)
+		failures += len(result.Problems)
+		results = append(results, result)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(redacted(os.Stdout))
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			log.Fatalf("Error encoding sunset report: %v", err)
+		}
+	} else {
+		writeSunsetReport(redacted(os.Stdout), results)
+	}
+	exitOnDeadline(ctx)
+	if failures > 0 {
+		log.Fatalf("Error: sunset finished with %d failures", failures)
+	}
+}
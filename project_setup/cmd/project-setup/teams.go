@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxIssueAssignees is the most assignees GitHub accepts on one issue
+const maxIssueAssignees = 10
+
+// Strategies for picking the members of a team assignee ("@org/team")
+const (
+	teamStrategyAll        = "all"         // Every member
+	teamStrategyRoundRobin = "round-robin" // Count members per issue, taking turns across the issues of a repository
+	teamStrategyRandom     = "random"      // Count random members per issue
+)
+
+// TeamAssigneeConfig controls how team assignees are expanded, from config.json
+type TeamAssigneeConfig struct {
+	Strategy string `json:"strategy,omitempty"` // all (default), round-robin or random
+	Count    int    `json:"count,omitempty"`    // Members per issue for round-robin/random, default 1
+}
+
+// validate fills in the defaults and rejects unknown strategies
+func (c *TeamAssigneeConfig) validate() error {
+	switch c.Strategy {
+	case "":
+		c.Strategy = teamStrategyAll
+	case teamStrategyAll, teamStrategyRoundRobin, teamStrategyRandom:
+	default:
+		return fmt.Errorf("invalid team assignee strategy %q, expected all, round-robin or random", c.Strategy)
+	}
+	if c.Count < 0 {
+		return fmt.Errorf("invalid team assignee count %d", c.Count)
+	}
+	if c.Count == 0 {
+		c.Count = 1
+	}
+	return nil
+}
+
+// parseTeamAssignee splits "@org/team" into organization and team slug; ok is false for a user login
+func parseTeamAssignee(assignee string) (org, slug string, ok bool) {
+	if !strings.HasPrefix(assignee, "@") {
+		return "", "", false
+	}
+	org, slug, ok = strings.Cut(assignee[1:], "/")
+	return org, slug, ok && org != "" && slug != ""
+}
+
+// teamMembers caches the members of every team for the whole run
+var teamMembers = struct {
+	mu      sync.Mutex
+	members map[string][]string
+}{members: make(map[string][]string)}
+
+// getTeamMembers returns the logins of a team's members, sorted
+func getTeamMembers(ctx context.Context, org, slug string) ([]string, error) {
+	key := strings.ToLower(org + "/" + slug)
+	teamMembers.mu.Lock()
+	defer teamMembers.mu.Unlock()
+	if members, ok := teamMembers.members[key]; ok {
+		return members, nil
+	}
+
+	url := fmt.Sprintf("%s/orgs/%s/teams/%s/members?per_page=100", githubAPIBaseURL, org, slug)
+	users, err := getAllPages[struct {
+		Login string `json:"login"`
+	}](ctx, "team members", url)
+	if err != nil {
+		return nil, fmt.Errorf("error listing members of team @%s/%s: %w", org, slug, err)
+	}
+	members := make([]string, 0, len(users))
+	for _, user := range users {
+		members = append(members, user.Login)
+	}
+	sort.Strings(members)
+	teamMembers.members[key] = members
+	return members, nil
+}
+
+// expandAssignees replaces team assignees by their members according to the configured strategy.
+// Duplicates are dropped and the result is capped at GitHub's limit.
+func expandAssignees(ctx context.Context, run *repoRun, issue IssueData) ([]string, error) {
+	var assignees []string
+	seen := make(map[string]bool)
+	add := func(login string) {
+		if !seen[strings.ToLower(login)] {
+			seen[strings.ToLower(login)] = true
+			assignees = append(assignees, login)
+		}
+	}
+
+	for _, assignee := range issue.Assignees {
+		org, slug, isTeam := parseTeamAssignee(assignee)
+		if !isTeam {
+			add(assignee)
+			continue
+		}
+		members, err := getTeamMembers(ctx, org, slug)
+		if err != nil {
+			return nil, err
+		}
+		if len(members) == 0 {
+			log.Printf("Warning: Team %s has no members, no one assigned from it to issue '%s'.", assignee, issue.Title)
+			continue
+		}
+		strategy := config.TeamAssignees
+		switch count := min(strategy.Count, len(members)); strategy.Strategy {
+		case teamStrategyRoundRobin:
+			turn := run.teamTurns[assignee]
+			for i := 0; i < count; i++ {
+				add(members[(turn+i)%len(members)])
+			}
+			run.teamTurns[assignee] = (turn + count) % len(members)
+		case teamStrategyRandom:
+			for _, i := range rand.Perm(len(members))[:count] {
+				add(members[i])
+			}
+		default:
+			for _, member := range members {
+				add(member)
+			}
+		}
+	}
+
+	if len(assignees) > maxIssueAssignees {
+		log.Printf("Warning: Issue '%s' has %d assignees after expanding teams, only the first %d are assigned.", issue.Title, len(assignees), maxIssueAssignees)
+		assignees = assignees[:maxIssueAssignees]
+	}
+	return assignees, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// repoMetadata describes the target repository to templates; on its own it prints its
+// name, so templates written for the plain {{.Repo}} keep working
+type repoMetadata struct {
+	Name          string   `json:"name"`
+	FullName      string   `json:"full_name"`
+	Description   string   `json:"description"`
+	DefaultBranch string   `json:"default_branch"`
+	Visibility    string   `json:"visibility"` // public, private or internal
+	URL           string   `json:"html_url"`
+	Topics        []string `json:"topics"`
+}
+
+func (r repoMetadata) String() string { return r.Name }
+
+// orgMetadata describes the owner of the target repository, an organization or a user;
+// on its own it prints its login
+type orgMetadata struct {
+	Login       string `json:"login"`
+	Name        string `json:"name"` // Display name, the login when none is set
+	Description string `json:"description"`
+	URL         string `json:"html_url"`
+	Type        string `json:"type"` // Organization or User
+}
+
+func (o orgMetadata) String() string { return o.Login }
+
+// targetMetadata is what templates know about a target beyond its name, read from the API
+type targetMetadata struct {
+	Repo repoMetadata
+	Org  orgMetadata
+}
+
+// templateData is what community file templates and templated issue bodies can refer to
+type templateData struct {
+	Owner      string
+	Repo       repoMetadata
+	Org        orgMetadata
+	Repository string
+	Vars       map[string]string
+}
+
+// newTemplateData is the data of the templates rendered for t. Without metadata only the
+// names are known; overrides from --repos-file replace config.json's variables.
+func newTemplateData(t repoTarget, meta *targetMetadata, overrides repoVariableOverrides) templateData {
+	data := templateData{Owner: t.Owner, Repository: t.String(), Vars: config.Community.Variables}
+	if meta != nil {
+		data.Repo, data.Org = meta.Repo, meta.Org
+	}
+	if data.Repo.Name == "" {
+		data.Repo = repoMetadata{Name: t.Repo, FullName: t.String()}
+	}
+	if data.Org.Login == "" {
+		data.Org = orgMetadata{Login: t.Owner, Name: t.Owner}
+	}
+	if len(overrides[t]) > 0 {
+		vars := make(map[string]string, len(data.Vars)+len(overrides[t]))
+		for name, value := range data.Vars {
+			vars[name] = value
+		}
+		for name, value := range overrides[t] {
+			vars[name] = value
+		}
+		data.Vars = vars
+	}
+	if data.Vars == nil {
+		data.Vars = make(map[string]string)
+	}
+	return data
+}
+
+// usesMetadata reports whether a template refers to fields that are read from the API
+func usesMetadata(text string) bool {
+	return strings.Contains(text, ".Repo.") || strings.Contains(text, ".Org")
+}
+
+// fetchTargetMetadata reads the repository and its owner; /users also answers for organizations
+func fetchTargetMetadata(ctx context.Context, t repoTarget) (targetMetadata, error) {
+	var meta targetMetadata
+	for _, read := range []struct {
+		url  string
+		into interface{}
+	}{
+		{fmt.Sprintf("%s/repos/%s/%s", githubAPIBaseURL, t.Owner, t.Repo), &meta.Repo},
+		{fmt.Sprintf("%s/users/%s", githubAPIBaseURL, t.Owner), &meta.Org},
+	} {
+		resp, bodyBytes, err := sendGitHubRequest(ctx, "GET", read.url, nil)
+		if err != nil {
+			return meta, fmt.Errorf("error sending request for template metadata of %s: %w", t, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return meta, fmt.Errorf("error getting template metadata of %s: %w", t, github.NewAPIError(resp, bodyBytes))
+		}
+		if err := json.Unmarshal(bodyBytes, read.into); err != nil {
+			return meta, fmt.Errorf("error unmarshalling template metadata of %s: %w", t, err)
+		}
+	}
+	if meta.Org.Name == "" {
+		meta.Org.Name = meta.Org.Login
+	}
+	return meta, nil
+}
+
+// bodyTemplates lists the issue body texts rendered as templates with issue_body.templates
+func bodyTemplates(issues []IssueData) []string {
+	texts := []string{config.IssueBody.Header, config.IssueBody.Footer}
+	for _, issue := range issues {
+		texts = append(texts, issue.Description)
+	}
+	return texts
+}
+
+// renderBodyTemplate executes one section of an issue body
+func renderBodyTemplate(text string, data templateData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("body").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// checkBodyTemplates renders every templated body for every target up front, so a typo or a
+// missing variable stops the run before anything is changed
+func checkBodyTemplates(issues []IssueData, plans []repoPlan, overrides repoVariableOverrides) error {
+	if !config.IssueBody.Templates {
+		return nil
+	}
+	for _, plan := range plans {
+		data := newTemplateData(plan.Target, plan.Metadata, overrides)
+		for _, issue := range issues {
+			if _, err := renderBodyTemplate(issue.Description, data); err != nil {
+				return fmt.Errorf("error rendering the body of issue '%s' for %s: %w", issue.Title, plan.Target, err)
+			}
+		}
+		for _, text := range []string{config.IssueBody.Header, config.IssueBody.Footer} {
+			if _, err := renderBodyTemplate(text, data); err != nil {
+				return fmt.Errorf("error rendering the issue body header/footer for %s: %w", plan.Target, err)
+			}
+		}
+	}
+	return nil
+}
+
+// fetchPlanMetadata reads the metadata of every target when a template refers to it
+func fetchPlanMetadata(ctx context.Context, defs *definitions, plans []repoPlan) error {
+	needed := defs.Community != nil && defs.Community.usesMetadata
+	if config.IssueBody.Templates {
+		for _, text := range bodyTemplates(defs.Issues) {
+			needed = needed || usesMetadata(text)
+		}
+	}
+	if !needed {
+		return nil
+	}
+	for i := range plans {
+		meta, err := fetchTargetMetadata(ctx, plans[i].Target)
+		if err != nil {
+			return err
+		}
+		plans[i].Metadata = &meta
+	}
+	return nil
+}
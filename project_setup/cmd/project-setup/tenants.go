@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// Tenant is one organization served by a shared --json-rpc process with its own credentials.
+// Requests name their tenant and run in its directory, so config.json, the definitions and a
+// relative --state are the tenant's own.
+type Tenant struct {
+	Name     string   `json:"name"`
+	TokenEnv string   `json:"token_env"`          // Environment variable holding the tenant's token
+	Owners   []string `json:"owners"`             // Organizations and users the tenant may target
+	Dir      string   `json:"dir"`                // Working directory of the tenant's requests
+	LogFile  string   `json:"log_file,omitempty"` // Also append the tenant's log lines to this file
+	// API the tenant's requests go to, by default the one of the process; a --repo on another host is refused
+	APIURL string `json:"api_url,omitempty"`
+	// s3://bucket/prefix under which the tenant may keep --state; S3 state is refused without it
+	StatePrefix string `json:"state_prefix,omitempty"`
+
+	token    string
+	dir      string             // Absolute Dir, with symlinks resolved
+	throttle *throttleTransport // The tenant's write budget, kept across requests
+}
+
+// tenantSet holds the tenants by name
+type tenantSet map[string]*Tenant
+
+// loadTenants reads and checks the tenants file; every token has to be set
+func loadTenants(path string) (tenantSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading tenants file %s: %w", path, err)
+	}
+	if data, err = stripJSONC(data); err != nil {
+		return nil, fmt.Errorf("error reading tenants file %s: %w", path, err)
+	}
+	var list []*Tenant
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("error unmarshalling tenants JSON: %w", err)
+	}
+	tenants := make(tenantSet, len(list))
+	for i, tenant := range list {
+		switch {
+		case tenant.Name == "":
+			return nil, fmt.Errorf("tenant #%d has no name", i+1)
+		case tenants[tenant.Name] != nil:
+			return nil, fmt.Errorf("tenant '%s' is defined more than once", tenant.Name)
+		case tenant.TokenEnv == "":
+			return nil, fmt.Errorf("tenant '%s' has no token_env", tenant.Name)
+		case len(tenant.Owners) == 0:
+			return nil, fmt.Errorf("tenant '%s' lists no owners it may target", tenant.Name)
+		}
+		if info, err := os.Stat(tenant.Dir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("dir of tenant '%s' is not a directory: %q", tenant.Name, tenant.Dir)
+		}
+		if tenant.dir, err = filepath.Abs(tenant.Dir); err == nil {
+			tenant.dir, err = filepath.EvalSymlinks(tenant.dir)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("dir of tenant '%s': %w", tenant.Name, err)
+		}
+		if tenant.StatePrefix != "" && !strings.HasPrefix(tenant.StatePrefix, "s3://") {
+			return nil, fmt.Errorf("state_prefix of tenant '%s' is not an s3:// location: %q", tenant.Name, tenant.StatePrefix)
+		}
+		tenant.APIURL = strings.TrimSuffix(tenant.APIURL, "/")
+		if tenant.token = os.Getenv(tenant.TokenEnv); tenant.token == "" {
+			return nil, fmt.Errorf("token of tenant '%s': environment variable %s is not set", tenant.Name, tenant.TokenEnv)
+		}
+		secrets.add(tenant.token)
+		tenants[tenant.Name] = tenant
+	}
+	log.Printf("Serving %d tenants from %s.", len(tenants), path)
+	return tenants, nil
+}
+
+// servesTenants reports whether the command line starts a multi-tenant --json-rpc server,
+// which takes its tokens from the tenants instead of GITHUB_TOKEN
+func servesTenants(args []string) bool {
+	if len(args) == 0 || args[0] != "--json-rpc" {
+		return false
+	}
+	for _, arg := range args[1:] {
+		if arg == "--tenants" || strings.HasPrefix(arg, "--tenants=") || arg == "-tenants" || strings.HasPrefix(arg, "-tenants=") {
+			return true
+		}
+	}
+	return false
+}
+
+// enter switches the process to the tenant for one request: its token, API, directory, write
+// budget and log. The returned function switches back. Requests are handled one at a
+// time, so nothing of one tenant is seen by another.
+func (t *Tenant) enter(logOutput io.Writer) (leave func(), err error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("error switching to tenant '%s': %w", t.Name, err)
+	}
+	if err := os.Chdir(t.Dir); err != nil {
+		return nil, fmt.Errorf("error switching to tenant '%s': %w", t.Name, err)
+	}
+	var logFile *os.File
+	if t.LogFile != "" {
+		if logFile, err = os.OpenFile(t.LogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600); err != nil {
+			os.Chdir(dir)
+			return nil, fmt.Errorf("error opening log file of tenant '%s': %w", t.Name, err)
+		}
+		log.SetOutput(io.MultiWriter(logOutput, redacted(logFile)))
+	}
+	token, apiBaseURL, throttle := githubToken, githubAPIBaseURL, writeThrottle
+	githubToken, writeThrottle = t.token, t.throttle
+	if t.APIURL != "" {
+		githubAPIBaseURL = t.APIURL
+	}
+
+	return func() {
+		t.throttle = writeThrottle
+		githubToken, githubAPIBaseURL, writeThrottle = token, apiBaseURL, throttle
+		if logFile != nil {
+			log.SetOutput(logOutput)
+			logFile.Close()
+		}
+		if err := os.Chdir(dir); err != nil {
+			log.Printf("Warning: Could not return to %s after a request of tenant '%s': %v", dir, t.Name, err)
+		}
+	}, nil
+}
+
+// checkTargetOwners rejects targets outside the owners a tenant may change; a nil tenant
+// allows every target
+func (t *Tenant) checkTargetOwners(targets []repoTarget) error {
+	if t == nil {
+		return nil
+	}
+	for _, target := range targets {
+		if !containsFold(t.Owners, target.Owner) {
+			return fmt.Errorf("%s is outside the owners this tenant may target (%s)", target, strings.Join(t.Owners, ", "))
+		}
+	}
+	return nil
+}
+
+// checkPath rejects files outside the tenant's directory, so one tenant cannot read or
+// write the definitions and state of another; stdin and empty paths are not files
+func (t *Tenant) checkPath(what, path string) error {
+	if t == nil || path == "" || path == stdinPath {
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("%s %q: %w", what, path, err)
+	}
+	abs = resolveSymlinks(abs)
+	if rel, err := filepath.Rel(t.dir, abs); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s %q is outside the directory of tenant '%s'", what, path, t.Name)
+	}
+	return nil
+}
+
+// checkPaths checks the files named by flags with checkPath, in the order of the flag names
+func (t *Tenant) checkPaths(paths map[string]string) error {
+	for _, name := range sortedKeys(paths) {
+		if err := t.checkPath(name, paths[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveSymlinks resolves the symlinks of the longest part of an absolute path that exists,
+// so files still to be written compare like existing ones
+func resolveSymlinks(path string) string {
+	rest := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		if filepath.Dir(dir) == dir {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// checkState rejects run state the tenant does not own: directories outside its own,
+// and S3 locations outside its state_prefix, as the AWS credentials are the process's
+func (t *Tenant) checkState(location string) error {
+	if t == nil {
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(location, "s3://"):
+		prefix := strings.TrimSuffix(t.StatePrefix, "/")
+		if prefix == "" || (location != prefix && !strings.HasPrefix(location, prefix+"/")) {
+			return fmt.Errorf("--state %q is outside the state_prefix of tenant '%s'", location, t.Name)
+		}
+		return nil
+	case location == "github-branch" || strings.HasPrefix(location, "github-branch:"):
+		return nil // Kept in the target repositories, which are checked against the owners
+	}
+	return t.checkPath("--state", strings.TrimPrefix(location, "file://"))
+}
+
+// checkAPIHost rejects repositories on a host the tenant's API does not serve, which
+// would otherwise receive the tenant's token; a nil tenant switches to any host
+func (t *Tenant) checkAPIHost(host string) error {
+	if t == nil || strings.EqualFold(github.BaseURLForHost(host), githubAPIBaseURL) {
+		return nil
+	}
+	return fmt.Errorf("%s is not served by the API of tenant '%s' (%s)", host, t.Name, githubAPIBaseURL)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testTenant returns a tenant owning a temporary directory, the working directory of the test
+func testTenant(t *testing.T) *Tenant {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, "acme")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return &Tenant{Name: "acme", Owners: []string{"acme"}, Dir: dir, dir: resolved, StatePrefix: "s3://states/acme"}
+}
+
+func TestTenantCheckPath(t *testing.T) {
+	tenant := testTenant(t)
+	for _, path := range []string{"labels.json", "backlog/*.json", filepath.Join(tenant.dir, "issues.json"), "-", ""} {
+		if err := tenant.checkPath("--issues", path); err != nil {
+			t.Errorf("checkPath(%q) = %v, want nil", path, err)
+		}
+	}
+	for _, path := range []string{"../other/labels.json", filepath.Join(filepath.Dir(tenant.dir), "other"), "/etc/passwd"} {
+		if err := tenant.checkPath("--issues", path); err == nil {
+			t.Errorf("checkPath(%q) = nil, want an error", path)
+		}
+	}
+}
+
+func TestTenantCheckState(t *testing.T) {
+	tenant := testTenant(t)
+	for _, location := range []string{"", "state", "file://state", "s3://states/acme", "s3://states/acme/prod", "github-branch:setup-state"} {
+		if err := tenant.checkState(location); err != nil {
+			t.Errorf("checkState(%q) = %v, want nil", location, err)
+		}
+	}
+	for _, location := range []string{"../other", "file:///srv/other", "s3://states/acme-other", "s3://states/other", "s3://other/acme"} {
+		if err := tenant.checkState(location); err == nil {
+			t.Errorf("checkState(%q) = nil, want an error", location)
+		}
+	}
+}
+
+func TestTenantCheckAPIHost(t *testing.T) {
+	saved := githubAPIBaseURL
+	t.Cleanup(func() { githubAPIBaseURL = saved })
+	githubAPIBaseURL = "https://api.github.com"
+	tenant := &Tenant{Name: "acme"}
+	if err := tenant.checkAPIHost("github.com"); err != nil {
+		t.Errorf("checkAPIHost(github.com) = %v, want nil", err)
+	}
+	if err := tenant.checkAPIHost("attacker.example"); err == nil {
+		t.Error("checkAPIHost(attacker.example) = nil, want an error")
+	}
+	var none *Tenant
+	if err := none.checkAPIHost("ghes.example"); err != nil {
+		t.Errorf("checkAPIHost without a tenant = %v, want nil", err)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/engine"
+)
+
+// ThrottleConfig paces the writes of a run, so seeding a busy repository does not flood
+// its team with notifications during working hours
+type ThrottleConfig struct {
+	// Writes are only sent inside this daily window, e.g. 22:00-06:00; outside it the run waits
+	WriteWindow *WriteWindow `json:"write_window,omitempty"`
+	// At most this many writes within any hour (0 for no limit)
+	MaxWritesPerHour int `json:"max_writes_per_hour,omitempty"`
+}
+
+// WriteWindow is a daily time range in the time zone of the repository owners
+type WriteWindow struct {
+	From     string `json:"from"`                // e.g. "22:00"
+	To       string `json:"to"`                  // e.g. "06:00"; before From, the window spans midnight
+	TimeZone string `json:"time_zone,omitempty"` // IANA name such as "Europe/Berlin", UTC by default
+
+	from, to time.Duration // Parsed by validate, since midnight
+	location *time.Location
+}
+
+// validate parses the window and checks the budget
+func (c *ThrottleConfig) validate() error {
+	if c.MaxWritesPerHour < 0 {
+		return fmt.Errorf("throttle.max_writes_per_hour must not be negative")
+	}
+	w := c.WriteWindow
+	if w == nil {
+		return nil
+	}
+	for _, bound := range []struct {
+		name, value string
+		parsed      *time.Duration
+	}{{"from", w.From, &w.from}, {"to", w.To, &w.to}} {
+		clock, err := time.Parse("15:04", bound.value)
+		if err != nil {
+			return fmt.Errorf("throttle.write_window.%s must be a time such as 22:00, got %q", bound.name, bound.value)
+		}
+		*bound.parsed = time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute
+	}
+	if w.from == w.to {
+		return fmt.Errorf("throttle.write_window needs different from and to times")
+	}
+	w.location = time.UTC
+	if w.TimeZone != "" {
+		location, err := time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return fmt.Errorf("throttle.write_window.time_zone: %w", err)
+		}
+		w.location = location
+	}
+	return nil
+}
+
+// nextOpen returns now when it is inside the window, otherwise when the window opens next
+func (w *WriteWindow) nextOpen(now time.Time) time.Time {
+	local := now.In(w.location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, w.location)
+	since := local.Sub(midnight)
+	open := since >= w.from && since < w.to
+	if w.from > w.to {
+		open = since >= w.from || since < w.to
+	}
+	if open {
+		return now
+	}
+	opens := time.Date(local.Year(), local.Month(), local.Day(), int(w.from/time.Hour), int(w.from%time.Hour/time.Minute), 0, 0, w.location)
+	if !opens.After(local) {
+		opens = opens.AddDate(0, 0, 1)
+	}
+	return opens
+}
+
+// throttleTransport holds back writes outside the write window and beyond the hourly budget.
+// Reads are never held back, so planning and lookups run at full speed.
+type throttleTransport struct {
+	next http.RoundTripper
+
+	mu     sync.Mutex
+	config ThrottleConfig
+	writes []time.Time // Writes of the last hour, oldest first
+}
+
+// writeThrottle is created once; later runs of a long-lived process update its config and
+// keep its write history
+var writeThrottle *throttleTransport
+
+// enableWriteThrottle puts the throttle in front of the API client when one is configured
+func enableWriteThrottle(config ThrottleConfig) {
+	if writeThrottle != nil {
+		writeThrottle.mu.Lock()
+		writeThrottle.config = config
+		writeThrottle.mu.Unlock()
+		// A --json-rpc request starts from the client without it
+		if httpClient.Transport != writeThrottle {
+			writeThrottle.next = orDefaultTransport(httpClient.Transport)
+			httpClient.Transport = writeThrottle
+		}
+		return
+	}
+	if config.WriteWindow == nil && config.MaxWritesPerHour == 0 {
+		return
+	}
+	writeThrottle = &throttleTransport{next: orDefaultTransport(httpClient.Transport), config: config}
+	httpClient.Transport = writeThrottle
+	if w := config.WriteWindow; w != nil {
+		log.Printf("Write throttle: writes only between %s and %s (%s).", w.From, w.To, w.location)
+	}
+	if config.MaxWritesPerHour > 0 {
+		log.Printf("Write throttle: at most %d writes per hour.", config.MaxWritesPerHour)
+	}
+}
+
+func (t *throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if checkReadOnly(req) != nil {
+		if err := t.wait(req.Context()); err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// wait blocks until a write may be sent, or ctx ends (e.g. at --run-deadline)
+func (t *throttleTransport) wait(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		t.mu.Lock()
+		now := time.Now()
+		until, reason := now, ""
+		if w := t.config.WriteWindow; w != nil {
+			if opens := w.nextOpen(now); opens.After(until) {
+				until, reason = opens, fmt.Sprintf("the write window opens at %s %s", w.From, w.location)
+			}
+		}
+		if limit := t.config.MaxWritesPerHour; limit > 0 {
+			for len(t.writes) > 0 && now.Sub(t.writes[0]) >= time.Hour {
+				t.writes = t.writes[1:]
+			}
+			if len(t.writes) >= limit {
+				if free := t.writes[len(t.writes)-limit].Add(time.Hour); free.After(until) {
+					until, reason = free, fmt.Sprintf("%d writes within the last hour", len(t.writes))
+				}
+			}
+		}
+		if !until.After(now) {
+			t.writes = append(t.writes, now)
+			t.mu.Unlock()
+			return nil
+		}
+		t.mu.Unlock()
+
+		log.Printf("Write throttle: waiting %s, %s.", until.Sub(now).Round(time.Second), reason)
+		engine.Report(ctx, engine.Event{Kind: engine.EventWait, Wait: until.Sub(now), Reason: reason})
+		timer := time.NewTimer(until.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
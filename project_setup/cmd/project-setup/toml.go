@@ -0,0 +1,446 @@
+package main
+
+// A small TOML reader for definition files: tables, arrays of tables, dotted keys, basic
+// and literal strings (also multi-line), numbers, booleans, arrays and inline tables.
+// Dates and times are read as strings, in the format due_on expects them. Documents are
+// decoded with decodeTree, like YAML, so the structs' json tags name the keys.
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// decodeTOML parses a TOML definition file and stores its definitions, the array of tables
+// named table, in out
+func decodeTOML(data []byte, table string, out interface{}) error {
+	definitions, err := parseTOMLDefinitions(string(data), table)
+	if err != nil {
+		return err
+	}
+	return decodeTree(definitions, out)
+}
+
+// parseTOMLDefinitions parses a TOML definition file, whose definitions are the array of
+// tables of its only key, table, e.g. [[labels]] or [[issues]]
+func parseTOMLDefinitions(src, table string) (interface{}, error) {
+	doc, err := parseTOML(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc) == 0 {
+		return []interface{}{}, nil
+	}
+	if list, ok := doc[table].([]interface{}); ok && len(doc) == 1 {
+		return list, nil
+	}
+	return nil, fmt.Errorf("expected a single array of tables [[%s]], found the keys %s", table, strings.Join(sortedKeys(doc), ", "))
+}
+
+// tomlDatePattern matches TOML dates and times, which are read as strings
+var tomlDatePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?([Zz]|[+-]\d{2}:\d{2})?)?$|^\d{2}:\d{2}:\d{2}(\.\d+)?$`)
+
+// tomlParser parses a TOML document into the same trees as parseYAML: maps, slices,
+// strings, booleans, int64 and float64
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+	// Tables defined by a header, which cannot be defined again
+	defined map[string]bool
+}
+
+func parseTOML(src string) (map[string]interface{}, error) {
+	p := &tomlParser{src: strings.ReplaceAll(src, "\r\n", "\n"), line: 1, defined: make(map[string]bool)}
+	root := make(map[string]interface{})
+	current := root
+	for {
+		p.skipBlankLines()
+		if p.pos == len(p.src) {
+			return root, nil
+		}
+		var err error
+		if p.src[p.pos] == '[' {
+			current, err = p.header(root)
+		} else {
+			err = p.keyValue(current)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("toml: line %d: %w", p.line, err)
+		}
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == '#' {
+			p.skipComment()
+		}
+		if p.pos < len(p.src) && p.src[p.pos] != '\n' {
+			return nil, fmt.Errorf("toml: line %d: unexpected %q", p.line, p.rest())
+		}
+	}
+}
+
+// rest is what is left of the current line, for messages
+func (p *tomlParser) rest() string {
+	end := strings.IndexByte(p.src[p.pos:], '\n')
+	if end < 0 {
+		return p.src[p.pos:]
+	}
+	return p.src[p.pos : p.pos+end]
+}
+
+func (p *tomlParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+		p.pos++
+	}
+}
+
+// skipBlankLines skips whitespace, newlines and comments
+func (p *tomlParser) skipBlankLines() {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t':
+			p.pos++
+		case '\n':
+			p.pos++
+			p.line++
+		case '#':
+			p.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+// header parses [table] or [[array of tables]] and returns the table that follows
+func (p *tomlParser) header(root map[string]interface{}) (map[string]interface{}, error) {
+	array := strings.HasPrefix(p.src[p.pos:], "[[")
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+	keys, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return nil, fmt.Errorf("expected %s after the table name", closing)
+	}
+	p.pos += len(closing)
+
+	table := root
+	for _, key := range keys[:len(keys)-1] {
+		if table, err = tomlSubtable(table, key); err != nil {
+			return nil, err
+		}
+	}
+	last := keys[len(keys)-1]
+	if array {
+		list, ok := table[last].([]interface{})
+		if _, exists := table[last]; exists && !ok {
+			return nil, fmt.Errorf("%s is already defined and not an array of tables", strings.Join(keys, "."))
+		}
+		entry := make(map[string]interface{})
+		table[last] = append(list, entry)
+		return entry, nil
+	}
+	name := strings.Join(keys, "\x00")
+	if p.defined[name] {
+		return nil, fmt.Errorf("table %s is defined twice", strings.Join(keys, "."))
+	}
+	p.defined[name] = true
+	return tomlSubtable(table, last)
+}
+
+// tomlSubtable returns the table under key, created when missing; for an array of tables,
+// its last entry
+func tomlSubtable(table map[string]interface{}, key string) (map[string]interface{}, error) {
+	switch v := table[key].(type) {
+	case nil:
+		sub := make(map[string]interface{})
+		table[key] = sub
+		return sub, nil
+	case map[string]interface{}:
+		return v, nil
+	case []interface{}:
+		if len(v) > 0 {
+			if last, ok := v[len(v)-1].(map[string]interface{}); ok {
+				return last, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s is already defined as a value", key)
+}
+
+// key parses a dotted key of bare and quoted parts
+func (p *tomlParser) key() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		if p.pos == len(p.src) {
+			return nil, fmt.Errorf("expected a key")
+		}
+		switch c := p.src[p.pos]; {
+		case c == '"' || c == '\'':
+			s, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, s)
+		default:
+			start := p.pos
+			for p.pos < len(p.src) && isTOMLBareKeyChar(p.src[p.pos]) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, fmt.Errorf("expected a key, found %q", p.rest())
+			}
+			keys = append(keys, p.src[start:p.pos])
+		}
+		p.skipSpace()
+		if p.pos == len(p.src) || p.src[p.pos] != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func isTOMLBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// keyValue parses key = value into table
+func (p *tomlParser) keyValue(table map[string]interface{}) error {
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	if p.pos == len(p.src) || p.src[p.pos] != '=' {
+		return fmt.Errorf("expected '=' after %s", strings.Join(keys, "."))
+	}
+	p.pos++
+	value, err := p.value()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys[:len(keys)-1] {
+		if table, err = tomlSubtable(table, key); err != nil {
+			return err
+		}
+	}
+	last := keys[len(keys)-1]
+	if _, exists := table[last]; exists {
+		return fmt.Errorf("%s is defined twice", strings.Join(keys, "."))
+	}
+	table[last] = value
+	return nil
+}
+
+// value parses a string, array, inline table, boolean, number or date
+func (p *tomlParser) value() (interface{}, error) {
+	p.skipSpace()
+	if p.pos == len(p.src) {
+		return nil, fmt.Errorf("expected a value")
+	}
+	switch p.src[p.pos] {
+	case '"', '\'':
+		return p.str()
+	case '[':
+		return p.array()
+	case '{':
+		return p.inlineTable()
+	}
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(" \t\n,]}#", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	// A local date-time may separate the date and time with a space
+	if tomlDatePattern.MatchString(p.src[start:p.pos]+"T00:00") && p.pos+1 < len(p.src) && p.src[p.pos] == ' ' && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9' {
+		p.pos++
+		for p.pos < len(p.src) && !strings.ContainsRune(" \t\n,]}#", rune(p.src[p.pos])) {
+			p.pos++
+		}
+	}
+	return resolveTOMLBare(p.src[start:p.pos])
+}
+
+// resolveTOMLBare types a bare value: booleans, dates (as strings) and numbers
+func resolveTOMLBare(token string) (interface{}, error) {
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "":
+		return nil, fmt.Errorf("expected a value")
+	}
+	if tomlDatePattern.MatchString(token) {
+		return token, nil
+	}
+	digits := strings.TrimPrefix(strings.ReplaceAll(token, "_", ""), "+")
+	for prefix, base := range map[string]int{"0x": 16, "0o": 8, "0b": 2} {
+		if strings.HasPrefix(digits, prefix) {
+			n, err := strconv.ParseInt(digits[2:], base, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", token)
+			}
+			return n, nil
+		}
+	}
+	if n, err := strconv.ParseInt(digits, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(digits, 64); err == nil && !strings.Contains(digits, "inf") && !strings.Contains(digits, "nan") {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid value %q (strings have to be quoted)", token)
+}
+
+func (p *tomlParser) array() (interface{}, error) {
+	p.pos++ // [
+	items := []interface{}{}
+	for {
+		p.skipBlankLines()
+		if p.pos == len(p.src) {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		if p.src[p.pos] == ']' {
+			p.pos++
+			return items, nil
+		}
+		item, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		p.skipBlankLines()
+		if p.pos < len(p.src) && p.src[p.pos] == ',' {
+			p.pos++
+		} else if p.pos < len(p.src) && p.src[p.pos] != ']' {
+			return nil, fmt.Errorf("expected ',' or ']' in an array, found %q", p.rest())
+		}
+	}
+}
+
+func (p *tomlParser) inlineTable() (interface{}, error) {
+	p.pos++ // {
+	table := make(map[string]interface{})
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == '}' {
+		p.pos++
+		return table, nil
+	}
+	for {
+		if err := p.keyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos == len(p.src) {
+			return nil, fmt.Errorf("unterminated inline table")
+		}
+		switch p.src[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, fmt.Errorf("expected ',' or '}' in an inline table, found %q", p.rest())
+		}
+	}
+}
+
+// str parses a basic or literal string, single- or multi-line
+func (p *tomlParser) str() (string, error) {
+	quote := p.src[p.pos]
+	multi := strings.HasPrefix(p.src[p.pos:], strings.Repeat(string(quote), 3))
+	if multi {
+		p.pos += 3
+		// A newline right after the opening delimiter is not part of the string
+		if p.pos < len(p.src) && p.src[p.pos] == '\n' {
+			p.pos++
+			p.line++
+		}
+	} else {
+		p.pos++
+	}
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case multi && strings.HasPrefix(p.src[p.pos:], strings.Repeat(string(quote), 3)):
+			p.pos += 3
+			// Up to two more quotes right before the delimiter belong to the string
+			for i := 0; i < 2 && p.pos < len(p.src) && p.src[p.pos] == quote; i++ {
+				b.WriteByte(quote)
+				p.pos++
+			}
+			return b.String(), nil
+		case !multi && c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\n' && !multi:
+			return "", fmt.Errorf("unterminated string")
+		case c == '\\' && quote == '"':
+			if err := p.escape(&b, multi); err != nil {
+				return "", err
+			}
+		default:
+			if c == '\n' {
+				p.line++
+			}
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// escape handles a backslash in a basic string; in a multi-line one, a backslash at the
+// end of a line trims the line break and the whitespace after it
+func (p *tomlParser) escape(b *strings.Builder, multi bool) error {
+	if p.pos+1 >= len(p.src) {
+		return fmt.Errorf("unterminated string")
+	}
+	c := p.src[p.pos+1]
+	if multi && (c == '\n' || c == ' ' || c == '\t') {
+		rest := strings.TrimLeft(p.src[p.pos+1:], " \t")
+		if strings.HasPrefix(rest, "\n") {
+			p.pos++
+			for p.pos < len(p.src) && strings.ContainsRune(" \t\n", rune(p.src[p.pos])) {
+				if p.src[p.pos] == '\n' {
+					p.line++
+				}
+				p.pos++
+			}
+			return nil
+		}
+	}
+	if e, ok := map[byte]string{'n': "\n", 't': "\t", 'r': "\r", '"': "\"", '\\': "\\", 'b': "\b", 'f': "\f", 'e': "\x1b"}[c]; ok {
+		b.WriteString(e)
+		p.pos += 2
+		return nil
+	}
+	digits := map[byte]int{'u': 4, 'U': 8}[c]
+	if digits == 0 || p.pos+2+digits > len(p.src) {
+		return fmt.Errorf("invalid escape \\%c", c)
+	}
+	code, err := strconv.ParseUint(p.src[p.pos+2:p.pos+2+digits], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return fmt.Errorf("invalid escape \\%s", p.src[p.pos+1:p.pos+2+digits])
+	}
+	b.WriteRune(rune(code))
+	p.pos += 2 + digits
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeTOML(t *testing.T) {
+	due := "2026-11-01T00:00:00Z"
+	tests := []struct {
+		name, in string
+		want     []MilestoneData
+	}{
+		{"empty document", "# no milestones yet\n", []MilestoneData{}},
+		{"array of tables", "[[milestones]]\ntitle = \"v1.0\"\ndue_on = 2026-11-01T00:00:00Z\n\n[[milestones]]\ntitle = 'v2.0' # later\n", []MilestoneData{
+			{Title: "v1.0", DueOn: &due},
+			{Title: "v2.0"},
+		}},
+		{"multi-line string", "[[milestones]]\ntitle = \"v1.0\"\ndescription = \"\"\"\nFirst release.\nNo breaking changes.\"\"\"\n", []MilestoneData{
+			{Title: "v1.0", Description: "First release.\nNo breaking changes."},
+		}},
+		{"inline empty array", "milestones = []\n", []MilestoneData{}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []MilestoneData
+			if err := decodeTOML([]byte(tc.in), "milestones", &got); err != nil {
+				t.Fatalf("decodeTOML() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("decodeTOML() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name, in, wantErr string
+	}{
+		{"table of another kind", "[[labels]]\nname = \"bug\"\n", "expected a single array of tables [[milestones]], found the keys labels"},
+		{"second array of tables", "[[milestones]]\ntitle = \"v1.0\"\n\n[[labels]]\nname = \"bug\"\n", "found the keys labels, milestones"},
+		{"table instead of an array of tables", "[milestones]\ntitle = \"v1.0\"\n", "expected a single array of tables [[milestones]]"},
+		{"key outside the tables", "version = 1\n\n[[milestones]]\ntitle = \"v1.0\"\n", "found the keys milestones, version"},
+		{"duplicate key", "[[milestones]]\ntitle = \"v1.0\"\ntitle = \"v2.0\"\n", "line 3"},
+		{"unterminated string", "[[milestones]]\ntitle = \"v1.0\n", "line 2"},
+		{"wrong type", "[[milestones]]\ntitle = [\"v1.0\"]\n", "title"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []MilestoneData
+			err := decodeTOML([]byte(tc.in), "milestones", &got)
+			if err == nil {
+				t.Fatalf("decodeTOML() = %+v, want an error", got)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("decodeTOML() error = %q, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
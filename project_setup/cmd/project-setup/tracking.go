@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// Tracking issues list the issues of a milestone as a task list
+const (
+	trackingIssueTitle = "Tracking: %s"
+	// The marker identifies a tracking issue among the milestone's issues, so a
+	// renamed tracking issue is still updated instead of duplicated
+	trackingIssueMarker = "<!-- project-setup:tracking-issue -->"
+)
+
+// GitHubIssueUpdateRequest is the payload for updating an issue
+type GitHubIssueUpdateRequest struct {
+	Body string `json:"body"`
+}
+
+// updateIssueBody replaces the body of an existing issue
+func updateIssueBody(ctx context.Context, t repoTarget, issueNumber int, body string) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d", githubAPIBaseURL, t.Owner, t.Repo, issueNumber)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "PATCH", url, GitHubIssueUpdateRequest{Body: body})
+	if err != nil {
+		return fmt.Errorf("error sending update request for issue #%d: %w", issueNumber, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error updating issue #%d: %w", issueNumber, github.NewAPIError(resp, bodyBytes))
+	}
+	return nil
+}
+
+// createTrackingIssue opens the tracking issue of a milestone
+func createTrackingIssue(ctx context.Context, t repoTarget, title string, milestoneID int, body string) (github.Issue, error) {
+	var created github.Issue
+	url := fmt.Sprintf("%s/repos/%s/%s/issues", githubAPIBaseURL, t.Owner, t.Repo)
+	payload := github.IssueRequest{Title: fmt.Sprintf(trackingIssueTitle, title), Body: body, Milestone: &milestoneID}
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "POST", url, payload)
+	if err != nil {
+		return created, fmt.Errorf("error sending create request for tracking issue of '%s': %w", title, err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return created, fmt.Errorf("error creating tracking issue of '%s': %w", title, github.NewAPIError(resp, bodyBytes))
+	}
+	if err := json.Unmarshal(bodyBytes, &created); err != nil {
+		return created, fmt.Errorf("error unmarshalling created tracking issue of '%s': %w", title, err)
+	}
+	return created, nil
+}
+
+// trackingIssueBody renders the task list of a milestone's issues; closed issues are checked
+func trackingIssueBody(title string, issues []github.Issue) string {
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Number < issues[j].Number })
+	var b strings.Builder
+	b.WriteString(trackingIssueMarker + "\n")
+	fmt.Fprintf(&b, "Issues of the milestone **%s**. This list is regenerated by the project setup, edits will be overwritten.\n\n", title)
+	for _, issue := range issues {
+		check := " "
+		if issue.State == "closed" {
+			check = "x"
+		}
+		// GitHub links the #number, so titles with brackets need no escaping
+		fmt.Fprintf(&b, "- [%s] #%d %s\n", check, issue.Number, issue.Title)
+	}
+	return b.String()
+}
+
+// splitTrackingIssue separates the tracking issue of a milestone from the issues it tracks.
+// Pull requests in the milestone are left out.
+func splitTrackingIssue(issues []github.Issue) (tracking *github.Issue, tracked []github.Issue) {
+	for i, issue := range issues {
+		switch {
+		case issue.PullRequest != nil:
+		case strings.HasPrefix(issue.Body, trackingIssueMarker):
+			tracking = &issues[i]
+		default:
+			tracked = append(tracked, issue)
+		}
+	}
+	return tracking, tracked
+}
+
+// hasMilestoneIssues reports whether any issue definition is assigned to the milestone
+func hasMilestoneIssues(issues []IssueData, title string) bool {
+	for _, issue := range issues {
+		if issue.MilestoneTitle != nil && milestoneKey(*issue.MilestoneTitle) == milestoneKey(title) {
+			return true
+		}
+	}
+	return false
+}
+
+// syncTrackingIssues creates or refreshes one tracking issue per defined milestone
+func syncTrackingIssues(ctx context.Context, run *repoRun, milestones []MilestoneData, milestoneTitleToIDMap map[string]int) (entityCounts, error) {
+	var counts entityCounts
+	t := run.target
+	log.Printf("--- Updating Tracking Issues ---")
+
+	for _, milestone := range milestones {
+		number, ok, err := lookupMilestoneID(milestoneTitleToIDMap, milestone.Title)
+		if err != nil {
+			run.failed("Failed to update the tracking issue: %v", err)
+			counts.Failed++
+			continue
+		}
+		if !ok {
+			continue // Failed to create, already reported
+		}
+		issues, err := listMilestoneIssues(ctx, t, number)
+		if err != nil {
+			run.failed("Failed to list issues of milestone '%s': %v", milestone.Title, err)
+			counts.Failed++
+			continue
+		}
+
+		tracking, tracked := splitTrackingIssue(issues)
+		body := trackingIssueBody(milestone.Title, tracked)
+		switch {
+		case tracking == nil && len(tracked) == 0:
+			log.Printf("Milestone \"%s\" has no issues, no tracking issue needed.", milestone.Title)
+		case tracking == nil:
+			created, err := createTrackingIssue(ctx, t, milestone.Title, number, body)
+			if err != nil {
+				run.failed("Failed to create tracking issue of milestone '%s': %v", milestone.Title, err)
+				counts.Failed++
+				continue
+			}
+			log.Printf("Created tracking issue #%d for milestone \"%s\".", created.Number, milestone.Title)
+			counts.Created++
+			time.Sleep(requestDelay)
+		case !sameMarkdown(tracking.Body, body):
+			if err := updateIssueBody(ctx, t, tracking.Number, body); err != nil {
+				run.failed("Failed to update tracking issue #%d: %v", tracking.Number, err)
+				counts.Failed++
+				continue
+			}
+			log.Printf("Updated tracking issue #%d for milestone \"%s\".", tracking.Number, milestone.Title)
+			counts.Updated++
+			time.Sleep(requestDelay)
+		default:
+			log.Printf("Tracking issue #%d for milestone \"%s\" is up to date.", tracking.Number, milestone.Title)
+		}
+	}
+	log.Printf("Finished tracking issues. Created %d, updated %d.", counts.Created, counts.Updated)
+	return counts, nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// varsSchemaPath is the optional catalog of the template variables
+const varsSchemaPath = "vars.schema.json"
+
+// VariableSpec declares one template variable in vars.schema.json
+type VariableSpec struct {
+	Type        string   `json:"type,omitempty"`     // string (default), integer, boolean, date (YYYY-MM-DD) or enum
+	Values      []string `json:"values,omitempty"`   // Allowed values of an enum
+	Required    bool     `json:"required,omitempty"` // Every target needs a value, from config.json or --repos-file
+	Default     *string  `json:"default,omitempty"`  // Used when no value is given
+	Description string   `json:"description,omitempty"`
+}
+
+// variableCatalog maps variable names to their declarations; nil without vars.schema.json
+type variableCatalog map[string]VariableSpec
+
+// loadVariableCatalog reads and checks vars.schema.json; a missing file at the default
+// path means the variables are not checked
+func loadVariableCatalog(path string) (variableCatalog, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && path == varsSchemaPath {
+		return nil, nil
+	}
+	if err == nil {
+		data, err = stripJSONC(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading variable schema %s: %w", path, err)
+	}
+	var schema struct {
+		Variables variableCatalog `json:"variables"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("error unmarshalling variable schema %s: %w", path, err)
+	}
+	var problems []error
+	for _, name := range schema.Variables.names() {
+		spec := schema.Variables[name]
+		switch spec.Type {
+		case "", "string", "integer", "boolean", "date":
+			if len(spec.Values) > 0 {
+				problems = append(problems, fmt.Errorf("variable '%s' lists values but is not an enum", name))
+			}
+		case "enum":
+			if len(spec.Values) == 0 {
+				problems = append(problems, fmt.Errorf("enum variable '%s' lists no values", name))
+			}
+		default:
+			problems = append(problems, fmt.Errorf("variable '%s' has unknown type '%s', expected string, integer, boolean, date or enum", name, spec.Type))
+			continue
+		}
+		if spec.Default != nil {
+			if err := spec.check(*spec.Default); err != nil {
+				problems = append(problems, fmt.Errorf("default of variable '%s': %w", name, err))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("error in variable schema %s: %w", path, errors.Join(problems...))
+	}
+	log.Printf("Read %d variable declarations from %s.", len(schema.Variables), path)
+	return schema.Variables, nil
+}
+
+// names returns the declared variables in order
+func (c variableCatalog) names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// check rejects a value that does not have the declared type
+func (s VariableSpec) check(value string) error {
+	switch s.Type {
+	case "integer":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+	case "boolean":
+		if value != "true" && value != "false" {
+			return fmt.Errorf("%q is not a boolean, expected true or false", value)
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return fmt.Errorf("%q is not a date, expected YYYY-MM-DD", value)
+		}
+	case "enum":
+		for _, allowed := range s.Values {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", value, strings.Join(s.Values, ", "))
+	}
+	return nil
+}
+
+// checkValues type-checks one set of variables and reports undeclared ones and required
+// ones with neither a value nor a default
+func (c variableCatalog) checkValues(vars map[string]string) []string {
+	var problems []string
+	for _, name := range c.names() {
+		spec := c[name]
+		value, ok := vars[name]
+		switch {
+		case ok:
+			if err := spec.check(value); err != nil {
+				problems = append(problems, fmt.Sprintf("variable '%s': %v", name, err))
+			}
+		case spec.Required && spec.Default == nil:
+			problems = append(problems, fmt.Sprintf("required variable '%s' is not set", name))
+		}
+	}
+	var undeclared []string
+	for name := range vars {
+		if _, ok := c[name]; !ok {
+			undeclared = append(undeclared, name)
+		}
+	}
+	sort.Strings(undeclared)
+	for _, name := range undeclared {
+		problems = append(problems, fmt.Sprintf("variable '%s' is not declared in the variable schema", name))
+	}
+	return problems
+}
+
+// check type-checks the variables every target gets, config.json's with the --repos-file
+// overrides; without targets only config.json's. A problem shared by several targets is
+// reported once, naming them.
+func (c variableCatalog) check(base map[string]string, overrides repoVariableOverrides, targets []repoTarget) error {
+	if c == nil {
+		return nil
+	}
+	if len(targets) == 0 {
+		if problems := c.checkValues(base); len(problems) > 0 {
+			return fmt.Errorf("invalid template variables: %s", strings.Join(problems, "; "))
+		}
+		return nil
+	}
+	var order []string
+	affected := make(map[string][]string)
+	for _, t := range targets {
+		vars := make(map[string]string, len(base)+len(overrides[t]))
+		for name, value := range base {
+			vars[name] = value
+		}
+		for name, value := range overrides[t] {
+			vars[name] = value
+		}
+		for _, problem := range c.checkValues(vars) {
+			if affected[problem] == nil {
+				order = append(order, problem)
+			}
+			affected[problem] = append(affected[problem], t.String())
+		}
+	}
+	if len(order) == 0 {
+		return nil
+	}
+	problems := make([]string, 0, len(order))
+	for _, problem := range order {
+		problems = append(problems, fmt.Sprintf("%s (%s)", problem, strings.Join(affected[problem], ", ")))
+	}
+	return fmt.Errorf("invalid template variables: %s", strings.Join(problems, "; "))
+}
+
+// withDefaults returns vars with the defaults of the variables that have no value
+func (c variableCatalog) withDefaults(vars map[string]string) map[string]string {
+	merged := make(map[string]string, len(vars)+len(c))
+	for name, spec := range c {
+		if spec.Default != nil {
+			merged[name] = *spec.Default
+		}
+	}
+	for name, value := range vars {
+		merged[name] = value
+	}
+	return merged
+}
+
+// applyVariableCatalog checks the variables of the targets and fills in the defaults
+func applyVariableCatalog(defs *definitions, targets []repoTarget) error {
+	if defs.Variables == nil {
+		return nil
+	}
+	if err := defs.Variables.check(config.Community.Variables, defs.RepoVariables, targets); err != nil {
+		return err
+	}
+	config.Community.Variables = defs.Variables.withDefaults(config.Community.Variables)
+	return nil
+}
+
+// runValidate checks config.json, the definitions and the template variables without
+// contacting GitHub, e.g. in a pull request changing them
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	var paths definitionPaths
+	paths.register(fs)
+	reposFile := fs.String("repos-file", "", "File listing target repositories whose variable overrides are checked too")
+	if _, err := parseArgs(fs, args); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	var err error
+	if config, err = loadConfig(configJSONPath); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defs, err := loadDefinitions(paths, labelFixes{}, nil)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	var targets []repoTarget
+	if *reposFile != "" {
+		repos, overrides, err := loadReposFile(*reposFile)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		for _, r := range repos {
+			t, _, err := parseRepoReference(r)
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			targets = append(targets, t)
+		}
+		defs.RepoVariables = overrides
+	}
+	if err := defs.Variables.check(config.Community.Variables, defs.RepoVariables, targets); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	log.Printf("Definitions are valid (template hash %s).", defs.Hash)
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// verifyMismatch is one way a created issue differs from its definition
+type verifyMismatch struct {
+	Repo   string `json:"repo"`
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Field  string `json:"field"` // "body", "labels" or "milestone"
+	Detail string `json:"detail"`
+}
+
+// verifyReport is the outcome of verify for every target
+type verifyReport struct {
+	Sampled    int              `json:"sampled"`
+	Created    int              `json:"created"` // Defined issues found in the repositories
+	Mismatches []verifyMismatch `json:"mismatches,omitempty"`
+	Errors     []string         `json:"errors,omitempty"`
+}
+
+// sampleCandidate is a defined issue that exists in the target
+type sampleCandidate struct {
+	index  int
+	number int
+	weight float64
+}
+
+// sampleWeight favors the issues most likely to come out wrong: long bodies, many labels
+// and bodies rendered from templates or forms
+func sampleWeight(issue IssueData, body string) float64 {
+	weight := 1 + float64(len(body))/1000 + float64(len(issue.Labels))/2
+	if issue.templateData != nil || issue.Form != "" {
+		weight *= 2
+	}
+	return weight
+}
+
+// weightedSample picks n candidates without replacement, each with a probability
+// proportional to its weight (Efraimidis-Spirakis: the n largest u^(1/w))
+func weightedSample(candidates []sampleCandidate, n int, rng *rand.Rand) []sampleCandidate {
+	keys := make([]float64, len(candidates))
+	for i, c := range candidates {
+		keys[i] = math.Pow(rng.Float64(), 1/c.weight)
+	}
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return keys[order[i]] > keys[order[j]] })
+	if n > len(order) {
+		n = len(order)
+	}
+	sample := make([]sampleCandidate, 0, n)
+	for _, i := range order[:n] {
+		sample = append(sample, candidates[i])
+	}
+	sort.Slice(sample, func(i, j int) bool { return sample[i].number < sample[j].number })
+	return sample
+}
+
+// getIssue fetches one issue as it is now
+func getIssue(ctx context.Context, t repoTarget, number int) (github.Issue, error) {
+	var issue github.Issue
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d", githubAPIBaseURL, t.Owner, t.Repo, number)
+	resp, bodyBytes, err := sendGitHubRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return issue, fmt.Errorf("error sending get issue request for #%d: %w", number, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return issue, fmt.Errorf("error getting issue #%d: %w", number, github.NewAPIError(resp, bodyBytes))
+	}
+	if err := json.Unmarshal(bodyBytes, &issue); err != nil {
+		return issue, fmt.Errorf("error unmarshalling issue #%d: %w", number, err)
+	}
+	return issue, nil
+}
+
+// bodyDifference describes where two bodies first differ, byte for byte
+func bodyDifference(have, want string) string {
+	if have == want {
+		return ""
+	}
+	i := 0
+	for i < len(have) && i < len(want) && have[i] == want[i] {
+		i++
+	}
+	line := strings.Count(want[:i], "\n") + 1
+	excerpt := func(s string) string {
+		end := min(i+40, len(s))
+		return s[i:end]
+	}
+	return fmt.Sprintf("differs at byte %d (line %d): %q, expected %q (%d bytes, expected %d)", i, line, excerpt(have), excerpt(want), len(have), len(want))
+}
+
+// labelDifference compares the labels of an issue with the expected ones, ignoring case and order
+func labelDifference(have []github.Label, want []string) string {
+	var missing, extra []string
+	names := make([]string, 0, len(have))
+	for _, label := range have {
+		names = append(names, label.Name)
+	}
+	for _, name := range want {
+		if !containsFold(names, name) {
+			missing = append(missing, name)
+		}
+	}
+	for _, name := range names {
+		if !containsFold(want, name) {
+			extra = append(extra, name)
+		}
+	}
+	if len(missing) == 0 && len(extra) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("missing %v, unexpected %v", missing, extra)
+}
+
+// verifyRepo checks a sample of the defined issues created in one target
+func verifyRepo(ctx context.Context, rp repoPlan, defs *definitions, options applyOptions, n int, rng *rand.Rand, report *verifyReport) error {
+	t := rp.Target
+	existing, err := getExistingIssues(ctx, t)
+	if err != nil {
+		return err
+	}
+	var data *templateData
+	if config.IssueBody.Templates {
+		d := newTemplateData(t, rp.Metadata, defs.RepoVariables)
+		data = &d
+	}
+	epics := epicChildren(defs.Issues)
+	var candidates []sampleCandidate
+	for index, issue := range defs.Issues {
+		created, ok := existing[issue.Title]
+		if !ok {
+			continue
+		}
+		issue.templateData, issue.muteMentions = data, options.MuteMentions
+		candidates = append(candidates, sampleCandidate{index: index, number: created.Number, weight: sampleWeight(issue, renderIssueBody(issue))})
+	}
+	sample := weightedSample(candidates, n, rng)
+	report.Created += len(candidates)
+	report.Sampled += len(sample)
+	log.Printf("Verifying %d of the %d defined issues found in %s...", len(sample), len(candidates), t)
+
+	for _, c := range sample {
+		issue := defs.Issues[c.index]
+		issue.templateData, issue.muteMentions = data, options.MuteMentions
+		created, err := getIssue(ctx, t, c.number)
+		if err != nil {
+			return err
+		}
+		mismatch := func(field, detail string) {
+			report.Mismatches = append(report.Mismatches, verifyMismatch{Repo: t.String(), Number: c.number, Title: issue.Title, Field: field, Detail: detail})
+		}
+		// Epics carry their synced task list
+		if _, isEpic := epics[c.index]; !isEpic {
+			if diff := bodyDifference(created.Body, renderIssueBody(issue)); diff != "" {
+				mismatch("body", diff)
+			}
+		}
+		labels := issue.Labels
+		milestone := ""
+		if issue.MilestoneTitle != nil {
+			milestone = *issue.MilestoneTitle
+		}
+		if options.MirrorMilestoneLabels && milestone != "" {
+			labels = append(append([]string(nil), labels...), milestoneLabelName(milestone))
+		}
+		if diff := labelDifference(created.Labels, labels); diff != "" {
+			mismatch("labels", diff)
+		}
+		actual := ""
+		if created.Milestone != nil {
+			actual = created.Milestone.Title
+		}
+		if milestoneKey(actual) != milestoneKey(milestone) {
+			mismatch("milestone", fmt.Sprintf("%q, expected %q", actual, milestone))
+		}
+		time.Sleep(requestDelay)
+	}
+	return nil
+}
+
+// writeVerifyReport prints the mismatches and a summary line
+func writeVerifyReport(w io.Writer, report verifyReport) {
+	for _, m := range report.Mismatches {
+		fmt.Fprintf(w, "%s#%d %q: %s %s\n", m.Repo, m.Number, m.Title, m.Field, m.Detail)
+	}
+	for _, e := range report.Errors {
+		fmt.Fprintf(w, "Error: %s\n", e)
+	}
+	fmt.Fprintf(w, "Verified %d of %d created issues: %d mismatches.\n", report.Sampled, report.Created, len(report.Mismatches))
+}
+
+// runVerify checks a weighted random sample of the created issues against their rendered
+// definitions, a fast sanity check after a large import. Nothing is changed.
+func runVerify(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	n := fs.Int("sample", 10, "Number of created issues to check per repository")
+	seed := fs.Int64("seed", 0, "Seed of the random sample, to check the same issues again (default: random)")
+	jsonOutput := fs.Bool("json", false, "Print the result as JSON")
+	var shared targetFlags
+	shared.register(fs)
+	layers, err := parseArgs(fs, args)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	shared.paths.Layers = layers
+	if *n < 1 {
+		log.Fatal("Error: --sample must be at least 1")
+	}
+	if err := shared.limits.check(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	ctx, cancel := shared.limits.start(ctx)
+	defer cancel()
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	log.Printf("Sample seed: %d", *seed)
+	rng := rand.New(rand.NewSource(*seed))
+
+	shared.readOnly = true
+	prepared, err := shared.prepare(ctx)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	var report verifyReport
+	for _, rp := range prepared.plans {
+		if err := verifyRepo(ctx, rp, prepared.defs, shared.options, *n, rng, &report); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", rp.Target, err))
+		}
+	}
+	if *jsonOutput {
+		enc := json.NewEncoder(redacted(os.Stdout))
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("Error encoding result: %v", err)
+		}
+	} else {
+		writeVerifyReport(redacted(os.Stdout), report)
+	}
+	exitOnDeadline(ctx)
+	if len(report.Mismatches) > 0 || len(report.Errors) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/alcorg/project_setup/project_setup/providers/github"
+)
+
+// IssueOutput records the issue created for a definition, written by --write-back
+type IssueOutput struct {
+	Repository string `json:"repository"` // owner/repo the issue was created in
+	Number     int    `json:"number"`
+	URL        string `json:"url"`
+}
+
+// writeBackIssues annotates the issue definitions file with the issues created in t.
+// The file is re-read so issue forms and other expansions are not written back.
+func writeBackIssues(path string, t repoTarget, created map[int]github.Issue) error {
+	if len(created) == 0 {
+		return nil
+	}
+	issues, err := loadIssues(path)
+	if err != nil {
+		return err
+	}
+	for index, issue := range created {
+		if index >= len(issues) {
+			return fmt.Errorf("error writing back issue #%d: %s changed during the run", index+1, path)
+		}
+		issues[index].Output = &IssueOutput{Repository: t.String(), Number: issue.Number, URL: issue.HTMLURL}
+	}
+
+	if err := writeJSONFile(path, issues); err != nil {
+		return fmt.Errorf("error writing back issues to %s: %w", path, err)
+	}
+	log.Printf("Wrote %d created issue numbers back to %s.", len(created), path)
+	return nil
+}
+
+// writeBackMilestoneDueDates replaces the due dates of the given milestones in the milestones file
+func writeBackMilestoneDueDates(path string, dueDates map[string]string) error {
+	if len(dueDates) == 0 {
+		return nil
+	}
+	milestones, err := loadMilestones(path)
+	if err != nil {
+		return err
+	}
+	updated := 0
+	for i, milestone := range milestones {
+		if due, ok := dueDates[milestone.Title]; ok {
+			milestones[i].DueOn = &due
+			updated++
+		}
+	}
+	if err := writeJSONFile(path, milestones); err != nil {
+		return fmt.Errorf("error writing back milestones to %s: %w", path, err)
+	}
+	log.Printf("Wrote %d shifted due dates back to %s.", updated, path)
+	return nil
+}
+
+// writeJSONFile replaces a definitions file with v as indented JSON
+func writeJSONFile(path string, v interface{}) error {
+	if current, err := os.ReadFile(path); err == nil && isSOPSDocument(current) {
+		return fmt.Errorf("%s is encrypted with SOPS and is not rewritten in plaintext; update it with sops instead", path)
+	}
+	warnDroppedComments(path)
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false) // Keep '&' and '<' readable in titles
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("error marshalling JSON: %w", err)
+	}
+	// Write to a temporary file first so an interrupted run never truncates the definitions
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".write-back-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
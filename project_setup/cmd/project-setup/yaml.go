@@ -0,0 +1,611 @@
+package main
+
+// A small YAML reader covering the subset used by definition files and GitHub
+// issue forms: block mappings and sequences, flow collections, quoted and plain
+// scalars, literal (|) and folded (>) block scalars, and comments. Anchors,
+// tags and multi-document streams are not supported.
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// yamlPlain is an unquoted scalar; its type (bool, number, null, string) is only
+// decided once the target it is decoded into is known
+type yamlPlain string
+
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+// parseYAML parses a YAML document into maps, slices, strings and yamlPlain scalars
+func parseYAML(data []byte) (interface{}, error) {
+	// The final line break ends the last line, it does not start an empty one
+	text := strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	p := &yamlParser{lines: strings.Split(strings.TrimPrefix(text, "\ufeff"), "\n")}
+	p.skipBlank()
+	if p.pos < len(p.lines) && strings.TrimSpace(p.lines[p.pos]) == "---" {
+		p.pos++
+	}
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	node, err := p.parseBlock(indentOf(p.lines[p.pos]))
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) && strings.TrimSpace(p.lines[p.pos]) != "..." {
+		return nil, p.errorf("unexpected content %q", strings.TrimSpace(p.lines[p.pos]))
+	}
+	return node, nil
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("yaml: line %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// stripComment removes a trailing comment that is not inside quotes
+func stripComment(s string) string {
+	inSingle, inDouble := false, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '"' && !inSingle && (i == 0 || s[i-1] != '\\'):
+			inDouble = !inDouble
+		case c == '#' && !inSingle && !inDouble && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return strings.TrimRight(s[:i], " \t")
+		}
+	}
+	return strings.TrimRight(s, " \t")
+}
+
+// skipBlank moves past empty and comment-only lines
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) {
+		if trimmed := strings.TrimSpace(p.lines[p.pos]); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return
+		}
+		p.pos++
+	}
+}
+
+// parseBlock parses the mapping, sequence or scalar starting at the current line
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	content := stripComment(p.lines[p.pos][indent:])
+	if content == "-" || strings.HasPrefix(content, "- ") {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitMappingKey(content); ok {
+		return p.parseMapping(indent)
+	}
+	p.pos++
+	return parseFlowScalar(content)
+}
+
+// parseSequence parses "- item" lines at the given indentation
+func (p *yamlParser) parseSequence(indent int) ([]interface{}, error) {
+	items := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) || indentOf(p.lines[p.pos]) != indent {
+			return items, nil
+		}
+		line := p.lines[p.pos]
+		content := stripComment(line[indent:])
+		if content != "-" && !strings.HasPrefix(content, "- ") {
+			return items, nil
+		}
+
+		rest := strings.TrimLeft(content[1:], " ")
+		var item interface{}
+		var err error
+		switch {
+		case rest == "":
+			p.pos++
+			item, err = p.parseNested(indent)
+		case strings.HasPrefix(rest, "- ") || rest == "-" || isMappingStart(rest):
+			// "- key: value" or "- - x": continue as a block indented past the dash
+			childIndent := indent + (len(line[indent:]) - len(strings.TrimLeft(line[indent+1:], " ")))
+			p.lines[p.pos] = strings.Repeat(" ", childIndent) + line[childIndent:]
+			item, err = p.parseBlock(childIndent)
+		default:
+			item, err = p.parseValue(rest, indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+func isMappingStart(s string) bool {
+	_, _, ok := splitMappingKey(s)
+	return ok && !strings.HasPrefix(s, "[") && !strings.HasPrefix(s, "{")
+}
+
+// parseMapping parses "key: value" lines at the given indentation
+func (p *yamlParser) parseMapping(indent int) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) || indentOf(p.lines[p.pos]) != indent {
+			return m, nil
+		}
+		content := stripComment(p.lines[p.pos][indent:])
+		key, rest, ok := splitMappingKey(content)
+		if !ok {
+			if content == "-" || strings.HasPrefix(content, "- ") {
+				return m, nil // A sequence at the parent's indentation
+			}
+			return nil, p.errorf("expected 'key: value', got %q", content)
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+
+		var value interface{}
+		var err error
+		if rest == "" {
+			p.pos++
+			value, err = p.parseNested(indent)
+		} else {
+			value, err = p.parseValue(rest, indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+}
+
+// parseNested parses the block below a "key:" or "-" line, allowing sequences at
+// the same indentation as a mapping key as YAML does
+func (p *yamlParser) parseNested(parentIndent int) (interface{}, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	indent := indentOf(p.lines[p.pos])
+	content := strings.TrimSpace(p.lines[p.pos])
+	if indent > parentIndent || (indent == parentIndent && (content == "-" || strings.HasPrefix(content, "- "))) {
+		return p.parseBlock(indent)
+	}
+	return nil, nil
+}
+
+// parseValue parses the value after "key: " or "- ", which may start a block scalar
+func (p *yamlParser) parseValue(rest string, indent int) (interface{}, error) {
+	if strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">") {
+		header := rest
+		p.pos++
+		return p.parseBlockScalar(header, indent)
+	}
+	value, err := parseFlowScalar(rest)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	p.pos++
+	return value, nil
+}
+
+// parseBlockScalar reads a literal (|) or folded (>) scalar with optional chomping indicator
+func (p *yamlParser) parseBlockScalar(header string, parentIndent int) (string, error) {
+	folded := header[0] == '>'
+	chomp := byte(0)
+	if len(header) > 1 && (header[1] == '-' || header[1] == '+') {
+		chomp = header[1]
+	}
+
+	var lines []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		indent := indentOf(line)
+		if indent <= parentIndent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = indent
+		}
+		if indent < blockIndent {
+			break
+		}
+		lines = append(lines, line[blockIndent:])
+		p.pos++
+	}
+
+	// Trailing blank lines belong to the block only for chomping purposes
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var text string
+	if folded {
+		var b strings.Builder
+		for i, line := range lines {
+			switch {
+			case i == 0 || (lines[i-1] == "" && line != ""):
+				// The blank line before already ended the paragraph
+			case line == "" || strings.HasPrefix(line, " "):
+				b.WriteString("\n")
+			default:
+				b.WriteString(" ")
+			}
+			b.WriteString(line)
+		}
+		text = b.String()
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+
+	switch {
+	case len(lines) == 0:
+		return "", nil
+	case chomp == '-':
+		return text, nil
+	case chomp == '+':
+		return text + strings.Repeat("\n", trailing+1), nil
+	default:
+		return text + "\n", nil
+	}
+}
+
+// splitMappingKey splits "key: value" outside of quotes and flow collections
+func splitMappingKey(s string) (key, rest string, ok bool) {
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+		return "", "", false
+	}
+	if strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'") {
+		end := closingQuote(s)
+		if end < 0 || end+1 >= len(s) || s[end+1] != ':' || (end+2 < len(s) && s[end+2] != ' ') {
+			return "", "", false
+		}
+		unquoted, err := parseFlowScalar(s[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		return fmt.Sprint(unquoted), strings.TrimSpace(s[end+2:]), true
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == ':' && (i+1 == len(s) || s[i+1] == ' ') {
+			return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// closingQuote returns the index of the quote closing the string starting at s[0]
+func closingQuote(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case quote == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// parseFlowScalar parses an inline value: quoted or plain scalar, or a flow collection
+func parseFlowScalar(s string) (interface{}, error) {
+	s = strings.TrimSpace(s)
+	value, rest, err := parseFlow(s)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rest) != "" {
+		// Plain scalars may contain anything that was taken for flow syntax, e.g. "a, b"
+		if _, isPlain := value.(yamlPlain); !isPlain {
+			return nil, fmt.Errorf("unexpected %q after value", rest)
+		}
+		value = yamlPlain(s)
+	}
+	// "key: a: b" is not a value with a colon but a nested mapping YAML does not allow
+	// here; the value has to be quoted
+	if plain, isPlain := value.(yamlPlain); isPlain && (strings.Contains(string(plain), ": ") || strings.HasSuffix(string(plain), ":")) {
+		return nil, fmt.Errorf("mapping values are not allowed in this context")
+	}
+	return value, nil
+}
+
+// parseFlow parses one flow value from the start of s and returns the remainder
+func parseFlow(s string) (interface{}, string, error) {
+	s = strings.TrimLeft(s, " ")
+	if s == "" {
+		return yamlPlain(""), "", nil
+	}
+	switch s[0] {
+	case '"':
+		end := closingQuote(s)
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated string %s", s)
+		}
+		unquoted, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid string %s: %v", s[:end+1], err)
+		}
+		return unquoted, s[end+1:], nil
+	case '\'':
+		end := closingQuote(s)
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.ReplaceAll(s[1:end], "''", "'"), s[end+1:], nil
+	case '[':
+		var items []interface{}
+		rest := strings.TrimLeft(s[1:], " ")
+		for !strings.HasPrefix(rest, "]") {
+			item, r, err := parseFlowItem(rest, "],")
+			if err != nil {
+				return nil, "", err
+			}
+			items = append(items, item)
+			rest = strings.TrimLeft(r, " ")
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimLeft(rest[1:], " ")
+			} else if !strings.HasPrefix(rest, "]") {
+				return nil, "", fmt.Errorf("expected ',' or ']' in %s", s)
+			}
+		}
+		if items == nil {
+			items = []interface{}{}
+		}
+		return items, rest[1:], nil
+	case '{':
+		m := make(map[string]interface{})
+		rest := strings.TrimLeft(s[1:], " ")
+		for !strings.HasPrefix(rest, "}") {
+			key, r, err := parseFlowItem(rest, ":,}")
+			if err != nil {
+				return nil, "", err
+			}
+			r = strings.TrimLeft(r, " ")
+			var value interface{}
+			if strings.HasPrefix(r, ":") {
+				if value, r, err = parseFlowItem(strings.TrimLeft(r[1:], " "), ",}"); err != nil {
+					return nil, "", err
+				}
+			}
+			m[fmt.Sprint(key)] = value
+			rest = strings.TrimLeft(r, " ")
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimLeft(rest[1:], " ")
+			} else if !strings.HasPrefix(rest, "}") {
+				return nil, "", fmt.Errorf("expected ',' or '}' in %s", s)
+			}
+		}
+		return m, rest[1:], nil
+	}
+	return yamlPlain(strings.TrimSpace(s)), "", nil
+}
+
+// parseFlowItem parses a value inside a flow collection; plain scalars end at any of stops
+func parseFlowItem(s, stops string) (interface{}, string, error) {
+	if s == "" {
+		return nil, "", fmt.Errorf("unterminated flow collection")
+	}
+	if strings.ContainsRune("\"'[{", rune(s[0])) {
+		return parseFlow(s)
+	}
+	end := strings.IndexAny(s, stops)
+	if end < 0 {
+		return nil, "", fmt.Errorf("unterminated flow collection")
+	}
+	return yamlPlain(strings.TrimSpace(s[:end])), s[end:], nil
+}
+
+// resolvePlain gives a plain scalar its natural type, as YAML's core schema does
+func resolvePlain(s yamlPlain) interface{} {
+	switch string(s) {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if i, err := strconv.ParseInt(string(s), 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(string(s), 64); err == nil {
+		return f
+	}
+	return string(s)
+}
+
+// --- Decoding into Go values ---
+
+// decodeYAML parses data and stores the result in out, matching struct fields by their json tags
+func decodeYAML(data []byte, out interface{}) error {
+	node, err := parseYAML(data)
+	if err != nil {
+		return err
+	}
+	return decodeTree(node, out)
+}
+
+// decodeTree stores a parsed document tree (maps, slices, scalars) in out,
+// matching struct fields by their json tags so the same structs serve every format
+func decodeTree(node interface{}, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("decode target must be a non-nil pointer")
+	}
+	return decodeValue(node, v.Elem(), "")
+}
+
+func decodeValue(node interface{}, v reflect.Value, path string) error {
+	if plain, ok := node.(yamlPlain); ok && v.Kind() != reflect.String {
+		node = resolvePlain(plain)
+	}
+	if node == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := decodeValue(node, elem.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(naturalValue(node)))
+		return nil
+	case reflect.String:
+		switch n := node.(type) {
+		case string:
+			v.SetString(n)
+		case yamlPlain:
+			v.SetString(string(n))
+		case bool, int64, float64:
+			v.SetString(fmt.Sprint(n))
+		default:
+			return fmt.Errorf("%s: expected a string, got %T", pathOrRoot(path), node)
+		}
+		return nil
+	case reflect.Bool:
+		b, ok := node.(bool)
+		if !ok {
+			return fmt.Errorf("%s: expected true or false, got %v", pathOrRoot(path), node)
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := node.(int64)
+		if !ok {
+			return fmt.Errorf("%s: expected an integer, got %v", pathOrRoot(path), node)
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch n := node.(type) {
+		case int64:
+			v.SetFloat(float64(n))
+		case float64:
+			v.SetFloat(n)
+		default:
+			return fmt.Errorf("%s: expected a number, got %v", pathOrRoot(path), node)
+		}
+		return nil
+	case reflect.Slice:
+		items, ok := node.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected a list, got %T", pathOrRoot(path), node)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(item, slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.Map:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected a mapping, got %T", pathOrRoot(path), node)
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%s: unsupported map key type %s", pathOrRoot(path), v.Type().Key())
+		}
+		result := reflect.MakeMapWithSize(v.Type(), len(m))
+		for key, item := range m {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(item, elem, path+"."+key); err != nil {
+				return err
+			}
+			result.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		v.Set(result)
+		return nil
+	case reflect.Struct:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected a mapping, got %T", pathOrRoot(path), node)
+		}
+		fields := structFieldsByTag(v.Type())
+		for key, item := range m {
+			index, known := fields[key]
+			if !known {
+				continue // Unknown keys are ignored, like encoding/json does
+			}
+			if err := decodeValue(item, v.Field(index), path+"."+key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%s: unsupported target type %s", pathOrRoot(path), v.Type())
+}
+
+// structFieldsByTag maps the json name of every exported field to its index
+func structFieldsByTag(t reflect.Type) map[string]int {
+	fields := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = i
+	}
+	return fields
+}
+
+// naturalValue converts a tree into plain Go values for interface{} targets
+func naturalValue(node interface{}) interface{} {
+	switch n := node.(type) {
+	case yamlPlain:
+		return resolvePlain(n)
+	case []interface{}:
+		out := make([]interface{}, len(n))
+		for i, item := range n {
+			out[i] = naturalValue(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(n))
+		for key, item := range n {
+			out[key] = naturalValue(item)
+		}
+		return out
+	}
+	return node
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "document"
+	}
+	return strings.TrimPrefix(path, ".")
+}
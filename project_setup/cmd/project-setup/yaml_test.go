@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name, in string
+		want     interface{}
+	}{
+		{"empty document", "# nothing here\n", nil},
+		{"mapping", "name: bug\ncolor: d73a4a # red\n", map[string]interface{}{"name": yamlPlain("bug"), "color": yamlPlain("d73a4a")}},
+		{"document markers", "---\n- a\n...\n", []interface{}{yamlPlain("a")}},
+		{"sequence of mappings", "- title: One\n  labels: [bug]\n- title: Two\n", []interface{}{
+			map[string]interface{}{"title": yamlPlain("One"), "labels": []interface{}{yamlPlain("bug")}},
+			map[string]interface{}{"title": yamlPlain("Two")},
+		}},
+		{"sequence at the indentation of its key", "labels:\n- bug\n- docs\nmilestone: v1\n", map[string]interface{}{
+			"labels":    []interface{}{yamlPlain("bug"), yamlPlain("docs")},
+			"milestone": yamlPlain("v1"),
+		}},
+		{"quoted scalars", `a: "tab\there: # not a comment"` + "\nb: 'it''s'\n\"c d\": x\n", map[string]interface{}{
+			"a": "tab\there: # not a comment", "b": "it's", "c d": yamlPlain("x"),
+		}},
+		{"plain scalar with flow characters", "title: Fix a, b and [c]\n", map[string]interface{}{"title": yamlPlain("Fix a, b and [c]")}},
+		{"plain scalar with a URL", "url: https://github.com/acme/web\n", map[string]interface{}{"url": yamlPlain("https://github.com/acme/web")}},
+		{"flow sequence", "a: [1, 'two', \"three\", []]\n", map[string]interface{}{"a": []interface{}{yamlPlain("1"), "two", "three", []interface{}{}}}},
+		{"flow mapping", "a: {name: bug, tags: [x, y], empty: }\n", map[string]interface{}{"a": map[string]interface{}{
+			"name": yamlPlain("bug"), "tags": []interface{}{yamlPlain("x"), yamlPlain("y")}, "empty": yamlPlain(""),
+		}}},
+		{"literal block", "body: |\n  line one\n\n    indented\n  line three\nnext: x\n", map[string]interface{}{
+			"body": "line one\n\n  indented\nline three\n", "next": yamlPlain("x"),
+		}},
+		{"literal block strip", "body: |-\n  text\n\n", map[string]interface{}{"body": "text"}},
+		{"literal block keep", "body: |+\n  text\n\n", map[string]interface{}{"body": "text\n\n"}},
+		{"folded block", "body: >\n  one\n  two\n\n  three\n    code\n", map[string]interface{}{"body": "one two\nthree\n  code\n"}},
+		{"block scalar in a sequence", "- |\n  text\n- x\n", []interface{}{"text\n", yamlPlain("x")}},
+		{"empty block scalar", "body: |\nnext: x\n", map[string]interface{}{"body": "", "next": yamlPlain("x")}},
+		{"CRLF and BOM", "\ufeffa: 1\r\nb: 2\r\n", map[string]interface{}{"a": yamlPlain("1"), "b": yamlPlain("2")}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tc.in))
+			if err != nil {
+				t.Fatalf("parseYAML() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseYAML() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name, in, wantErr string
+	}{
+		{"second colon in a plain scalar", "- title: Key with colon: inside\n", "mapping values are not allowed"},
+		{"plain scalar ending in a colon", "title: Key:\n", "mapping values are not allowed"},
+		{"duplicate key", "a: 1\na: 2\n", `duplicate key "a"`},
+		{"unterminated string", "a: \"open\n", "unterminated string"},
+		{"unterminated flow sequence", "a: [1, 2\n", "unterminated flow collection"},
+		{"text after a quoted scalar", "a: \"x\" y\n", "unexpected"},
+		{"not a mapping entry", "a: 1\nb\n", "expected 'key: value'"},
+		{"content after the document", "a:\n    b: 1\n  c: 2\n", "unexpected content"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tc.in))
+			if err == nil {
+				t.Fatalf("parseYAML() = %#v, want an error", got)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("parseYAML() error = %q, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeYAML(t *testing.T) {
+	in := `
+- title: Set up CI
+  labels: [ci, "good first issue"]
+  milestone_title: v1.0
+  acceptance_criteria:
+    - Builds on every push
+    - 'Fails on: lint errors'
+- title: "Docs: getting started"
+  description: >-
+    Write the guide
+    for new users.
+`
+	var issues []IssueData
+	if err := decodeYAML([]byte(in), &issues); err != nil {
+		t.Fatalf("decodeYAML() failed: %v", err)
+	}
+	milestone := "v1.0"
+	want := []IssueData{
+		{Title: "Set up CI", Labels: []string{"ci", "good first issue"}, MilestoneTitle: &milestone, AcceptanceCriteria: []string{"Builds on every push", "Fails on: lint errors"}},
+		{Title: "Docs: getting started", Description: "Write the guide for new users."},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("decodeYAML() = %+v, want %+v", issues, want)
+	}
+
+	var labels []LabelData
+	if err := decodeYAML([]byte("- name: bug\n  color: [red]\n"), &labels); err == nil {
+		t.Error("decodeYAML() accepted a list as a label color")
+	}
+}
+
+func TestLoadIssueForm(t *testing.T) {
+	form := `name: Bug report
+description: File a bug report
+title: "[Bug]: "
+labels: ["bug", "triage"]
+body:
+  - type: markdown
+    attributes:
+      value: |
+        Thanks for taking the time to fill out this bug report!
+  - type: input
+    id: contact
+    attributes:
+      label: Contact details
+      placeholder: ex. email@example.com
+    validations:
+      required: false
+  - type: textarea
+    id: logs
+    attributes:
+      label: Relevant log output
+      render: shell
+  - type: dropdown
+    id: version
+    attributes:
+      label: Version
+      options:
+        - 1.0.2 (Default)
+        - 1.0.3 (Edge)
+      default: 0
+    validations:
+      required: true
+  - type: checkboxes
+    id: terms
+    attributes:
+      label: Code of Conduct
+      options:
+        - label: I agree to follow this project's Code of Conduct
+          required: true
+`
+	path := filepath.Join(t.TempDir(), "bug.yml")
+	if err := os.WriteFile(path, []byte(form), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadIssueForm(path)
+	if err != nil {
+		t.Fatalf("loadIssueForm() failed: %v", err)
+	}
+	if got.Title != "[Bug]: " || !reflect.DeepEqual(got.Labels, []string{"bug", "triage"}) || len(got.Body) != 5 {
+		t.Fatalf("loadIssueForm() = %+v", got)
+	}
+	version := got.Body[3]
+	if version.Attributes.Default == nil || *version.Attributes.Default != 0 || !version.Validations.Required {
+		t.Errorf("dropdown = %+v, want default 0 and required", version)
+	}
+	wantOptions := []formOption{{Label: "1.0.2 (Default)"}, {Label: "1.0.3 (Edge)"}}
+	if !reflect.DeepEqual(version.Attributes.Options, wantOptions) {
+		t.Errorf("dropdown options = %+v, want %+v", version.Attributes.Options, wantOptions)
+	}
+	terms := got.Body[4].Attributes.Options
+	if len(terms) != 1 || terms[0].Label != "I agree to follow this project's Code of Conduct" {
+		t.Errorf("checkbox options = %+v", terms)
+	}
+	if got.Body[2].Attributes.Render != "shell" {
+		t.Errorf("textarea render = %q, want shell", got.Body[2].Attributes.Render)
+	}
+}
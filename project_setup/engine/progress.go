@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"context"
+	"time"
+)
+
+// Kinds of progress events
+const (
+	EventPhaseStarted  = "phase.started"
+	EventPhaseFinished = "phase.finished"
+	EventEntityCreated = "entity.created"
+	EventRetry         = "retry"
+	EventWait          = "rate_limit.wait" // Writes are held back by the throttle or a rate limit
+)
+
+// Event is one step of a run, reported to the progress function of the context, so
+// programs embedding the tool can render their own progress instead of parsing the log
+type Event struct {
+	Kind    string        `json:"kind"`
+	Time    time.Time     `json:"time"`
+	Repo    string        `json:"repo,omitempty"`
+	Phase   string        `json:"phase,omitempty"`
+	Entity  string        `json:"entity,omitempty"` // "label", "milestone" or "issue"
+	Name    string        `json:"name,omitempty"`   // Name or title of the entity
+	Number  int           `json:"number,omitempty"` // Issue or milestone number
+	Attempt int           `json:"attempt,omitempty"`
+	Wait    time.Duration `json:"wait,omitempty"`
+	Reason  string        `json:"reason,omitempty"`
+	Err     string        `json:"error,omitempty"` // Set when a phase failed
+}
+
+type progressFuncKey struct{}
+
+type progressRepoKey struct{}
+
+// WithProgressFunc returns a context whose runs report their progress to fn. fn is called
+// from the goroutines doing the work, phases of one repository may run in parallel, so it
+// has to be safe for concurrent use and should return quickly.
+func WithProgressFunc(ctx context.Context, fn func(Event)) context.Context {
+	return context.WithValue(ctx, progressFuncKey{}, fn)
+}
+
+// WithProgressRepo attributes the events reported with ctx to a repository, "owner/repo"
+func WithProgressRepo(ctx context.Context, repo string) context.Context {
+	return context.WithValue(ctx, progressRepoKey{}, repo)
+}
+
+// Report passes an event to the progress function of ctx, if there is one
+func Report(ctx context.Context, event Event) {
+	fn, _ := ctx.Value(progressFuncKey{}).(func(Event))
+	if fn == nil {
+		return
+	}
+	event.Time = time.Now().UTC()
+	if event.Repo == "" {
+		event.Repo, _ = ctx.Value(progressRepoKey{}).(string)
+	}
+	fn(event)
+}
+
+// ReportCreated reports an entity created in a repository
+func ReportCreated(ctx context.Context, repo, entity, name string, number int) {
+	Report(ctx, Event{Kind: EventEntityCreated, Repo: repo, Entity: entity, Name: name, Number: number})
+}
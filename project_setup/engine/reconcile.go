@@ -0,0 +1,251 @@
+// Package engine reconciles the labels and milestones of a repository with their
+// definitions through a Provider, and reports the progress of runs to programs embedding it.
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Label is a label as defined and as it exists in a repository
+type Label struct {
+	Name        string
+	Description string
+	Color       string // Hex code without '#'
+}
+
+// Milestone is a milestone as defined and as it exists in a repository
+type Milestone struct {
+	Number      int // Set for existing milestones
+	Title       string
+	Description string
+	DueOn       *string // RFC 3339, nil without a due date
+}
+
+// Provider is one repository of a hosting provider
+type Provider interface {
+	Labels(ctx context.Context) ([]Label, error)
+	CreateLabel(ctx context.Context, label Label) error
+	UpdateLabel(ctx context.Context, label Label) error
+	// Milestones lists the open and closed milestones
+	Milestones(ctx context.Context) ([]Milestone, error)
+	CreateMilestone(ctx context.Context, milestone Milestone) (number int, err error)
+	UpdateMilestone(ctx context.Context, number int, milestone Milestone) error
+}
+
+// Counts are the results of reconciling one kind of entity
+type Counts struct {
+	Created int
+	Updated int
+	Failed  int
+}
+
+// Reconciler creates the defined labels and milestones a repository lacks and updates the
+// ones that differ where Resolve allows it. The hooks are optional.
+type Reconciler struct {
+	Provider Provider
+	Repo     string // "owner/repo", for messages and progress events
+	// Resolve decides whether an existing entity that differs from its definition is
+	// overwritten; without it, such entities are kept as they are
+	Resolve func(kind, name string, diffs []string) bool
+	// Failed is told about every entity that could not be created or updated (action
+	// "create" or "update"); without it, the failure is logged
+	Failed func(kind, name, action string, err error)
+	// Created is told about every created entity; number is 0 for labels
+	Created func(kind, name string, number int)
+	// Updated is told about every entity that was overwritten
+	Updated func(kind, name string, diffs []string)
+	// MilestoneKey maps milestone titles to what they are matched by; exact titles without it
+	MilestoneKey func(title string) string
+	// Delay after every write, to stay clear of secondary rate limits
+	Delay time.Duration
+}
+
+// Labels reconciles the labels of the repository with the defined ones
+func (r *Reconciler) Labels(ctx context.Context, labels []Label) (Counts, error) {
+	var counts Counts
+	existing, err := r.Provider.Labels(ctx)
+	if err != nil {
+		return counts, fmt.Errorf("error getting existing labels: %w", err)
+	}
+	existingByName := make(map[string]Label, len(existing))
+	for _, l := range existing {
+		existingByName[l.Name] = l
+	}
+	log.Printf("Found %d existing labels.", len(existingByName))
+
+	for _, label := range labels {
+		if remote, exists := existingByName[label.Name]; !exists {
+			if err := r.Provider.CreateLabel(ctx, label); err != nil {
+				r.failed("label", label.Name, "create", err)
+				counts.Failed++
+				// Continue processing other labels even if one fails
+				continue
+			}
+			ReportCreated(ctx, r.Repo, "label", label.Name, 0)
+			r.created("label", label.Name, 0)
+			counts.Created++
+			time.Sleep(r.Delay)
+		} else if diffs := LabelDifferences(label, remote); len(diffs) > 0 {
+			if !r.resolve("label", label.Name, diffs) {
+				continue
+			}
+			if err := r.Provider.UpdateLabel(ctx, label); err != nil {
+				r.failed("label", label.Name, "update", err)
+				counts.Failed++
+				continue
+			}
+			r.updated("label", label.Name, diffs)
+			counts.Updated++
+			time.Sleep(r.Delay)
+		} else {
+			log.Printf("Label \"%s\" already exists.", label.Name)
+		}
+	}
+	log.Printf("Finished processing labels. Created %d new labels, updated %d.", counts.Created, counts.Updated)
+	return counts, nil
+}
+
+// Milestones reconciles the milestones of the repository with the defined ones and returns
+// the numbers of all milestones by title; milestones matched by MilestoneKey are listed
+// under their defined title
+func (r *Reconciler) Milestones(ctx context.Context, milestones []Milestone) (map[string]int, Counts, error) {
+	var counts Counts
+	existing, err := r.Provider.Milestones(ctx)
+	if err != nil {
+		return nil, counts, fmt.Errorf("error getting existing milestones: %w", err)
+	}
+	existingByKey := make(map[string]Milestone, len(existing))
+	for _, m := range existing {
+		key := r.milestoneKey(m.Title)
+		if other, ok := existingByKey[key]; ok {
+			log.Printf("Warning: Milestones \"%s\" (#%d) and \"%s\" (#%d) in %s have the same title, using #%d.", other.Title, other.Number, m.Title, m.Number, r.Repo, other.Number)
+			continue
+		}
+		existingByKey[key] = m
+	}
+	log.Printf("Found %d existing milestones.", len(existingByKey))
+
+	titleToNumber := make(map[string]int)
+	for _, m := range existingByKey {
+		titleToNumber[m.Title] = m.Number
+	}
+
+	for _, milestone := range milestones {
+		remote, exists := existingByKey[r.milestoneKey(milestone.Title)]
+		if exists && remote.Title != milestone.Title {
+			// Known by the defined title from here on, e.g. for issues and mirror labels
+			delete(titleToNumber, remote.Title)
+			titleToNumber[milestone.Title] = remote.Number
+		}
+		if !exists {
+			number, err := r.Provider.CreateMilestone(ctx, milestone)
+			if err != nil {
+				r.failed("milestone", milestone.Title, "create", err)
+				counts.Failed++
+				continue // Skip trying to use this milestone later if creation failed
+			}
+			titleToNumber[milestone.Title] = number
+			ReportCreated(ctx, r.Repo, "milestone", milestone.Title, number)
+			r.created("milestone", milestone.Title, number)
+			counts.Created++
+			time.Sleep(r.Delay)
+		} else if diffs := MilestoneDifferences(milestone, remote); len(diffs) > 0 {
+			if !r.resolve("milestone", milestone.Title, diffs) {
+				continue
+			}
+			if err := r.Provider.UpdateMilestone(ctx, remote.Number, milestone); err != nil {
+				r.failed("milestone", milestone.Title, "update", err)
+				counts.Failed++
+				continue
+			}
+			r.updated("milestone", milestone.Title, diffs)
+			counts.Updated++
+			time.Sleep(r.Delay)
+		} else {
+			log.Printf("Milestone \"%s\" already exists.", milestone.Title)
+		}
+	}
+	log.Printf("Finished processing milestones. Created %d new milestones, updated %d.", counts.Created, counts.Updated)
+	log.Printf("Current Milestone Title -> ID Map: %v", titleToNumber)
+	return titleToNumber, counts, nil
+}
+
+func (r *Reconciler) resolve(kind, name string, diffs []string) bool {
+	return r.Resolve != nil && r.Resolve(kind, name, diffs)
+}
+
+func (r *Reconciler) failed(kind, name, action string, err error) {
+	if r.Failed == nil {
+		log.Printf("Failed to %s %s '%s': %v", action, kind, name, err)
+		return
+	}
+	r.Failed(kind, name, action, err)
+}
+
+func (r *Reconciler) created(kind, name string, number int) {
+	if r.Created != nil {
+		r.Created(kind, name, number)
+	}
+}
+
+func (r *Reconciler) updated(kind, name string, diffs []string) {
+	if r.Updated != nil {
+		r.Updated(kind, name, diffs)
+	}
+}
+
+func (r *Reconciler) milestoneKey(title string) string {
+	if r.MilestoneKey == nil {
+		return title
+	}
+	return r.MilestoneKey(title)
+}
+
+// LabelDifferences lists the fields where the existing label differs from its definition
+func LabelDifferences(local, remote Label) []string {
+	var diffs []string
+	if !strings.EqualFold(local.Color, remote.Color) {
+		diffs = append(diffs, fmt.Sprintf("color %s (remote) vs %s (local)", remote.Color, local.Color))
+	}
+	if local.Description != remote.Description {
+		diffs = append(diffs, fmt.Sprintf("description %q (remote) vs %q (local)", remote.Description, local.Description))
+	}
+	return diffs
+}
+
+// MilestoneDifferences lists the fields where the existing milestone differs from its definition
+func MilestoneDifferences(local, remote Milestone) []string {
+	var diffs []string
+	if local.Title != remote.Title {
+		diffs = append(diffs, fmt.Sprintf("title %q (remote) vs %q (local)", remote.Title, local.Title))
+	}
+	if DueDate(local.DueOn) != DueDate(remote.DueOn) {
+		diffs = append(diffs, fmt.Sprintf("due date %s (remote) vs %s (local)", orNone(DueDate(remote.DueOn)), orNone(DueDate(local.DueOn))))
+	}
+	if local.Description != remote.Description {
+		diffs = append(diffs, fmt.Sprintf("description %q (remote) vs %q (local)", remote.Description, local.Description))
+	}
+	return diffs
+}
+
+// DueDate returns the calendar day of a due date; GitHub normalizes the time of day
+func DueDate(dueOn *string) string {
+	if dueOn == nil {
+		return ""
+	}
+	if len(*dueOn) >= len("2006-01-02") {
+		return (*dueOn)[:len("2006-01-02")]
+	}
+	return *dueOn
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,3 @@
+module github.com/alcorg/project_setup/project_setup
+
+go 1.22
@@ -0,0 +1,242 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the REST API of github.com
+const DefaultBaseURL = "https://api.github.com"
+
+// DefaultMaxResponseBytes bounds a response body unless the client says otherwise; a full
+// page of 100 issues with long bodies stays well below it
+const DefaultMaxResponseBytes = 32 << 20
+
+// maxErrorBodyBytes bounds the part of an error response that is kept, enough for any
+// message GitHub sends; the rest is dropped
+const maxErrorBodyBytes = 64 << 10
+
+// Client sends requests to the REST and GraphQL API of github.com or a GitHub Enterprise
+// Server. Throttling, caching and retries are up to the transport of HTTP.
+type Client struct {
+	BaseURL string // REST API base, DefaultBaseURL or https://<host>/api/v3
+	Token   string
+	HTTP    *http.Client
+	// Largest response body read, DefaultMaxResponseBytes when 0
+	MaxResponseBytes int64
+	// Delay between the pages of a list, to be nice to the API
+	PageDelay time.Duration
+	// Applied to error bodies before they are kept, as error pages may echo the request
+	// including the Authorization header; nil keeps them as they are
+	Redact func(string) string
+}
+
+// BaseURLForHost returns the REST API base of github.com or a GitHub Enterprise Server host
+func BaseURLForHost(host string) string {
+	if host == "github.com" || host == "www.github.com" {
+		return DefaultBaseURL
+	}
+	return "https://" + host + "/api/v3"
+}
+
+// Send sends a request and reads the response. Error responses are returned with their
+// (redacted) body and a nil error; build an *APIError from them with NewAPIError.
+func (c *Client) Send(ctx context.Context, method, url string, payload interface{}) (*http.Response, []byte, error) {
+	resp, err := c.Open(ctx, method, url, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp, c.ReadError(resp), nil
+	}
+	bodyBytes, readErr := c.ReadBody(resp.Body)
+	var tooLarge *ResponseTooLargeError
+	if errors.As(readErr, &tooLarge) {
+		return nil, nil, fmt.Errorf("error reading response for %s %s: %w", method, url, readErr)
+	}
+	if readErr != nil {
+		log.Printf("Warning: could not read response body for %s %s: %v", method, url, readErr)
+	}
+	return resp, bodyBytes, nil
+}
+
+// Open sends a request and returns the response with its body still to be read and
+// closed, for responses decoded as they arrive
+func (c *Client) Open(ctx context.Context, method, url string, payload interface{}) (*http.Response, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		payloadBytes, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling payload for %s %s: %w", method, url, err)
+		}
+		reqBody = bytes.NewBuffer(payloadBytes)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request for %s %s: %w", method, url, err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28") // Recommended header
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request for %s %s: %w", method, url, err)
+	}
+	return resp, nil
+}
+
+// ReadError reads the body of an error response, at most maxErrorBodyBytes of it
+func (c *Client) ReadError(resp *http.Response) []byte {
+	bodyBytes, readErr := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	if readErr != nil {
+		log.Printf("Warning: could not read error response body: %v", readErr)
+	}
+	if c.Redact != nil {
+		bodyBytes = []byte(c.Redact(string(bodyBytes)))
+	}
+	if NewAPIError(resp, bodyBytes).RateLimited {
+		log.Printf("Rate limit exceeded. Consider increasing the delay between requests.")
+	}
+	return bodyBytes
+}
+
+// ReadBody reads a whole response body within the client's limit
+func (c *Client) ReadBody(r io.Reader) ([]byte, error) {
+	return io.ReadAll(c.LimitBody(r))
+}
+
+// LimitBody guards a response body with the client's limit
+func (c *Client) LimitBody(r io.Reader) io.Reader {
+	limit := c.MaxResponseBytes
+	if limit <= 0 {
+		limit = DefaultMaxResponseBytes
+	}
+	return &limitedBody{r: r, left: limit, limit: limit}
+}
+
+// ResponseTooLargeError reports a response body larger than the limit
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body exceeds %d bytes", e.Limit)
+}
+
+// limitedBody reads a response body and fails once more than left bytes would be read,
+// instead of silently cutting the body short like io.LimitReader
+type limitedBody struct {
+	r     io.Reader
+	left  int64
+	limit int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1] // One byte more tells a body of exactly the limit from a larger one
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.left {
+		n, l.left = int(l.left), 0
+		return n, &ResponseTooLargeError{Limit: l.limit}
+	}
+	l.left -= int64(n)
+	return n, err
+}
+
+// GetAllPages fetches every page of a list endpoint and decodes the items; url has to
+// carry a query already, the page is appended to it
+func GetAllPages[T any](ctx context.Context, c *Client, what, url string) ([]T, error) {
+	var items []T
+	page := 1
+
+	for {
+		pageURL := fmt.Sprintf("%s&page=%d", url, page)
+		log.Printf("Fetching existing %s (page %d)...", what, page)
+		resp, err := c.Open(ctx, "GET", pageURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching %s page %d: %w", what, page, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			bodyBytes := c.ReadError(resp)
+			resp.Body.Close()
+			return nil, fmt.Errorf("error fetching %s page %d: %w", what, page, NewAPIError(resp, bodyBytes))
+		}
+
+		// Items are decoded as they arrive, so a page is never held as raw JSON and decoded at once
+		fetched := 0
+		err = DecodeJSONArray(c.LimitBody(resp.Body), func(item T) {
+			items = append(items, item)
+			fetched++
+		})
+		resp.Body.Close()
+		var tooLarge *ResponseTooLargeError
+		if errors.As(err, &tooLarge) {
+			return nil, fmt.Errorf("error reading %s page %d: %w", what, page, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling %s page %d: %w", what, page, err)
+		}
+
+		if fetched == 0 {
+			break // No more items on subsequent pages
+		}
+
+		log.Printf("Fetched %d %s on page %d.", fetched, what, page)
+
+		// Check Link header for next page (basic check)
+		linkHeader := resp.Header.Get("Link")
+		if !strings.Contains(linkHeader, `rel="next"`) {
+			break // No next page indicated
+		}
+		page++
+		time.Sleep(c.PageDelay)
+	}
+
+	return items, nil
+}
+
+// DecodeJSONArray decodes a JSON array element by element, calling each for every element.
+// A null document is an empty array, as with json.Unmarshal.
+func DecodeJSONArray[T any](r io.Reader, each func(T)) error {
+	dec := json.NewDecoder(r)
+	start, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if start == nil {
+		return nil
+	}
+	if delim, ok := start.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected an array, found %v", start)
+	}
+	for dec.More() {
+		var item T
+		if err := dec.Decode(&item); err != nil {
+			return err
+		}
+		each(item)
+	}
+	if _, err := dec.Token(); err != nil { // Closing ']'
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("unexpected data after the array")
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Package github is the GitHub provider: a REST and GraphQL API client with typed errors,
+// and the repository operations the engine reconciles.
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Kinds of GitHub API failures; test with errors.Is, which also sees through wrapping.
+// An *APIError or *GraphQLError carries the details.
+var (
+	ErrRateLimited = errors.New("rate limited")
+	ErrNotFound    = errors.New("not found")
+	ErrValidation  = errors.New("validation failed")
+	ErrPermission  = errors.New("permission denied")
+)
+
+// FieldError is one entry of the errors array of a 422 response
+type FieldError struct {
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Code     string `json:"code"` // e.g. "missing_field", "invalid", "already_exists", "custom"
+	Message  string `json:"message,omitempty"`
+}
+
+// APIError is an unsuccessful response of the REST API
+type APIError struct {
+	StatusCode       int
+	Message          string       // GitHub's message, e.g. "Validation Failed"
+	Errors           []FieldError // Parsed field errors of a validation failure
+	DocumentationURL string
+	RateLimited      bool   // Primary or secondary rate limit, as opposed to missing permissions
+	Body             string // Raw, already redacted body
+	RequestID        string // X-GitHub-Request-Id of the failed request
+}
+
+// NewAPIError parses the error response of a request. The errors array may hold plain
+// strings on some endpoints, those become field errors with only a message.
+func NewAPIError(resp *http.Response, body []byte) *APIError {
+	e := &APIError{StatusCode: resp.StatusCode, Body: string(body), RequestID: resp.Header.Get("X-Github-Request-Id")}
+	var parsed struct {
+		Message          string            `json:"message"`
+		DocumentationURL string            `json:"documentation_url"`
+		Errors           []json.RawMessage `json:"errors"`
+	}
+	if json.Unmarshal(body, &parsed) == nil {
+		e.Message, e.DocumentationURL = parsed.Message, parsed.DocumentationURL
+		for _, raw := range parsed.Errors {
+			var field FieldError
+			if json.Unmarshal(raw, &field) != nil {
+				var message string
+				json.Unmarshal(raw, &message)
+				field = FieldError{Message: message}
+			}
+			e.Errors = append(e.Errors, field)
+		}
+	}
+	// GitHub signals rate limits with 429, or with 403 and an exhausted quota or a
+	// secondary rate limit message
+	e.RateLimited = resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusForbidden && (resp.Header.Get("X-Ratelimit-Remaining") == "0" ||
+			strings.Contains(strings.ToLower(e.Message), "rate limit")))
+	return e
+}
+
+// Error keeps the format of the messages from before the errors were typed
+func (e *APIError) Error() string {
+	return fmt.Sprintf("status %d, body: %s", e.StatusCode, e.Body)
+}
+
+// Is maps the response to one of the error kinds
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.RateLimited
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrValidation:
+		return e.StatusCode == http.StatusUnprocessableEntity
+	case ErrPermission:
+		return (e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden) && !e.RateLimited
+	}
+	return false
+}
+
+// HasFieldError reports whether a field error matches resource and code; empty matches any
+func (e *APIError) HasFieldError(resource, code string) bool {
+	for _, field := range e.Errors {
+		if (resource == "" || field.Resource == resource) && (code == "" || field.Code == code) {
+			return true
+		}
+	}
+	return false
+}
+
+// GraphQLError holds the errors of a GraphQL response
+type GraphQLError struct {
+	Errors []GraphQLErrorEntry
+}
+
+// GraphQLErrorEntry is one error of a GraphQL response
+type GraphQLErrorEntry struct {
+	Type    string   `json:"type"` // e.g. NOT_FOUND, FORBIDDEN, RATE_LIMITED
+	Message string   `json:"message"`
+	Path    []string `json:"path"`
+}
+
+func (e *GraphQLError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, entry := range e.Errors {
+		messages[i] = entry.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Is matches when any of the errors is of the target kind
+func (e *GraphQLError) Is(target error) bool {
+	for _, entry := range e.Errors {
+		switch entry.Type {
+		case "RATE_LIMITED":
+			if target == ErrRateLimited {
+				return true
+			}
+		case "NOT_FOUND":
+			if target == ErrNotFound {
+				return true
+			}
+		case "FORBIDDEN", "INSUFFICIENT_SCOPES":
+			if target == ErrPermission {
+				return true
+			}
+		case "UNPROCESSABLE", "":
+			// Schema and argument errors have no type
+			if target == ErrValidation {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ErrorKind names the kind of err for machine-readable output, "" when it is none of them
+func ErrorKind(err error) string {
+	for _, kind := range []struct {
+		err  error
+		name string
+	}{
+		{ErrRateLimited, "rate_limited"},
+		{ErrNotFound, "not_found"},
+		{ErrValidation, "validation"},
+		{ErrPermission, "permission"},
+	} {
+		if errors.Is(err, kind.err) {
+			return kind.name
+		}
+	}
+	return ""
+}
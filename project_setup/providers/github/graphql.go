@@ -0,0 +1,65 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GraphQLRequest is the payload of a GraphQL query
+type GraphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// GraphQLResponse is the envelope of a GraphQL response
+type GraphQLResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []GraphQLErrorEntry        `json:"errors"`
+}
+
+// GraphQLURL derives the GraphQL endpoint from the REST API base (github.com or GHES)
+func (c *Client) GraphQLURL() string {
+	if strings.HasSuffix(c.BaseURL, "/api/v3") {
+		return strings.TrimSuffix(c.BaseURL, "/v3") + "/graphql"
+	}
+	return c.BaseURL + "/graphql"
+}
+
+// Query runs a GraphQL query and returns its data. NOT_FOUND errors are not
+// returned, the affected fields are simply null in the data.
+func (c *Client) Query(ctx context.Context, query string, variables map[string]interface{}) (map[string]json.RawMessage, error) {
+	result, err := c.GraphQL(ctx, query, variables)
+	if err != nil {
+		return nil, err
+	}
+	var failed GraphQLError
+	for _, e := range result.Errors {
+		if e.Type != "NOT_FOUND" {
+			failed.Errors = append(failed.Errors, e)
+		}
+	}
+	if len(failed.Errors) > 0 {
+		return nil, fmt.Errorf("error running GraphQL query: %w", &failed)
+	}
+	return result.Data, nil
+}
+
+// GraphQL runs a query and returns data and errors as they are, for callers that
+// attribute errors to the aliases of a batch themselves
+func (c *Client) GraphQL(ctx context.Context, query string, variables map[string]interface{}) (GraphQLResponse, error) {
+	var result GraphQLResponse
+	resp, bodyBytes, err := c.Send(ctx, "POST", c.GraphQLURL(), GraphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		return result, fmt.Errorf("error sending GraphQL query: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("error running GraphQL query: %w", NewAPIError(resp, bodyBytes))
+	}
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return result, fmt.Errorf("error unmarshalling GraphQL response: %w", err)
+	}
+	return result, nil
+}
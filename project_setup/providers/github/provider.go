@@ -0,0 +1,171 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	neturl "net/url"
+
+	"github.com/alcorg/project_setup/project_setup/engine"
+)
+
+// Provider is a repository on GitHub, the engine.Provider of the GitHub provider
+type Provider struct {
+	Client *Client
+	Owner  string
+	Repo   string
+}
+
+var _ engine.Provider = (*Provider)(nil)
+
+// NewProvider returns the provider of the repository owner/repo
+func NewProvider(c *Client, owner, repo string) *Provider {
+	return &Provider{Client: c, Owner: owner, Repo: repo}
+}
+
+// url returns the API URL of a path below the repository
+func (p *Provider) url(format string, args ...interface{}) string {
+	return fmt.Sprintf("%s/repos/%s/%s", p.Client.BaseURL, p.Owner, p.Repo) + fmt.Sprintf(format, args...)
+}
+
+// ListLabels fetches all labels of the repository
+func (p *Provider) ListLabels(ctx context.Context) ([]Label, error) {
+	return GetAllPages[Label](ctx, p.Client, "labels", p.url("/labels?per_page=100"))
+}
+
+// Labels fetches all labels of the repository for the engine
+func (p *Provider) Labels(ctx context.Context) ([]engine.Label, error) {
+	labels, err := p.ListLabels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]engine.Label, len(labels))
+	for i, l := range labels {
+		result[i] = engine.Label{Name: l.Name, Description: l.Description, Color: l.Color}
+	}
+	return result, nil
+}
+
+// CreateLabel creates a single label; a label that already exists is not an error
+func (p *Provider) CreateLabel(ctx context.Context, label engine.Label) error {
+	payload := LabelRequest{
+		Name:        label.Name,
+		Description: label.Description,
+		Color:       label.Color,
+	}
+
+	log.Printf("Attempting to create label: \"%s\"", label.Name)
+	resp, bodyBytes, err := p.Client.Send(ctx, "POST", p.url("/labels"), payload)
+	if err != nil {
+		return fmt.Errorf("error sending create label request for '%s': %w", label.Name, err)
+	}
+
+	// GitHub returns 201 Created on success
+	if resp.StatusCode != http.StatusCreated {
+		apiErr := NewAPIError(resp, bodyBytes)
+		// Check if it already exists (Conflict - 422 Unprocessable Entity)
+		if errors.Is(apiErr, ErrValidation) && apiErr.HasFieldError("Label", "already_exists") {
+			log.Printf("Label \"%s\" already exists (API reported conflict).", label.Name)
+			return nil // Not an error in our case, just skip
+		}
+		return fmt.Errorf("error creating label '%s': %w", label.Name, apiErr)
+	}
+
+	log.Printf("Successfully created label: \"%s\"\n", label.Name)
+	return nil
+}
+
+// UpdateLabel overwrites the color and description of an existing label
+func (p *Provider) UpdateLabel(ctx context.Context, label engine.Label) error {
+	payload := LabelRequest{
+		Name:        label.Name,
+		Description: label.Description,
+		Color:       label.Color,
+	}
+
+	log.Printf("Attempting to update label: \"%s\"", label.Name)
+	resp, bodyBytes, err := p.Client.Send(ctx, "PATCH", p.url("/labels/%s", neturl.PathEscape(label.Name)), payload)
+	if err != nil {
+		return fmt.Errorf("error sending update label request for '%s': %w", label.Name, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error updating label '%s': %w", label.Name, NewAPIError(resp, bodyBytes))
+	}
+
+	log.Printf("Successfully updated label: \"%s\"\n", label.Name)
+	return nil
+}
+
+// ListMilestones fetches all open and closed milestones of the repository
+func (p *Provider) ListMilestones(ctx context.Context) ([]Milestone, error) {
+	// Fetch both open and closed to avoid creating duplicates if one was closed manually
+	return GetAllPages[Milestone](ctx, p.Client, "milestones", p.url("/milestones?state=all&per_page=100"))
+}
+
+// Milestones fetches all open and closed milestones of the repository for the engine
+func (p *Provider) Milestones(ctx context.Context) ([]engine.Milestone, error) {
+	milestones, err := p.ListMilestones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]engine.Milestone, len(milestones))
+	for i, m := range milestones {
+		result[i] = engine.Milestone{Number: m.ID, Title: m.Title, Description: m.Description, DueOn: m.DueOn}
+	}
+	return result, nil
+}
+
+// CreateMilestone creates a single open milestone and returns its number
+func (p *Provider) CreateMilestone(ctx context.Context, milestone engine.Milestone) (int, error) {
+	payload := MilestoneRequest{
+		Title:       milestone.Title,
+		Description: milestone.Description,
+		State:       "open", // Default to open
+		DueOn:       milestone.DueOn,
+	}
+
+	log.Printf("Attempting to create milestone: \"%s\"", milestone.Title)
+	resp, bodyBytes, err := p.Client.Send(ctx, "POST", p.url("/milestones"), payload)
+	if err != nil {
+		return 0, fmt.Errorf("error sending create milestone request for '%s': %w", milestone.Title, err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		return 0, fmt.Errorf("error creating milestone '%s': %w", milestone.Title, NewAPIError(resp, bodyBytes))
+	}
+
+	var created Milestone
+	if err := json.Unmarshal(bodyBytes, &created); err != nil {
+		return 0, fmt.Errorf("error unmarshalling created milestone response for '%s': %w", milestone.Title, err)
+	}
+
+	log.Printf("Successfully created milestone: \"%s\" (ID: %d)\n", created.Title, created.ID)
+	return created.ID, nil
+}
+
+// UpdateMilestone overwrites the title, description and due date of an existing milestone
+func (p *Provider) UpdateMilestone(ctx context.Context, number int, milestone engine.Milestone) error {
+	// A map is used so that a nil due date is sent as null and clears the remote value
+	payload := map[string]interface{}{
+		"title":       milestone.Title,
+		"description": milestone.Description,
+		"due_on":      milestone.DueOn,
+	}
+
+	log.Printf("Attempting to update milestone: \"%s\" (ID: %d)", milestone.Title, number)
+	resp, bodyBytes, err := p.Client.Send(ctx, "PATCH", p.url("/milestones/%d", number), payload)
+	if err != nil {
+		return fmt.Errorf("error sending update milestone request for '%s': %w", milestone.Title, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error updating milestone '%s': %w", milestone.Title, NewAPIError(resp, bodyBytes))
+	}
+
+	log.Printf("Successfully updated milestone: \"%s\"\n", milestone.Title)
+	return nil
+}
@@ -0,0 +1,98 @@
+package github
+
+// Payloads and responses of the REST API
+
+// LabelRequest is the payload for creating/updating a label
+type LabelRequest struct {
+	Name        string `json:"name,omitempty"`
+	NewName     string `json:"new_name,omitempty"` // Only used to rename an existing label
+	Description string `json:"description,omitempty"`
+	Color       string `json:"color"` // Color hex code without '#'
+}
+
+// Label represents a label returned by the API
+type Label struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Color       string `json:"color"`
+	URL         string `json:"url"`
+}
+
+// MilestoneRequest is the payload for creating/updating a milestone
+type MilestoneRequest struct {
+	Title       string  `json:"title"`
+	State       string  `json:"state,omitempty"` // e.g., "open"
+	Description string  `json:"description,omitempty"`
+	DueOn       *string `json:"due_on,omitempty"` // Format: "2012-10-09T23:39:01Z"
+}
+
+// Milestone represents a milestone returned by the API
+type Milestone struct {
+	ID           int     `json:"number"` // GitHub uses 'number' for milestone ID
+	NodeID       string  `json:"node_id"`
+	URL          string  `json:"url"`
+	Title        string  `json:"title"`
+	State        string  `json:"state"`
+	Description  string  `json:"description"`
+	DueOn        *string `json:"due_on"`
+	HTMLURL      string  `json:"html_url"`
+	OpenIssues   int     `json:"open_issues"`
+	ClosedIssues int     `json:"closed_issues"`
+}
+
+// IssueRequest is the payload for creating an issue
+type IssueRequest struct {
+	Title     string   `json:"title"`
+	Body      string   `json:"body"`
+	Labels    []string `json:"labels,omitempty"`    // Uses label names
+	Milestone *int     `json:"milestone,omitempty"` // API field name is 'milestone' (the number/ID)
+	Assignees []string `json:"assignees,omitempty"` // User logins
+}
+
+// Issue represents an issue returned by the API
+type Issue struct {
+	Number  int     `json:"number"`
+	NodeID  string  `json:"node_id"`
+	Title   string  `json:"title"`
+	HTMLURL string  `json:"html_url"`
+	State   string  `json:"state"`
+	Body    string  `json:"body"`
+	Labels  []Label `json:"labels"`
+	// Read by export
+	Assignees []struct {
+		Login string `json:"login"`
+	} `json:"assignees,omitempty"`
+	// nil without a milestone
+	Milestone *Milestone `json:"milestone,omitempty"`
+	// RFC 3339, the cursor of the issue cache
+	UpdatedAt string `json:"updated_at,omitempty"`
+	// Set when the issue is a pull request, the issues API lists both
+	PullRequest *struct{} `json:"pull_request,omitempty"`
+}
+
+// ReactionRequest is the payload for adding a reaction
+type ReactionRequest struct {
+	Content string `json:"content"` // One of ValidReactions
+}
+
+// Repository represents a repository returned by the API
+type Repository struct {
+	Name          string `json:"name"`
+	FullName      string `json:"full_name"`
+	Archived      bool   `json:"archived"`
+	HasIssues     bool   `json:"has_issues"`
+	Fork          bool   `json:"fork"`
+	DefaultBranch string `json:"default_branch"`
+	Parent        struct {
+		FullName string `json:"full_name"`
+	} `json:"parent"` // The repository a fork was made from
+	Owner struct {
+		Type string `json:"type"` // "Organization" or "User"
+	} `json:"owner"`
+}
+
+// ValidReactions are the reaction contents accepted by the Reactions API
+var ValidReactions = map[string]bool{
+	"+1": true, "-1": true, "laugh": true, "confused": true,
+	"heart": true, "hooray": true, "rocket": true, "eyes": true,
+}